@@ -20,8 +20,11 @@ import (
 	"context"
 	"errors"
 	"math/big"
+	"sync"
 
+	abeychain "github.com/AbeyFoundation/go-abey"
 	"github.com/AbeyFoundation/go-abey/abey/downloader"
+	"github.com/AbeyFoundation/go-abey/abey/filters"
 	"github.com/AbeyFoundation/go-abey/abey/gasprice"
 	"github.com/AbeyFoundation/go-abey/abeydb"
 	"github.com/AbeyFoundation/go-abey/accounts"
@@ -30,39 +33,80 @@ import (
 	"github.com/AbeyFoundation/go-abey/core"
 	"github.com/AbeyFoundation/go-abey/core/bloombits"
 	"github.com/AbeyFoundation/go-abey/core/rawdb"
+	snaildb "github.com/AbeyFoundation/go-abey/core/snailchain/rawdb"
 	"github.com/AbeyFoundation/go-abey/core/state"
 	"github.com/AbeyFoundation/go-abey/core/types"
 	"github.com/AbeyFoundation/go-abey/core/vm"
 	"github.com/AbeyFoundation/go-abey/event"
+	"github.com/AbeyFoundation/go-abey/internal/abeyapi"
+	"github.com/AbeyFoundation/go-abey/log"
 	"github.com/AbeyFoundation/go-abey/params"
+	"github.com/AbeyFoundation/go-abey/rlp"
 	"github.com/AbeyFoundation/go-abey/rpc"
+	lru "github.com/hashicorp/golang-lru"
 )
 
 // ABEYAPIBackend implements ethapi.Backend for full nodes
 type ABEYAPIBackend struct {
 	abey *Abeychain
 	gpo  *gasprice.Oracle
+
+	snap     *state.FlatSnapshot // Optional flat snapshot serving the state reads of its block
+	snapLock sync.RWMutex
+
+	fruitCache     *lru.Cache // Cache of the including snail numbers of fruits keyed by fast hash
+	fruitCacheOnce sync.Once
+
+	nonces abeyapi.NonceReserver // Nonces handed out to concurrent senders
 }
 
+// fruitStatusCacheSize is the number of fruit inclusions cached.
+const fruitStatusCacheSize = 1024
+
 // ChainConfig returns the active chain configuration.
 func (b *ABEYAPIBackend) ChainConfig() *params.ChainConfig {
 	return b.abey.chainConfig
 }
 
+// RPCGasCap returns the maximum gas of a call or a simulated bundle.
+func (b *ABEYAPIBackend) RPCGasCap() uint64 {
+	return b.abey.config.RPCGasCap
+}
+
 // CurrentBlock return the fast chain current Block
 func (b *ABEYAPIBackend) CurrentBlock() *types.Block {
 	return b.abey.blockchain.CurrentBlock()
 }
 
+// CurrentFinalizedBlock returns the latest fast block included as a fruit in a
+// snail block confirmed by params.SnailConfirmInterval later snail blocks, the
+// genesis block if no snail block is confirmed yet.
+func (b *ABEYAPIBackend) CurrentFinalizedBlock() *types.Block {
+	var (
+		head     = b.abey.snailblockchain.CurrentBlock().NumberU64()
+		confirms = params.SnailConfirmInterval.Uint64()
+	)
+	for number := int64(head) - int64(confirms); number > 0; number-- {
+		block := b.abey.snailblockchain.GetBlockByNumber(uint64(number))
+		if block == nil {
+			break
+		}
+		if fast := block.MaxFruitNumber(); fast != nil {
+			return b.abey.blockchain.GetBlockByNumber(fast.Uint64())
+		}
+	}
+	return b.abey.blockchain.Genesis()
+}
+
 // CurrentSnailBlock return the Snail chain current Block
 func (b *ABEYAPIBackend) CurrentSnailBlock() *types.SnailBlock {
 	return b.abey.snailblockchain.CurrentBlock()
 }
 
 // SetHead Set the newest position of Fast Chain, that will reset the fast blockchain comment
-func (b *ABEYAPIBackend) SetHead(number uint64) {
+func (b *ABEYAPIBackend) SetHead(number uint64) error {
 	b.abey.protocolManager.downloader.Cancel()
-	b.abey.blockchain.SetHead(number)
+	return b.abey.blockchain.SetHead(number)
 }
 
 // SetSnailHead Set the newest position of snail chain
@@ -86,6 +130,12 @@ func (b *ABEYAPIBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNu
 	return b.abey.blockchain.GetHeaderByNumber(uint64(blockNr)), nil
 }
 
+// HeaderByTimestamp returns the last header of the fast chain not after the
+// given time, see abeyapi.HeaderByTimestamp.
+func (b *ABEYAPIBackend) HeaderByTimestamp(ctx context.Context, unixTime int64) (*types.Header, error) {
+	return abeyapi.HeaderByTimestamp(ctx, b, unixTime)
+}
+
 // HeaderByHash returns header of fast chain by the hash
 func (b *ABEYAPIBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
 	return b.abey.blockchain.GetHeaderByHash(hash), nil
@@ -156,9 +206,59 @@ func (b *ABEYAPIBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc
 	if header == nil || err != nil {
 		return nil, nil, err
 	}
-	stateDb, err := b.abey.BlockChain().StateAt(header.Root)
+	stateDb, err := b.stateAt(header.Root)
 	return stateDb, header, err
 }
+
+// GetBalances returns the unlocked balances of the given accounts in the state
+// of the block with the given number, in the order of addrs. The state is only
+// resolved once for all of them.
+func (b *ABEYAPIBackend) GetBalances(ctx context.Context, addrs []common.Address, blockNr rpc.BlockNumber) ([]*big.Int, error) {
+	statedb, _, err := b.StateAndHeaderByNumber(ctx, blockNr)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	balances := make([]*big.Int, len(addrs))
+	for i, addr := range addrs {
+		balances[i] = statedb.GetUnlockedBalance(addr)
+	}
+	return balances, statedb.Error()
+}
+
+// GetCode returns the code of the account in the state of the block with the
+// given number, empty for accounts without code.
+func (b *ABEYAPIBackend) GetCode(ctx context.Context, addr common.Address, blockNr rpc.BlockNumber) ([]byte, error) {
+	statedb, _, err := b.StateAndHeaderByNumber(ctx, blockNr)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	code := statedb.GetCode(addr)
+	if code == nil {
+		code = []byte{}
+	}
+	return code, statedb.Error()
+}
+
+// GetStorageAt returns the value of the storage slot of the account in the state
+// of the block with the given number, the zero hash if the slot is unset.
+func (b *ABEYAPIBackend) GetStorageAt(ctx context.Context, addr common.Address, key common.Hash, blockNr rpc.BlockNumber) (common.Hash, error) {
+	statedb, _, err := b.StateAndHeaderByNumber(ctx, blockNr)
+	if statedb == nil || err != nil {
+		return common.Hash{}, err
+	}
+	return statedb.GetState(addr, key), statedb.Error()
+}
+
+// GetProof returns the merkle proofs of the account and of the given storage
+// slots of it against the state root of the block with the given number.
+func (b *ABEYAPIBackend) GetProof(ctx context.Context, addr common.Address, storageKeys []common.Hash, blockNr rpc.BlockNumber) (*abeyapi.AccountResult, error) {
+	statedb, _, err := b.StateAndHeaderByNumber(ctx, blockNr)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	return abeyapi.NewAccountResult(statedb, addr, storageKeys)
+}
+
 func (b *ABEYAPIBackend) StateAndHeaderByHash(ctx context.Context, hash common.Hash) (*state.StateDB, *types.Header, error) {
 	header, err := b.HeaderByHash(ctx, hash)
 	if err != nil {
@@ -167,10 +267,75 @@ func (b *ABEYAPIBackend) StateAndHeaderByHash(ctx context.Context, hash common.H
 	if header == nil {
 		return nil, nil, errors.New("header for hash not found")
 	}
-	stateDb, err := b.abey.BlockChain().StateAt(header.Root)
+	stateDb, err := b.stateAt(header.Root)
 	return stateDb, header, err
 }
 
+// SetStateSnapshot installs a flat snapshot of a block state, serving the account
+// reads of the states opened for that block without walking the account trie. A
+// nil snapshot removes it. States of other blocks are read from the trie.
+func (b *ABEYAPIBackend) SetStateSnapshot(snap *state.FlatSnapshot) {
+	b.snapLock.Lock()
+	defer b.snapLock.Unlock()
+
+	b.snap = snap
+}
+
+// updateStateSnapshot keeps a flat snapshot of the state of the fast chain head
+// installed until quit is closed, taking a new one on every head. Heads arriving
+// while a snapshot is taken are skipped in favour of the latest one. A state
+// holding more than limit accounts is not snapshotted, its reads falling back to
+// the trie.
+func (b *ABEYAPIBackend) updateStateSnapshot(limit int, quit chan bool) {
+	heads := make(chan types.FastChainHeadEvent, 16)
+	sub := b.abey.BlockChain().SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	b.takeStateSnapshot(b.abey.BlockChain().CurrentBlock().Root(), limit)
+	for {
+		select {
+		case head := <-heads:
+			for pending := true; pending; {
+				select {
+				case head = <-heads:
+				default:
+					pending = false
+				}
+			}
+			b.takeStateSnapshot(head.Block.Root(), limit)
+
+		case <-sub.Err():
+			return
+		case <-quit:
+			return
+		}
+	}
+}
+
+// takeStateSnapshot installs a flat snapshot of the state of root holding at most
+// limit accounts, removing the installed one if the snapshot can't be taken.
+func (b *ABEYAPIBackend) takeStateSnapshot(root common.Hash, limit int) {
+	snap, err := state.NewFlatSnapshot(b.abey.BlockChain().StateCache(), root, limit)
+	if err != nil {
+		log.Debug("Failed to take state snapshot", "root", root, "err", err)
+		snap = nil
+	}
+	b.SetStateSnapshot(snap)
+}
+
+// stateAt opens the state of root, reading the accounts from the installed
+// snapshot if it was taken of the same root.
+func (b *ABEYAPIBackend) stateAt(root common.Hash) (*state.StateDB, error) {
+	b.snapLock.RLock()
+	snap := b.snap
+	b.snapLock.RUnlock()
+
+	if snap != nil && snap.Root() == root {
+		return state.NewWithSnapshot(root, b.abey.BlockChain().StateCache(), snap)
+	}
+	return b.abey.BlockChain().StateAt(root)
+}
+
 // GetBlock returns the block by the block's hash
 func (b *ABEYAPIBackend) GetBlock(ctx context.Context, hash common.Hash) (*types.Block, error) {
 	return b.abey.blockchain.GetBlockByHash(hash), nil
@@ -186,6 +351,24 @@ func (b *ABEYAPIBackend) GetFruit(ctx context.Context, fastblockHash common.Hash
 	return b.abey.snailblockchain.GetFruit(fastblockHash), nil
 }
 
+// FruitStatus reports whether the fast block with the given hash is included as
+// a fruit in the snail chain, along with the number of the including snail block.
+// Inclusions are looked up in the fruit index and cached, as they are permanent.
+func (b *ABEYAPIBackend) FruitStatus(ctx context.Context, fastHash common.Hash) (bool, uint64, error) {
+	b.fruitCacheOnce.Do(func() {
+		b.fruitCache, _ = lru.New(fruitStatusCacheSize)
+	})
+	if cached, ok := b.fruitCache.Get(fastHash); ok {
+		return true, cached.(uint64), nil
+	}
+	blockHash, number, _ := snaildb.ReadFtLookupEntry(b.abey.chainDb, fastHash)
+	if blockHash == (common.Hash{}) {
+		return false, 0, nil
+	}
+	b.fruitCache.Add(fastHash, number)
+	return true, number, nil
+}
+
 // GetReceipts returns the Receipt details by txhash
 func (b *ABEYAPIBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
 	if number := rawdb.ReadHeaderNumber(b.abey.chainDb, hash); number != nil {
@@ -211,6 +394,20 @@ func (b *ABEYAPIBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*ty
 	return logs, nil
 }
 
+// GetAddressTransactions returns the transactions sent from or to addr between
+// the blocks from and to, at most filters.MaxHistoryRange blocks apart. See
+// filters.AddressTransactions for the transactions it cannot find.
+func (b *ABEYAPIBackend) GetAddressTransactions(ctx context.Context, addr common.Address, from, to rpc.BlockNumber) ([]*abeyapi.AddressTransaction, error) {
+	begin, end := from.Int64(), to.Int64()
+	if from == rpc.PendingBlockNumber {
+		begin = rpc.LatestBlockNumber.Int64()
+	}
+	if to == rpc.PendingBlockNumber {
+		end = rpc.LatestBlockNumber.Int64()
+	}
+	return filters.AddressTransactions(ctx, b, addr, begin, end)
+}
+
 // GetTd returns the total diffcult with block height by blockhash
 func (b *ABEYAPIBackend) GetTd(blockHash common.Hash) *big.Int {
 	return b.abey.snailblockchain.GetTdByHash(blockHash)
@@ -225,6 +422,96 @@ func (b *ABEYAPIBackend) GetEVM(ctx context.Context, msg core.Message, state *st
 	return vm.NewEVM(context, state, b.abey.chainConfig, vmCfg), vmError, nil
 }
 
+// SimulateBundle applies the transactions in order on top of the state of the
+// given block without committing them, returning the outcome of each one.
+// Bundles whose gas adds up to more than the RPC gas cap are rejected.
+func (b *ABEYAPIBackend) SimulateBundle(ctx context.Context, txs []*types.Transaction, blockNr rpc.BlockNumber, stopOnRevert bool) ([]core.BundleTxResult, error) {
+	statedb, header, err := b.StateAndHeaderByNumber(ctx, blockNr)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	return core.SimulateBundleWithGasCap(b.abey.chainConfig, b.abey.BlockChain(), statedb, header, txs, b.RPCGasCap(), vm.Config{}, stopOnRevert)
+}
+
+// SimulateTransaction applies the transaction on top of the state of the given
+// block without committing it, returning the balance changes it causes.
+func (b *ABEYAPIBackend) SimulateTransaction(ctx context.Context, tx *types.Transaction, blockNr rpc.BlockNumber) (map[common.Address]*big.Int, error) {
+	statedb, header, err := b.StateAndHeaderByNumber(ctx, blockNr)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	return core.SimulateTransaction(b.abey.chainConfig, b.abey.BlockChain(), statedb, header, tx, vm.Config{})
+}
+
+// CreateAccessListFixedPoint generates the access list of the transaction on top
+// of the state of the given block, re-running it until the list settles, and
+// returns the list along with the gas the transaction needs.
+func (b *ABEYAPIBackend) CreateAccessListFixedPoint(ctx context.Context, tx *types.Transaction, blockNr rpc.BlockNumber) (types.AccessList, uint64, error) {
+	statedb, header, err := b.StateAndHeaderByNumber(ctx, blockNr)
+	if statedb == nil || err != nil {
+		return nil, 0, err
+	}
+	return core.CreateAccessListFixedPoint(b.abey.chainConfig, b.abey.BlockChain(), statedb, header, tx, vm.Config{})
+}
+
+// ResolveName returns the address the name with the given hash resolves to in
+// the name registry, as answered by its addr(bytes32) method in the state of
+// the given block.
+func (b *ABEYAPIBackend) ResolveName(ctx context.Context, registry common.Address, node common.Hash, blockNr rpc.BlockNumber) (common.Address, error) {
+	statedb, header, err := b.StateAndHeaderByNumber(ctx, blockNr)
+	if statedb == nil || err != nil {
+		return common.Address{}, err
+	}
+	return core.ResolveName(b.abey.chainConfig, b.abey.BlockChain(), statedb, header, registry, node, vm.Config{})
+}
+
+// ClassifyTransaction classifies the transaction for display, verifying its payer
+// under the rules of the current block.
+func (b *ABEYAPIBackend) ClassifyTransaction(tx *types.Transaction) (*types.TxClassification, error) {
+	return types.ClassifyTransaction(types.MakeSigner(b.ChainConfig(), b.CurrentBlock().Number()), tx)
+}
+
+// GetIssuance returns the amount of coins minted by the chain rewards up to and
+// including the given block.
+func (b *ABEYAPIBackend) GetIssuance(ctx context.Context, blockNr rpc.BlockNumber) (*big.Int, error) {
+	header, err := b.HeaderByNumber(ctx, blockNr)
+	if header == nil || err != nil {
+		return nil, err
+	}
+	issuance := b.abey.blockchain.GetIssuance(header.Hash(), header.Number.Uint64())
+	if issuance == nil {
+		return nil, core.ErrUnknownIssuance
+	}
+	return issuance, nil
+}
+
+// GetRewardBreakdown returns the rewards and fees paid out by the fast block with
+// the given number. The rewards minted are the growth of the issuance over the
+// block, itemized by the payouts recorded for the snail block it rewards.
+func (b *ABEYAPIBackend) GetRewardBreakdown(ctx context.Context, blockNr rpc.BlockNumber) (*abeyapi.RewardBreakdown, error) {
+	block, err := b.BlockByNumber(ctx, blockNr)
+	if block == nil || err != nil {
+		return nil, err
+	}
+	receipts, err := b.GetReceipts(ctx, block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	var infos *types.ChainReward
+	if number := block.SnailNumber(); number.Sign() > 0 {
+		infos = b.abey.blockchain.GetRewardInfos(number.Uint64())
+	}
+	var minted *big.Int
+	if number := block.NumberU64(); number > 0 {
+		issuance := b.abey.blockchain.GetIssuance(block.Hash(), number)
+		parent := b.abey.blockchain.GetIssuance(block.ParentHash(), number-1)
+		if issuance != nil && parent != nil {
+			minted = issuance.Sub(issuance, parent)
+		}
+	}
+	return abeyapi.NewRewardBreakdown(block, receipts, infos, minted)
+}
+
 // SubscribeRemovedLogsEvent registers a subscription of RemovedLogsEvent in fast blockchain
 func (b *ABEYAPIBackend) SubscribeRemovedLogsEvent(ch chan<- types.RemovedLogsEvent) event.Subscription {
 	return b.abey.BlockChain().SubscribeRemovedLogsEvent(ch)
@@ -245,6 +532,63 @@ func (b *ABEYAPIBackend) SubscribeChainSideEvent(ch chan<- types.FastChainSideEv
 	return b.abey.BlockChain().SubscribeChainSideEvent(ch)
 }
 
+// SubscribeReorgEvent registers a subscription of reorganisations of the fast blockchain
+func (b *ABEYAPIBackend) SubscribeReorgEvent(ch chan<- types.ReorgEvent) event.Subscription {
+	return b.abey.BlockChain().SubscribeReorgEvent(ch)
+}
+
+// SubscribeNewSnailBlockEvent registers a subscription of chainEvent in snail blockchain
+func (b *ABEYAPIBackend) SubscribeNewSnailBlockEvent(ch chan<- types.SnailChainEvent) event.Subscription {
+	return b.abey.SnailBlockChain().SubscribeChainEvent(ch)
+}
+
+// SubscribeFilteredLogs registers a subscription of logs added to and removed
+// from fast blockchain, merged into a single stream. Logs removed by a reorg are
+// delivered before the logs of the new chain.
+func (b *ABEYAPIBackend) SubscribeFilteredLogs(ch chan<- types.FilteredLogEvent) event.Subscription {
+	// The feeds are read through unbuffered channels, so the events are
+	// forwarded in the order blockchain sends them.
+	var (
+		logsCh    = make(chan []*types.Log)
+		rmLogsCh  = make(chan types.RemovedLogsEvent)
+		logsSub   = b.abey.BlockChain().SubscribeLogsEvent(logsCh)
+		rmLogsSub = b.abey.BlockChain().SubscribeRemovedLogsEvent(rmLogsCh)
+	)
+	forward := func(logs []*types.Log, removed bool, quit <-chan struct{}) bool {
+		for _, log := range logs {
+			select {
+			case ch <- types.FilteredLogEvent{Log: log, Removed: removed}:
+			case <-quit:
+				return false
+			}
+		}
+		return true
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer logsSub.Unsubscribe()
+		defer rmLogsSub.Unsubscribe()
+
+		for {
+			select {
+			case logs := <-logsCh:
+				if !forward(logs, false, quit) {
+					return nil
+				}
+			case ev := <-rmLogsCh:
+				if !forward(ev.Logs, true, quit) {
+					return nil
+				}
+			case err := <-logsSub.Err():
+				return err
+			case err := <-rmLogsSub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	})
+}
+
 // SubscribeLogsEvent registers a subscription of log in fast blockchain
 func (b *ABEYAPIBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription {
 	return b.abey.BlockChain().SubscribeLogsEvent(ch)
@@ -320,9 +664,38 @@ func (b *ABEYAPIBackend) GetCurrentCommitteeNumber() *big.Int {
 	return b.abey.election.GetCurrentCommitteeNumber()
 }
 
+// GetBlockSignatures returns the committee signature status of the fast block
+// with the given number.
+func (b *ABEYAPIBackend) GetBlockSignatures(ctx context.Context, blockNr rpc.BlockNumber) (*abeyapi.BlockSignatures, error) {
+	block, err := b.BlockByNumber(ctx, blockNr)
+	if block == nil || err != nil {
+		return nil, err
+	}
+	return abeyapi.NewBlockSignatures(block, b.abey.engine.GetElection())
+}
+
+// GetCommitteeSchedule returns the epoch of the committee serving the head of the
+// chain, as elected by the consensus engine, and the block of the next rotation.
+func (b *ABEYAPIBackend) GetCommitteeSchedule(ctx context.Context) (*abeyapi.CommitteeSchedule, error) {
+	head := b.abey.blockchain.CurrentBlock().NumberU64()
+	return abeyapi.NewCommitteeSchedule(head, b.abey.engine.GetElection()), nil
+}
+
 // SendTx returns nil by success to add local txpool
 func (b *ABEYAPIBackend) SendTx(ctx context.Context, signedTx *types.Transaction) error {
-	return b.abey.txPool.AddLocal(signedTx)
+	return core.ClassifyTxError(b.abey.txPool.AddLocal(signedTx))
+}
+
+// ValidateTransaction checks whether SendTx would accept the transaction into
+// the pool, without submitting it.
+func (b *ABEYAPIBackend) ValidateTransaction(ctx context.Context, tx *types.Transaction) error {
+	return core.ClassifyTxError(b.abey.txPool.ValidateTransaction(tx))
+}
+
+// ReplaceTransaction replaces the pending transaction with the given hash by a
+// re-signed copy paying the new gas price, honouring the price bump of the pool.
+func (b *ABEYAPIBackend) ReplaceTransaction(ctx context.Context, oldHash common.Hash, newGasPrice *big.Int) (common.Hash, error) {
+	return abeyapi.ReplaceTransaction(ctx, b, oldHash, newGasPrice, b.abey.config.TxPool.PriceBump)
 }
 
 // GetPoolTransactions returns Transactions by pending state in txpool
@@ -343,11 +716,82 @@ func (b *ABEYAPIBackend) GetPoolTransaction(hash common.Hash) *types.Transaction
 	return b.abey.txPool.Get(hash)
 }
 
+// GetRawTransaction returns the RLP encoding of the mined or pooled transaction
+// with the given hash, nil if it is unknown.
+func (b *ABEYAPIBackend) GetRawTransaction(ctx context.Context, txHash common.Hash) ([]byte, error) {
+	tx, _, _, _ := rawdb.ReadTransaction(b.abey.chainDb, txHash)
+	if tx == nil && b.abey.txPool != nil {
+		tx = b.abey.txPool.Get(txHash)
+	}
+	if tx == nil {
+		return nil, nil
+	}
+	return rlp.EncodeToBytes(tx)
+}
+
+// GetTransactionWithConfirmations locates the transaction with the given hash on
+// the canonical chain and counts its confirmations. Transactions whose block was
+// reorganised out are reported as pending if they are back in the pool, and as
+// unknown with a nil result otherwise.
+func (b *ABEYAPIBackend) GetTransactionWithConfirmations(ctx context.Context, txHash common.Hash) (*abeyapi.TxConfirmations, error) {
+	tx, blockHash, number, index := rawdb.ReadTransaction(b.abey.chainDb, txHash)
+	if tx != nil && rawdb.ReadCanonicalHash(b.abey.chainDb, number) == blockHash {
+		return abeyapi.NewTxConfirmations(b, tx, blockHash, number, index), nil
+	}
+	if b.abey.txPool != nil {
+		if tx := b.abey.txPool.Get(txHash); tx != nil {
+			return &abeyapi.TxConfirmations{Tx: tx, Pending: true}, nil
+		}
+	}
+	return nil, nil
+}
+
+// EffectiveGasPrice returns the price per gas the mined transaction with the
+// given hash paid including its fee, nil if it is unknown, see
+// abeyapi.EffectiveGasPrice.
+func (b *ABEYAPIBackend) EffectiveGasPrice(ctx context.Context, txHash common.Hash) (*big.Int, error) {
+	tx, _, _, _ := rawdb.ReadTransaction(b.abey.chainDb, txHash)
+	if tx == nil {
+		return nil, nil
+	}
+	receipt, _, _, _ := rawdb.ReadReceipt(b.abey.chainDb, txHash)
+	if receipt == nil {
+		return nil, nil
+	}
+	return abeyapi.EffectiveGasPrice(tx, receipt), nil
+}
+
+// GetRawReceipt returns the receipt of the mined transaction with the given hash
+// in its RLP storage encoding, which retains the transaction hash, nil if it is
+// unknown.
+func (b *ABEYAPIBackend) GetRawReceipt(ctx context.Context, txHash common.Hash) ([]byte, error) {
+	receipt, _, _, _ := rawdb.ReadReceipt(b.abey.chainDb, txHash)
+	if receipt == nil {
+		return nil, nil
+	}
+	return rlp.EncodeToBytes((*types.ReceiptForStorage)(receipt))
+}
+
 // GetPoolNonce returns user nonce by user address in txpool
 func (b *ABEYAPIBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
 	return b.abey.txPool.State().GetNonce(addr), nil
 }
 
+// NextNonceAndReserve returns the next nonce of the account not handed out to
+// another sender yet and reserves it, see abeyapi.NonceReserver.
+func (b *ABEYAPIBackend) NextNonceAndReserve(ctx context.Context, addr common.Address) (uint64, error) {
+	return b.nonces.Reserve(addr, func() (uint64, error) {
+		return b.GetPoolNonce(ctx, addr)
+	})
+}
+
+// PendingNonceGap returns the nonces [gapStart, gapEnd) missing in txpool
+// before the queued transactions of the user can execute
+func (b *ABEYAPIBackend) PendingNonceGap(ctx context.Context, addr common.Address) (gapStart, gapEnd uint64, hasGap bool, err error) {
+	gapStart, gapEnd, hasGap = b.abey.txPool.NonceGap(addr)
+	return gapStart, gapEnd, hasGap, nil
+}
+
 // Stats returns the count tx in txpool
 func (b *ABEYAPIBackend) Stats() (pending int, queued int) {
 	return b.abey.txPool.Stats()
@@ -357,6 +801,55 @@ func (b *ABEYAPIBackend) TxPoolContent() (map[common.Address]types.Transactions,
 	return b.abey.TxPool().Content()
 }
 
+// TxPoolContentSorted returns the content of the transaction pool ordered by
+// account address and nonce, so that equal pool states yield equal output.
+func (b *ABEYAPIBackend) TxPoolContentSorted() (pending, queued []abeyapi.AccountTxs) {
+	return abeyapi.SortTxPoolContent(b.TxPoolContent())
+}
+
+// PendingTransactionsOf returns the pending transactions of the account sorted by
+// nonce, followed by its queued ones if queued is set.
+func (b *ABEYAPIBackend) PendingTransactionsOf(addr common.Address, queued bool) (types.Transactions, error) {
+	pending, future := b.abey.txPool.ContentFrom(addr)
+	if queued {
+		pending = append(pending, future...)
+	}
+	return pending, nil
+}
+
+// TxPoolFeeHistogram returns the number of pending transactions of the pool per
+// gas price range, see abeyapi.FeeHistogram.
+func (b *ABEYAPIBackend) TxPoolFeeHistogram() []abeyapi.FeeBucket {
+	return abeyapi.FeeHistogram(b.abey.txPool.PendingPriceHistogram)
+}
+
+// ExportTxPool serializes the pending and queued transactions of the pool, so
+// that they can be restored with ImportTxPool after a restart.
+func (b *ABEYAPIBackend) ExportTxPool() ([]byte, error) {
+	return abeyapi.ExportTxPool(b)
+}
+
+// ImportTxPool re-injects the transactions of a pool export, returning the
+// number of them skipped as no longer valid.
+func (b *ABEYAPIBackend) ImportTxPool(data []byte) (int, error) {
+	return abeyapi.ImportTxPool(context.Background(), b, data)
+}
+
+// EstimateInclusion heuristically estimates the number of blocks until a
+// transaction paying gasPrice gets included, see abeyapi.EstimateInclusion.
+func (b *ABEYAPIBackend) EstimateInclusion(ctx context.Context, gasPrice *big.Int) (uint64, error) {
+	return abeyapi.EstimateInclusion(ctx, b, gasPrice)
+}
+
+// TxPoolOrdered returns the pending transactions in the price and nonce sorted
+// order the miner would execute them, grouped into runs of consecutive
+// transactions sent by the same account.
+func (b *ABEYAPIBackend) TxPoolOrdered() [][]*types.Transaction {
+	pending, _ := b.abey.TxPool().Pending()
+	signer := types.NewTIP1Signer(b.abey.chainConfig.ChainID)
+	return types.NewTransactionsByPriceAndNonce(signer, pending).Runs()
+}
+
 // SubscribeNewTxsEvent returns the subscript event of new tx
 func (b *ABEYAPIBackend) SubscribeNewTxsEvent(ch chan<- types.NewTxsEvent) event.Subscription {
 	return b.abey.TxPool().SubscribeNewTxsEvent(ch)
@@ -367,6 +860,19 @@ func (b *ABEYAPIBackend) Downloader() *downloader.Downloader {
 	return b.abey.Downloader()
 }
 
+// SyncProgress returns the synchronisation progress of both chains, the fast
+// chain one reported by the fast downloader and the snail chain one by the
+// snail downloader.
+func (b *ABEYAPIBackend) SyncProgress() abeychain.SyncProgress {
+	progress := b.abey.Downloader().Progress()
+	fast := b.abey.FastDownloader().Progress()
+
+	progress.StartingFastBlock = fast.StartingFastBlock
+	progress.CurrentFastBlock = fast.CurrentFastBlock
+	progress.HighestFastBlock = fast.HighestFastBlock
+	return progress
+}
+
 // ProtocolVersion returns the version of protocol
 func (b *ABEYAPIBackend) ProtocolVersion() int {
 	return b.abey.EthVersion()
@@ -377,6 +883,11 @@ func (b *ABEYAPIBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	return b.gpo.SuggestPrice(ctx)
 }
 
+// HeaderFeeInfo returns the gas usage and the base fee proxy of a block.
+func (b *ABEYAPIBackend) HeaderFeeInfo(ctx context.Context, blockNr rpc.BlockNumber) (*gasprice.HeaderFeeInfo, error) {
+	return b.gpo.HeaderFeeInfo(ctx, blockNr)
+}
+
 // ChainDb returns tht database of fastchain
 func (b *ABEYAPIBackend) ChainDb() abeydb.Database {
 	return b.abey.ChainDb()
@@ -413,6 +924,16 @@ func (b *ABEYAPIBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocks, sections
 }
 
+// BloomIndexProgress returns the number of leading blocks covered by the bloom
+// index and the number of the chain head, along with whether the index caught
+// up with the head. The blocks of the last sections are only indexed once they
+// are confirmed, so a ready index still leaves recent blocks to scan directly.
+func (b *ABEYAPIBackend) BloomIndexProgress() (indexed, head uint64, ready bool) {
+	head = b.abey.blockchain.CurrentBlock().NumberU64()
+	indexed, ready = b.abey.bloomIndexer.Progress(head)
+	return indexed, head, ready
+}
+
 // ServiceFilter make the Filter for the truechian
 func (b *ABEYAPIBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
 	for i := 0; i < bloomFilterThreads; i++ {
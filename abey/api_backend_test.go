@@ -0,0 +1,1071 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package abey
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AbeyFoundation/go-abey/abey/downloader"
+	"github.com/AbeyFoundation/go-abey/abeydb"
+	"github.com/AbeyFoundation/go-abey/accounts"
+	"github.com/AbeyFoundation/go-abey/accounts/keystore"
+	"github.com/AbeyFoundation/go-abey/common"
+	"github.com/AbeyFoundation/go-abey/core"
+	"github.com/AbeyFoundation/go-abey/core/rawdb"
+	"github.com/AbeyFoundation/go-abey/core/snailchain"
+	"github.com/AbeyFoundation/go-abey/core/state"
+	"github.com/AbeyFoundation/go-abey/core/types"
+	"github.com/AbeyFoundation/go-abey/core/vm"
+	"github.com/AbeyFoundation/go-abey/internal/abeyapi"
+	"github.com/AbeyFoundation/go-abey/params"
+	"github.com/AbeyFoundation/go-abey/rlp"
+	"github.com/AbeyFoundation/go-abey/rpc"
+)
+
+// Tests that subscribers of the backend get notified of newly inserted snail
+// blocks.
+func TestSubscribeNewSnailBlockEvent(t *testing.T) {
+	// Generate enough fast blocks to fill the fruits of a snail block
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 2*60+1, 0, nil, nil, nil, nil)
+	defer pm.Stop()
+
+	backend := &ABEYAPIBackend{abey: &Abeychain{snailblockchain: pm.snailchain}}
+
+	events := make(chan types.SnailChainEvent, 1)
+	sub := backend.SubscribeNewSnailBlockEvent(events)
+	defer sub.Unsubscribe()
+
+	blocks := snailchain.GenerateChain(params.TestChainConfig, pm.blockchain, []*types.SnailBlock{pm.snailchain.Genesis()}, 1, 7, nil)
+	if len(blocks) != 1 {
+		t.Fatalf("snail block generation failed: have %d blocks, want 1", len(blocks))
+	}
+	if _, err := pm.snailchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert snail block: %v", err)
+	}
+	select {
+	case ev := <-events:
+		if ev.Block.Hash() != blocks[0].Hash() {
+			t.Errorf("snail block mismatch: have %x, want %x", ev.Block.Hash(), blocks[0].Hash())
+		}
+	case err := <-sub.Err():
+		t.Fatalf("subscription failed: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("snail block event not delivered")
+	}
+}
+
+// Tests that the fruit status of a fast block flips to included once a snail
+// block carrying its fruit is mined.
+func TestFruitStatus(t *testing.T) {
+	pm, db := newTestProtocolManagerMust(t, downloader.FullSync, 2*60+1, 0, nil, nil, nil, nil)
+	defer pm.Stop()
+
+	backend := &ABEYAPIBackend{abey: &Abeychain{chainDb: db, snailblockchain: pm.snailchain}}
+
+	blocks := snailchain.GenerateChain(params.TestChainConfig, pm.blockchain, []*types.SnailBlock{pm.snailchain.Genesis()}, 1, 7, nil)
+	if len(blocks) != 1 || len(blocks[0].Fruits()) == 0 {
+		t.Fatalf("snail block generation failed: have %d blocks", len(blocks))
+	}
+	fruit := blocks[0].Fruits()[0].FastHash()
+
+	if included, _, err := backend.FruitStatus(context.Background(), fruit); included || err != nil {
+		t.Fatalf("fruit status before mining mismatch: have included %v, err %v", included, err)
+	}
+	if _, err := pm.snailchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert snail block: %v", err)
+	}
+	included, number, err := backend.FruitStatus(context.Background(), fruit)
+	if err != nil {
+		t.Fatalf("failed to look up fruit status: %v", err)
+	}
+	if !included || number != blocks[0].NumberU64() {
+		t.Fatalf("fruit status mismatch: have included %v at %d, want included at %d", included, number, blocks[0].NumberU64())
+	}
+	// Fast blocks beyond the fruits of the snail block remain pending
+	head := pm.blockchain.CurrentBlock()
+	if fruits := blocks[0].Fruits(); head.Number().Cmp(fruits[len(fruits)-1].FastNumber()) > 0 {
+		if included, _, err := backend.FruitStatus(context.Background(), head.Hash()); included || err != nil {
+			t.Fatalf("fruit status of the fast head mismatch: have included %v, err %v", included, err)
+		}
+	}
+}
+
+// Tests that logs removed by a reorg are delivered ahead of the logs of the new
+// canonical chain through the filtered logs subscription.
+func TestSubscribeFilteredLogsReorg(t *testing.T) {
+	var (
+		db       = abeydb.NewMemDatabase()
+		contract = common.Address{0xac}
+		gspec    = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: types.GenesisAlloc{
+				testBank: {Balance: big.NewInt(1000000000000000000)},
+				// Contract emitting a single empty log on every call
+				contract: {Code: []byte{byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x00, byte(vm.LOG0), byte(vm.STOP)}, Balance: new(big.Int)},
+			},
+		}
+		genesis = gspec.MustFastCommit(db)
+		signer  = types.NewTIP1Signer(gspec.Config.ChainID)
+	)
+	blockchain, err := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	generate := func(n int, calls int) []*types.Block {
+		blocks, _ := core.GenerateChain(gspec.Config, genesis, engine, db, n, func(i int, gen *core.BlockGen) {
+			for j := 0; j < calls; j++ {
+				tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(testBank), contract, new(big.Int), 100000, nil, nil), signer, testBankKey)
+				gen.AddTx(tx)
+			}
+		})
+		return blocks
+	}
+	// Import a chain of two blocks with a log each, then reorg to a sibling
+	// chain with two logs in each of its blocks
+	oldChain, newChain := generate(2, 1), generate(2, 2)
+	if _, err := blockchain.InsertChain(oldChain); err != nil {
+		t.Fatalf("failed to insert old chain: %v", err)
+	}
+	backend := &ABEYAPIBackend{abey: &Abeychain{blockchain: blockchain}}
+
+	events := make(chan types.FilteredLogEvent, 16)
+	sub := backend.SubscribeFilteredLogs(events)
+	defer sub.Unsubscribe()
+
+	if _, err := blockchain.InsertChain(newChain); err != nil {
+		t.Fatalf("failed to insert new chain: %v", err)
+	}
+	inChain := func(blocks []*types.Block, hash common.Hash) bool {
+		for _, block := range blocks {
+			if block.Hash() == hash {
+				return true
+			}
+		}
+		return false
+	}
+	for i := 0; i < 6; i++ {
+		select {
+		case ev := <-events:
+			if removed := i < 2; ev.Removed != removed {
+				t.Fatalf("event %d: removal flag mismatch: have %v, want %v", i, ev.Removed, removed)
+			}
+			if ev.Removed && !inChain(oldChain, ev.Log.BlockHash) {
+				t.Errorf("event %d: removed log from block %x not in old chain", i, ev.Log.BlockHash)
+			}
+			if !ev.Removed && !inChain(newChain, ev.Log.BlockHash) {
+				t.Errorf("event %d: added log from block %x not in new chain", i, ev.Log.BlockHash)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d: log not delivered", i)
+		}
+	}
+}
+
+// Tests that the finalized block trails the snail chain head by the snail
+// confirmation interval and advances as new snail blocks are inserted.
+func TestCurrentFinalizedBlock(t *testing.T) {
+	confirms := int(params.SnailConfirmInterval.Int64())
+
+	// Generate enough fast blocks to fill the fruits of all snail blocks
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, (confirms+3)*60+1, confirms, nil, nil, nil, nil)
+	defer pm.Stop()
+
+	backend := &ABEYAPIBackend{abey: &Abeychain{blockchain: pm.blockchain, snailblockchain: pm.snailchain}}
+	if block := backend.CurrentFinalizedBlock(); block.NumberU64() != 0 {
+		t.Fatalf("finalized block before confirmation: have %d, want 0", block.NumberU64())
+	}
+	var last uint64
+	for i := 0; i < 2; i++ {
+		// The generator needs the whole snail chain to find the fruit pointers
+		var parents []*types.SnailBlock
+		for n := uint64(0); n <= pm.snailchain.CurrentBlock().NumberU64(); n++ {
+			parents = append(parents, pm.snailchain.GetBlockByNumber(n))
+		}
+		blocks := snailchain.GenerateChain(params.TestChainConfig, pm.blockchain, parents, 1, 7, nil)
+		if len(blocks) != 1 {
+			t.Fatalf("snail block generation failed: have %d blocks, want 1", len(blocks))
+		}
+		if _, err := pm.snailchain.InsertChain(blocks); err != nil {
+			t.Fatalf("failed to insert snail block: %v", err)
+		}
+		finalized := backend.CurrentFinalizedBlock()
+		confirmed := pm.snailchain.GetBlockByNumber(blocks[0].NumberU64() - uint64(confirms))
+		if want := confirmed.MaxFruitNumber().Uint64(); finalized.NumberU64() != want {
+			t.Fatalf("round %d: finalized block mismatch: have %d, want %d", i, finalized.NumberU64(), want)
+		}
+		if finalized.NumberU64() <= last {
+			t.Fatalf("round %d: finalized block not advancing: have %d, previous %d", i, finalized.NumberU64(), last)
+		}
+		if finalized.NumberU64() >= blocks[0].MinFruitNumber().Uint64() {
+			t.Fatalf("round %d: finalized block %d not trailing the snail head", i, finalized.NumberU64())
+		}
+		last = finalized.NumberU64()
+	}
+}
+
+// Tests that the committee signatures carried by a finalized block reach the
+// finality threshold.
+func TestGetBlockSignatures(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 4, 0, nil, nil, nil, nil)
+	defer pm.Stop()
+
+	backend := &ABEYAPIBackend{abey: &Abeychain{blockchain: pm.blockchain, engine: engine}}
+
+	status, err := backend.GetBlockSignatures(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("failed to retrieve block signatures: %v", err)
+	}
+	block := pm.blockchain.GetBlockByNumber(2)
+	if status.Hash != block.Hash() || uint64(status.Number) != 2 {
+		t.Fatalf("block mismatch: have %d [%x], want 2 [%x]", status.Number, status.Hash, block.Hash())
+	}
+	members := engine.GetElection().GetCommittee(block.Number())
+	if status.Committee != len(members) || status.Threshold != len(members)*2/3+1 {
+		t.Fatalf("committee mismatch: have %d members, threshold %d, want %d members", status.Committee, status.Threshold, len(members))
+	}
+	if len(status.Signers) < status.Threshold || !status.Final {
+		t.Fatalf("signers below threshold: have %d, threshold %d, final %v", len(status.Signers), status.Threshold, status.Final)
+	}
+	// Unknown blocks have no signature status
+	if status, err := backend.GetBlockSignatures(context.Background(), 100); status != nil || err != nil {
+		t.Fatalf("unknown block status mismatch: have %v, err %v", status, err)
+	}
+}
+
+// Tests that the committee schedule points to the first block of the next epoch.
+func TestGetCommitteeSchedule(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 4, 0, nil, nil, nil, nil)
+	defer pm.Stop()
+
+	backend := &ABEYAPIBackend{abey: &Abeychain{blockchain: pm.blockchain, engine: engine}}
+
+	schedule, err := backend.GetCommitteeSchedule(context.Background())
+	if err != nil {
+		t.Fatalf("failed to retrieve committee schedule: %v", err)
+	}
+	head := pm.blockchain.CurrentBlock()
+	if uint64(schedule.Head) != head.NumberU64() {
+		t.Fatalf("head mismatch: have %d, want %d", schedule.Head, head.NumberU64())
+	}
+	if uint64(schedule.NextRotation) <= head.NumberU64() {
+		t.Fatalf("next rotation %d not above head %d", schedule.NextRotation, head.NumberU64())
+	}
+	if schedule.BeginNumber > schedule.Head || schedule.Head > schedule.EndNumber || schedule.NextRotation != schedule.EndNumber+1 {
+		t.Fatalf("epoch bounds mismatch: head %d, epoch [%d, %d], next rotation %d", schedule.Head, schedule.BeginNumber, schedule.EndNumber, schedule.NextRotation)
+	}
+	if length := uint64(schedule.EndNumber - schedule.BeginNumber + 1); length != params.NewEpochLength {
+		t.Fatalf("epoch length mismatch: have %d, want %d", length, params.NewEpochLength)
+	}
+	if want := types.GetEpochFromHeight(head.NumberU64()).EpochID; uint64(schedule.Epoch) != want {
+		t.Fatalf("epoch mismatch: have %d, want %d", schedule.Epoch, want)
+	}
+	if want := len(engine.GetElection().GetCommittee(head.Number())); schedule.Committee != want {
+		t.Fatalf("committee size mismatch: have %d, want %d", schedule.Committee, want)
+	}
+}
+
+// Tests that the reward breakdown of a block adds up to the coins it minted plus
+// the fees of its transactions.
+func TestGetRewardBreakdown(t *testing.T) {
+	pm, db := newTestProtocolManagerMust(t, downloader.FullSync, 2, 0, nil, nil, nil, nil)
+	defer pm.Stop()
+
+	backend := &ABEYAPIBackend{abey: &Abeychain{blockchain: pm.blockchain, chainDb: db}}
+
+	// Finalize a block rewarding snail block 1, paying the fees of a transfer
+	parent := pm.blockchain.CurrentBlock()
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	tx, err := types.SignTx(types.NewTransaction(0, common.Address{0x01}, big.NewInt(1000), params.TxGas, big.NewInt(1000), nil), signer, testBankKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	receipt := types.NewReceipt(nil, false, params.TxGas)
+	receipt.TxHash, receipt.GasUsed = tx.Hash(), params.TxGas
+
+	header := &types.Header{
+		ParentHash:  parent.Hash(),
+		Number:      new(big.Int).Add(parent.Number(), common.Big1),
+		SnailHash:   common.Hash{0x01},
+		SnailNumber: big.NewInt(1),
+		Time:        new(big.Int).Add(parent.Time(), common.Big1),
+		Extra:       []byte{},
+	}
+	block := types.NewBlock(header, []*types.Transaction{tx}, []*types.Receipt{receipt}, nil, nil)
+	rawdb.WriteBlock(db, block)
+	rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+	rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), types.Receipts{receipt})
+
+	reward := func(addr byte, amount int64) *types.RewardInfo {
+		return &types.RewardInfo{Address: common.Address{addr}, Amount: big.NewInt(amount), Staking: new(big.Int)}
+	}
+	infos := types.NewChainReward(1, 0, reward(0x01, 500), []*types.RewardInfo{reward(0x02, 30), reward(0x03, 20)},
+		[]*types.SARewardInfos{{Items: []*types.RewardInfo{reward(0x04, 100), reward(0x05, 50)}}})
+	pm.blockchain.WriteRewardInfos(infos)
+
+	issuance := pm.blockchain.GetIssuance(parent.Hash(), parent.NumberU64())
+	if issuance == nil {
+		t.Fatalf("parent issuance not tracked")
+	}
+	minted := infos.Total()
+	rawdb.WriteIssuance(db, block.Hash(), block.NumberU64(), new(big.Int).Add(issuance, minted))
+
+	breakdown, err := backend.GetRewardBreakdown(context.Background(), rpc.BlockNumber(block.NumberU64()))
+	if err != nil {
+		t.Fatalf("failed to retrieve reward breakdown: %v", err)
+	}
+	if breakdown.Hash != block.Hash() || breakdown.SnailNumber != 1 {
+		t.Fatalf("block mismatch: have %x rewarding %d, want %x rewarding 1", breakdown.Hash, breakdown.SnailNumber, block.Hash())
+	}
+	if breakdown.Miner == nil || len(breakdown.FruitMiners) != 2 || len(breakdown.Committee) != 2 {
+		t.Fatalf("payouts mismatch: have %+v", breakdown)
+	}
+	sum := new(big.Int).Set(breakdown.Miner.Amount.ToInt())
+	for _, entry := range append(breakdown.FruitMiners, breakdown.Committee...) {
+		sum.Add(sum, entry.Amount.ToInt())
+	}
+	fees := new(big.Int).Mul(tx.GasPrice(), new(big.Int).SetUint64(params.TxGas))
+	if breakdown.Fees.ToInt().Cmp(fees) != 0 {
+		t.Fatalf("fees mismatch: have %v, want %v", breakdown.Fees, fees)
+	}
+	if sum.Cmp(minted) != 0 || breakdown.Rewards.ToInt().Cmp(minted) != 0 {
+		t.Fatalf("rewards mismatch: have payouts %v, rewards %v, want %v", sum, breakdown.Rewards, minted)
+	}
+	if want := new(big.Int).Add(minted, fees); breakdown.Total.ToInt().Cmp(want) != 0 || new(big.Int).Add(sum, breakdown.Fees.ToInt()).Cmp(want) != 0 {
+		t.Fatalf("total mismatch: have %v, want %v", breakdown.Total, want)
+	}
+	// Blocks rewarding no snail block mint nothing
+	breakdown, err = backend.GetRewardBreakdown(context.Background(), rpc.BlockNumber(parent.NumberU64()))
+	if err != nil {
+		t.Fatalf("failed to retrieve parent reward breakdown: %v", err)
+	}
+	if breakdown.Miner != nil || breakdown.Rewards == nil || breakdown.Rewards.ToInt().Sign() != 0 {
+		t.Fatalf("parent breakdown mismatch: have %+v", breakdown)
+	}
+}
+
+// Tests that raw transactions and receipts decode back to the requested hash.
+func TestGetRawTransactionAndReceipt(t *testing.T) {
+	db := abeydb.NewMemDatabase()
+
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	tx, err := types.SignTx(types.NewTransaction(0, common.Address{0x01}, big.NewInt(1000), params.TxGas, big.NewInt(1), nil), signer, testBankKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	receipt := types.NewReceipt(nil, false, params.TxGas)
+	receipt.TxHash, receipt.GasUsed = tx.Hash(), params.TxGas
+	receipt.Logs = []*types.Log{{Address: common.Address{0x01}, Data: []byte{0x01}}}
+
+	block := types.NewBlock(&types.Header{Number: big.NewInt(1), SnailNumber: new(big.Int)}, []*types.Transaction{tx}, []*types.Receipt{receipt}, nil, nil)
+	rawdb.WriteBody(db, block.Hash(), 1, block.Body())
+	rawdb.WriteReceipts(db, block.Hash(), 1, types.Receipts{receipt})
+	rawdb.WriteTxLookupEntries(db, block)
+
+	backend := &ABEYAPIBackend{abey: &Abeychain{chainDb: db}}
+
+	data, err := backend.GetRawTransaction(context.Background(), tx.Hash())
+	if err != nil {
+		t.Fatalf("failed to get raw transaction: %v", err)
+	}
+	decodedTx := new(types.Transaction)
+	if err := rlp.DecodeBytes(data, decodedTx); err != nil {
+		t.Fatalf("failed to decode raw transaction: %v", err)
+	}
+	if decodedTx.Hash() != tx.Hash() {
+		t.Errorf("transaction hash mismatch: have %x, want %x", decodedTx.Hash(), tx.Hash())
+	}
+	data, err = backend.GetRawReceipt(context.Background(), tx.Hash())
+	if err != nil {
+		t.Fatalf("failed to get raw receipt: %v", err)
+	}
+	decodedReceipt := new(types.ReceiptForStorage)
+	if err := rlp.DecodeBytes(data, decodedReceipt); err != nil {
+		t.Fatalf("failed to decode raw receipt: %v", err)
+	}
+	if decodedReceipt.TxHash != tx.Hash() || len(decodedReceipt.Logs) != 1 {
+		t.Errorf("receipt mismatch: have %+v, want transaction %x with 1 log", decodedReceipt, tx.Hash())
+	}
+	// Unknown transactions yield no data
+	if data, err := backend.GetRawTransaction(context.Background(), common.Hash{0xff}); data != nil || err != nil {
+		t.Errorf("unknown transaction: have %x, %v, want nil", data, err)
+	}
+	if data, err := backend.GetRawReceipt(context.Background(), common.Hash{0xff}); data != nil || err != nil {
+		t.Errorf("unknown receipt: have %x, %v, want nil", data, err)
+	}
+}
+
+// Tests that the effective gas price of a mined transaction carrying a fee
+// spreads the fee over the gas used on top of the gas price.
+func TestEffectiveGasPrice(t *testing.T) {
+	db := abeydb.NewMemDatabase()
+
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	var (
+		txs      []*types.Transaction
+		receipts types.Receipts
+	)
+	for i, fee := range []*big.Int{nil, big.NewInt(10 * int64(params.TxGas))} {
+		tx, err := types.SignTx(types.NewTransaction_Payment(uint64(i), common.Address{0x01}, big.NewInt(1000), fee, params.TxGas, big.NewInt(2), nil, common.Address{}), signer, testBankKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		receipt := types.NewReceipt(nil, false, params.TxGas*uint64(i+1))
+		receipt.TxHash, receipt.GasUsed, receipt.FeePaid = tx.Hash(), params.TxGas, fee
+		txs, receipts = append(txs, tx), append(receipts, receipt)
+	}
+	block := types.NewBlock(&types.Header{Number: big.NewInt(1), SnailNumber: new(big.Int)}, txs, receipts, nil, nil)
+	rawdb.WriteBody(db, block.Hash(), 1, block.Body())
+	rawdb.WriteReceipts(db, block.Hash(), 1, receipts)
+	rawdb.WriteTxLookupEntries(db, block)
+
+	backend := &ABEYAPIBackend{abey: &Abeychain{chainDb: db}}
+
+	if price, err := backend.EffectiveGasPrice(context.Background(), txs[0].Hash()); err != nil || price.Cmp(txs[0].GasPrice()) != 0 {
+		t.Errorf("feeless price mismatch: have %v, %v, want %v", price, err, txs[0].GasPrice())
+	}
+	price, err := backend.EffectiveGasPrice(context.Background(), txs[1].Hash())
+	if err != nil {
+		t.Fatalf("failed to get effective gas price: %v", err)
+	}
+	if price.Cmp(txs[1].GasPrice()) <= 0 || price.Cmp(big.NewInt(12)) != 0 {
+		t.Errorf("fee price mismatch: have %v, want %v above the gas price %v", price, 12, txs[1].GasPrice())
+	}
+	if price, err := backend.EffectiveGasPrice(context.Background(), common.Hash{0xff}); price != nil || err != nil {
+		t.Errorf("unknown transaction: have %v, %v, want nil", price, err)
+	}
+}
+
+// newTestPoolBackend creates a backend over a test chain of the given number of
+// blocks and a transaction pool without journal accepting any gas price. The
+// returned function stops both.
+func newTestPoolBackend(t *testing.T, blocks int, generator func(int, *core.BlockGen)) (*ABEYAPIBackend, func()) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, blocks, 0, generator, nil, nil, nil)
+
+	config := core.DefaultTxPoolConfig
+	config.Journal = ""
+	pool := core.NewTxPool(config, params.TestChainConfig, pm.blockchain)
+	pool.SetGasPrice(big.NewInt(1))
+
+	backend := &ABEYAPIBackend{abey: &Abeychain{
+		config:      &Config{TxPool: config},
+		chainConfig: params.TestChainConfig,
+		blockchain:  pm.blockchain,
+		txPool:      pool,
+	}}
+	return backend, func() {
+		pool.Stop()
+		pm.Stop()
+	}
+}
+
+// Tests that a pending transaction is replaced by a re-signed copy paying a
+// higher gas price, and that replacements below the price bump are refused.
+func TestReplaceTransaction(t *testing.T) {
+	backend, stop := newTestPoolBackend(t, 0, nil)
+	defer stop()
+	pool := backend.abey.txPool
+
+	// Import the bank key into an unlocked keystore to re-sign with
+	dir, err := ioutil.TempDir("", "replace-tx")
+	if err != nil {
+		t.Fatalf("failed to create keystore dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+	account, err := ks.ImportECDSA(testBankKey, "")
+	if err != nil {
+		t.Fatalf("failed to import key: %v", err)
+	}
+	if err := ks.Unlock(account, ""); err != nil {
+		t.Fatalf("failed to unlock account: %v", err)
+	}
+	backend.abey.accountManager = accounts.NewManager(ks)
+
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	tx, err := types.SignTx(types.NewTransaction(0, common.Address{0x01}, big.NewInt(1000), params.TxGas, big.NewInt(1000), nil), signer, testBankKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if err := backend.SendTx(context.Background(), tx); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	// Replacements must pay at least the price bump more
+	if _, err := backend.ReplaceTransaction(context.Background(), tx.Hash(), big.NewInt(1050)); !errors.Is(err, core.ErrReplaceUnderpriced) {
+		t.Fatalf("underpriced replacement: have %v, want %v", err, core.ErrReplaceUnderpriced)
+	}
+	hash, err := backend.ReplaceTransaction(context.Background(), tx.Hash(), big.NewInt(1100))
+	if err != nil {
+		t.Fatalf("failed to replace transaction: %v", err)
+	}
+	if pool.Get(tx.Hash()) != nil {
+		t.Errorf("replaced transaction still pooled")
+	}
+	replacement := pool.Get(hash)
+	if replacement == nil {
+		t.Fatalf("replacement transaction not pooled")
+	}
+	if replacement.Nonce() != tx.Nonce() || replacement.GasPrice().Cmp(big.NewInt(1100)) != 0 || *replacement.To() != *tx.To() || replacement.Value().Cmp(tx.Value()) != 0 {
+		t.Errorf("replacement mismatch: have nonce %d price %v, want nonce %d price 1100", replacement.Nonce(), replacement.GasPrice(), tx.Nonce())
+	}
+	if from, err := types.Sender(signer, replacement); err != nil || from != testBank {
+		t.Errorf("replacement sender mismatch: have %x (%v), want %x", from, err, testBank)
+	}
+	// The replaced transaction is no longer pending
+	if _, err := backend.ReplaceTransaction(context.Background(), tx.Hash(), big.NewInt(2000)); !errors.Is(err, abeyapi.ErrTxNotPending) {
+		t.Errorf("replacing evicted transaction: have %v, want %v", err, abeyapi.ErrTxNotPending)
+	}
+}
+
+// Tests that the sorted pool content orders accounts by address and their
+// transactions by nonce, yielding the same output across calls.
+func TestTxPoolContentSorted(t *testing.T) {
+	backend, stop := newTestPoolBackend(t, 0, nil)
+	defer stop()
+	pool := backend.abey.txPool
+
+	// Pool nonces 0-2 as pending and, past a gap, 4-5 as queued, out of order
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	var txs []*types.Transaction
+	for _, nonce := range []uint64{2, 5, 0, 4, 1} {
+		tx, err := types.SignTx(types.NewTransaction(nonce, common.Address{0x01}, big.NewInt(1), params.TxGas, big.NewInt(1000), nil), signer, testBankKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		txs = append(txs, tx)
+	}
+	for i, err := range pool.AddLocals(txs) {
+		if err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	flatten := func(content []abeyapi.AccountTxs) (nonces []uint64, hashes []common.Hash) {
+		for _, account := range content {
+			hashes = append(hashes, common.BytesToHash(account.Address[:]))
+			for _, tx := range account.Txs {
+				nonces = append(nonces, tx.Nonce())
+				hashes = append(hashes, tx.Hash())
+			}
+		}
+		return nonces, hashes
+	}
+	pending, queued := backend.TxPoolContentSorted()
+	if len(pending) != 1 || pending[0].Address != testBank || len(queued) != 1 || queued[0].Address != testBank {
+		t.Fatalf("pooled accounts mismatch: have %d pending, %d queued", len(pending), len(queued))
+	}
+	pendingNonces, pendingHashes := flatten(pending)
+	queuedNonces, queuedHashes := flatten(queued)
+	if !reflect.DeepEqual(pendingNonces, []uint64{0, 1, 2}) || !reflect.DeepEqual(queuedNonces, []uint64{4, 5}) {
+		t.Fatalf("nonce order mismatch: have pending %v queued %v, want [0 1 2] and [4 5]", pendingNonces, queuedNonces)
+	}
+	for i := 0; i < 3; i++ {
+		pending, queued := backend.TxPoolContentSorted()
+		if _, hashes := flatten(pending); !reflect.DeepEqual(hashes, pendingHashes) {
+			t.Fatalf("call %d: pending content mismatch: have %x, want %x", i, hashes, pendingHashes)
+		}
+		if _, hashes := flatten(queued); !reflect.DeepEqual(hashes, queuedHashes) {
+			t.Fatalf("call %d: queued content mismatch: have %x, want %x", i, hashes, queuedHashes)
+		}
+	}
+	// Accounts are ordered by address and equal nonces by transaction hash
+	var (
+		tie1 = types.NewTransaction(0, common.Address{}, big.NewInt(1), params.TxGas, big.NewInt(1), nil)
+		tie2 = types.NewTransaction(0, common.Address{}, big.NewInt(2), params.TxGas, big.NewInt(1), nil)
+		next = types.NewTransaction(1, common.Address{}, big.NewInt(1), params.TxGas, big.NewInt(1), nil)
+	)
+	low, high := tie1, tie2
+	if h1, h2 := tie1.Hash(), tie2.Hash(); bytes.Compare(h1[:], h2[:]) > 0 {
+		low, high = tie2, tie1
+	}
+	content := map[common.Address]types.Transactions{
+		{0x03}: {next},
+		{0x01}: {next, high, low},
+		{0x02}: {high},
+	}
+	sorted, _ := abeyapi.SortTxPoolContent(content, nil)
+	_, have := flatten(sorted)
+	want := []common.Hash{
+		common.BytesToHash(common.Address{0x01}.Bytes()), low.Hash(), high.Hash(), next.Hash(),
+		common.BytesToHash(common.Address{0x02}.Bytes()), high.Hash(),
+		common.BytesToHash(common.Address{0x03}.Bytes()), next.Hash(),
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("sorted content mismatch: have %x, want %x", have, want)
+	}
+}
+
+// Tests that an exported transaction pool is restored into a fresh pool, minus
+// the transactions invalidated in the meantime.
+func TestExportImportTxPool(t *testing.T) {
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	newTx := func(nonce uint64) *types.Transaction {
+		tx, err := types.SignTx(types.NewTransaction(nonce, common.Address{0x01}, big.NewInt(1), params.TxGas, big.NewInt(1000), nil), signer, testBankKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		return tx
+	}
+	// Pool nonces 0-2 as pending and 4 as queued, and export them
+	backend, stop := newTestPoolBackend(t, 0, nil)
+	defer stop()
+
+	for _, nonce := range []uint64{0, 1, 2, 4} {
+		if err := backend.SendTx(context.Background(), newTx(nonce)); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", nonce, err)
+		}
+	}
+	data, err := backend.ExportTxPool()
+	if err != nil {
+		t.Fatalf("failed to export pool: %v", err)
+	}
+	// Restore them on a chain which already included nonce 0
+	freshBackend, stopFresh := newTestPoolBackend(t, 1, func(i int, gen *core.BlockGen) {
+		gen.AddTx(newTx(0))
+	})
+	defer stopFresh()
+
+	skipped, err := freshBackend.ImportTxPool(data)
+	if err != nil {
+		t.Fatalf("failed to import pool: %v", err)
+	}
+	if skipped != 1 {
+		t.Fatalf("skipped transactions mismatch: have %d, want 1", skipped)
+	}
+	pending, queued := freshBackend.TxPoolContentSorted()
+	if len(pending) != 1 || len(queued) != 1 {
+		t.Fatalf("pooled accounts mismatch: have %d pending, %d queued", len(pending), len(queued))
+	}
+	if len(pending[0].Txs) != 2 || len(queued[0].Txs) != 1 || queued[0].Txs[0].Nonce() != 4 {
+		t.Fatalf("restored content mismatch: have %d pending, %d queued", len(pending[0].Txs), len(queued[0].Txs))
+	}
+	for i, tx := range pending[0].Txs {
+		if want := newTx(uint64(i + 1)); tx.Hash() != want.Hash() {
+			t.Errorf("pending transaction %d mismatch: have %x, want %x", i, tx.Hash(), want.Hash())
+		}
+	}
+	// Malformed exports must be rejected
+	if _, err := freshBackend.ImportTxPool([]byte{0x01, 0x02}); err == nil {
+		t.Fatalf("malformed export accepted")
+	}
+}
+
+// Tests that the transactions refused by the pool are reported with the reason
+// of the refusal, the errors of the pool remaining reachable.
+func TestSendTxRejected(t *testing.T) {
+	// Include nonce 0 in the chain for the pool to see it as used
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	sign := func(signer types.Signer, nonce uint64, to common.Address, value *big.Int, gas uint64, price *big.Int, data []byte) *types.Transaction {
+		tx, err := types.SignTx(types.NewTransaction(nonce, to, value, gas, price, data), signer, testBankKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		return tx
+	}
+	price := big.NewInt(1000)
+	backend, stop := newTestPoolBackend(t, 1, func(i int, gen *core.BlockGen) {
+		gen.AddTx(sign(signer, 0, common.Address{0x01}, big.NewInt(1), params.TxGas, price, nil))
+	})
+	defer stop()
+	backend.abey.txPool.SetGasPrice(big.NewInt(10))
+
+	pooled := sign(signer, 1, common.Address{0x01}, big.NewInt(1), params.TxGas, price, nil)
+	if err := backend.SendTx(context.Background(), pooled); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	gasLimit := backend.abey.blockchain.CurrentBlock().GasLimit()
+	tests := []struct {
+		tx     *types.Transaction
+		reason core.TxRejectReason
+		err    error
+	}{
+		{pooled, core.TxRejectKnown, core.ErrAlreadyKnown},
+		{sign(signer, 1, common.Address{0x02}, big.NewInt(1), params.TxGas, price, nil), core.TxRejectReplaceUnderpriced, core.ErrReplaceUnderpriced},
+		{sign(signer, 0, common.Address{0x01}, big.NewInt(1), params.TxGas, price, nil), core.TxRejectNonceTooLow, core.ErrNonceTooLow},
+		{sign(signer, 2, common.Address{0x01}, big.NewInt(1), params.TxGas, big.NewInt(1), nil), core.TxRejectUnderpriced, core.ErrUnderpriced},
+		{sign(signer, 2, common.Address{0x01}, big.NewInt(1000000000), params.TxGas, price, nil), core.TxRejectInsufficientFunds, core.ErrInsufficientFunds},
+		{sign(signer, 2, common.Address{0x01}, big.NewInt(1), params.TxGas-1, price, nil), core.TxRejectIntrinsicGas, core.ErrIntrinsicGas},
+		{sign(signer, 2, common.Address{0x01}, big.NewInt(1), gasLimit+1, price, nil), core.TxRejectGasLimit, core.ErrGasLimit},
+		{sign(signer, 2, common.Address{0x01}, big.NewInt(1), params.TxGas, price, make([]byte, 33*1024)), core.TxRejectOversized, core.ErrOversizedData},
+		{sign(types.NewTIP1Signer(big.NewInt(1234)), 2, common.Address{0x01}, big.NewInt(1), params.TxGas, price, nil), core.TxRejectInvalidSignature, core.ErrInvalidSender},
+	}
+	for i, tt := range tests {
+		err := backend.SendTx(context.Background(), tt.tx)
+		var rejected *core.TxRejectedError
+		if !errors.As(err, &rejected) {
+			t.Errorf("test %d: error mismatch: have %v, want rejection", i, err)
+			continue
+		}
+		if rejected.Reason != tt.reason {
+			t.Errorf("test %d: reason mismatch: have %q, want %q", i, rejected.Reason, tt.reason)
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("test %d: wrapped error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
+
+// Tests that a transaction outbidding the pending pool is expected in the next
+// block, while one queued behind more gas than a block holds has to wait longer.
+func TestEstimateInclusion(t *testing.T) {
+	backend, stop := newTestPoolBackend(t, 2, nil)
+	defer stop()
+	pool := backend.abey.txPool
+
+	// Pool more gas at a low price than the recent blocks can hold
+	gasLimit := backend.abey.blockchain.CurrentBlock().GasLimit()
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	var txs []*types.Transaction
+	for nonce := uint64(0); nonce < 4; nonce++ {
+		tx, err := types.SignTx(types.NewTransaction(nonce, common.Address{0x01}, big.NewInt(1), gasLimit*2/5, big.NewInt(10), nil), signer, testBankKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		txs = append(txs, tx)
+	}
+	for i, err := range pool.AddLocals(txs) {
+		if err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	if blocks, err := backend.EstimateInclusion(context.Background(), big.NewInt(100)); err != nil || blocks != 1 {
+		t.Errorf("high price inclusion mismatch: have %d blocks, %v, want 1", blocks, err)
+	}
+	if blocks, err := backend.EstimateInclusion(context.Background(), big.NewInt(10)); err != nil || blocks < 2 {
+		t.Errorf("low price inclusion mismatch: have %d blocks, %v, want at least 2", blocks, err)
+	}
+}
+
+// Tests that the confirmations of a mined transaction grow with the chain, and
+// that a transaction reorganised out of the chain is no longer reported as mined.
+func TestGetTransactionWithConfirmations(t *testing.T) {
+	pm, db := newTestProtocolManagerMust(t, downloader.FullSync, 0, 0, nil, nil, nil, nil)
+	defer pm.Stop()
+
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	tx, err := types.SignTx(types.NewTransaction(0, common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), signer, testBankKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	chain, _ := core.GenerateChain(params.TestChainConfig, pm.blockchain.Genesis(), engine, db, 3, func(i int, gen *core.BlockGen) {
+		if i == 0 {
+			gen.AddTx(tx)
+		}
+	})
+	fork, _ := core.GenerateChain(params.TestChainConfig, pm.blockchain.Genesis(), engine, db, 4, nil)
+
+	backend := &ABEYAPIBackend{abey: &Abeychain{chainDb: db, blockchain: pm.blockchain, snailblockchain: pm.snailchain}}
+	check := func(want uint64) {
+		t.Helper()
+		confs, err := backend.GetTransactionWithConfirmations(context.Background(), tx.Hash())
+		if err != nil {
+			t.Fatalf("failed to retrieve transaction: %v", err)
+		}
+		if confs == nil || confs.Pending {
+			t.Fatalf("mined transaction not found: %+v", confs)
+		}
+		if confs.Tx.Hash() != tx.Hash() || confs.BlockHash != chain[0].Hash() || confs.BlockNumber != 1 || confs.Index != 0 {
+			t.Errorf("position mismatch: have block %x #%d index %d, want %x #1 index 0", confs.BlockHash, confs.BlockNumber, confs.Index, chain[0].Hash())
+		}
+		if confs.Confirmations != want {
+			t.Errorf("confirmations mismatch: have %d, want %d", confs.Confirmations, want)
+		}
+		// No snail block confirms the fast chain yet
+		if confs.Finalized {
+			t.Errorf("transaction reported final")
+		}
+	}
+	if _, err := pm.blockchain.InsertChain(chain[:1]); err != nil {
+		t.Fatalf("failed to insert block: %v", err)
+	}
+	check(0)
+	if _, err := pm.blockchain.InsertChain(chain[1:]); err != nil {
+		t.Fatalf("failed to insert blocks: %v", err)
+	}
+	check(2)
+
+	// Reorg the transaction out of the chain
+	if _, err := pm.blockchain.InsertChain(fork); err != nil {
+		t.Fatalf("failed to insert fork: %v", err)
+	}
+	if head := pm.blockchain.CurrentBlock().Hash(); head != fork[len(fork)-1].Hash() {
+		t.Fatalf("fork not canonical: head %x, want %x", head, fork[len(fork)-1].Hash())
+	}
+	if confs, err := backend.GetTransactionWithConfirmations(context.Background(), tx.Hash()); confs != nil || err != nil {
+		t.Errorf("reorged transaction still reported: have %+v, %v", confs, err)
+	}
+}
+
+// Tests that states served from an installed snapshot match the trie backed
+// ones, and that blocks the snapshot was not taken of fall back to the trie.
+func TestStateAndHeaderSnapshot(t *testing.T) {
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 4, 0, func(i int, gen *core.BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(testBank), common.Address{byte(i + 1)}, big.NewInt(1000), params.TxGas, nil, nil), signer, testBankKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		gen.AddTx(tx)
+	}, nil, nil, nil)
+	defer pm.Stop()
+
+	backend := &ABEYAPIBackend{abey: &Abeychain{blockchain: pm.blockchain}}
+	head := pm.blockchain.CurrentBlock()
+	snap, err := state.NewFlatSnapshot(pm.blockchain.StateCache(), head.Root(), 0)
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+	backend.SetStateSnapshot(snap)
+
+	addrs := []common.Address{testBank, {0x01}, {0x02}, {0x03}, {0x04}, {0xff}}
+	for _, number := range []rpc.BlockNumber{rpc.LatestBlockNumber, 2} {
+		statedb, header, err := backend.StateAndHeaderByNumber(context.Background(), number)
+		if err != nil {
+			t.Fatalf("block %d: failed to retrieve state: %v", number, err)
+		}
+		want, _ := pm.blockchain.StateAt(header.Root)
+		for _, addr := range addrs {
+			if have, want := statedb.GetBalance(addr), want.GetBalance(addr); have.Cmp(want) != 0 {
+				t.Errorf("block %d: balance of %x mismatch: have %v, want %v", number, addr, have, want)
+			}
+			if have, want := statedb.GetNonce(addr), want.GetNonce(addr); have != want {
+				t.Errorf("block %d: nonce of %x mismatch: have %d, want %d", number, addr, have, want)
+			}
+		}
+	}
+	// Reads of the head through its hash are served from the snapshot as well
+	statedb, _, err := backend.StateAndHeaderByHash(context.Background(), head.Hash())
+	if err != nil {
+		t.Fatalf("failed to retrieve state by hash: %v", err)
+	}
+	if have := statedb.GetBalance(common.Address{0x04}); have.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("balance by hash mismatch: have %v, want 1000", have)
+	}
+}
+
+// Tests that the state snapshot follows the head of the chain, and that states
+// beyond the account limit are not snapshotted.
+func TestStateSnapshotFollowsHead(t *testing.T) {
+	pm, db := newTestProtocolManagerMust(t, downloader.FullSync, 0, 0, nil, nil, nil, nil)
+	defer pm.Stop()
+
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	chain, _ := core.GenerateChain(params.TestChainConfig, pm.blockchain.Genesis(), engine, db, 2, func(i int, gen *core.BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(testBank), common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), signer, testBankKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		gen.AddTx(tx)
+	})
+	backend := &ABEYAPIBackend{abey: &Abeychain{blockchain: pm.blockchain}}
+
+	quit, done := make(chan bool), make(chan struct{})
+	go func() {
+		backend.updateStateSnapshot(1000, quit)
+		close(done)
+	}()
+	defer func() {
+		close(quit)
+		<-done
+	}()
+	// wait blocks until the installed snapshot is the one of root
+	wait := func(root common.Hash) {
+		t.Helper()
+		for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+			backend.snapLock.RLock()
+			snap := backend.snap
+			backend.snapLock.RUnlock()
+
+			if snap != nil && snap.Root() == root {
+				return
+			}
+		}
+		t.Fatalf("snapshot of %x not taken", root)
+	}
+	wait(pm.blockchain.Genesis().Root())
+	for _, block := range chain {
+		if _, err := pm.blockchain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("failed to insert block %d: %v", block.NumberU64(), err)
+		}
+		wait(block.Root())
+	}
+	// A state with more accounts than the limit removes the snapshot
+	backend.takeStateSnapshot(chain[1].Root(), 1)
+	backend.snapLock.RLock()
+	defer backend.snapLock.RUnlock()
+	if backend.snap != nil {
+		t.Fatalf("oversized state snapshotted")
+	}
+}
+
+// Tests that bulk balance reads match the balances read one by one, in the
+// order of the requested addresses.
+func TestGetBalances(t *testing.T) {
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 4, 0, func(i int, gen *core.BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(testBank), common.Address{byte(i + 1)}, big.NewInt(int64(1000*(i+1))), params.TxGas, nil, nil), signer, testBankKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		gen.AddTx(tx)
+	}, nil, nil, nil)
+	defer pm.Stop()
+
+	backend := &ABEYAPIBackend{abey: &Abeychain{blockchain: pm.blockchain}}
+
+	addrs := []common.Address{{0x04}, testBank, {0xff}, {0x01}, {0x03}, {0x02}, {0x01}}
+	for _, number := range []rpc.BlockNumber{rpc.LatestBlockNumber, 2} {
+		balances, err := backend.GetBalances(context.Background(), addrs, number)
+		if err != nil {
+			t.Fatalf("block %d: failed to retrieve balances: %v", number, err)
+		}
+		if len(balances) != len(addrs) {
+			t.Fatalf("block %d: balance count mismatch: have %d, want %d", number, len(balances), len(addrs))
+		}
+		for i, addr := range addrs {
+			statedb, _, err := backend.StateAndHeaderByNumber(context.Background(), number)
+			if err != nil {
+				t.Fatalf("block %d: failed to retrieve state: %v", number, err)
+			}
+			if want := statedb.GetUnlockedBalance(addr); balances[i].Cmp(want) != 0 {
+				t.Errorf("block %d: balance %d of %x mismatch: have %v, want %v", number, i, addr, balances[i], want)
+			}
+		}
+	}
+}
+
+// Tests that concurrent senders are handed unique and contiguous nonces, which
+// are released once their transactions are submitted or their reservations
+// expire.
+func TestNextNonceAndReserve(t *testing.T) {
+	backend, stop := newTestPoolBackend(t, 0, nil)
+	defer stop()
+	pool := backend.abey.txPool
+	now := time.Now()
+	backend.nonces.Now = func() time.Time { return now }
+
+	const senders = 64
+	var (
+		nonces = make([]uint64, senders)
+		errs   = make([]error, senders)
+		wg     sync.WaitGroup
+	)
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			nonces[i], errs[i] = backend.NextNonceAndReserve(context.Background(), testBank)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("sender %d: failed to reserve nonce: %v", i, err)
+		}
+	}
+	sort.Slice(nonces, func(i, j int) bool { return nonces[i] < nonces[j] })
+	for i, nonce := range nonces {
+		if nonce != uint64(i) {
+			t.Fatalf("nonce %d mismatch: have %d, want %d", i, nonce, i)
+		}
+	}
+	// Submitting a transaction releases its nonce without handing it out again
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	tx, err := types.SignTx(types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), params.TxGas, big.NewInt(1), nil), signer, testBankKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if err := pool.AddLocal(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if nonce, err := backend.NextNonceAndReserve(context.Background(), testBank); err != nil || nonce != senders {
+		t.Errorf("nonce after submission mismatch: have %d, %v, want %d", nonce, err, senders)
+	}
+	// Expired reservations are handed out again, starting at the pool nonce
+	now = now.Add(abeyapi.DefaultNonceReservation)
+	if nonce, err := backend.NextNonceAndReserve(context.Background(), testBank); err != nil || nonce != 1 {
+		t.Errorf("nonce after expiry mismatch: have %d, %v, want 1", nonce, err)
+	}
+}
+
+// Tests that the header looked up by timestamp is the last one not after the
+// requested time, clamped to the genesis and the head of the chain.
+func TestHeaderByTimestamp(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 16, 0, nil, nil, nil, nil)
+	defer pm.Stop()
+
+	backend := &ABEYAPIBackend{abey: &Abeychain{blockchain: pm.blockchain}}
+	head := pm.blockchain.CurrentBlock().Header()
+	genesis := pm.blockchain.Genesis().Header()
+
+	for ts := genesis.Time.Int64() - 1; ts <= head.Time.Int64()+1; ts++ {
+		header, err := backend.HeaderByTimestamp(context.Background(), ts)
+		if err != nil {
+			t.Fatalf("time %d: failed to look up header: %v", ts, err)
+		}
+		switch number := header.Number.Uint64(); {
+		case ts < genesis.Time.Int64():
+			if number != 0 {
+				t.Errorf("time %d before genesis: have header #%d, want genesis", ts, number)
+			}
+		case header.Time.Int64() > ts:
+			t.Errorf("time %d: header #%d is after it at %d", ts, number, header.Time)
+		case number < head.Number.Uint64():
+			if next := pm.blockchain.GetHeaderByNumber(number + 1); next.Time.Int64() <= ts {
+				t.Errorf("time %d: header #%d is not the last before it, #%d is at %d", ts, number, number+1, next.Time)
+			}
+		}
+	}
+}
+
+// Tests that the bloom index progress reports the lag of an indexer behind the
+// chain head, and that an index without any confirmed section to process is
+// ready.
+func TestBloomIndexProgress(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 10, 0, nil, nil, nil, nil)
+	defer pm.Stop()
+
+	tests := []struct {
+		size    uint64
+		indexed uint64
+		ready   bool
+	}{
+		{size: 4, indexed: 0, ready: false}, // Two confirmed sections left to index
+		{size: 16, indexed: 0, ready: true}, // The head completes no section yet
+	}
+	for i, tt := range tests {
+		indexer := NewBloomIndexer(abeydb.NewMemDatabase(), tt.size, 1, false)
+		backend := &ABEYAPIBackend{abey: &Abeychain{blockchain: pm.blockchain, bloomIndexer: indexer}}
+
+		indexed, head, ready := backend.BloomIndexProgress()
+		if indexed != tt.indexed || head != 10 || ready != tt.ready {
+			t.Errorf("test %d: progress mismatch: have %d of %d, ready %v, want %d of %d, ready %v", i, indexed, head, ready, tt.indexed, 10, tt.ready)
+		}
+		indexer.Close()
+	}
+}
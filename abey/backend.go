@@ -29,6 +29,7 @@ import (
 	"sync/atomic"
 
 	"github.com/AbeyFoundation/go-abey/abey/downloader"
+	"github.com/AbeyFoundation/go-abey/abey/fastdownloader"
 	"github.com/AbeyFoundation/go-abey/abey/filters"
 	"github.com/AbeyFoundation/go-abey/abey/gasprice"
 	"github.com/AbeyFoundation/go-abey/abeydb"
@@ -243,7 +244,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Abeychain, error) {
 		abey.miner.SetElection(abey.config.EnableElection, crypto.FromECDSAPub(&committeeKey.PublicKey))
 	}
 
-	abey.APIBackend = &ABEYAPIBackend{abey, nil}
+	abey.APIBackend = &ABEYAPIBackend{abey: abey}
 	gpoParams := config.GPO
 	if gpoParams.Default == nil {
 		gpoParams.Default = config.GasPrice
@@ -346,7 +347,7 @@ func (s *Abeychain) APIs() []rpc.API {
 			}, {
 				Namespace: name,
 				Version:   "1.0",
-				Service:   filters.NewPublicFilterAPI(s.APIBackend, false),
+				Service:   filters.NewPublicFilterAPI(s.APIBackend, false, s.config.RPCLogsLimit),
 				Public:    true,
 			},
 		}...)
@@ -474,6 +475,8 @@ func (s *Abeychain) Downloader() *downloader.Downloader { return s.protocolManag
 func (s *Abeychain) Synced() bool                       { return atomic.LoadUint32(&s.protocolManager.acceptTxs) == 1 }
 func (s *Abeychain) ArchiveMode() bool                  { return s.config.NoPruning }
 
+func (s *Abeychain) FastDownloader() *fastdownloader.Downloader { return s.protocolManager.fdownloader }
+
 // Protocols implements node.Service, returning all the currently configured
 // network protocols to start.
 func (s *Abeychain) Protocols() []p2p.Protocol {
@@ -490,6 +493,11 @@ func (s *Abeychain) Start(srvr *p2p.Server) error {
 	// Start the bloom bits servicing goroutines
 	s.startBloomHandlers()
 
+	// Keep a flat snapshot of the head state serving the RPC reads if requested
+	if s.config.StateSnapshotAccounts > 0 {
+		go s.APIBackend.updateStateSnapshot(s.config.StateSnapshotAccounts, s.shutdownChan)
+	}
+
 	// Start the RPC service
 	s.netRPCService = abeyapi.NewPublicNetAPI(srvr, s.NetVersion())
 
@@ -28,6 +28,7 @@ import (
 	"crypto/ecdsa"
 
 	"github.com/AbeyFoundation/go-abey/abey/downloader"
+	"github.com/AbeyFoundation/go-abey/abey/filters"
 	"github.com/AbeyFoundation/go-abey/abey/gasprice"
 	"github.com/AbeyFoundation/go-abey/common"
 	"github.com/AbeyFoundation/go-abey/common/hexutil"
@@ -46,22 +47,27 @@ var DefaultConfig = Config{
 		DatasetsInMem:  1,
 		DatasetsOnDisk: 2,
 	},
-	NetworkId:     179,
-	LightPeers:    20,
-	DatabaseCache: 768,
-	TrieCache:     256,
-	TrieTimeout:   60 * time.Minute,
-	MinerGasFloor: 16000000,
-	MinerGasCeil:  20000000,
-	GasPrice:      big.NewInt(10 * params.GWei),
+	NetworkId:        179,
+	LightPeers:       20,
+	LightHeaderCache: 256,
+	DatabaseCache:    768,
+	TrieCache:        256,
+	TrieTimeout:      60 * time.Minute,
+	MinerGasFloor:    16000000,
+	MinerGasCeil:     20000000,
+	GasPrice:         big.NewInt(10 * params.GWei),
+	RPCLogsLimit:     filters.DefaultLogsLimit,
+	RPCGasCap:        25000000,
 
 	//GasPrice: big.NewInt(1 * params.Szabo),
 
 	TxPool:    core.DefaultTxPoolConfig,
 	SnailPool: snailchain.DefaultSnailPoolConfig,
 	GPO: gasprice.Config{
-		Blocks:     20,
-		Percentile: 60,
+		Blocks:         20,
+		Percentile:     60,
+		LowPercentile:  30,
+		HighPercentile: 90,
 	},
 	MinerThreads: 2,
 	Port:         30310,
@@ -111,6 +117,10 @@ type Config struct {
 	LightServ  int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightPeers int `toml:",omitempty"` // Maximum number of LES client peers
 
+	LightHeaderCache int           `toml:",omitempty"` // Number of network retrieved headers cached by the light API backend
+	LightMaxHeadAge  time.Duration `toml:",omitempty"` // Maximum age of the light head served to latest reads, zero disabling the check
+	LightCacheBudget uint64        `toml:",omitempty"` // Memory in bytes the light API backend caches ODR results in, zero for the default
+
 	// election options
 
 	EnableElection bool `toml:",omitempty"`
@@ -163,6 +173,16 @@ type Config struct {
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
+	// Maximum number of logs returned by a range log query over RPC
+	RPCLogsLimit int `toml:",omitempty"`
+
+	// Maximum gas of a call or a simulated bundle over RPC, zero for no cap
+	RPCGasCap uint64 `toml:",omitempty"`
+
+	// Maximum number of accounts of the flat snapshot of the head state serving
+	// RPC state reads, zero to disable the snapshot
+	StateSnapshotAccounts int `toml:",omitempty"`
+
 	// Miscellaneous options
 	DocRoot string `toml:"-"`
 
@@ -31,6 +31,7 @@ import (
 	"github.com/AbeyFoundation/go-abey/common/hexutil"
 	"github.com/AbeyFoundation/go-abey/core/types"
 	"github.com/AbeyFoundation/go-abey/event"
+	"github.com/AbeyFoundation/go-abey/internal/abeyapi"
 	"github.com/AbeyFoundation/go-abey/rpc"
 )
 
@@ -59,16 +60,22 @@ type PublicFilterAPI struct {
 	events    *EventSystem
 	filtersMu sync.Mutex
 	filters   map[rpc.ID]*filter
+	logsLimit int // Maximum number of logs returned by a range log query
 }
 
-// NewPublicFilterAPI returns a new PublicFilterAPI instance.
-func NewPublicFilterAPI(backend Backend, lightMode bool) *PublicFilterAPI {
+// NewPublicFilterAPI returns a new PublicFilterAPI instance. Range log queries
+// fail once they match more than logsLimit logs, DefaultLogsLimit if not positive.
+func NewPublicFilterAPI(backend Backend, lightMode bool, logsLimit int) *PublicFilterAPI {
+	if logsLimit <= 0 {
+		logsLimit = DefaultLogsLimit
+	}
 	api := &PublicFilterAPI{
-		backend: backend,
-		mux:     backend.EventMux(),
-		chainDb: backend.ChainDb(),
-		events:  NewEventSystem(backend.EventMux(), backend, lightMode),
-		filters: make(map[rpc.ID]*filter),
+		backend:   backend,
+		mux:       backend.EventMux(),
+		chainDb:   backend.ChainDb(),
+		events:    NewEventSystem(backend.EventMux(), backend, lightMode),
+		filters:   make(map[rpc.ID]*filter),
+		logsLimit: logsLimit,
 	}
 	go api.timeoutLoop()
 
@@ -135,7 +142,9 @@ func (api *PublicFilterAPI) NewPendingTransactionFilter() rpc.ID {
 
 // NewPendingTransactions creates a subscription that is triggered each time a transaction
 // enters the transaction pool and was signed from one of the transactions this nodes manages.
-func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Subscription, error) {
+// The transaction hashes are sent by default, the full transactions in the form returned
+// by abey_getTransactionByHash if fullTx is set.
+func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context, fullTx *bool) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
@@ -143,6 +152,28 @@ func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Su
 
 	rpcSub := notifier.CreateSubscription()
 
+	if fullTx != nil && *fullTx {
+		go func() {
+			txs := make(chan []*types.Transaction, 128)
+			pendingTxSub := api.events.SubscribePendingTxBodies(txs)
+
+			for {
+				select {
+				case batch := <-txs:
+					for _, tx := range batch {
+						notifier.Notify(rpcSub.ID, abeyapi.NewRPCPendingTransaction(tx, true))
+					}
+				case <-rpcSub.Err():
+					pendingTxSub.Unsubscribe()
+					return
+				case <-notifier.Closed():
+					pendingTxSub.Unsubscribe()
+					return
+				}
+			}
+		}()
+		return rpcSub, nil
+	}
 	go func() {
 		txHashes := make(chan []common.Hash, 128)
 		pendingTxSub := api.events.SubscribePendingTxs(txHashes)
@@ -338,6 +369,7 @@ func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([
 	}
 	// Create and run the filter to get all the logs
 	filter := NewRangeFilter(api.backend, fNumber, tNumber, crit.Addresses, crit.Topics)
+	filter.SetLimit(api.logsLimit)
 
 	logs, err := filter.Logs(ctx)
 	if err != nil {
@@ -389,6 +421,7 @@ func (api *PublicFilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*ty
 	}*/
 	// Create and run the filter to get all the logs
 	filter := NewRangeFilter(api.backend, begin, end, f.crit.Addresses, f.crit.Topics)
+	filter.SetLimit(api.logsLimit)
 
 	logs, err := filter.Logs(ctx)
 	if err != nil {
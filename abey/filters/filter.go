@@ -19,6 +19,7 @@ package filters
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/AbeyFoundation/go-abey/abeydb"
@@ -29,6 +30,25 @@ import (
 	"github.com/AbeyFoundation/go-abey/rpc"
 )
 
+// DefaultLogsLimit is the maximum number of logs a range log query served over
+// RPC returns unless configured otherwise.
+const DefaultLogsLimit = 10000
+
+// LimitExceededError is returned by a range filter matching more logs than its
+// limit. Block is the last block scanned, the one whose logs crossed the limit,
+// from which the query can be resumed.
+type LimitExceededError struct {
+	Limit int
+	Block uint64
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("query returned more than %d results, last scanned block %d", e.Limit, e.Block)
+}
+
+// ErrorCode returns the JSON-RPC error code of a query exceeding its limit.
+func (e *LimitExceededError) ErrorCode() int { return -32005 }
+
 type Backend interface {
 	ChainDb() abeydb.Database
 	EventMux() *event.TypeMux
@@ -43,6 +63,7 @@ type Backend interface {
 	SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription
 
 	BloomStatus() (uint64, uint64)
+	BloomIndexProgress() (indexed, head uint64, ready bool)
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
 }
 
@@ -57,6 +78,7 @@ type Filter struct {
 
 	block   common.Hash // Block hash if filtering a single block
 	matcher *bloombits.Matcher
+	limit   int // Maximum number of logs returned, zero for no limit
 }
 
 // NewRangeFilter creates a new filter which uses a bloom filter on blocks to
@@ -101,6 +123,12 @@ func NewBlockFilter(backend Backend, block common.Hash, addresses []common.Addre
 	return filter
 }
 
+// SetLimit caps the number of logs the filter may return, failing with a
+// LimitExceededError instead once more logs match. Zero removes the limit.
+func (f *Filter) SetLimit(limit int) {
+	f.limit = limit
+}
+
 // newFilter creates a generic filter that can either filter based on a block hash,
 // or based on range queries. The search criteria needs to be explicitly set.
 func newFilter(backend Backend, addresses []common.Address, topics [][]common.Hash) *Filter {
@@ -156,9 +184,7 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 			return logs, err
 		}
 	}
-	rest, err := f.unindexedLogs(ctx, end)
-	logs = append(logs, rest...)
-	return logs, err
+	return f.unindexedLogs(ctx, end, logs)
 }
 
 // indexedLogs returns the logs matching the filter criteria based on the bloom
@@ -200,7 +226,9 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 			if err != nil {
 				return logs, err
 			}
-			logs = append(logs, found...)
+			if logs, err = f.appendLogs(logs, found, number); err != nil {
+				return nil, err
+			}
 
 		case <-ctx.Done():
 			return logs, ctx.Err()
@@ -208,11 +236,9 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 	}
 }
 
-// indexedLogs returns the logs matching the filter criteria based on raw block
-// iteration and bloom matching.
-func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
-	var logs []*types.Log
-
+// unindexedLogs appends the logs matching the filter criteria based on raw block
+// iteration and bloom matching to the ones already gathered.
+func (f *Filter) unindexedLogs(ctx context.Context, end uint64, logs []*types.Log) ([]*types.Log, error) {
 	for ; f.begin <= int64(end); f.begin++ {
 		header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(f.begin))
 		if header == nil || err != nil {
@@ -222,11 +248,22 @@ func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, e
 		if err != nil {
 			return logs, err
 		}
-		logs = append(logs, found...)
+		if logs, err = f.appendLogs(logs, found, uint64(f.begin)); err != nil {
+			return nil, err
+		}
 	}
 	return logs, nil
 }
 
+// appendLogs appends the logs found in the block with the given number to the
+// ones already gathered, failing if they exceed the limit of the filter.
+func (f *Filter) appendLogs(logs, found []*types.Log, number uint64) ([]*types.Log, error) {
+	if f.limit > 0 && len(logs)+len(found) > f.limit {
+		return nil, &LimitExceededError{Limit: f.limit, Block: number}
+	}
+	return append(logs, found...), nil
+}
+
 // blockLogs returns the logs matching the filter criteria within a single block.
 func (f *Filter) blockLogs(ctx context.Context, header *types.Header) (logs []*types.Log, err error) {
 	if bloomFilter(header.Bloom, f.addresses, f.topics) {
@@ -52,6 +52,9 @@ const (
 	PendingTransactionsSubscription
 	// BlocksSubscription queries hashes for blocks that are imported
 	BlocksSubscription
+	// PendingTransactionBodiesSubscription queries the full bodies of pending
+	// transactions entering the pending state
+	PendingTransactionBodiesSubscription
 	// LastSubscription keeps track of the last index
 	LastIndexSubscription
 )
@@ -80,6 +83,7 @@ type subscription struct {
 	logsCrit  abeychain.FilterQuery
 	logs      chan []*types.Log
 	hashes    chan []common.Hash
+	txs       chan []*types.Transaction
 	headers   chan *types.Header
 	installed chan struct{} // closed when the filter is installed
 	err       chan error    // closed when the filter is uninstalled
@@ -173,6 +177,7 @@ func (sub *Subscription) Unsubscribe() {
 				break uninstallLoop
 			case <-sub.f.logs:
 			case <-sub.f.hashes:
+			case <-sub.f.txs:
 			case <-sub.f.headers:
 			}
 		}
@@ -240,6 +245,7 @@ func (es *EventSystem) subscribeMinedPendingLogs(crit abeychain.FilterQuery, log
 		created:   time.Now(),
 		logs:      logs,
 		hashes:    make(chan []common.Hash),
+		txs:       make(chan []*types.Transaction),
 		headers:   make(chan *types.Header),
 		installed: make(chan struct{}),
 		err:       make(chan error),
@@ -257,6 +263,7 @@ func (es *EventSystem) subscribeLogs(crit abeychain.FilterQuery, logs chan []*ty
 		created:   time.Now(),
 		logs:      logs,
 		hashes:    make(chan []common.Hash),
+		txs:       make(chan []*types.Transaction),
 		headers:   make(chan *types.Header),
 		installed: make(chan struct{}),
 		err:       make(chan error),
@@ -274,6 +281,7 @@ func (es *EventSystem) subscribePendingLogs(crit abeychain.FilterQuery, logs cha
 		created:   time.Now(),
 		logs:      logs,
 		hashes:    make(chan []common.Hash),
+		txs:       make(chan []*types.Transaction),
 		headers:   make(chan *types.Header),
 		installed: make(chan struct{}),
 		err:       make(chan error),
@@ -290,6 +298,7 @@ func (es *EventSystem) SubscribeNewHeads(headers chan *types.Header) *Subscripti
 		created:   time.Now(),
 		logs:      make(chan []*types.Log),
 		hashes:    make(chan []common.Hash),
+		txs:       make(chan []*types.Transaction),
 		headers:   headers,
 		installed: make(chan struct{}),
 		err:       make(chan error),
@@ -306,6 +315,26 @@ func (es *EventSystem) SubscribePendingTxs(hashes chan []common.Hash) *Subscript
 		created:   time.Now(),
 		logs:      make(chan []*types.Log),
 		hashes:    hashes,
+		txs:       make(chan []*types.Transaction),
+		headers:   make(chan *types.Header),
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
+// SubscribePendingTxBodies creates a subscription that writes the full bodies of
+// the transactions entering the transaction pool. Unlike SubscribePendingTxs it
+// spares the subscriber from fetching every transaction by hash, at the cost of
+// the larger notifications.
+func (es *EventSystem) SubscribePendingTxBodies(txs chan []*types.Transaction) *Subscription {
+	sub := &subscription{
+		id:        rpc.NewID(),
+		typ:       PendingTransactionBodiesSubscription,
+		created:   time.Now(),
+		logs:      make(chan []*types.Log),
+		hashes:    make(chan []common.Hash),
+		txs:       txs,
 		headers:   make(chan *types.Header),
 		installed: make(chan struct{}),
 		err:       make(chan error),
@@ -348,12 +377,17 @@ func (es *EventSystem) broadcast(filters filterIndex, ev interface{}) {
 			}
 		}
 	case types.NewTxsEvent:
-		hashes := make([]common.Hash, 0, len(e.Txs))
-		for _, tx := range e.Txs {
-			hashes = append(hashes, tx.Hash())
+		if len(filters[PendingTransactionsSubscription]) > 0 {
+			hashes := make([]common.Hash, 0, len(e.Txs))
+			for _, tx := range e.Txs {
+				hashes = append(hashes, tx.Hash())
+			}
+			for _, f := range filters[PendingTransactionsSubscription] {
+				f.hashes <- hashes
+			}
 		}
-		for _, f := range filters[PendingTransactionsSubscription] {
-			f.hashes <- hashes
+		for _, f := range filters[PendingTransactionBodiesSubscription] {
+			f.txs <- e.Txs
 		}
 	case types.FastChainEvent:
 		for _, f := range filters[BlocksSubscription] {
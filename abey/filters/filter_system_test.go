@@ -0,0 +1,195 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/AbeyFoundation/go-abey/abeydb"
+	"github.com/AbeyFoundation/go-abey/common"
+	"github.com/AbeyFoundation/go-abey/core/bloombits"
+	"github.com/AbeyFoundation/go-abey/core/types"
+	"github.com/AbeyFoundation/go-abey/crypto"
+	"github.com/AbeyFoundation/go-abey/event"
+	"github.com/AbeyFoundation/go-abey/internal/abeyapi"
+	"github.com/AbeyFoundation/go-abey/params"
+	"github.com/AbeyFoundation/go-abey/rpc"
+)
+
+// eventTestBackend is a filter backend feeding the event system from feeds the
+// tests send to directly.
+type eventTestBackend struct {
+	mux        *event.TypeMux
+	txFeed     event.Feed
+	logsFeed   event.Feed
+	rmLogsFeed event.Feed
+	chainFeed  event.Feed
+}
+
+func (b *eventTestBackend) ChainDb() abeydb.Database                   { return nil }
+func (b *eventTestBackend) EventMux() *event.TypeMux                   { return b.mux }
+func (b *eventTestBackend) BloomStatus() (uint64, uint64)              { return 0, 0 }
+func (b *eventTestBackend) BloomIndexProgress() (uint64, uint64, bool) { return 0, 0, false }
+
+func (b *eventTestBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	return nil, nil
+}
+
+func (b *eventTestBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	return nil, nil
+}
+
+func (b *eventTestBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return nil, nil
+}
+
+func (b *eventTestBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*types.Log, error) {
+	return nil, nil
+}
+
+func (b *eventTestBackend) SubscribeNewTxsEvent(ch chan<- types.NewTxsEvent) event.Subscription {
+	return b.txFeed.Subscribe(ch)
+}
+
+func (b *eventTestBackend) SubscribeChainEvent(ch chan<- types.FastChainEvent) event.Subscription {
+	return b.chainFeed.Subscribe(ch)
+}
+
+func (b *eventTestBackend) SubscribeRemovedLogsEvent(ch chan<- types.RemovedLogsEvent) event.Subscription {
+	return b.rmLogsFeed.Subscribe(ch)
+}
+
+func (b *eventTestBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription {
+	return b.logsFeed.Subscribe(ch)
+}
+
+func (b *eventTestBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {}
+
+// Tests that pending transaction body subscribers receive the complete
+// transactions, payment fields included, alongside the hash subscribers.
+func TestPendingTxBodies(t *testing.T) {
+	backend := &eventTestBackend{mux: new(event.TypeMux)}
+	defer backend.mux.Stop()
+
+	es := NewEventSystem(backend.mux, backend, false)
+
+	var (
+		bodies  = make(chan []*types.Transaction, 1)
+		hashes  = make(chan []common.Hash, 1)
+		bodySub = es.SubscribePendingTxBodies(bodies)
+		hashSub = es.SubscribePendingTxs(hashes)
+	)
+	defer bodySub.Unsubscribe()
+	defer hashSub.Unsubscribe()
+
+	payer := common.Address{0x02}
+	txs := []*types.Transaction{
+		types.NewTransaction(0, common.Address{0x01}, big.NewInt(1000), 21000, big.NewInt(1), nil),
+		types.NewTransaction_Payment(1, common.Address{0x01}, big.NewInt(1000), big.NewInt(12345), 21000, big.NewInt(1), []byte{0x01}, payer),
+	}
+	backend.txFeed.Send(types.NewTxsEvent{Txs: txs})
+
+	select {
+	case have := <-bodies:
+		if len(have) != len(txs) {
+			t.Fatalf("transaction count mismatch: have %d, want %d", len(have), len(txs))
+		}
+		for i, tx := range have {
+			if tx.Hash() != txs[i].Hash() {
+				t.Errorf("transaction %d: hash mismatch: have %x, want %x", i, tx.Hash(), txs[i].Hash())
+			}
+		}
+		if fee := have[1].Fee(); fee == nil || fee.Cmp(big.NewInt(12345)) != 0 {
+			t.Errorf("fee mismatch: have %v, want 12345", fee)
+		}
+		if have := have[1].Payer(); have == nil || *have != payer {
+			t.Errorf("payer mismatch: have %v, want %x", have, payer)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("transaction bodies not delivered")
+	}
+	select {
+	case have := <-hashes:
+		if len(have) != len(txs) || have[0] != txs[0].Hash() || have[1] != txs[1].Hash() {
+			t.Errorf("transaction hashes mismatch: have %x", have)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("transaction hashes not delivered")
+	}
+}
+
+// Tests that full pending transaction notifications deliver the transactions in
+// their RPC form, sender and payment fields included.
+func TestNewPendingTransactionsFullTx(t *testing.T) {
+	backend := &eventTestBackend{mux: new(event.TypeMux)}
+	defer backend.mux.Stop()
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("abey", NewPublicFilterAPI(backend, false, 0)); err != nil {
+		t.Fatalf("failed to register filter API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	txs := make(chan *abeyapi.RPCTransaction, 1)
+	sub, err := client.Subscribe(context.Background(), "abey", txs, "newPendingTransactions", true)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	var (
+		key, _ = crypto.GenerateKey()
+		signer = types.NewTIP1Signer(params.TestChainConfig.ChainID)
+		payer  = common.Address{0x02}
+	)
+	tx, err := types.SignTx(types.NewTransaction_Payment(0, common.Address{0x01}, big.NewInt(1000), big.NewInt(12345), 21000, big.NewInt(1), []byte{0x01}, payer), signer, key)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	// The event subscription of the notifier is set up asynchronously, so the
+	// transaction is announced until it comes through
+	for start := time.Now(); ; {
+		backend.txFeed.Send(types.NewTxsEvent{Txs: []*types.Transaction{tx}})
+		select {
+		case have := <-txs:
+			if have.Hash != tx.Hash() {
+				t.Errorf("hash mismatch: have %x, want %x", have.Hash, tx.Hash())
+			}
+			if want := crypto.PubkeyToAddress(key.PublicKey); have.From != want {
+				t.Errorf("sender mismatch: have %x, want %x", have.From, want)
+			}
+			if have.Fee == nil || have.Fee.ToInt().Cmp(big.NewInt(12345)) != 0 {
+				t.Errorf("fee mismatch: have %v, want 12345", have.Fee)
+			}
+			if have.Payer == nil || *have.Payer != payer {
+				t.Errorf("payer mismatch: have %v, want %x", have.Payer, payer)
+			}
+			return
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(50 * time.Millisecond):
+			if time.Since(start) > 5*time.Second {
+				t.Fatal("pending transaction not notified")
+			}
+		}
+	}
+}
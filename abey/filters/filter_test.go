@@ -0,0 +1,92 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/AbeyFoundation/go-abey/common"
+	"github.com/AbeyFoundation/go-abey/core/types"
+)
+
+// logsTestBackend is a history backend whose blocks carry logs.
+type logsTestBackend struct {
+	*historyTestBackend
+	logs map[common.Hash][][]*types.Log
+}
+
+func (b *logsTestBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*types.Log, error) {
+	return b.logs[hash], nil
+}
+
+func TestRangeFilterLimit(t *testing.T) {
+	backend := &logsTestBackend{
+		historyTestBackend: &historyTestBackend{blocks: []*types.Block{types.NewBlock(&types.Header{Number: new(big.Int)}, nil, nil, nil, nil)}},
+		logs:               make(map[common.Hash][][]*types.Log),
+	}
+	// Every block holds two logs
+	for i := 1; i <= 5; i++ {
+		header := &types.Header{
+			ParentHash: backend.blocks[i-1].Hash(),
+			Number:     big.NewInt(int64(i)),
+		}
+		block := types.NewBlock(header, nil, nil, nil, nil)
+		backend.blocks = append(backend.blocks, block)
+		backend.logs[block.Hash()] = [][]*types.Log{{
+			{Address: common.Address{0x01}, BlockNumber: uint64(i), TxHash: common.Hash{byte(i)}},
+			{Address: common.Address{0x01}, BlockNumber: uint64(i), TxHash: common.Hash{byte(i)}},
+		}}
+	}
+	tests := []struct {
+		begin int64
+		limit int
+		logs  int    // Number of logs returned
+		block uint64 // Block reported as last scanned, zero if within the limit
+	}{
+		{begin: 1, limit: 0, logs: 10},
+		{begin: 1, limit: 10, logs: 10},
+		{begin: 1, limit: 5, block: 3},
+		{begin: 3, limit: 5, block: 5},
+		{begin: 5, limit: 5, logs: 2},
+	}
+	for i, tt := range tests {
+		filter := NewRangeFilter(backend, tt.begin, 5, nil, nil)
+		filter.SetLimit(tt.limit)
+
+		logs, err := filter.Logs(context.Background())
+		if tt.block == 0 {
+			if err != nil || len(logs) != tt.logs {
+				t.Errorf("test %d: logs mismatch: have %d, %v, want %d", i, len(logs), err, tt.logs)
+			}
+			continue
+		}
+		lerr, ok := err.(*LimitExceededError)
+		if !ok {
+			t.Errorf("test %d: error mismatch: have %v, want limit exceeded", i, err)
+			continue
+		}
+		if lerr.Limit != tt.limit || lerr.Block != tt.block {
+			t.Errorf("test %d: limit error mismatch: have limit %d at block %d, want %d at %d", i, lerr.Limit, lerr.Block, tt.limit, tt.block)
+		}
+		if !strings.HasPrefix(err.Error(), "query returned more than 5 results") {
+			t.Errorf("test %d: error message mismatch: have %q", i, err)
+		}
+	}
+}
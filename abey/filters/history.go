@@ -0,0 +1,129 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/AbeyFoundation/go-abey/common"
+	"github.com/AbeyFoundation/go-abey/core/types"
+	"github.com/AbeyFoundation/go-abey/internal/abeyapi"
+	"github.com/AbeyFoundation/go-abey/params"
+	"github.com/AbeyFoundation/go-abey/rpc"
+)
+
+// MaxHistoryRange is the maximum number of blocks AddressTransactions inspects
+// in a single call.
+const MaxHistoryRange = 1000
+
+var (
+	// ErrInvalidHistoryRange is returned if the first block of a history range
+	// comes after the last one.
+	ErrInvalidHistoryRange = errors.New("invalid block range")
+
+	// ErrHistoryRangeTooLarge is returned if a history range spans more than
+	// MaxHistoryRange blocks.
+	ErrHistoryRangeTooLarge = errors.New("block range too large")
+)
+
+// HistoryBackend is the backend needed to enumerate the transactions of an
+// account.
+type HistoryBackend interface {
+	ChainConfig() *params.ChainConfig
+	HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error)
+	GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error)
+}
+
+// AddressTransactions returns the transactions sent from or to addr within the
+// blocks begin to end, -1 standing for the latest block. An end beyond the head
+// of the chain is lowered to the head.
+//
+// The log bloom index only holds the addresses of the contracts emitting logs
+// and the indexed log topics, missing the plain value transfers, so the body of
+// every block in the range is inspected instead. The range is thus limited to
+// MaxHistoryRange blocks. Internal calls never appear without trace indexing.
+func AddressTransactions(ctx context.Context, backend HistoryBackend, addr common.Address, begin, end int64) ([]*abeyapi.AddressTransaction, error) {
+	// Figure out the limits of the search range
+	header, _ := backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if header == nil {
+		return nil, nil
+	}
+	head := int64(header.Number.Uint64())
+
+	if begin == -1 {
+		begin = head
+	}
+	if end == -1 || end > head {
+		end = head
+	}
+	if begin > end {
+		return nil, fmt.Errorf("%w: %d > %d", ErrInvalidHistoryRange, begin, end)
+	}
+	if end-begin >= MaxHistoryRange {
+		return nil, fmt.Errorf("%w: %d blocks, maximum %d", ErrHistoryRangeTooLarge, end-begin+1, MaxHistoryRange)
+	}
+	var txs []*abeyapi.AddressTransaction
+	for ; begin <= end; begin++ {
+		if err := ctx.Err(); err != nil {
+			return txs, err
+		}
+		header, err := backend.HeaderByNumber(ctx, rpc.BlockNumber(begin))
+		if header == nil || err != nil {
+			return txs, err
+		}
+		found, err := addressTransactions(ctx, backend, header, addr)
+		if err != nil {
+			return txs, err
+		}
+		txs = append(txs, found...)
+	}
+	return txs, nil
+}
+
+// addressTransactions returns the transactions of the block belonging to header
+// which were sent from or to addr.
+func addressTransactions(ctx context.Context, backend HistoryBackend, header *types.Header, addr common.Address) ([]*abeyapi.AddressTransaction, error) {
+	block, err := backend.GetBlock(ctx, header.Hash())
+	if block == nil || err != nil {
+		return nil, err
+	}
+	var (
+		signer = types.MakeSigner(backend.ChainConfig(), block.Number())
+		txs    []*abeyapi.AddressTransaction
+	)
+	for i, tx := range block.Transactions() {
+		involved := tx.To() != nil && *tx.To() == addr
+		if !involved {
+			from, err := types.Sender(signer, tx)
+			if err != nil {
+				return nil, err
+			}
+			involved = from == addr
+		}
+		if involved {
+			txs = append(txs, &abeyapi.AddressTransaction{
+				Tx:          tx,
+				BlockHash:   block.Hash(),
+				BlockNumber: block.NumberU64(),
+				Index:       uint64(i),
+			})
+		}
+	}
+	return txs, nil
+}
@@ -0,0 +1,207 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/AbeyFoundation/go-abey/abeydb"
+	"github.com/AbeyFoundation/go-abey/common"
+	"github.com/AbeyFoundation/go-abey/core/bloombits"
+	"github.com/AbeyFoundation/go-abey/core/types"
+	"github.com/AbeyFoundation/go-abey/crypto"
+	"github.com/AbeyFoundation/go-abey/event"
+	"github.com/AbeyFoundation/go-abey/internal/abeyapi"
+	"github.com/AbeyFoundation/go-abey/params"
+	"github.com/AbeyFoundation/go-abey/rpc"
+)
+
+// historyTestBackend is a filter backend serving a fixed chain of blocks, with
+// no bloom bits index.
+type historyTestBackend struct {
+	blocks []*types.Block // Blocks indexed by number
+}
+
+func (b *historyTestBackend) ChainDb() abeydb.Database      { return nil }
+func (b *historyTestBackend) EventMux() *event.TypeMux      { return nil }
+func (b *historyTestBackend) BloomStatus() (uint64, uint64) { return params.BloomBitsBlocks, 0 }
+func (b *historyTestBackend) BloomIndexProgress() (uint64, uint64, bool) {
+	return 0, b.blocks[len(b.blocks)-1].NumberU64(), false
+}
+func (b *historyTestBackend) ChainConfig() *params.ChainConfig {
+	return params.TestChainConfig
+}
+
+func (b *historyTestBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number == rpc.LatestBlockNumber {
+		return b.blocks[len(b.blocks)-1].Header(), nil
+	}
+	if int(number) >= len(b.blocks) {
+		return nil, nil
+	}
+	return b.blocks[number].Header(), nil
+}
+
+func (b *historyTestBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	block, err := b.GetBlock(ctx, hash)
+	if block == nil {
+		return nil, err
+	}
+	return block.Header(), nil
+}
+
+func (b *historyTestBackend) GetBlock(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	for _, block := range b.blocks {
+		if block.Hash() == hash {
+			return block, nil
+		}
+	}
+	return nil, nil
+}
+
+func (b *historyTestBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return nil, nil
+}
+
+func (b *historyTestBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*types.Log, error) {
+	return nil, nil
+}
+
+func (b *historyTestBackend) SubscribeNewTxsEvent(chan<- types.NewTxsEvent) event.Subscription {
+	return nil
+}
+
+func (b *historyTestBackend) SubscribeChainEvent(ch chan<- types.FastChainEvent) event.Subscription {
+	return nil
+}
+
+func (b *historyTestBackend) SubscribeRemovedLogsEvent(ch chan<- types.RemovedLogsEvent) event.Subscription {
+	return nil
+}
+
+func (b *historyTestBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription {
+	return nil
+}
+
+func (b *historyTestBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {}
+
+func TestAddressTransactions(t *testing.T) {
+	var (
+		targetKey, _ = crypto.GenerateKey()
+		otherKey, _  = crypto.GenerateKey()
+		target       = crypto.PubkeyToAddress(targetKey.PublicKey)
+		other        = crypto.PubkeyToAddress(otherKey.PublicKey)
+		token        = common.Address{0x01}
+		signer       = types.MakeSigner(params.TestChainConfig, nil)
+		nonces       = make(map[*ecdsa.PrivateKey]uint64)
+	)
+	newTx := func(key *ecdsa.PrivateKey, to common.Address) *types.Transaction {
+		tx := types.NewTransaction(nonces[key], to, big.NewInt(1), params.TxGas, big.NewInt(1), nil)
+		nonces[key]++
+
+		signed, err := types.SignTx(tx, signer, key)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		return signed
+	}
+	transfer := &types.Log{Address: token, Topics: []common.Hash{{0x02}, common.BytesToHash(target.Bytes())}}
+
+	// Block 5 mentions the target in a log without holding any of its
+	// transactions, while the plain transfers of blocks 3 and 4 leave no log
+	// behind and are found all the same
+	bodies := []struct {
+		txs  []*types.Transaction
+		logs []*types.Log
+		want []int
+	}{
+		1: {txs: []*types.Transaction{newTx(otherKey, other)}},
+		2: {txs: []*types.Transaction{newTx(otherKey, token), newTx(targetKey, other)}, logs: []*types.Log{transfer}, want: []int{1}},
+		3: {txs: []*types.Transaction{newTx(otherKey, target)}, want: []int{0}},
+		4: {txs: []*types.Transaction{newTx(targetKey, other)}, want: []int{0}},
+		5: {txs: []*types.Transaction{newTx(otherKey, other), newTx(otherKey, token)}, logs: []*types.Log{transfer}},
+		6: {txs: []*types.Transaction{newTx(targetKey, target), newTx(otherKey, other)}, want: []int{0}},
+		7: {},
+	}
+	backend := &historyTestBackend{blocks: []*types.Block{types.NewBlock(&types.Header{Number: new(big.Int)}, nil, nil, nil, nil)}}
+	for i := 1; i < len(bodies); i++ {
+		receipts := make([]*types.Receipt, len(bodies[i].txs))
+		for j := range receipts {
+			receipts[j] = &types.Receipt{}
+		}
+		if len(receipts) > 0 {
+			receipts[0].Logs = bodies[i].logs
+		}
+		header := &types.Header{
+			ParentHash: backend.blocks[i-1].Hash(),
+			Number:     big.NewInt(int64(i)),
+		}
+		backend.blocks = append(backend.blocks, types.NewBlock(header, bodies[i].txs, receipts, nil, nil))
+	}
+	// check enumerates the transactions of the target within the given range and
+	// compares them against the expected ones of blocks from to to
+	check := func(begin, end int64, from, to int) {
+		txs, err := AddressTransactions(context.Background(), backend, target, begin, end)
+		if err != nil {
+			t.Fatalf("range [%d, %d]: failed to enumerate transactions: %v", begin, end, err)
+		}
+		var want []*abeyapi.AddressTransaction
+		for i := from; i <= to; i++ {
+			for _, index := range bodies[i].want {
+				want = append(want, &abeyapi.AddressTransaction{
+					Tx:          bodies[i].txs[index],
+					BlockHash:   backend.blocks[i].Hash(),
+					BlockNumber: uint64(i),
+					Index:       uint64(index),
+				})
+			}
+		}
+		if len(txs) != len(want) {
+			t.Fatalf("range [%d, %d]: transaction count mismatch: have %d, want %d", begin, end, len(txs), len(want))
+		}
+		for i := range txs {
+			if txs[i].Tx.Hash() != want[i].Tx.Hash() || txs[i].BlockHash != want[i].BlockHash || txs[i].BlockNumber != want[i].BlockNumber || txs[i].Index != want[i].Index {
+				t.Errorf("range [%d, %d]: transaction %d mismatch: have %x in block %d at %d, want %x in block %d at %d", begin, end, i,
+					txs[i].Tx.Hash(), txs[i].BlockNumber, txs[i].Index, want[i].Tx.Hash(), want[i].BlockNumber, want[i].Index)
+			}
+		}
+	}
+	check(0, -1, 1, 7)
+	check(3, 4, 3, 4)
+
+	// An end beyond the head is lowered to it
+	check(4, 100, 4, 7)
+
+	// Inverted and oversized ranges are rejected
+	if _, err := AddressTransactions(context.Background(), backend, target, 4, 3); !errors.Is(err, ErrInvalidHistoryRange) {
+		t.Errorf("inverted range: error mismatch: have %v, want %v", err, ErrInvalidHistoryRange)
+	}
+	if _, err := AddressTransactions(context.Background(), backend, target, 8, -1); !errors.Is(err, ErrInvalidHistoryRange) {
+		t.Errorf("range past the head: error mismatch: have %v, want %v", err, ErrInvalidHistoryRange)
+	}
+	backend.blocks = append(backend.blocks, make([]*types.Block, MaxHistoryRange)...)
+	for i := len(bodies); i < len(backend.blocks); i++ {
+		backend.blocks[i] = types.NewBlock(&types.Header{ParentHash: backend.blocks[i-1].Hash(), Number: big.NewInt(int64(i))}, nil, nil, nil, nil)
+	}
+	if _, err := AddressTransactions(context.Background(), backend, target, 0, -1); !errors.Is(err, ErrHistoryRangeTooLarge) {
+		t.Errorf("oversized range: error mismatch: have %v, want %v", err, ErrHistoryRangeTooLarge)
+	}
+}
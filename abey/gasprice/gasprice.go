@@ -18,11 +18,13 @@ package gasprice
 
 import (
 	"context"
+	"errors"
 	"math/big"
 	"sort"
 	"sync"
 
 	"github.com/AbeyFoundation/go-abey/common"
+	"github.com/AbeyFoundation/go-abey/common/hexutil"
 	"github.com/AbeyFoundation/go-abey/core/types"
 	"github.com/AbeyFoundation/go-abey/params"
 	"github.com/AbeyFoundation/go-abey/rpc"
@@ -30,10 +32,25 @@ import (
 
 var maxPrice = big.NewInt(50 * params.GWei)
 
+var (
+	// ErrInvalidBlocks is returned if the number of blocks to sample is not
+	// positive.
+	ErrInvalidBlocks = errors.New("gas price oracle needs at least one block to sample")
+
+	// ErrInvalidPercentile is returned if a percentile lies outside of 0 to 100.
+	ErrInvalidPercentile = errors.New("gas price percentile out of range [0, 100]")
+)
+
+// minRangeSamples is the number of sampled block prices needed to derive
+// distinct price tiers, below which all tiers fall back to the suggested price.
+const minRangeSamples = 3
+
 type Config struct {
-	Blocks     int
-	Percentile int
-	Default    *big.Int `toml:",omitempty"`
+	Blocks         int
+	Percentile     int
+	LowPercentile  int
+	HighPercentile int
+	Default        *big.Int `toml:",omitempty"`
 }
 
 // Oracle recommends gas prices based on the content of recent
@@ -42,12 +59,15 @@ type Oracle struct {
 	backend      OracleBackend
 	lastHead     common.Hash
 	lastPrice    *big.Int
+	lastRange    [3]*big.Int
+	lastRangeAt  common.Hash
 	defaultPrice *big.Int
 	cacheLock    sync.RWMutex
 	fetchLock    sync.Mutex
 
 	checkBlocks, maxEmpty, maxBlocks int
 	percentile                       int
+	lowPercentile, highPercentile    int
 }
 
 // OracleBackend includes all necessary background APIs for oracle.
@@ -70,15 +90,76 @@ func NewOracle(backend OracleBackend, params Config) *Oracle {
 	if percent > 100 {
 		percent = 100
 	}
+	low := params.LowPercentile
+	if low < 0 {
+		low = 0
+	}
+	if low > percent {
+		low = percent
+	}
+	high := params.HighPercentile
+	if high < percent {
+		high = percent
+	}
+	if high > 100 {
+		high = 100
+	}
 	return &Oracle{
-		backend:      backend,
-		lastPrice:    params.Default,
-		defaultPrice: params.Default,
-		checkBlocks:  blocks,
-		maxEmpty:     blocks / 2,
-		maxBlocks:    blocks * 5,
-		percentile:   percent,
+		backend:        backend,
+		lastPrice:      params.Default,
+		defaultPrice:   params.Default,
+		checkBlocks:    blocks,
+		maxEmpty:       blocks / 2,
+		maxBlocks:      blocks * 5,
+		percentile:     percent,
+		lowPercentile:  low,
+		highPercentile: high,
+	}
+}
+
+// SetBlocks changes the number of recent blocks sampled for the suggestions,
+// taking effect from the next suggestion on.
+func (gpo *Oracle) SetBlocks(blocks int) error {
+	if blocks < 1 {
+		return ErrInvalidBlocks
 	}
+	gpo.fetchLock.Lock()
+	defer gpo.fetchLock.Unlock()
+
+	gpo.checkBlocks, gpo.maxEmpty, gpo.maxBlocks = blocks, blocks/2, blocks*5
+	gpo.resetCache()
+	return nil
+}
+
+// SetPercentile changes the percentile of the sampled block prices suggested,
+// taking effect from the next suggestion on. The low and high tiers of the
+// price range are moved along where needed to keep the tiers ordered.
+func (gpo *Oracle) SetPercentile(percent int) error {
+	if percent < 0 || percent > 100 {
+		return ErrInvalidPercentile
+	}
+	gpo.fetchLock.Lock()
+	defer gpo.fetchLock.Unlock()
+
+	gpo.percentile = percent
+	if gpo.lowPercentile > percent {
+		gpo.lowPercentile = percent
+	}
+	if gpo.highPercentile < percent {
+		gpo.highPercentile = percent
+	}
+	gpo.resetCache()
+	return nil
+}
+
+// resetCache drops the cached suggestions, keeping the last price as the
+// fallback of failing retrievals. The fetch lock must be held.
+func (gpo *Oracle) resetCache() {
+	gpo.cacheLock.Lock()
+	defer gpo.cacheLock.Unlock()
+
+	gpo.lastHead = common.Hash{}
+	gpo.lastRangeAt = common.Hash{}
 }
 
 // SuggestPrice returns the recommended gas price.
@@ -106,6 +187,104 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 		return lastPrice, nil
 	}
 
+	blockPrices, err := gpo.blockPrices(ctx, head)
+	if err != nil {
+		return lastPrice, err
+	}
+	price := lastPrice
+	if len(blockPrices) > 0 {
+		price = percentilePrice(blockPrices, gpo.percentile)
+	}
+	price = gpo.bound(price)
+
+	gpo.cacheLock.Lock()
+	gpo.lastHead = headHash
+	gpo.lastPrice = price
+	gpo.cacheLock.Unlock()
+	return price, nil
+}
+
+// SuggestPriceRange returns a low, medium and high gas price recommendation,
+// taken at the configured percentiles of the recent block prices. If too few
+// blocks could be sampled to tell the tiers apart, all three are set to the
+// price returned by SuggestPrice.
+func (gpo *Oracle) SuggestPriceRange(ctx context.Context) (low, med, high *big.Int, err error) {
+	head, _ := gpo.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	headHash := head.Hash()
+
+	gpo.cacheLock.RLock()
+	lastRange, lastRangeAt := gpo.lastRange, gpo.lastRangeAt
+	gpo.cacheLock.RUnlock()
+	if headHash == lastRangeAt {
+		return lastRange[0], lastRange[1], lastRange[2], nil
+	}
+	price, err := gpo.SuggestPrice(ctx)
+	if err != nil {
+		return price, price, price, err
+	}
+	gpo.fetchLock.Lock()
+	defer gpo.fetchLock.Unlock()
+
+	blockPrices, err := gpo.blockPrices(ctx, head)
+	if err != nil {
+		return price, price, price, err
+	}
+	low, med, high = price, price, price
+	if len(blockPrices) >= minRangeSamples {
+		low = gpo.bound(percentilePrice(blockPrices, gpo.lowPercentile))
+		med = gpo.bound(percentilePrice(blockPrices, gpo.percentile))
+		high = gpo.bound(percentilePrice(blockPrices, gpo.highPercentile))
+	}
+	gpo.cacheLock.Lock()
+	gpo.lastRange = [3]*big.Int{low, med, high}
+	gpo.lastRangeAt = headHash
+	gpo.cacheLock.Unlock()
+	return low, med, high, nil
+}
+
+// HeaderFeeInfo is the gas usage of a block together with a proxy of the base
+// fee, for fee estimation tooling expecting one. The chain has no protocol base
+// fee, so the proxy is the median of the lowest gas prices paid in the recent
+// blocks up to the block, or zero if none of them carried transactions.
+type HeaderFeeInfo struct {
+	Number       hexutil.Uint64 `json:"number"`
+	GasUsed      hexutil.Uint64 `json:"gasUsed"`
+	GasLimit     hexutil.Uint64 `json:"gasLimit"`
+	GasUsedRatio float64        `json:"gasUsedRatio"`
+	BaseFeeProxy *hexutil.Big   `json:"baseFeePerGas"`
+}
+
+// HeaderFeeInfo returns the fee information of the block with the given number,
+// nil if it is unknown.
+func (gpo *Oracle) HeaderFeeInfo(ctx context.Context, number rpc.BlockNumber) (*HeaderFeeInfo, error) {
+	header, err := gpo.backend.HeaderByNumber(ctx, number)
+	if header == nil || err != nil {
+		return nil, err
+	}
+	info := &HeaderFeeInfo{
+		Number:       hexutil.Uint64(header.Number.Uint64()),
+		GasUsed:      hexutil.Uint64(header.GasUsed),
+		GasLimit:     hexutil.Uint64(header.GasLimit),
+		BaseFeeProxy: (*hexutil.Big)(new(big.Int)),
+	}
+	if header.GasLimit > 0 {
+		info.GasUsedRatio = float64(header.GasUsed) / float64(header.GasLimit)
+	}
+	gpo.fetchLock.Lock()
+	blockPrices, err := gpo.blockPrices(ctx, header)
+	gpo.fetchLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if len(blockPrices) > 0 {
+		info.BaseFeeProxy = (*hexutil.Big)(new(big.Int).Set(percentilePrice(blockPrices, 50)))
+	}
+	return info, nil
+}
+
+// blockPrices samples the lowest gas price of the recent blocks up to head,
+// returning them in ascending order. The fetch lock must be held.
+func (gpo *Oracle) blockPrices(ctx context.Context, head *types.Header) ([]*big.Int, error) {
 	blockNum := head.Number.Uint64()
 	ch := make(chan getBlockPricesResult, gpo.checkBlocks)
 	sent := 0
@@ -121,7 +300,7 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	for exp > 0 {
 		res := <-ch
 		if res.err != nil {
-			return lastPrice, res.err
+			return nil, res.err
 		}
 		exp--
 		if res.price != nil {
@@ -139,25 +318,24 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 			blockNum--
 		}
 	}
-	price := lastPrice
-	if len(blockPrices) > 0 {
-		sort.Sort(bigIntArray(blockPrices))
-		num := (len(blockPrices) - 1) * gpo.percentile / 100
-		price = blockPrices[num]
-	}
+	sort.Sort(bigIntArray(blockPrices))
+	return blockPrices, nil
+}
+
+// bound caps the price to the maximum and raises it to the default price.
+func (gpo *Oracle) bound(price *big.Int) *big.Int {
 	if price.Cmp(maxPrice) > 0 {
 		price = new(big.Int).Set(maxPrice)
 	}
-
 	if price.Cmp(gpo.defaultPrice) < 0 {
 		price = new(big.Int).Set(gpo.defaultPrice)
 	}
+	return price
+}
 
-	gpo.cacheLock.Lock()
-	gpo.lastHead = headHash
-	gpo.lastPrice = price
-	gpo.cacheLock.Unlock()
-	return price, nil
+// percentilePrice picks the price at the given percentile of a sorted list.
+func percentilePrice(prices []*big.Int, percentile int) *big.Int {
+	return prices[(len(prices)-1)*percentile/100]
 }
 
 type getBlockPricesResult struct {
@@ -130,3 +130,202 @@ func TestSuggestPrice(t *testing.T) {
 		t.Fatalf("Gas price mismatch, want %d, got %d", expect, got)
 	}
 }
+
+// priceHistoryBackend serves a synthetic chain where every block contains a
+// single transaction with a predefined gas price.
+type priceHistoryBackend struct {
+	blocks []*types.Block
+}
+
+func newPriceHistoryBackend(t *testing.T, prices []int64) *priceHistoryBackend {
+	var (
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		signer = types.NewTIP1Signer(params.TestChainConfig.ChainID)
+		blocks = []*types.Block{types.NewBlockWithHeader(&types.Header{Number: new(big.Int)})}
+	)
+	for i, price := range prices {
+		tx, err := types.SignTx(types.NewTransaction(uint64(i), common.HexToAddress("deadbeef"), big.NewInt(100), 21000, big.NewInt(price), nil), signer, key)
+		if err != nil {
+			t.Fatalf("failed to create tx: %v", err)
+		}
+		header := &types.Header{ParentHash: blocks[i].Hash(), Number: big.NewInt(int64(i + 1))}
+		blocks = append(blocks, types.NewBlock(header, []*types.Transaction{tx}, nil, nil, nil))
+	}
+	return &priceHistoryBackend{blocks: blocks}
+}
+
+func (b *priceHistoryBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	block, err := b.BlockByNumber(ctx, number)
+	if block == nil {
+		return nil, err
+	}
+	return block.Header(), nil
+}
+
+func (b *priceHistoryBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number == rpc.LatestBlockNumber {
+		return b.blocks[len(b.blocks)-1], nil
+	}
+	if int(number) >= len(b.blocks) {
+		return nil, nil
+	}
+	return b.blocks[number], nil
+}
+
+func (b *priceHistoryBackend) ChainConfig() *params.ChainConfig {
+	return params.TestChainConfig
+}
+
+func TestSuggestPriceRange(t *testing.T) {
+	config := Config{
+		Blocks:         10,
+		Percentile:     50,
+		LowPercentile:  10,
+		HighPercentile: 90,
+		Default:        big.NewInt(params.Babbage),
+	}
+	// Blocks carry the prices 1G..10G, in shuffled order
+	var prices []int64
+	for _, p := range []int64{4, 9, 1, 7, 10, 2, 6, 3, 8, 5} {
+		prices = append(prices, p*params.GWei)
+	}
+	oracle := NewOracle(newPriceHistoryBackend(t, prices), config)
+
+	low, med, high, err := oracle.SuggestPriceRange(context.Background())
+	if err != nil {
+		t.Fatalf("failed to retrieve price range: %v", err)
+	}
+	if low.Cmp(med) > 0 || med.Cmp(high) > 0 {
+		t.Fatalf("price tiers not ordered: low %v, med %v, high %v", low, med, high)
+	}
+	// With 10 sorted samples the percentiles pick indices 0, 4 and 8
+	for _, tier := range []struct {
+		name       string
+		have, want *big.Int
+	}{
+		{"low", low, big.NewInt(1 * params.GWei)},
+		{"med", med, big.NewInt(5 * params.GWei)},
+		{"high", high, big.NewInt(9 * params.GWei)},
+	} {
+		if tier.have.Cmp(tier.want) != 0 {
+			t.Errorf("%s price mismatch: have %v, want %v", tier.name, tier.have, tier.want)
+		}
+	}
+	suggested, err := oracle.SuggestPrice(context.Background())
+	if err != nil {
+		t.Fatalf("failed to retrieve suggested price: %v", err)
+	}
+	if suggested.Cmp(med) != 0 {
+		t.Errorf("medium tier differs from suggested price: have %v, want %v", med, suggested)
+	}
+}
+
+func TestSuggestPriceRangeSparse(t *testing.T) {
+	config := Config{
+		Blocks:         10,
+		Percentile:     50,
+		LowPercentile:  10,
+		HighPercentile: 90,
+		Default:        big.NewInt(params.Babbage),
+	}
+	oracle := NewOracle(newPriceHistoryBackend(t, []int64{2 * params.GWei, 8 * params.GWei}), config)
+
+	low, med, high, err := oracle.SuggestPriceRange(context.Background())
+	if err != nil {
+		t.Fatalf("failed to retrieve price range: %v", err)
+	}
+	suggested, _ := oracle.SuggestPrice(context.Background())
+	if low.Cmp(suggested) != 0 || med.Cmp(suggested) != 0 || high.Cmp(suggested) != 0 {
+		t.Fatalf("sparse tiers mismatch: have %v/%v/%v, want all %v", low, med, high, suggested)
+	}
+}
+
+func TestHeaderFeeInfo(t *testing.T) {
+	config := Config{
+		Blocks:     20,
+		Percentile: 60,
+		Default:    big.NewInt(500 * params.Shannon),
+	}
+	backend := newTestBackend(t)
+	oracle := NewOracle(backend, config)
+
+	for _, number := range []rpc.BlockNumber{0, 10, rpc.LatestBlockNumber} {
+		info, err := oracle.HeaderFeeInfo(context.Background(), number)
+		if err != nil {
+			t.Fatalf("block %d: failed to retrieve fee info: %v", number, err)
+		}
+		header, _ := backend.HeaderByNumber(context.Background(), number)
+		if uint64(info.Number) != header.Number.Uint64() || uint64(info.GasUsed) != header.GasUsed || uint64(info.GasLimit) != header.GasLimit {
+			t.Errorf("block %d: gas mismatch: have %d/%d, want %d/%d", number, info.GasUsed, info.GasLimit, header.GasUsed, header.GasLimit)
+		}
+		if want := float64(header.GasUsed) / float64(header.GasLimit); info.GasUsedRatio != want {
+			t.Errorf("block %d: gas used ratio mismatch: have %f, want %f", number, info.GasUsedRatio, want)
+		}
+		if info.BaseFeeProxy.ToInt().Sign() < 0 {
+			t.Errorf("block %d: negative base fee proxy %v", number, info.BaseFeeProxy.ToInt())
+		}
+		// Every non-genesis block pays at least the lowest price
+		want := big.NewInt(params.Babbage)
+		if number == 0 {
+			want = new(big.Int)
+		}
+		if info.BaseFeeProxy.ToInt().Cmp(want) != 0 {
+			t.Errorf("block %d: base fee proxy mismatch: have %v, want %v", number, info.BaseFeeProxy.ToInt(), want)
+		}
+	}
+	if info, err := oracle.HeaderFeeInfo(context.Background(), 1000); info != nil || err != nil {
+		t.Errorf("unknown block: have %v, %v, want nil", info, err)
+	}
+}
+
+func TestSetPercentile(t *testing.T) {
+	config := Config{
+		Blocks:     10,
+		Percentile: 10,
+		Default:    big.NewInt(params.Babbage),
+	}
+	var prices []int64
+	for _, p := range []int64{4, 9, 1, 7, 10, 2, 6, 3, 8, 5} {
+		prices = append(prices, p*params.GWei)
+	}
+	oracle := NewOracle(newPriceHistoryBackend(t, prices), config)
+
+	low, err := oracle.SuggestPrice(context.Background())
+	if err != nil {
+		t.Fatalf("failed to retrieve price: %v", err)
+	}
+	// A higher percentile raises the suggestion over the same head
+	if err := oracle.SetPercentile(90); err != nil {
+		t.Fatalf("failed to set percentile: %v", err)
+	}
+	high, err := oracle.SuggestPrice(context.Background())
+	if err != nil {
+		t.Fatalf("failed to retrieve price: %v", err)
+	}
+	if high.Cmp(low) <= 0 {
+		t.Fatalf("suggestion not raised: have %v, low percentile %v", high, low)
+	}
+	if want := big.NewInt(9 * params.GWei); high.Cmp(want) != 0 {
+		t.Errorf("high percentile price mismatch: have %v, want %v", high, want)
+	}
+	// The price range follows the new percentile
+	if _, med, _, err := oracle.SuggestPriceRange(context.Background()); err != nil || med.Cmp(high) != 0 {
+		t.Errorf("medium tier mismatch: have %v (%v), want %v", med, err, high)
+	}
+	// Narrowing the window to the last block only samples its price
+	if err := oracle.SetBlocks(1); err != nil {
+		t.Fatalf("failed to set blocks: %v", err)
+	}
+	if price, err := oracle.SuggestPrice(context.Background()); err != nil || price.Cmp(big.NewInt(5*params.GWei)) != 0 {
+		t.Errorf("single block price mismatch: have %v (%v), want %v", price, err, 5*params.GWei)
+	}
+	// Out of range settings are refused
+	for _, percent := range []int{-1, 101} {
+		if err := oracle.SetPercentile(percent); err != ErrInvalidPercentile {
+			t.Errorf("percentile %d: error mismatch: have %v, want %v", percent, err, ErrInvalidPercentile)
+		}
+	}
+	if err := oracle.SetBlocks(0); err != ErrInvalidBlocks {
+		t.Errorf("blocks error mismatch: have %v, want %v", err, ErrInvalidBlocks)
+	}
+}
@@ -4,6 +4,7 @@ package abey
 
 import (
 	"math/big"
+	"time"
 
 	"github.com/AbeyFoundation/go-abey/common"
 	"github.com/AbeyFoundation/go-abey/common/hexutil"
@@ -22,6 +23,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		SyncMode                downloader.SyncMode
 		LightServ               int           `toml:",omitempty"`
 		LightPeers              int           `toml:",omitempty"`
+		LightHeaderCache        int           `toml:",omitempty"`
+		LightMaxHeadAge         time.Duration `toml:",omitempty"`
+		LightCacheBudget        uint64        `toml:",omitempty"`
 		EnableElection          bool          `toml:",omitempty"`
 		CommitteeKey            hexutil.Bytes `toml:",omitempty"`
 		Host                    string        `toml:",omitempty"`
@@ -38,6 +42,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		TxPool                  core.TxPoolConfig
 		GPO                     gasprice.Config
 		EnablePreimageRecording bool
+		RPCLogsLimit            int    `toml:",omitempty"`
+		RPCGasCap               uint64 `toml:",omitempty"`
+		StateSnapshotAccounts   int    `toml:",omitempty"`
 		DocRoot                 string `toml:"-"`
 	}
 	var enc Config
@@ -46,6 +53,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.SyncMode = c.SyncMode
 	enc.LightServ = c.LightServ
 	enc.LightPeers = c.LightPeers
+	enc.LightHeaderCache = c.LightHeaderCache
+	enc.LightMaxHeadAge = c.LightMaxHeadAge
+	enc.LightCacheBudget = c.LightCacheBudget
 	enc.EnableElection = c.EnableElection
 	enc.CommitteeKey = c.CommitteeKey
 	enc.Host = c.Host
@@ -62,6 +72,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.TxPool = c.TxPool
 	enc.GPO = c.GPO
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
+	enc.RPCLogsLimit = c.RPCLogsLimit
+	enc.RPCGasCap = c.RPCGasCap
+	enc.StateSnapshotAccounts = c.StateSnapshotAccounts
 	enc.DocRoot = c.DocRoot
 	return &enc, nil
 }
@@ -78,6 +91,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		StandbyPort             *int           `toml:",omitempty"`
 		LightServ               *int           `toml:",omitempty"`
 		LightPeers              *int           `toml:",omitempty"`
+		LightHeaderCache        *int           `toml:",omitempty"`
+		LightMaxHeadAge         *time.Duration `toml:",omitempty"`
+		LightCacheBudget        *uint64        `toml:",omitempty"`
 		SkipBcVersionCheck      *bool          `toml:"-"`
 		DatabaseHandles         *int           `toml:"-"`
 		DatabaseCache           *int
@@ -89,6 +105,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		TxPool                  *core.TxPoolConfig
 		GPO                     *gasprice.Config
 		EnablePreimageRecording *bool
+		RPCLogsLimit            *int    `toml:",omitempty"`
+		RPCGasCap               *uint64 `toml:",omitempty"`
+		StateSnapshotAccounts   *int    `toml:",omitempty"`
 		DocRoot                 *string `toml:"-"`
 	}
 	var dec Config
@@ -125,6 +144,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.LightPeers != nil {
 		c.LightPeers = *dec.LightPeers
 	}
+	if dec.LightHeaderCache != nil {
+		c.LightHeaderCache = *dec.LightHeaderCache
+	}
+	if dec.LightMaxHeadAge != nil {
+		c.LightMaxHeadAge = *dec.LightMaxHeadAge
+	}
+	if dec.LightCacheBudget != nil {
+		c.LightCacheBudget = *dec.LightCacheBudget
+	}
 	if dec.SkipBcVersionCheck != nil {
 		c.SkipBcVersionCheck = *dec.SkipBcVersionCheck
 	}
@@ -158,6 +186,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.EnablePreimageRecording != nil {
 		c.EnablePreimageRecording = *dec.EnablePreimageRecording
 	}
+	if dec.RPCLogsLimit != nil {
+		c.RPCLogsLimit = *dec.RPCLogsLimit
+	}
+	if dec.RPCGasCap != nil {
+		c.RPCGasCap = *dec.RPCGasCap
+	}
+	if dec.StateSnapshotAccounts != nil {
+		c.StateSnapshotAccounts = *dec.StateSnapshotAccounts
+	}
 	if dec.DocRoot != nil {
 		c.DocRoot = *dec.DocRoot
 	}
@@ -62,11 +62,8 @@ func newTestProtocolManager(mode downloader.SyncMode, blocks int, sBlocks int, g
 			Alloc:      types.GenesisAlloc{testBank: {Balance: big.NewInt(1000000000)}},
 			Difficulty: big.NewInt(20000),
 		}
-		genesis       = gspec.MustFastCommit(db)
-		blockchain, _ = core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
-
-		snailGenesis  = gspec.MustSnailCommit(db)
-		snailChain, _ = snailchain.NewSnailBlockChain(db, gspec.Config, engine, blockchain)
+		genesis      = gspec.MustFastCommit(db)
+		snailGenesis = gspec.MustSnailCommit(db)
 
 		priKey, _     = crypto.GenerateKey()
 		coinbase      = crypto.PubkeyToAddress(priKey.PublicKey) //coinbase
@@ -84,14 +81,22 @@ func newTestProtocolManager(mode downloader.SyncMode, blocks int, sBlocks int, g
 	)
 	params.MinimumFruits = 60
 	params.MinTimeGap = big.NewInt(0)
+	blockchain, err := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
+	if err != nil {
+		return nil, nil, err
+	}
+	snailChain, err := snailchain.NewSnailBlockChain(db, gspec.Config, engine, blockchain)
+	if err != nil {
+		return nil, nil, err
+	}
 	chain, _ := core.GenerateChain(gspec.Config, genesis, engine, db, blocks, generator)
 	if _, err := blockchain.InsertChain(chain); err != nil {
-		panic(err)
+		return nil, nil, err
 	}
 
 	schain := snailchain.GenerateChain(gspec.Config, blockchain, []*types.SnailBlock{snailGenesis}, sBlocks, 7, snailGenerator)
 	if _, err := snailChain.InsertChain(schain); err != nil {
-		panic(err)
+		return nil, nil, err
 	}
 
 	//snailPool	abey.snailblockchain
@@ -1285,12 +1285,8 @@ func (env *AgentWork) commitTransactions(mux *event.TypeMux, txs *types.Transact
 		//
 		// We use the eip155 signer regardless of the current hf.
 		from, _ := types.Sender(env.signer, tx)
-		txhash := tx.HashOld()
-		if env.config.IsTIP10(env.header.Number) {
-			txhash = tx.Hash()
-		}
 		// Start executing the transaction
-		env.state.Prepare(txhash, common.Hash{}, env.tcount)
+		env.state.Prepare(core.CanonicalTxHash(env.config, env.header.Number, tx), common.Hash{}, env.tcount)
 
 		logs, err := env.commitTransaction(tx, bc, env.gasPool, feeAmount)
 		switch err {
@@ -123,3 +123,38 @@ func TestFullSync(t *testing.T) {
 	time.Sleep(250 * time.Millisecond)
 	pmEmpty.synchronise(pmEmpty.peers.BestPeer())
 }
+
+// Tests that the sync progress reported by the backend covers both the fast
+// and the snail chain, and that both advance while synchronising.
+func TestSyncProgress(t *testing.T) {
+	pmEmpty, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, 0, nil, nil, nil, nil)
+	defer pmEmpty.Stop()
+	// Generate enough fast blocks to fill the fruits of the snail blocks
+	pmFull, _ := newTestProtocolManagerMust(t, downloader.FullSync, 3*60+1, 2, nil, nil, nil, nil)
+	defer pmFull.Stop()
+
+	backend := &ABEYAPIBackend{abey: &Abeychain{protocolManager: pmEmpty}}
+	before := backend.SyncProgress()
+	if before.CurrentFastBlock != 0 || before.CurrentSnailBlock != 0 {
+		t.Fatalf("progress before sync: have fast %d, snail %d, want 0, 0", before.CurrentFastBlock, before.CurrentSnailBlock)
+	}
+	// Sync up the two peers
+	io1, io2 := p2p.MsgPipe()
+
+	go pmFull.handle(pmFull.newPeer(63, p2p.NewPeer(enode.ID{0}, "empty", nil), io2))
+	go pmEmpty.handle(pmEmpty.newPeer(63, p2p.NewPeer(enode.ID{1}, "full", nil), io1))
+
+	time.Sleep(250 * time.Millisecond)
+	pmEmpty.synchronise(pmEmpty.peers.BestPeer())
+
+	after := backend.SyncProgress()
+	if head := pmEmpty.snailchain.CurrentBlock().NumberU64(); after.CurrentSnailBlock != head || head <= before.CurrentSnailBlock {
+		t.Errorf("snail progress mismatch: have %d, want %d above %d", after.CurrentSnailBlock, head, before.CurrentSnailBlock)
+	}
+	if head := pmEmpty.blockchain.CurrentBlock().NumberU64(); after.CurrentFastBlock != head || head <= before.CurrentFastBlock {
+		t.Errorf("fast progress mismatch: have %d, want %d above %d", after.CurrentFastBlock, head, before.CurrentFastBlock)
+	}
+	if after.HighestSnailBlock < after.CurrentSnailBlock {
+		t.Errorf("highest snail block %d below current %d", after.HighestSnailBlock, after.CurrentSnailBlock)
+	}
+}
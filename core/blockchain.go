@@ -51,6 +51,8 @@ var (
 	blockExecutionTimer  = metrics.NewRegisteredTimer("chain/execution", nil)
 	blockWriteTimer      = metrics.NewRegisteredTimer("chain/write", nil)
 
+	blockReorgDepthHist = metrics.NewRegisteredHistogram("chain/reorg/depth", nil, metrics.NewExpDecaySample(1028, 0.015))
+
 	ErrNoGenesis = errors.New("Genesis not found in chain")
 )
 
@@ -110,6 +112,7 @@ type BlockChain struct {
 	rmLogsFeed       event.Feed
 	chainFeed        event.Feed
 	chainSideFeed    event.Feed
+	reorgFeed        event.Feed
 	chainHeadFeed    event.Feed
 	logsFeed         event.Feed
 	blockProcFeed    event.Feed
@@ -155,11 +158,15 @@ type BlockChain struct {
 
 // NewBlockChain returns a fully initialised block chain using information
 // available in the database. It initialises the default Ethereum Validator and
-// Processor.
+// Processor. A vmConfig naming an unregistered interpreter is refused with
+// vm.ErrUnknownInterpreter.
 func NewBlockChain(db abeydb.Database, cacheConfig *CacheConfig,
 	chainConfig *params.ChainConfig, engine consensus.Engine,
 	vmConfig vm.Config) (*BlockChain, error) {
 
+	if err := vmConfig.CheckInterpreter(); err != nil {
+		return nil, err
+	}
 	if cacheConfig == nil {
 		cacheConfig = &CacheConfig{
 			Deleted:        false,
@@ -918,11 +925,7 @@ func SetReceiptsData(config *params.ChainConfig, block *types.Block, receipts ty
 
 	for j := 0; j < len(receipts); j++ {
 		// The transaction hash can be retrieved from the transaction itself
-		if config.IsTIP10(block.Number()) {
-			receipts[j].TxHash = transactions[j].Hash()
-		} else {
-			receipts[j].TxHash = transactions[j].HashOld()
-		}
+		receipts[j].TxHash = CanonicalTxHash(config, block.Number(), transactions[j])
 		// block location fields
 		receipts[j].BlockHash = block.Hash()
 		receipts[j].BlockNumber = block.Number()
@@ -1069,8 +1072,11 @@ func (bc *BlockChain) WriteBlockWithoutState(block *types.Block) (err error) {
 	return nil
 }
 
-// WriteBlockWithState writes the block and all associated state to the database.
-func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.Receipt, state *state.StateDB) (status WriteStatus, err error) {
+// WriteBlockWithState writes the block and all associated state to the database,
+// along with the coins issued up to it given the rewards paid out by the block.
+// The logs removed from the canonical chain if the block caused a reorg are
+// returned, to be announced after the chain lock is released.
+func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.Receipt, state *state.StateDB, infos *types.ChainReward) (status WriteStatus, removedLogs []*types.Log, err error) {
 	bc.wg.Add(1)
 	defer bc.wg.Done()
 
@@ -1097,7 +1103,7 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 
 	root, err := state.Commit(true)
 	if err != nil {
-		return NonStatTy, err
+		return NonStatTy, nil, err
 	}
 	triedb := bc.stateCache.TrieDB()
 
@@ -1110,7 +1116,7 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 		rawdb.WriteBalanceInfo(bc.db, block.Number().Uint64(), balanceC)
 
 		if err := triedb.Commit(root, false); err != nil {
-			return NonStatTy, err
+			return NonStatTy, nil, err
 		}
 	} else {
 		// Full but not archive node, do proper garbage collection
@@ -1158,17 +1164,18 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	rawdb.WriteReceipts(batch, block.Hash(), block.NumberU64(), receipts)
 
 	if block.ParentHash() != currentBlock.Hash() {
-		if err := bc.reorg(currentBlock, block); err != nil {
-			return NonStatTy, err
+		if removedLogs, err = bc.reorg(currentBlock, block); err != nil {
+			return NonStatTy, nil, err
 		}
 	}
 	// Write the positional metadata for transaction/receipt lookups and preimages
 	rawdb.WriteTxLookupEntries2(batch, block, bc.chainConfig.TIP10.FastNumber)
 	rawdb.WritePreimages(batch, block.NumberU64(), state.Preimages())
+	bc.writeIssuance(batch, block, infos)
 
 	status = CanonStatTy
 	if err := batch.Write(); err != nil {
-		return NonStatTy, err
+		return NonStatTy, nil, err
 	}
 
 	if bc.cacheConfig.Deleted {
@@ -1180,7 +1187,7 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 
 	bc.insert(block)
 	bc.futureBlocks.Remove(block.Hash())
-	return status, nil
+	return status, removedLogs, nil
 }
 
 // addFutureBlock checks if the block is within the max allowed window to get
@@ -1349,11 +1356,14 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, []
 		proctime := time.Since(start)
 
 		// Write the block to the chain and get the status.
-		status, err := bc.writeBlockWithState(block, receipts, state)
+		status, removedLogs, err := bc.writeBlockWithState(block, receipts, state, infos)
 		t3 := time.Now()
 		if err != nil {
 			return it.index, events, coalescedLogs, err
 		}
+		if len(removedLogs) > 0 {
+			events = append(events, types.RemovedLogsEvent{Logs: removedLogs})
+		}
 		bc.engine.FinalizeCommittee(block)
 		if infos != nil {
 			bc.WriteRewardInfos(infos)
@@ -1510,9 +1520,12 @@ func (bc *BlockChain) insertSidechain(it *insertIterator) (int, []interface{}, [
 
 // reorgs takes two blocks, an old chain and a new chain and will reconstruct the blocks and inserts them
 // to be part of the new canonical chain and accumulates potential missing transactions and post an
-// event about them
-func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
+// event about them. The logs of the dropped blocks are returned for the caller to announce as removed
+// once the chain lock is released.
+func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) ([]*types.Log, error) {
 	var (
+		oldHead     = oldBlock
+		newHead     = newBlock
 		newChain    types.Blocks
 		oldChain    types.Blocks
 		commonBlock *types.Block
@@ -1555,10 +1568,10 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		}
 	}
 	if oldBlock == nil {
-		return fmt.Errorf("Invalid old chain")
+		return nil, fmt.Errorf("Invalid old chain")
 	}
 	if newBlock == nil {
-		return fmt.Errorf("Invalid new chain")
+		return nil, fmt.Errorf("Invalid new chain")
 	}
 
 	for {
@@ -1577,10 +1590,10 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 
 		oldBlock, newBlock = bc.GetBlock(oldBlock.ParentHash(), oldBlock.NumberU64()-1), bc.GetBlock(newBlock.ParentHash(), newBlock.NumberU64()-1)
 		if oldBlock == nil {
-			return fmt.Errorf("Invalid old chain")
+			return nil, fmt.Errorf("Invalid old chain")
 		}
 		if newBlock == nil {
-			return fmt.Errorf("Invalid new chain")
+			return nil, fmt.Errorf("Invalid new chain")
 		}
 	}
 	// Ensure the user sees large reorgs
@@ -1613,35 +1626,38 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 	batch := bc.db.NewBatch()
 	for _, tx := range diff {
 		if h, ok := tmp[tx.Hash()]; ok {
-			if bc.chainConfig.IsTIP10(big.NewInt(int64(h))) {
-				rawdb.DeleteTxLookupEntry(batch, tx.Hash())
-			} else {
-				rawdb.DeleteTxLookupEntry(batch, tx.HashOld())
-			}
+			rawdb.DeleteTxLookupEntry(batch, CanonicalTxHash(bc.chainConfig, new(big.Int).SetUint64(h), tx))
 		} else {
 			rawdb.DeleteTxLookupEntry(batch, tx.Hash())
 		}
 	}
 	batch.Write()
 
-	if len(deletedLogs) > 0 {
-		go bc.rmLogsFeed.Send(types.RemovedLogsEvent{Logs: deletedLogs})
-	}
 	if len(oldChain) > 0 {
+		blockReorgDepthHist.Update(int64(len(oldChain)))
+
+		reorg := types.ReorgEvent{OldHead: oldHead, NewHead: newHead, Ancestor: commonBlock, Depth: len(oldChain)}
 		go func() {
 			for _, block := range oldChain {
 				bc.chainSideFeed.Send(types.FastChainSideEvent{Block: block})
 			}
+			bc.reorgFeed.Send(reorg)
 		}()
 	}
 
-	return nil
+	return deletedLogs, nil
 }
 
 // PostChainEvents iterates over the events generated by a chain insertion and
 // posts them into the event feed.
 // TODO: Should not expose PostChainEvents. The chain events should be posted in WriteBlock.
 func (bc *BlockChain) postChainEvents(events []interface{}, logs []*types.Log) {
+	// post the logs removed by reorgs ahead of the logs of the new chain
+	for _, event := range events {
+		if ev, ok := event.(types.RemovedLogsEvent); ok {
+			bc.rmLogsFeed.Send(ev)
+		}
+	}
 	// post event logs for further processing
 	if logs != nil {
 		bc.logsFeed.Send(logs)
@@ -1825,6 +1841,12 @@ func (bc *BlockChain) SubscribeChainSideEvent(ch chan<- types.FastChainSideEvent
 	return bc.scope.Track(bc.chainSideFeed.Subscribe(ch))
 }
 
+// SubscribeReorgEvent registers a subscription of types.ReorgEvent, delivered
+// once the side events of the dropped blocks have been sent.
+func (bc *BlockChain) SubscribeReorgEvent(ch chan<- types.ReorgEvent) event.Subscription {
+	return bc.scope.Track(bc.reorgFeed.Subscribe(ch))
+}
+
 // SubscribeLogsEvent registers a subscription of []*types.Log.
 func (bc *BlockChain) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription {
 	return bc.scope.Track(bc.logsFeed.Subscribe(ch))
@@ -1871,10 +1893,7 @@ func (bc *BlockChain) stateGcBodyAndReceipt(gcNumber uint64) {
 		block := bc.GetBlockByNumber(gcNumber + i)
 		if bc.HasBlock(block.Hash(), block.NumberU64()) {
 			for _, tx := range block.Transactions() {
-				h := tx.HashOld()
-				if bc.chainConfig.IsTIP10(block.Number()) {
-					h = tx.Hash()
-				}
+				h := CanonicalTxHash(bc.chainConfig, block.Number(), tx)
 				if rawdb.HasTxLookupEntry(bc.db, h) {
 					rawdb.DeleteTxLookupEntry(bc.db, h)
 				}
@@ -1918,6 +1937,31 @@ func (bc *BlockChain) WriteRewardInfos(infos *types.ChainReward) error {
 	return nil
 }
 
+// writeIssuance stores the amount of coins minted up to the block, adding the
+// rewards paid out by the block to the running total of its parent. Nothing is
+// stored if the total of the parent is unknown, as for blocks imported before
+// the totals were tracked or by fast sync.
+func (bc *BlockChain) writeIssuance(db rawdb.DatabaseWriter, block *types.Block, infos *types.ChainReward) {
+	issuance := bc.GetIssuance(block.ParentHash(), block.NumberU64()-1)
+	if issuance == nil {
+		return
+	}
+	if infos != nil {
+		issuance.Add(issuance, infos.Total())
+	}
+	rawdb.WriteIssuance(db, block.Hash(), block.NumberU64(), issuance)
+}
+
+// GetIssuance retrieves the amount of coins minted by the rewards of the chain up
+// to and including the given block, or nil if it was not tracked. Coins allocated
+// in the genesis block do not count as issued.
+func (bc *BlockChain) GetIssuance(hash common.Hash, number uint64) *big.Int {
+	if number == 0 {
+		return new(big.Int)
+	}
+	return rawdb.ReadIssuance(bc.db, hash, number)
+}
+
 func (bc *BlockChain) GetBalanceInfos(number uint64) *types.BlockBalance {
 	// Short circuit if the td's already in the cache, retrieve otherwise
 	cached, ok := bc.balanceInfoCache.Get(number)
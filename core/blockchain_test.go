@@ -30,6 +30,7 @@ import (
 	"math/big"
 	"sync"
 	"testing"
+	"time"
 )
 
 // So we can deterministically seed different blockchains
@@ -401,6 +402,120 @@ func TestCanonicalBlockRetrieval(t *testing.T) {
 	pend.Wait()
 }
 
+// Tests that a reorganisation is announced with the number of blocks dropped
+// and the common ancestor of the two chains.
+func TestReorgEvent(t *testing.T) {
+	engine := ethash.NewFaker()
+
+	db, blockchain, err := newCanonical(engine, 5, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	var (
+		oldHead  = blockchain.CurrentBlock()
+		ancestor = blockchain.GetBlockByNumber(2)
+		fork     = makeBlockChain(ancestor, 4, engine, db, forkSeed)
+	)
+	events := make(chan types.ReorgEvent, 2)
+	sub := blockchain.SubscribeReorgEvent(events)
+	defer sub.Unsubscribe()
+
+	if _, err := blockchain.InsertChain(fork); err != nil {
+		t.Fatalf("failed to insert fork: %v", err)
+	}
+	if head := blockchain.CurrentBlock(); head.Hash() != fork[len(fork)-1].Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", head.Hash(), fork[len(fork)-1].Hash())
+	}
+	select {
+	case ev := <-events:
+		if ev.Depth != 3 {
+			t.Errorf("reorg depth mismatch: have %d, want 3", ev.Depth)
+		}
+		if ev.Ancestor.Hash() != ancestor.Hash() {
+			t.Errorf("ancestor mismatch: have %x, want %x", ev.Ancestor.Hash(), ancestor.Hash())
+		}
+		if ev.OldHead.Hash() != oldHead.Hash() || ev.NewHead.Hash() != fork[0].Hash() {
+			t.Errorf("heads mismatch: have %x -> %x, want %x -> %x", ev.OldHead.Hash(), ev.NewHead.Hash(), oldHead.Hash(), fork[0].Hash())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("reorg event not delivered")
+	}
+	// Extending the new head is no reorganisation
+	select {
+	case ev := <-events:
+		t.Errorf("unexpected reorg event of depth %d", ev.Depth)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// rewardingProcessor is a block processor reporting a fixed reward paid to the
+// block miner on top of the results of the wrapped processor.
+type rewardingProcessor struct {
+	Processor
+	reward *big.Int
+}
+
+func (p *rewardingProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, *types.ChainReward, error) {
+	receipts, logs, usedGas, _, err := p.Processor.Process(block, statedb, cfg)
+	infos := types.NewChainReward(block.NumberU64(), block.Time().Uint64(), &types.RewardInfo{Address: block.Coinbase(), Amount: new(big.Int).Set(p.reward)}, nil, nil)
+	return receipts, logs, usedGas, infos, err
+}
+
+// Tests that the coins minted by the chain rewards are totalled block by block.
+func TestIssuance(t *testing.T) {
+	engine := ethash.NewFaker()
+
+	db, blockchain, err := newCanonical(engine, 0, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	reward := big.NewInt(params.Ether)
+	blockchain.SetProcessor(&rewardingProcessor{
+		Processor: NewStateProcessor(blockchain.Config(), blockchain, engine),
+		reward:    reward,
+	})
+	blocks := makeBlockChain(blockchain.Genesis(), 4, engine, db, canonicalSeed)
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	if issuance := blockchain.GetIssuance(blockchain.Genesis().Hash(), 0); issuance == nil || issuance.Sign() != 0 {
+		t.Errorf("genesis issuance mismatch: have %v, want 0", issuance)
+	}
+	for i, block := range blocks {
+		want := new(big.Int).Mul(reward, big.NewInt(int64(i+1)))
+		if issuance := blockchain.GetIssuance(block.Hash(), block.NumberU64()); issuance == nil || issuance.Cmp(want) != 0 {
+			t.Errorf("block %d: issuance mismatch: have %v, want %v", block.NumberU64(), issuance, want)
+		}
+	}
+	// Blocks written with their state directly are totalled the same way
+	parent := blocks[len(blocks)-1]
+	block := makeBlockChain(parent, 1, engine, db, canonicalSeed)[0]
+	statedb, err := state.New(parent.Root(), blockchain.stateCache)
+	if err != nil {
+		t.Fatalf("failed to create state: %v", err)
+	}
+	receipts, _, _, _, err := blockchain.Processor().Process(block, statedb, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to process block: %v", err)
+	}
+	infos := types.NewChainReward(block.NumberU64(), block.Time().Uint64(), &types.RewardInfo{Address: block.Coinbase(), Amount: new(big.Int).Set(reward)}, nil, nil)
+	if _, _, err := blockchain.writeBlockWithState(block, receipts, statedb, infos); err != nil {
+		t.Fatalf("failed to write block: %v", err)
+	}
+	want := new(big.Int).Mul(reward, big.NewInt(int64(len(blocks)+1)))
+	if issuance := blockchain.GetIssuance(block.Hash(), block.NumberU64()); issuance == nil || issuance.Cmp(want) != 0 {
+		t.Errorf("written block issuance mismatch: have %v, want %v", issuance, want)
+	}
+	// Blocks whose parent total is unknown are not tracked
+	if issuance := blockchain.GetIssuance(common.Hash{0x01}, 5); issuance != nil {
+		t.Errorf("unknown block issuance reported: %v", issuance)
+	}
+}
+
 // Tests that importing small side forks doesn't leave junk in the trie database
 // cache (which would eventually cause memory issues).
 func TestTrieForkGC(t *testing.T) {
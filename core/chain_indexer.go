@@ -433,6 +433,20 @@ func (c *ChainIndexer) Sections() (uint64, uint64, common.Hash) {
 	return c.storedSections, c.storedSections*c.sectionSize - 1, c.SectionHead(c.storedSections - 1)
 }
 
+// Progress reports how far the indexer got relative to the chain head with the
+// given number: the number of leading blocks covered by the stored sections, and
+// whether every section completed and confirmed by the head is stored.
+func (c *ChainIndexer) Progress(head uint64) (indexed uint64, ready bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	var sections uint64
+	if head >= c.confirmsReq {
+		sections = (head + 1 - c.confirmsReq) / c.sectionSize
+	}
+	return c.storedSections * c.sectionSize, c.storedSections >= sections
+}
+
 // AddChildIndexer adds a child ChainIndexer that can use the output of this one
 func (c *ChainIndexer) AddChildIndexer(indexer *ChainIndexer) {
 	c.lock.Lock()
@@ -52,4 +52,32 @@ var (
 
 	// ErrGasUintOverflow is returned when calculating gas usage.
 	ErrGasUintOverflow = errors.New("gas uint64 overflow")
+
+	// ErrInvalidTxRange is returned if a transaction range to process does not
+	// lie within the transactions of the block.
+	ErrInvalidTxRange = errors.New("invalid transaction range")
+
+	// ErrUnknownIssuance is returned if the amount of coins minted up to a block
+	// was not tracked, as for blocks imported by fast sync.
+	ErrUnknownIssuance = errors.New("unknown issuance")
+
+	// ErrAccessListNotConverged is returned if the access list of a transaction
+	// keeps changing between generations.
+	ErrAccessListNotConverged = errors.New("access list did not converge")
+
+	// ErrInvalidResolverOutput is returned if a name resolver contract returns
+	// something else than an ABI encoded address.
+	ErrInvalidResolverOutput = errors.New("invalid name resolver output")
+
+	// ErrGasCapExceeded is returned if a simulation asks for more gas than the
+	// configured cap allows.
+	ErrGasCapExceeded = errors.New("gas cap exceeded")
+
+	// ErrTxChainIDMismatch is returned if a transaction was signed for another
+	// chain than the one executing it.
+	ErrTxChainIDMismatch = errors.New("transaction chain id mismatch")
+
+	// ErrUnprotectedTx is returned if a transaction signed without a chain ID is
+	// executed on a chain not allowing unprotected transactions.
+	ErrUnprotectedTx = errors.New("unprotected transaction")
 )
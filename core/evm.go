@@ -35,6 +35,36 @@ type ChainContext interface {
 	GetHeader(common.Hash, uint64) *types.Header
 }
 
+// headerCachingChain is a ChainContext memoizing the headers retrieved from the
+// wrapped chain. It is meant to live for the processing of a single block only,
+// so that the BLOCKHASH lookups of all its transactions share the headers, and
+// is not safe for concurrent use.
+type headerCachingChain struct {
+	ChainContext
+	headers map[common.Hash]*types.Header
+}
+
+// newHeaderCachingChain wraps chain into a header memoizing ChainContext.
+func newHeaderCachingChain(chain ChainContext) *headerCachingChain {
+	return &headerCachingChain{
+		ChainContext: chain,
+		headers:      make(map[common.Hash]*types.Header),
+	}
+}
+
+// GetHeader retrieves a header from the cache, falling back to the wrapped
+// chain. Missing headers are not cached.
+func (c *headerCachingChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header, ok := c.headers[hash]; ok && header.Number.Uint64() == number {
+		return header
+	}
+	header := c.ChainContext.GetHeader(hash, number)
+	if header != nil {
+		c.headers[hash] = header
+	}
+	return header
+}
+
 // NewEVMContext creates a new context for use in the EVM.
 func NewEVMContext(msg Message, header *types.Header, chain ChainContext, difficulty *big.Int, coinbase *common.Address) vm.Context {
 	// If we don't have an explicit author (i.e. not mining), extract from the header
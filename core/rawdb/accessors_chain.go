@@ -314,6 +314,33 @@ func WriteTd(db DatabaseWriter, hash common.Hash, number uint64, td *big.Int) {
 	}
 }
 
+// ReadIssuance retrieves the total amount of coins minted by the rewards of the
+// chain up to and including the given block.
+func ReadIssuance(db DatabaseReader, hash common.Hash, number uint64) *big.Int {
+	data, _ := db.Get(headerMintKey(number, hash))
+	if len(data) == 0 {
+		return nil
+	}
+	issuance := new(big.Int)
+	if err := rlp.Decode(bytes.NewReader(data), issuance); err != nil {
+		log.Error("Invalid block issuance RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return issuance
+}
+
+// WriteIssuance stores the total amount of coins minted up to a block into the
+// database.
+func WriteIssuance(db DatabaseWriter, hash common.Hash, number uint64, issuance *big.Int) {
+	data, err := rlp.EncodeToBytes(issuance)
+	if err != nil {
+		log.Crit("Failed to RLP encode block issuance", "err", err)
+	}
+	if err := db.Put(headerMintKey(number, hash), data); err != nil {
+		log.Crit("Failed to store block issuance", "err", err)
+	}
+}
+
 // HasReceipts verifies the existence of all the transaction receipts belonging
 // to a block.
 func HasReceipts(db DatabaseReader, hash common.Hash, number uint64) bool {
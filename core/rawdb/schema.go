@@ -51,6 +51,7 @@ var (
 	headerHashSuffix   = []byte("n") // headerPrefix + num (uint64 big endian) + headerHashSuffix -> hash
 	headerNumberPrefix = []byte("H") // headerNumberPrefix + hash -> num (uint64 big endian)
 	headerCISuffix     = []byte("c") // headerPrefix + num (uint64 big endian) + hash + headerCISuffix -> committee info
+	headerMintSuffix   = []byte("m") // headerPrefix + num (uint64 big endian) + hash + headerMintSuffix -> issuance
 
 	blockRewardPrefix = []byte("reward-")
 
@@ -104,6 +105,11 @@ func headerTDKey(number uint64, hash common.Hash) []byte {
 	return append(headerKey(number, hash), headerTDSuffix...)
 }
 
+// headerMintKey = headerPrefix + num (uint64 big endian) + hash + headerMintSuffix
+func headerMintKey(number uint64, hash common.Hash) []byte {
+	return append(headerKey(number, hash), headerMintSuffix...)
+}
+
 // headerHashKey = headerPrefix + num (uint64 big endian) + headerHashSuffix
 func headerHashKey(number uint64) []byte {
 	return append(append(headerPrefix, encodeBlockNumber(number)...), headerHashSuffix...)
@@ -0,0 +1,99 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"errors"
+
+	"github.com/AbeyFoundation/go-abey/common"
+	"github.com/AbeyFoundation/go-abey/crypto"
+	"github.com/AbeyFoundation/go-abey/trie"
+)
+
+var (
+	// ErrSnapshotTooLarge is returned if a state holds more accounts than a flat
+	// snapshot is allowed to copy.
+	ErrSnapshotTooLarge = errors.New("state too large for snapshot")
+
+	// errSnapshotRootMismatch is returned if a state is opened with a snapshot of
+	// a different state root.
+	errSnapshotRootMismatch = errors.New("snapshot root mismatch")
+)
+
+// FlatSnapshot is a flat, read-only copy of the accounts of a single state root.
+// A state opened with it resolves accounts with a single map lookup instead of
+// walking the account trie. Storage slots are still read from the storage tries.
+type FlatSnapshot struct {
+	root     common.Hash
+	accounts map[common.Hash][]byte // RLP encoded accounts keyed by address hash
+}
+
+// NewFlatSnapshot iterates over the account trie of root, copying every account
+// into a flat snapshot. The memory of the snapshot grows with the accounts of the
+// state, so ErrSnapshotTooLarge is returned once more than limit accounts are
+// met. A zero limit copies the state whatever its size.
+func NewFlatSnapshot(db Database, root common.Hash, limit int) (*FlatSnapshot, error) {
+	tr, err := db.OpenTrie(root)
+	if err != nil {
+		return nil, err
+	}
+	snap := &FlatSnapshot{
+		root:     root,
+		accounts: make(map[common.Hash][]byte),
+	}
+	it := trie.NewIterator(tr.NodeIterator(nil))
+	for it.Next() {
+		if limit > 0 && len(snap.accounts) == limit {
+			return nil, ErrSnapshotTooLarge
+		}
+		snap.accounts[common.BytesToHash(it.Key)] = common.CopyBytes(it.Value)
+	}
+	if it.Err != nil {
+		return nil, it.Err
+	}
+	return snap, nil
+}
+
+// Root returns the state root the snapshot was taken of.
+func (s *FlatSnapshot) Root() common.Hash {
+	return s.root
+}
+
+// Len returns the number of accounts in the snapshot.
+func (s *FlatSnapshot) Len() int {
+	return len(s.accounts)
+}
+
+// account returns the RLP encoded account of addr, nil if it does not exist.
+func (s *FlatSnapshot) account(addr common.Address) []byte {
+	return s.accounts[crypto.Keccak256Hash(addr[:])]
+}
+
+// NewWithSnapshot creates a new state from a given trie, reading the accounts
+// from snap instead of the account trie. The snapshot must have been taken of
+// the same root.
+func NewWithSnapshot(root common.Hash, db Database, snap *FlatSnapshot) (*StateDB, error) {
+	if snap.Root() != root {
+		return nil, errSnapshotRootMismatch
+	}
+	statedb, err := New(root, db)
+	if err != nil {
+		return nil, err
+	}
+	statedb.snap = snap
+	return statedb, nil
+}
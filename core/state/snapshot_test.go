@@ -0,0 +1,131 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/AbeyFoundation/go-abey/abeydb"
+	"github.com/AbeyFoundation/go-abey/common"
+)
+
+// newSnapshotTestState commits a state with the given number of accounts,
+// returning its database and root.
+func newSnapshotTestState(t testing.TB, accounts int) (Database, common.Hash) {
+	db := NewDatabase(abeydb.NewMemDatabase())
+	statedb, _ := New(common.Hash{}, db)
+	for i := 0; i < accounts; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i + 1)))
+		statedb.AddBalance(addr, big.NewInt(int64(11*i+1)))
+		statedb.SetNonce(addr, uint64(i))
+		if i%3 == 0 {
+			statedb.SetCode(addr, []byte{byte(i), 0xff})
+			statedb.SetState(addr, common.Hash{0x01}, common.BigToHash(big.NewInt(int64(i+1))))
+		}
+	}
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := db.TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	return db, root
+}
+
+func TestFlatSnapshotReads(t *testing.T) {
+	db, root := newSnapshotTestState(t, 100)
+
+	snap, err := NewFlatSnapshot(db, root, 0)
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+	if snap.Len() != 100 {
+		t.Fatalf("snapshot account count mismatch: have %d, want 100", snap.Len())
+	}
+	trieState, _ := New(root, db)
+	snapState, err := NewWithSnapshot(root, db, snap)
+	if err != nil {
+		t.Fatalf("failed to open snapshot state: %v", err)
+	}
+	// Include a few accounts beyond the ones created
+	for i := 0; i < 110; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i + 1)))
+		if trieState.Exist(addr) != snapState.Exist(addr) {
+			t.Fatalf("account %d: existence mismatch", i)
+		}
+		if have, want := snapState.GetBalance(addr), trieState.GetBalance(addr); have.Cmp(want) != 0 {
+			t.Fatalf("account %d: balance mismatch: have %v, want %v", i, have, want)
+		}
+		if have, want := snapState.GetNonce(addr), trieState.GetNonce(addr); have != want {
+			t.Fatalf("account %d: nonce mismatch: have %d, want %d", i, have, want)
+		}
+		if have, want := snapState.GetCode(addr), trieState.GetCode(addr); !bytes.Equal(have, want) {
+			t.Fatalf("account %d: code mismatch: have %x, want %x", i, have, want)
+		}
+		if have, want := snapState.GetState(addr, common.Hash{0x01}), trieState.GetState(addr, common.Hash{0x01}); have != want {
+			t.Fatalf("account %d: storage mismatch: have %x, want %x", i, have, want)
+		}
+	}
+	// Modifications on top of the snapshot must yield the same root as on the trie
+	for _, statedb := range []*StateDB{trieState, snapState} {
+		statedb.AddBalance(common.BigToAddress(big.NewInt(1)), big.NewInt(5))
+		statedb.Suicide(common.BigToAddress(big.NewInt(2)))
+		statedb.SetNonce(common.BigToAddress(big.NewInt(1000)), 7)
+	}
+	if have, want := snapState.IntermediateRoot(false), trieState.IntermediateRoot(false); have != want {
+		t.Fatalf("modified root mismatch: have %x, want %x", have, want)
+	}
+	if snapState.Exist(common.BigToAddress(big.NewInt(2))) {
+		t.Fatalf("suicided account still readable through the snapshot")
+	}
+	// States beyond the account limit are not copied
+	if _, err := NewFlatSnapshot(db, root, 99); err != ErrSnapshotTooLarge {
+		t.Fatalf("oversized state error: have %v, want %v", err, ErrSnapshotTooLarge)
+	}
+	if _, err := NewFlatSnapshot(db, root, 100); err != nil {
+		t.Fatalf("failed to create snapshot at the account limit: %v", err)
+	}
+	// Snapshots only open the state they were taken of
+	if _, err := NewWithSnapshot(common.Hash{0x01}, db, snap); err != errSnapshotRootMismatch {
+		t.Fatalf("root mismatch error: have %v, want %v", err, errSnapshotRootMismatch)
+	}
+}
+
+func BenchmarkBalanceReadTrie(b *testing.B) {
+	db, root := newSnapshotTestState(b, 10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		statedb, _ := New(root, db)
+		statedb.GetBalance(common.BigToAddress(big.NewInt(int64(i%10000 + 1))))
+	}
+}
+
+func BenchmarkBalanceReadSnapshot(b *testing.B) {
+	db, root := newSnapshotTestState(b, 10000)
+	snap, err := NewFlatSnapshot(db, root, 0)
+	if err != nil {
+		b.Fatalf("failed to create snapshot: %v", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		statedb, _ := NewWithSnapshot(root, db, snap)
+		statedb.GetBalance(common.BigToAddress(big.NewInt(int64(i%10000 + 1))))
+	}
+}
@@ -18,6 +18,7 @@
 package state
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math/big"
@@ -68,6 +69,7 @@ func lockedKey(addr common.Address) (h common.Hash) {
 type StateDB struct {
 	db   Database
 	trie Trie
+	snap *FlatSnapshot // Optional flat copy of the accounts of the opened root
 
 	// This map holds 'live' objects, which will get modified while processing a state transition.
 	stateObjects      map[common.Address]*stateObject
@@ -91,6 +93,10 @@ type StateDB struct {
 	preimages      map[common.Hash][]byte
 	balancesChange map[common.Address]*types.BalanceInfo
 
+	// Addresses whose state was accessed since tracking was enabled, nil while
+	// tracking is disabled.
+	accessed map[common.Address]struct{}
+
 	// Journal of state modifications. This is the backbone of
 	// Snapshot and RevertToSnapshot.
 	journal        *journal
@@ -137,6 +143,9 @@ func (self *StateDB) Reset(root common.Hash) error {
 		return err
 	}
 	self.trie = tr
+	if self.snap != nil && self.snap.Root() != root {
+		self.snap = nil
+	}
 	self.stateObjects = make(map[common.Address]*stateObject)
 	self.stateObjectsDirty = make(map[common.Address]struct{})
 	self.thash = common.Hash{}
@@ -433,6 +442,15 @@ func (self *StateDB) SetState(addr common.Address, key, value common.Hash) {
 	}
 }
 
+// SetStorage replaces the entire storage of the account with the given one.
+// The replacement is never committed, it is only meant for call simulations.
+func (self *StateDB) SetStorage(addr common.Address, storage map[common.Hash]common.Hash) {
+	stateObject := self.GetOrNewStateObject(addr)
+	if stateObject != nil {
+		stateObject.SetStorage(storage)
+	}
+}
+
 // Suicide marks the given account as suicided.
 // This clears the account balance.
 //
@@ -478,6 +496,9 @@ func (self *StateDB) deleteStateObject(stateObject *stateObject) {
 
 // Retrieve a state object given by the address. Returns nil if not found.
 func (self *StateDB) getStateObject(addr common.Address) (stateObject *stateObject) {
+	if self.accessed != nil {
+		self.accessed[addr] = struct{}{}
+	}
 	// Prefer 'live' objects.
 	if self.stateObjects != nil {
 		if obj := self.stateObjects[addr]; obj != nil {
@@ -488,10 +509,17 @@ func (self *StateDB) getStateObject(addr common.Address) (stateObject *stateObje
 		}
 	}
 
-	// Load the object from the database.
-	enc, err := self.trie.TryGet(addr[:])
+	// Load the object from the snapshot if available, otherwise the database.
+	var enc []byte
+	if self.snap != nil {
+		enc = self.snap.account(addr)
+	} else {
+		var err error
+		if enc, err = self.trie.TryGet(addr[:]); err != nil {
+			self.setError(err)
+		}
+	}
 	if len(enc) == 0 {
-		self.setError(err)
 		return nil
 	}
 	var data Account
@@ -589,6 +617,27 @@ func (db *StateDB) ForEachStorage(addr common.Address, cb func(key, value common
 	}
 }
 
+// TrackAccesses starts recording the addresses whose state is read or written,
+// discarding any addresses recorded before.
+func (self *StateDB) TrackAccesses() {
+	self.accessed = make(map[common.Address]struct{})
+}
+
+// AccessedAddresses stops recording accesses and returns the addresses whose
+// state was read or written since TrackAccesses, accounts looked up without
+// existing included, sorted by address.
+func (self *StateDB) AccessedAddresses() []common.Address {
+	addrs := make([]common.Address, 0, len(self.accessed))
+	for addr := range self.accessed {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i][:], addrs[j][:]) < 0
+	})
+	self.accessed = nil
+	return addrs
+}
+
 // Copy creates a deep, independent copy of the state.
 // Snapshots of the copied state cannot be applied to the copy.
 func (self *StateDB) Copy() *StateDB {
@@ -599,15 +648,17 @@ func (self *StateDB) Copy() *StateDB {
 	state := &StateDB{
 		db:                self.db,
 		trie:              self.db.CopyTrie(self.trie),
+		snap:              self.snap,
 		stateObjects:      make(map[common.Address]*stateObject, len(self.journal.dirties)),
 		stateObjectsDirty: make(map[common.Address]struct{}, len(self.journal.dirties)),
 		refund:            self.refund,
 		logs:              make(map[common.Hash][]*types.Log, len(self.logs)),
 		logSize:           self.logSize,
 		preimages:         make(map[common.Hash][]byte, len(self.preimages)),
+		balancesChange:    make(map[common.Address]*types.BalanceInfo, len(self.balancesChange)),
 		journal:           newJournal(),
 	}
-	// Copy the dirty states, logs, and preimages
+	// Copy the dirty states, logs, preimages and balance changes
 	for addr := range self.journal.dirties {
 		// As documented [here](https://github.com/AbeyFoundation/go-abey/pull/16485#issuecomment-380438527),
 		// and in the Finalise-method, there is a case where an object is in the journal but not
@@ -638,6 +689,10 @@ func (self *StateDB) Copy() *StateDB {
 	for hash, preimage := range self.preimages {
 		state.preimages[hash] = preimage
 	}
+	for addr, info := range self.balancesChange {
+		cpy := *info
+		state.balancesChange[addr] = &cpy
+	}
 	return state
 }
 
@@ -730,6 +785,12 @@ func (self *StateDB) Prepare(thash, bhash common.Hash, ti int) {
 	self.txIndex = ti
 }
 
+// ResetLogIndex numbers the logs added from now on starting from zero again, for
+// logs added so far not to count towards the block log indices.
+func (self *StateDB) ResetLogIndex() {
+	self.logSize = 0
+}
+
 func (s *StateDB) clearJournalAndRefund() {
 	s.journal = newJournal()
 	s.validRevisions = s.validRevisions[:0]
@@ -17,10 +17,18 @@
 package core
 
 import (
-	//"github.com/AbeyFoundation/go-abey/common"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/AbeyFoundation/go-abey/accounts/abi"
+	"github.com/AbeyFoundation/go-abey/common"
 	"github.com/AbeyFoundation/go-abey/crypto"
 	"github.com/AbeyFoundation/go-abey/metrics"
 	"math"
+	"reflect"
+	"runtime"
+	"sync"
 	"time"
 
 	//"github.com/AbeyFoundation/go-abey/log"
@@ -36,6 +44,14 @@ import (
 var (
 	blockExecutionTxTimer = metrics.NewRegisteredTimer("chain/state/executiontx", nil)
 	blockFinalizeTimer    = metrics.NewRegisteredTimer("chain/state/finalize", nil)
+	applyTxTimer          = metrics.NewRegisteredTimer("chain/state/applytx", nil)
+
+	// blockGasRateGauge tracks the execution throughput of the last non-empty
+	// block processed, in gas per second.
+	blockGasRateGauge = metrics.NewRegisteredGauge("chain/state/gaspersecond", nil)
+
+	emptyBlockCounter    = metrics.NewRegisteredCounter("chain/state/blocks/empty", nil)
+	nonEmptyBlockCounter = metrics.NewRegisteredCounter("chain/state/blocks/nonempty", nil)
 )
 
 // StateProcessor is a basic Processor, which takes care of transitioning
@@ -46,6 +62,43 @@ type StateProcessor struct {
 	config *params.ChainConfig // Chain configuration options
 	bc     *BlockChain         // Canonical block chain
 	engine consensus.Engine    // Consensus engine used for block rewards
+	txHook TxHook              // Optional hook invoked after every applied transaction
+	sysTx  SystemTxFunc        // Optional source of a system transaction run ahead of a block
+
+	feeDistributor FeeDistributor   // Optional accounting of the block fees ahead of the engine
+	senders        SenderCache      // Optional source of already verified transaction senders
+	storageHook    StorageWriteHook // Optional hook invoked for every storage write
+}
+
+// TxHook is called by the StateProcessor after applying the transaction at the
+// given index of a block, with the intermediate state root at that point.
+type TxHook func(index int, root common.Hash)
+
+// StorageWriteHook is called by the StateProcessor for every storage slot of the
+// account addr written by an SSTORE, with the values before and after the write.
+type StorageWriteHook func(addr common.Address, slot, prev, value common.Hash)
+
+// SystemTxIndex is the transaction index of a system transaction, set apart from
+// the indices of the transactions of the block.
+const SystemTxIndex = math.MaxInt32
+
+// SystemTxFunc returns the system transaction to run before the transactions of
+// the block with the given header, or nil if the block has none.
+type SystemTxFunc func(header *types.Header) *types.Transaction
+
+// FeeDistributor accounts for the fees collected from the transactions of a
+// block before the consensus engine finalizes it. DistributeFees may credit or
+// burn any part of the fees in statedb, and returns the remainder left for the
+// engine to distribute. The fees passed in must not be modified.
+type FeeDistributor interface {
+	DistributeFees(header *types.Header, statedb *state.StateDB, fees *big.Int) *big.Int
+}
+
+// SenderCache provides the senders of transactions already recovered and
+// verified elsewhere, such as by the transaction pool on admission. Sender must
+// only report senders derived with a signer equal to the given one.
+type SenderCache interface {
+	Sender(signer types.Signer, hash common.Hash) (common.Address, bool)
 }
 
 // NewStateProcessor initialises a new StateProcessor.
@@ -57,60 +110,456 @@ func NewStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consen
 	}
 }
 
+// SetTxHook installs a hook invoked with the intermediate state root after every
+// transaction processed, allowing to pinpoint the transaction a state root
+// mismatch originates from. A nil hook removes it. The hook must not be changed
+// while blocks are being processed.
+func (fp *StateProcessor) SetTxHook(hook TxHook) {
+	fp.txHook = hook
+}
+
+// SetFeeDistributor installs a distributor invoked with the collected fees of
+// every block before finalization. With a nil distributor, the default, all the
+// fees are handed to the consensus engine. The distributor must not be changed
+// while blocks are being processed.
+func (fp *StateProcessor) SetFeeDistributor(distributor FeeDistributor) {
+	fp.feeDistributor = distributor
+}
+
+// SetSenderCache installs a cache of verified transaction senders consulted
+// before processing a block, sparing the signature recovery of the transactions
+// it knows. Transactions from forbidden addresses are still rejected. With a nil
+// cache, the default, every sender not cached by the transaction itself is
+// recovered. The cache must not be changed while blocks are being processed.
+func (fp *StateProcessor) SetSenderCache(cache SenderCache) {
+	fp.senders = cache
+}
+
+// SetStorageWriteHook installs a hook invoked for every SSTORE executed while
+// processing blocks, letting indexers capture storage writes without executing
+// the blocks again. Writes made by calls reverted later on are reported too, so
+// only the last value reported for a slot in a transaction is reliable if the
+// transaction reverted any call. The hook runs off a tracer, hence is not
+// invoked when processing with a tracer of its own in the vm.Config. With a nil
+// hook, the default, no tracer is installed. The hook must not be changed while
+// blocks are being processed.
+func (fp *StateProcessor) SetStorageWriteHook(hook StorageWriteHook) {
+	fp.storageHook = hook
+}
+
+// vmConfig returns the EVM configuration to process blocks with, tracing the
+// storage writes if a storage write hook is installed.
+func (fp *StateProcessor) vmConfig(cfg vm.Config) vm.Config {
+	if fp.storageHook == nil || cfg.Debug {
+		return cfg
+	}
+	cfg.Debug = true
+	cfg.Tracer = vm.NewStorageWriteTracer(fp.storageHook)
+	return cfg
+}
+
+// SetSystemTx installs a source of system transactions, such as the bookkeeping
+// of a chain upgrade, applied at the start of every block ahead of its user
+// transactions. A system transaction runs on its own gas pool, so it neither
+// consumes the block gas limit nor counts towards the gas used by the block, and
+// any fees it pays are not handed to the consensus engine with the ones of the
+// block. Its receipt and logs carry SystemTxIndex as transaction index, while
+// the logs of the block are indexed from zero as without it. The receipt is kept
+// apart from the ones of the block, available through ProcessWithSystemReceipt.
+// With a nil source, the default, blocks are processed without one. The source
+// must not be changed while blocks are being processed.
+func (fp *StateProcessor) SetSystemTx(source SystemTxFunc) {
+	fp.sysTx = source
+}
+
 // Process processes the state changes according to the Ethereum rules by running
 // the transaction messages using the statedb and applying any rewards to both
 // the processor (coinbase) and any included uncles.
 //
 // Process returns the receipts and logs accumulated during the process and
-// returns the amount of gas that was used in the process. If any of the
-// transactions failed to execute due to insufficient gas it will return an error.
+// returns the amount of gas that was used in the process. The gas consumed by
+// each individual transaction is available through the GasUsed field of its
+// receipt. If any of the transactions failed to execute due to insufficient
+// gas it will return an error.
 func (fp *StateProcessor) Process(block *types.Block, statedb *state.StateDB,
 	cfg vm.Config) (types.Receipts, []*types.Log, uint64, *types.ChainReward, error) {
+	return fp.ProcessWithContext(context.Background(), block, statedb, cfg)
+}
+
+// ProcessTouched processes the block like Process, additionally returning the
+// sorted addresses of the accounts whose state was read or written during the
+// block, including the ones touched by the consensus engine when finalizing.
+// Accounts looked up without existing are reported as well. Process itself
+// does not track the accesses and is unaffected.
+func (fp *StateProcessor) ProcessTouched(block *types.Block, statedb *state.StateDB,
+	cfg vm.Config) (types.Receipts, []*types.Log, uint64, *types.ChainReward, []common.Address, error) {
+	statedb.TrackAccesses()
+	receipts, logs, usedGas, infos, err := fp.Process(block, statedb, cfg)
+	touched := statedb.AccessedAddresses()
+	if err != nil {
+		return nil, nil, 0, nil, nil, err
+	}
+	return receipts, logs, usedGas, infos, touched, nil
+}
+
+// ProcessWithContext processes the block like Process, but checks the context
+// before every transaction and aborts with the context error once it has been
+// cancelled. The statedb is left in an intermediate state in that case and no
+// receipts are returned.
+func (fp *StateProcessor) ProcessWithContext(ctx context.Context, block *types.Block, statedb *state.StateDB,
+	cfg vm.Config) (types.Receipts, []*types.Log, uint64, *types.ChainReward, error) {
+	_, receipts, logs, usedGas, infos, err := fp.process(ctx, block, statedb, cfg, nil)
+	return receipts, logs, usedGas, infos, err
+}
+
+// TxFailure is a transaction of a block which failed to apply.
+type TxFailure struct {
+	Index int         // Position of the transaction in the block
+	Hash  common.Hash // Canonical hash of the transaction
+	Err   error       // Error the transaction failed with
+}
+
+// ProcessLenient processes the block like Process, but carries on past the
+// transactions failing to apply instead of aborting, leaving their state changes
+// undone. It returns the receipts of the transactions applied along with the
+// failures of the others, in block order. Meant for analysing speculative
+// blocks, it must not be used to validate blocks: a block with failures is
+// invalid even though no error is returned.
+func (fp *StateProcessor) ProcessLenient(block *types.Block, statedb *state.StateDB,
+	cfg vm.Config) (types.Receipts, []*types.Log, uint64, *types.ChainReward, []*TxFailure, error) {
+	failures := make([]*TxFailure, 0)
+	_, receipts, logs, usedGas, infos, err := fp.process(context.Background(), block, statedb, cfg, &failures)
+	if err != nil {
+		return nil, nil, 0, nil, nil, err
+	}
+	return receipts, logs, usedGas, infos, failures, nil
+}
+
+// ProcessWithSystemReceipt processes the block like Process, additionally
+// returning the receipt of the system transaction run ahead of the block, or nil
+// if there was none. The system receipt and its logs are not part of the block
+// receipts and logs returned.
+func (fp *StateProcessor) ProcessWithSystemReceipt(block *types.Block, statedb *state.StateDB,
+	cfg vm.Config) (*types.Receipt, types.Receipts, []*types.Log, uint64, *types.ChainReward, error) {
+	return fp.process(context.Background(), block, statedb, cfg, nil)
+}
+
+// processScratch is the per-block working state of the processor which does not
+// outlive the processing of a block, recycled across blocks to spare allocations.
+type processScratch struct {
+	gp      GasPool
+	usedGas uint64
+	chain   headerCachingChain
+}
+
+// processScratchPool recycles the scratch states of finished blocks. A scratch
+// state is only ever used by one block at a time.
+var processScratchPool = sync.Pool{
+	New: func() interface{} {
+		return &processScratch{chain: headerCachingChain{headers: make(map[common.Hash]*types.Header)}}
+	},
+}
+
+// newProcessScratch returns a scratch state for processing a block with the
+// given gas limit on top of chain.
+func newProcessScratch(chain ChainContext, gasLimit uint64) *processScratch {
+	scratch := processScratchPool.Get().(*processScratch)
+	scratch.gp, scratch.usedGas = GasPool(gasLimit), 0
+	scratch.chain.ChainContext = chain
+	return scratch
+}
+
+// release resets the scratch state and returns it to the pool. Nothing of it may
+// be referenced afterwards.
+func (s *processScratch) release() {
+	s.chain.ChainContext = nil
+	for hash := range s.chain.headers {
+		delete(s.chain.headers, hash)
+	}
+	processScratchPool.Put(s)
+}
+
+// cacheSenders caches the senders the sender cache knows into the transactions
+// lacking one, so that deriving their messages skips the signature recovery.
+func (fp *StateProcessor) cacheSenders(header *types.Header, txs types.Transactions) {
+	if fp.senders == nil {
+		return
+	}
+	signer := types.MakeSigner(fp.config, header.Number)
+	for _, tx := range txs {
+		if _, ok := types.CachedSender(signer, tx); ok {
+			continue
+		}
+		if from, ok := fp.senders.Sender(signer, tx.Hash()); ok {
+			types.CacheSender(signer, tx, from)
+		}
+	}
+}
+
+// process implements ProcessWithContext, also returning the system receipt. If
+// failures is non-nil, transactions failing to apply are reverted and recorded
+// in it instead of aborting the block.
+func (fp *StateProcessor) process(ctx context.Context, block *types.Block, statedb *state.StateDB,
+	cfg vm.Config, failures *[]*TxFailure) (*types.Receipt, types.Receipts, []*types.Log, uint64, *types.ChainReward, error) {
+	scratch := newProcessScratch(fp.bc, block.GasLimit())
+	defer scratch.release()
+
 	var (
 		receipts  types.Receipts
-		usedGas   = new(uint64)
+		usedGas   = &scratch.usedGas
 		feeAmount = big.NewInt(0)
 		header    = block.Header()
 		allLogs   []*types.Log
-		gp        = new(GasPool).AddGas(block.GasLimit())
+		gp        = &scratch.gp
+		chain     = &scratch.chain
 	)
 	start := time.Now()
+	fp.cacheSenders(header, block.Transactions())
+	cfg = fp.vmConfig(cfg)
+
+	// Apply the system transaction ahead of the block, outside its gas accounting
+	var sysReceipt *types.Receipt
+	if fp.sysTx != nil {
+		if tx := fp.sysTx(header); tx != nil {
+			var (
+				sysGas uint64
+				sysFee = new(big.Int)
+			)
+			statedb.Prepare(CanonicalTxHash(fp.config, block.Number(), tx), block.Hash(), SystemTxIndex)
+			receipt, err := applyTransaction(fp.config, chain, new(GasPool).AddGas(tx.Gas()), statedb, header, tx, &sysGas, sysFee, cfg)
+			if err != nil {
+				return nil, nil, nil, 0, nil, fmt.Errorf("system transaction: %w", err)
+			}
+			deriveReceiptBlooms(types.Receipts{receipt})
+			sysReceipt = receipt
+
+			// Number the logs of the block as if the system transaction wasn't there
+			statedb.ResetLogIndex()
+		}
+	}
 	// Iterate over and process the individual transactions
 	for i, tx := range block.Transactions() {
-		txhash := tx.HashOld()
-		if fp.config.IsTIP10(block.Number()) {
-			txhash = tx.Hash()
+		if err := ctx.Err(); err != nil {
+			return nil, nil, nil, 0, nil, err
 		}
+		txhash := CanonicalTxHash(fp.config, block.Number(), tx)
 		statedb.Prepare(txhash, block.Hash(), i)
-		receipt, err := ApplyTransaction(fp.config, fp.bc, gp, statedb, header, tx, usedGas, feeAmount, cfg)
+
+		snap, gas := statedb.Snapshot(), gp.Gas()
+		receipt, err := applyTransaction(fp.config, chain, gp, statedb, header, tx, usedGas, feeAmount, cfg)
 		if err != nil {
-			return nil, nil, 0, nil, err
+			if failures == nil {
+				return nil, nil, nil, 0, nil, err
+			}
+			// Undo whatever the failed transaction did, including any gas bought
+			statedb.RevertToSnapshot(snap)
+			*gp = GasPool(gas)
+			*failures = append(*failures, &TxFailure{Index: i, Hash: txhash, Err: err})
+			continue
 		}
 		receipts = append(receipts, receipt)
 		allLogs = append(allLogs, receipt.Logs...)
+
+		if fp.txHook != nil {
+			fp.txHook(i, statedb.IntermediateRoot(true))
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, 0, nil, err
 	}
+	deriveReceiptBlooms(receipts)
+
 	t1 := time.Now()
+	if fp.feeDistributor != nil {
+		feeAmount = fp.feeDistributor.DistributeFees(header, statedb, feeAmount)
+	}
 	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
 	_, infos, err := fp.engine.Finalize(fp.bc, header, statedb, block.Transactions(), receipts, feeAmount)
 	if err != nil {
-		return nil, nil, 0, nil, err
+		return nil, nil, nil, 0, nil, err
+	}
+	// Only blocks executing transactions are timed for the execution, so that
+	// empty ones going straight to finalization do not skew it
+	if len(block.Transactions()) > 0 {
+		elapsed := t1.Sub(start)
+		blockExecutionTxTimer.Update(elapsed)
+		if elapsed > 0 {
+			blockGasRateGauge.Update(int64(float64(*usedGas) / elapsed.Seconds()))
+		}
+		nonEmptyBlockCounter.Inc(1)
+	} else {
+		emptyBlockCounter.Inc(1)
 	}
-	blockExecutionTxTimer.Update(t1.Sub(start))
 	blockFinalizeTimer.Update(time.Since(t1))
-	return receipts, allLogs, *usedGas, infos, nil
+	return sysReceipt, receipts, allLogs, *usedGas, infos, nil
+}
+
+// ProcessRange applies the transactions of the block with indices from up to,
+// but excluding, to on top of statedb, which must hold the state the block has
+// reached after its preceding transactions. It returns the receipts of the range
+// and the gas used by it, without finalizing the block.
+//
+// The gas of the preceding transactions is unknown, so the gas pool is seeded
+// with the full block gas limit and the cumulative gas of the receipts counts
+// from the start of the range.
+func (fp *StateProcessor) ProcessRange(block *types.Block, statedb *state.StateDB,
+	cfg vm.Config, from, to int) (types.Receipts, uint64, error) {
+	txs := block.Transactions()
+	if from < 0 || from > to || to > len(txs) {
+		return nil, 0, fmt.Errorf("%w: [%d, %d) of %d", ErrInvalidTxRange, from, to, len(txs))
+	}
+	scratch := newProcessScratch(fp.bc, block.GasLimit())
+	defer scratch.release()
+
+	var (
+		receipts  types.Receipts
+		usedGas   = &scratch.usedGas
+		feeAmount = big.NewInt(0)
+		header    = block.Header()
+		gp        = &scratch.gp
+		chain     = &scratch.chain
+	)
+	fp.cacheSenders(header, txs[from:to])
+	cfg = fp.vmConfig(cfg)
+	for i := from; i < to; i++ {
+		statedb.Prepare(CanonicalTxHash(fp.config, block.Number(), txs[i]), block.Hash(), i)
+		receipt, err := applyTransaction(fp.config, chain, gp, statedb, header, txs[i], usedGas, feeAmount, cfg)
+		if err != nil {
+			return nil, 0, err
+		}
+		receipts = append(receipts, receipt)
+	}
+	deriveReceiptBlooms(receipts)
+	return receipts, *usedGas, nil
+}
+
+// deriveReceiptBlooms computes the log bloom of every receipt, spreading the
+// hashing over the available CPUs. The blooms are identical to the ones set by
+// ApplyTransaction.
+func deriveReceiptBlooms(receipts types.Receipts) {
+	workers := runtime.NumCPU()
+	if workers > len(receipts) {
+		workers = len(receipts)
+	}
+	if workers <= 1 {
+		for _, receipt := range receipts {
+			receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+		}
+		return
+	}
+	var (
+		pend  sync.WaitGroup
+		tasks = make(chan *types.Receipt, len(receipts))
+	)
+	for _, receipt := range receipts {
+		tasks <- receipt
+	}
+	close(tasks)
+
+	pend.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer pend.Done()
+			for receipt := range tasks {
+				receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+			}
+		}()
+	}
+	pend.Wait()
+}
+
+// CanonicalTxHash returns the hash identifying tx in the receipts, logs and
+// lookup entries of the block with the given number, which is the legacy hash
+// before TIP10. Tools reproducing the hashes of historical transactions should
+// use it rather than picking between tx.Hash and tx.HashOld themselves.
+func CanonicalTxHash(config *params.ChainConfig, number *big.Int, tx *types.Transaction) common.Hash {
+	if config.IsTIP10(number) {
+		return tx.Hash()
+	}
+	return tx.HashOld()
+}
+
+// DeriveReceiptsRoot returns the receipts trie root committed to by the header
+// of the block producing the receipts. Only the consensus fields of receipts and
+// logs are hashed, which leave out the transaction hashes, so the root does not
+// depend on the TIP10 hash switch applied to them by CanonicalTxHash.
+func DeriveReceiptsRoot(receipts types.Receipts) common.Hash {
+	return types.DeriveSha(receipts)
+}
+
+// CheckTxChainID checks that a transaction was signed for the chain of config,
+// failing with ErrTxChainIDMismatch otherwise. Transactions signed without a
+// chain ID are rejected with ErrUnprotectedTx, unless the chain allows them.
+func CheckTxChainID(config *params.ChainConfig, tx *types.Transaction) error {
+	if !tx.Protected() {
+		if config.AllowUnprotectedTxs {
+			return nil
+		}
+		return ErrUnprotectedTx
+	}
+	if chainID := tx.ChainId(); chainID.Cmp(config.ChainID) != 0 {
+		return fmt.Errorf("%w: have %v, want %v", ErrTxChainIDMismatch, chainID, config.ChainID)
+	}
+	return nil
+}
+
+// txSigner returns the signer deriving the sender of a transaction executed in
+// the block with the given number, once its chain ID is checked. Unprotected
+// transactions are recovered by the UnprotectedSigner.
+func txSigner(config *params.ChainConfig, number *big.Int, tx *types.Transaction) (types.Signer, error) {
+	if err := CheckTxChainID(config, tx); err != nil {
+		return nil, err
+	}
+	if !tx.Protected() {
+		return types.NewUnprotectedSigner(), nil
+	}
+	return types.MakeSigner(config, number), nil
 }
 
 // ApplyTransaction attempts to apply a transaction to the given state database
 // and uses the input parameters for its environment. It returns the receipt
 // for the transaction, gas used and an error if the transaction failed,
-// indicating the block was invalid.
+// indicating the block was invalid. The receipt's GasUsed holds the exact gas
+// consumed by this transaction alone, intrinsic gas included, while usedGas
+// is advanced to the new cumulative total. The byte code is run by the
+// interpreter registered under the name set in cfg.Interpreter, the built-in
+// one by default, failing with vm.ErrUnknownInterpreter for unknown names. Custom
+// precompiled contracts enabled by the chain configuration at the block must be
+// registered with the EVM, or vm.ErrUnknownPrecompile is returned. Transactions
+// signed for another chain are rejected ahead of execution, see CheckTxChainID.
 func ApplyTransaction(config *params.ChainConfig, bc ChainContext, gp *GasPool,
 	statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *uint64, feeAmount *big.Int, cfg vm.Config) (*types.Receipt, error) {
-	msg, err := tx.AsMessage(types.MakeSigner(config, header.Number))
+	receipt, err := applyTransaction(config, bc, gp, statedb, header, tx, usedGas, feeAmount, cfg)
+	if err != nil {
+		return nil, err
+	}
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	return receipt, nil
+}
+
+// applyTransaction applies a transaction like ApplyTransaction, but leaves the
+// bloom of the receipt unset so that the caller can derive the blooms of a
+// whole block at once.
+func applyTransaction(config *params.ChainConfig, bc ChainContext, gp *GasPool,
+	statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *uint64, feeAmount *big.Int, cfg vm.Config) (*types.Receipt, error) {
+	if err := cfg.CheckInterpreter(); err != nil {
+		return nil, err
+	}
+	// Executing without a precompile the chain enabled would fork it off
+	if len(config.Precompiles) > 0 {
+		if _, err := vm.ActivePrecompiles(config, header.Number); err != nil {
+			return nil, err
+		}
+	}
+	signer, err := txSigner(config, header.Number, tx)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := tx.AsMessage(signer)
 	if err != nil {
 		return nil, err
 	}
-	if header.Number.Cmp(big.NewInt(6638000)) > 0 {
+	if config.IsForbid(header.Number) {
 		if err := types.ForbidAddress(msg.From()); err != nil {
 			return nil, err
 		}
@@ -122,7 +571,9 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, gp *GasPool,
 	// about the transaction and calling mechanisms.
 	vmenv := vm.NewEVM(context, statedb, config, cfg)
 	// Apply the transaction to the current state (included in the env)
+	start := time.Now()
 	result, err := ApplyMessage(vmenv, msg, gp)
+	applyTxTimer.UpdateSince(start)
 
 	if err != nil {
 		return nil, err
@@ -138,22 +589,22 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, gp *GasPool,
 	if msg.Fee() != nil {
 		feeAmount.Add(msg.Fee(), feeAmount) //add fee
 	}
-	txhash := tx.HashOld()
-	if config.IsTIP10(header.Number) {
-		txhash = tx.Hash()
-	}
+	txhash := CanonicalTxHash(config, header.Number, tx)
 	// Create a new receipt for the transaction, storing the intermediate root and gas used by the tx
 	// based on the eip phase, we're passing wether the root touch-delete accounts.
 	receipt := types.NewReceipt(root, result.Failed(), *usedGas)
 	receipt.TxHash = txhash
 	receipt.GasUsed = result.UsedGas
+	if msg.Fee() != nil {
+		receipt.FeePaid = new(big.Int).Set(msg.Fee())
+	}
+	receipt.Payment = msg.Payment()
 	// if the transaction created a contract, store the creation address in the receipt.
 	if msg.To() == nil {
 		receipt.ContractAddress = crypto.CreateAddress(vmenv.Context.Origin, tx.Nonce())
 	}
-	// Set the receipt logs and create a bloom for filtering
+	// Set the receipt logs, the bloom for filtering is left to the caller
 	receipt.Logs = statedb.GetLogs(txhash)
-	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
 	receipt.BlockHash = statedb.BlockHash()
 	receipt.BlockNumber = header.Number
 	receipt.TransactionIndex = uint(statedb.TxIndex())
@@ -161,37 +612,472 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, gp *GasPool,
 	return receipt, err
 }
 
+// ResultTracer is a tracer reporting the result of the trace it collected, as the
+// JavaScript tracers serving debug_traceTransaction do.
+type ResultTracer interface {
+	vm.Tracer
+	GetResult() (json.RawMessage, error)
+}
+
+// ApplyTransactionWithTracer applies a transaction exactly like ApplyTransaction,
+// but runs the EVM with the given tracer attached, returning the result of the
+// trace along with the receipt. The receipt is identical to the one of an
+// untraced execution.
+func ApplyTransactionWithTracer(config *params.ChainConfig, bc ChainContext, gp *GasPool,
+	statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *uint64, feeAmount *big.Int, cfg vm.Config, tracer ResultTracer) (*types.Receipt, json.RawMessage, error) {
+	cfg.Debug = true
+	cfg.Tracer = tracer
+	receipt, err := ApplyTransaction(config, bc, gp, statedb, header, tx, usedGas, feeAmount, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	result, err := tracer.GetResult()
+	if err != nil {
+		return nil, nil, fmt.Errorf("trace result: %w", err)
+	}
+	return receipt, result, nil
+}
+
 // ReadTransaction attempts to apply a transaction to the given state database
 // and uses the input parameters for its environment. It returns the result
 // for the transaction, gas used and an error if the transaction failed,
 // indicating the block was invalid.
 func ReadTransaction(config *params.ChainConfig, bc ChainContext,
 	statedb *state.StateDB, header *types.Header, tx *types.Transaction, cfg vm.Config) ([]byte, uint64, error) {
+	result, err := readTransaction(config, bc, statedb, header, tx, math.MaxUint64, cfg)
+	if err != nil {
+		return nil, 0, err
+	}
+	return result.ReturnData, result.UsedGas, nil
+}
 
-	msg, err := tx.AsMessage(types.MakeSigner(config, header.Number))
+// ReadTransactionWithGasCap evaluates a transaction like ReadTransaction, but
+// within a gas pool of gasCap instead of an unlimited one, lowering the gas of
+// the transaction to the cap if above it. Unlike ReadTransaction, a failed
+// execution such as running out of gas is reported as the returned error.
+func ReadTransactionWithGasCap(config *params.ChainConfig, bc ChainContext,
+	statedb *state.StateDB, header *types.Header, tx *types.Transaction, gasCap uint64, cfg vm.Config) ([]byte, uint64, error) {
+	result, err := readTransaction(config, bc, statedb, header, tx, gasCap, cfg)
+	if err != nil {
+		return nil, 0, err
+	}
+	return result.ReturnData, result.UsedGas, result.Err
+}
 
-	msgCopy := types.NewMessage(msg.From(), msg.To(), msg.Payment(), 0, msg.Value(), msg.Fee(), msg.Gas(), msg.GasPrice(), msg.Data(), false)
+// ReadResult is the outcome of a read-only transaction evaluation, reporting
+// whether the execution reverted together with the decoded revert reason.
+type ReadResult struct {
+	ReturnData   []byte // Data returned by the execution, or the revert payload
+	UsedGas      uint64 // Total gas used by the execution
+	Reverted     bool   // Whether the execution ended with a REVERT
+	RevertReason string // Reason string of a Solidity Error(string) revert, if any
+}
 
+// ReadTransactionResult evaluates a transaction like ReadTransaction, but also
+// reports whether the execution reverted and, when the revert data carries the
+// standard Error(string) selector, the decoded reason string.
+func ReadTransactionResult(config *params.ChainConfig, bc ChainContext,
+	statedb *state.StateDB, header *types.Header, tx *types.Transaction, cfg vm.Config) (*ReadResult, error) {
+	result, err := readTransaction(config, bc, statedb, header, tx, math.MaxUint64, cfg)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
-	if header.Number.Cmp(big.NewInt(6638000)) > 0 {
-		if err := types.ForbidAddress(msgCopy.From()); err != nil {
-			return nil, 0, err
+	return newReadResult(result), nil
+}
+
+// newReadResult reports the outcome of an execution, decoding its revert reason.
+func newReadResult(result *ExecutionResult) *ReadResult {
+	read := &ReadResult{
+		ReturnData: result.ReturnData,
+		UsedGas:    result.UsedGas,
+		Reverted:   result.Err == vm.ErrExecutionReverted,
+	}
+	if read.Reverted {
+		if reason, err := abi.UnpackRevert(result.Revert()); err == nil {
+			read.RevertReason = reason
 		}
 	}
+	return read
+}
 
+// ReadTransactionCallTrace evaluates a transaction like ReadTransactionResult, but
+// also returns the tree of calls made by the execution, each frame carrying its
+// own input, output, gas and error, to locate the inner call an execution failed
+// in. Frames nested deeper than maxDepth calls are left out, zero keeping all.
+func ReadTransactionCallTrace(config *params.ChainConfig, bc ChainContext, statedb *state.StateDB, header *types.Header,
+	tx *types.Transaction, maxDepth int, cfg vm.Config) (*ReadResult, *vm.CallFrame, error) {
+	tracer := vm.NewCallTracer(maxDepth)
+	cfg.Debug = true
+	cfg.Tracer = tracer
+
+	read, err := ReadTransactionResult(config, bc, statedb, header, tx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return read, tracer.CallTree(), nil
+}
+
+// readTransaction executes the transaction as a call within a gas pool of gasCap
+// and returns the raw execution result.
+func readTransaction(config *params.ChainConfig, bc ChainContext,
+	statedb *state.StateDB, header *types.Header, tx *types.Transaction, gasCap uint64, cfg vm.Config) (*ExecutionResult, error) {
+	msg, err := tx.AsMessage(types.MakeSigner(config, header.Number))
+	if err != nil {
+		return nil, err
+	}
+	return readMessage(config, bc, statedb, header, msg, gasCap, cfg)
+}
+
+// readMessage executes the message as a call within a gas pool of gasCap,
+// lowering its gas to the cap if above it, and returns the raw execution result.
+func readMessage(config *params.ChainConfig, bc ChainContext,
+	statedb *state.StateDB, header *types.Header, msg types.Message, gasCap uint64, cfg vm.Config) (*ExecutionResult, error) {
+	if msg.Gas() > gasCap {
+		msg = types.NewMessage(msg.From(), msg.To(), msg.Payment(), msg.Nonce(), msg.Value(), msg.Fee(), gasCap, msg.GasPrice(), msg.Data(), msg.CheckNonce())
+	}
+	if config.IsForbid(header.Number) {
+		if err := types.ForbidAddress(msg.From()); err != nil {
+			return nil, err
+		}
+	}
 	// Create a new context to be used in the EVM environment
-	context := NewEVMContext(msgCopy, header, bc, nil, nil)
+	context := NewEVMContext(msg, header, bc, nil, nil)
 	// Create a new environment which holds all relevant information
 	// about the transaction and calling mechanisms.
 	vmenv := vm.NewEVM(context, statedb, config, cfg)
 	// Apply the transaction to the current state (included in the env)
-	gp := new(GasPool).AddGas(math.MaxUint64)
-	result, err := ApplyMessage(vmenv, msg, gp)
+	gp := new(GasPool).AddGas(gasCap)
+	return ApplyMessage(vmenv, msg, gp)
+}
+
+// ReadTransactionAfter evaluates a transaction like ReadTransaction, but first
+// applies the given preceding transactions on top of statedb so the read sees
+// their intermediate state. The preceding transactions are bound by the block
+// gas limit of header and their receipts are discarded.
+func ReadTransactionAfter(config *params.ChainConfig, bc ChainContext, statedb *state.StateDB, header *types.Header,
+	preTxs []*types.Transaction, tx *types.Transaction, cfg vm.Config) ([]byte, uint64, error) {
+	var (
+		usedGas   uint64
+		feeAmount = new(big.Int)
+		gp        = new(GasPool).AddGas(header.GasLimit)
+	)
+	for i, preTx := range preTxs {
+		txhash := CanonicalTxHash(config, header.Number, preTx)
+		statedb.Prepare(txhash, common.Hash{}, i)
+		if _, err := applyTransaction(config, bc, gp, statedb, header, preTx, &usedGas, feeAmount, cfg); err != nil {
+			return nil, 0, fmt.Errorf("preceding transaction %d [%x]: %v", i, txhash, err)
+		}
+	}
+	return ReadTransaction(config, bc, statedb, header, tx, cfg)
+}
+
+// AccountOverride is a set of fields replaced in an account before evaluating
+// a transaction. Nil fields are left untouched. State replaces the entire
+// storage of the account while StateDiff only replaces the given slots, they
+// are mutually exclusive.
+type AccountOverride struct {
+	Nonce     *uint64
+	Code      *[]byte
+	Balance   *big.Int
+	State     map[common.Hash]common.Hash
+	StateDiff map[common.Hash]common.Hash
+}
+
+// StateOverride is the set of account overrides applied before evaluating a
+// transaction, keyed by account address.
+type StateOverride map[common.Address]AccountOverride
+
+// Apply overrides the fields of the specified accounts in statedb.
+func (diff StateOverride) Apply(statedb *state.StateDB) error {
+	for addr, account := range diff {
+		if account.State != nil && account.StateDiff != nil {
+			return fmt.Errorf("account %s has both state and state diff overrides", addr.Hex())
+		}
+		if account.Nonce != nil {
+			statedb.SetNonce(addr, *account.Nonce)
+		}
+		if account.Code != nil {
+			statedb.SetCode(addr, *account.Code)
+		}
+		if account.Balance != nil {
+			statedb.SetBalance(addr, account.Balance)
+		}
+		if account.State != nil {
+			statedb.SetStorage(addr, account.State)
+		}
+		for key, value := range account.StateDiff {
+			statedb.SetState(addr, key, value)
+		}
+	}
+	return nil
+}
+
+// ReadTransactionWithOverrides evaluates a transaction like ReadTransaction on
+// a copy of statedb with the given account overrides applied, leaving statedb
+// itself untouched.
+func ReadTransactionWithOverrides(config *params.ChainConfig, bc ChainContext, statedb *state.StateDB, header *types.Header,
+	tx *types.Transaction, overrides StateOverride, cfg vm.Config) ([]byte, uint64, error) {
+	statedb = statedb.Copy()
+	if err := overrides.Apply(statedb); err != nil {
+		return nil, 0, err
+	}
+	return ReadTransaction(config, bc, statedb, header, tx, cfg)
+}
+
+// resolverAddrSelector is the selector of addr(bytes32), the resolver method
+// returning the address a name hash points to.
+var resolverAddrSelector = crypto.Keccak256([]byte("addr(bytes32)"))[:4]
+
+// ResolveName returns the address the name with the given hash resolves to, by
+// reading the addr(bytes32) method of the resolver contract on top of statedb.
+// Names the resolver does not know resolve to the zero address. The call is
+// made from the zero address without a nonce check and pays no gas price, so
+// no account state can get in its way.
+func ResolveName(config *params.ChainConfig, bc ChainContext, statedb *state.StateDB, header *types.Header,
+	resolver common.Address, node common.Hash, cfg vm.Config) (common.Address, error) {
+	data := append(append([]byte{}, resolverAddrSelector...), node[:]...)
+	msg := types.NewMessage(common.Address{}, &resolver, common.Address{}, 0, new(big.Int), nil, header.GasLimit, new(big.Int), data, false)
+	result, err := readMessage(config, bc, statedb, header, msg, math.MaxUint64, cfg)
+	if err != nil {
+		return common.Address{}, err
+	}
+	read := newReadResult(result)
+	if read.Reverted {
+		return common.Address{}, fmt.Errorf("%w: %s", vm.ErrExecutionReverted, read.RevertReason)
+	}
+	if len(read.ReturnData) < common.HashLength || !bytes.Equal(read.ReturnData[:common.HashLength-common.AddressLength], make([]byte, common.HashLength-common.AddressLength)) {
+		return common.Address{}, fmt.Errorf("%w: %x", ErrInvalidResolverOutput, read.ReturnData)
+	}
+	return common.BytesToAddress(read.ReturnData[:common.HashLength]), nil
+}
+
+// EstimateGas binary searches the lowest gas limit with which the transaction
+// executes successfully on top of statedb, which is left untouched. The upper
+// bound is the gas limit of the transaction, or of the block if the former is
+// below the intrinsic minimum. Since the search re-executes the transaction at
+// every step, gas withheld from inner calls by the 63/64 rule is accounted for.
+// If the transaction fails even at the upper bound, the execution error is
+// returned instead of an estimate.
+func EstimateGas(config *params.ChainConfig, bc ChainContext, statedb *state.StateDB, header *types.Header,
+	tx *types.Transaction, cfg vm.Config) (uint64, error) {
+	msg, err := tx.AsMessage(types.MakeSigner(config, header.Number))
+	if err != nil {
+		return 0, err
+	}
+	if config.IsForbid(header.Number) {
+		if err := types.ForbidAddress(msg.From()); err != nil {
+			return 0, err
+		}
+	}
+	var (
+		txGas = config.IntrinsicGasSchedule().TxGas
+		lo    = txGas - 1
+		hi    = header.GasLimit
+		limit uint64
+	)
+	if msg.Gas() >= txGas {
+		hi = msg.Gas()
+	}
+	limit = hi
+
+	// executable runs the message with the given gas allowance on a copy of the
+	// state, reporting whether it failed.
+	executable := func(gas uint64) (bool, *ExecutionResult, error) {
+		call := types.NewMessage(msg.From(), msg.To(), msg.Payment(), msg.Nonce(), msg.Value(), msg.Fee(), gas, msg.GasPrice(), msg.Data(), false)
+		vmenv := vm.NewEVM(NewEVMContext(call, header, bc, nil, nil), statedb.Copy(), config, cfg)
+		result, err := ApplyMessage(vmenv, call, new(GasPool).AddGas(gas))
+		if err != nil {
+			if err == ErrIntrinsicGas {
+				return true, nil, nil // Special case, raise gas limit
+			}
+			return true, nil, err
+		}
+		return result.Failed(), result, nil
+	}
+	for lo+1 < hi {
+		mid := (hi + lo) / 2
+		failed, _, err := executable(mid)
+		if err != nil {
+			return 0, err
+		}
+		if failed {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	// Reject the transaction if it still fails at the highest allowance
+	if hi == limit {
+		failed, result, err := executable(hi)
+		if err != nil {
+			return 0, err
+		}
+		if failed {
+			if result != nil && result.Err != vm.ErrOutOfGas {
+				return 0, fmt.Errorf("transaction always failing: %w", result.Err)
+			}
+			return 0, fmt.Errorf("gas required exceeds allowance (%d)", limit)
+		}
+	}
+	return hi, nil
+}
+
+// GenerateAccessList evaluates a transaction like ReadTransaction on a copy of
+// statedb, collecting the accounts and storage slots it accesses into an access
+// list. The sender and the precompiled contracts are left out of the list. If
+// the execution itself fails, the list gathered up to the failure is returned
+// together with the execution error.
+func GenerateAccessList(config *params.ChainConfig, bc ChainContext, statedb *state.StateDB, header *types.Header,
+	tx *types.Transaction, cfg vm.Config) (types.AccessList, error) {
+	return generateAccessList(config, bc, statedb, header, tx, tx.Gas(), cfg)
+}
+
+// generateAccessList generates the access list of a transaction like
+// GenerateAccessList, executing it with the given gas limit in place of its own.
+func generateAccessList(config *params.ChainConfig, bc ChainContext, statedb *state.StateDB, header *types.Header,
+	tx *types.Transaction, gas uint64, cfg vm.Config) (types.AccessList, error) {
+	msg, err := tx.AsMessage(types.MakeSigner(config, header.Number))
+	if err != nil {
+		return nil, err
+	}
+	if config.IsForbid(header.Number) {
+		if err := types.ForbidAddress(msg.From()); err != nil {
+			return nil, err
+		}
+	}
+	call := types.NewMessage(msg.From(), msg.To(), msg.Payment(), msg.Nonce(), msg.Value(), msg.Fee(), gas, msg.GasPrice(), msg.Data(), false)
+
+	tracer := vm.NewAccessListTracer(msg.From())
+	cfg.Debug, cfg.Tracer = true, tracer
+	vmenv := vm.NewEVM(NewEVMContext(call, header, bc, nil, nil), statedb.Copy(), config, cfg)
+	result, err := ApplyMessage(vmenv, call, new(GasPool).AddGas(math.MaxUint64))
+	if err != nil {
+		return nil, err
+	}
+	return tracer.AccessList(), result.Err
+}
+
+// maxAccessListIterations is the number of access list generations after which
+// CreateAccessListFixedPoint gives up on the list settling.
+const maxAccessListIterations = 8
+
+// CreateAccessListFixedPoint generates the access list of a transaction along
+// with the gas it needs, re-generating the list with the estimated gas until
+// neither changes any more. The first pass runs with the gas limit of the
+// transaction, while contracts branching on the remaining gas may touch other
+// slots when sent with the estimate. The chain neither prices access lists nor
+// warms the slots they name, so the estimate stays the same across passes and
+// the list settles on the second one, but the loop is bounded all the same.
+// ErrAccessListNotConverged is returned if the list keeps changing.
+func CreateAccessListFixedPoint(config *params.ChainConfig, bc ChainContext, statedb *state.StateDB, header *types.Header,
+	tx *types.Transaction, cfg vm.Config) (types.AccessList, uint64, error) {
+	estimate, err := EstimateGas(config, bc, statedb, header, tx, cfg)
 	if err != nil {
 		return nil, 0, err
 	}
+	var (
+		gas  = tx.Gas()
+		prev types.AccessList
+	)
+	for i := 0; i < maxAccessListIterations; i++ {
+		list, err := generateAccessList(config, bc, statedb, header, tx, gas, cfg)
+		if err != nil {
+			return nil, 0, err
+		}
+		if i > 0 && gas == estimate && reflect.DeepEqual(list, prev) {
+			return list, estimate, nil
+		}
+		prev, gas = list, estimate
+	}
+	return nil, 0, ErrAccessListNotConverged
+}
+
+// BundleTxResult is the outcome of a single transaction of a simulated bundle.
+type BundleTxResult struct {
+	TxHash  common.Hash  `json:"txHash"`
+	Failed  bool         `json:"failed"`
+	GasUsed uint64       `json:"gasUsed"`
+	Logs    []*types.Log `json:"logs"`
+}
+
+// SimulateBundle applies the given transactions in order on a copy of statedb,
+// each one seeing the state left by the previous ones, and returns their
+// outcomes. The bundle shares the block gas limit of header. A reverted
+// transaction ends the simulation if stopOnRevert is set, while a transaction
+// that cannot be applied at all aborts it with an error.
+func SimulateBundle(config *params.ChainConfig, bc ChainContext, statedb *state.StateDB, header *types.Header,
+	txs []*types.Transaction, cfg vm.Config, stopOnRevert bool) ([]BundleTxResult, error) {
+	return SimulateBundleWithGasCap(config, bc, statedb, header, txs, 0, cfg, stopOnRevert)
+}
+
+// SimulateBundleWithGasCap simulates a bundle like SimulateBundle, but rejects
+// it upfront with ErrGasCapExceeded if the gas limits of its transactions add up
+// to more than gasCap, so that no simulation runs more code than the cap pays
+// for. A zero gasCap leaves the bundle uncapped.
+func SimulateBundleWithGasCap(config *params.ChainConfig, bc ChainContext, statedb *state.StateDB, header *types.Header,
+	txs []*types.Transaction, gasCap uint64, cfg vm.Config, stopOnRevert bool) ([]BundleTxResult, error) {
+	if gasCap != 0 {
+		var total uint64
+		for _, tx := range txs {
+			if total += tx.Gas(); total < tx.Gas() || total > gasCap {
+				return nil, fmt.Errorf("%w: bundle of %d transactions exceeds %d gas", ErrGasCapExceeded, len(txs), gasCap)
+			}
+		}
+	}
+	var (
+		usedGas   uint64
+		feeAmount = new(big.Int)
+		gp        = new(GasPool).AddGas(header.GasLimit)
+		results   = make([]BundleTxResult, 0, len(txs))
+	)
+	statedb = statedb.Copy()
+	for i, tx := range txs {
+		txhash := CanonicalTxHash(config, header.Number, tx)
+		statedb.Prepare(txhash, common.Hash{}, i)
+		receipt, err := ApplyTransaction(config, bc, gp, statedb, header, tx, &usedGas, feeAmount, cfg)
+		if err != nil {
+			return results, fmt.Errorf("bundle transaction %d [%x]: %v", i, txhash, err)
+		}
+		failed := receipt.Status == types.ReceiptStatusFailed
+		results = append(results, BundleTxResult{
+			TxHash:  txhash,
+			Failed:  failed,
+			GasUsed: receipt.GasUsed,
+			Logs:    receipt.Logs,
+		})
+		if failed && stopOnRevert {
+			break
+		}
+	}
+	return results, nil
+}
 
-	return result.ReturnData, result.UsedGas, err
+// SimulateTransaction applies tx on a copy of statedb and returns the change of
+// balance it causes to every account, fees and internal value transfers of
+// contract calls included. Accounts left with their balance are omitted. The
+// balances of a reverted transaction only change by the fees paid.
+func SimulateTransaction(config *params.ChainConfig, bc ChainContext, statedb *state.StateDB, header *types.Header,
+	tx *types.Transaction, cfg vm.Config) (map[common.Address]*big.Int, error) {
+	var (
+		usedGas uint64
+		tracer  = vm.NewBalanceTracer()
+		post    = statedb.Copy()
+	)
+	cfg.Debug, cfg.Tracer = true, tracer
+
+	post.Prepare(CanonicalTxHash(config, header.Number, tx), common.Hash{}, 0)
+	if _, err := ApplyTransaction(config, bc, new(GasPool).AddGas(header.GasLimit), post, header, tx, &usedGas, new(big.Int), cfg); err != nil {
+		return nil, err
+	}
+	accounts := tracer.Accounts()
+	if payer := tx.Payer(); payer != nil {
+		accounts = append(accounts, *payer)
+	}
+	diffs := make(map[common.Address]*big.Int)
+	for _, addr := range accounts {
+		if diff := new(big.Int).Sub(post.GetBalance(addr), statedb.GetBalance(addr)); diff.Sign() != 0 {
+			diffs[addr] = diff
+		}
+	}
+	return diffs, nil
 }
@@ -0,0 +1,2352 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AbeyFoundation/go-abey/abeydb"
+	"github.com/AbeyFoundation/go-abey/common"
+	"github.com/AbeyFoundation/go-abey/consensus/minerva"
+	"github.com/AbeyFoundation/go-abey/core/state"
+	"github.com/AbeyFoundation/go-abey/core/types"
+	"github.com/AbeyFoundation/go-abey/core/vm"
+	"github.com/AbeyFoundation/go-abey/crypto"
+	"github.com/AbeyFoundation/go-abey/metrics"
+	"github.com/AbeyFoundation/go-abey/params"
+	"github.com/AbeyFoundation/go-abey/rlp"
+)
+
+var (
+	processorTestKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	processorTestAddress = crypto.PubkeyToAddress(processorTestKey.PublicKey)
+)
+
+// newProcessorTestChain creates a blockchain on top of a genesis funding the
+// processor test account and containing any extra allocations, returning it
+// along with the backing database.
+func newProcessorTestChain(t *testing.T, alloc types.GenesisAlloc) (*BlockChain, *types.Block, abeydb.Database) {
+	gspec := &Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  types.GenesisAlloc{processorTestAddress: {Balance: big.NewInt(params.Ether)}},
+	}
+	for addr, account := range alloc {
+		gspec.Alloc[addr] = account
+	}
+	var (
+		db      = abeydb.NewMemDatabase()
+		genesis = gspec.MustFastCommit(db)
+	)
+	blockchain, err := NewBlockChain(db, nil, gspec.Config, minerva.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	return blockchain, genesis, db
+}
+
+// newProcessorTestState returns a fresh copy of the genesis state.
+func newProcessorTestState(t *testing.T, genesis *types.Block, db abeydb.Database) *state.StateDB {
+	statedb, err := state.New(genesis.Root(), state.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("failed to create state: %v", err)
+	}
+	return statedb
+}
+
+// newProcessorTestHeader returns the header of a block on top of the genesis.
+func newProcessorTestHeader(genesis *types.Block) *types.Header {
+	return &types.Header{
+		ParentHash:  genesis.Hash(),
+		Number:      big.NewInt(1),
+		SnailNumber: new(big.Int),
+		GasLimit:    genesis.GasLimit(),
+		Time:        new(big.Int).Add(genesis.Time(), big.NewInt(10)),
+	}
+}
+
+func TestProcessPerTransactionGas(t *testing.T) {
+	blockchain, genesis, db := newProcessorTestChain(t, nil)
+	defer blockchain.Stop()
+
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, minerva.NewFaker(), db, 1, func(i int, gen *BlockGen) {
+		// Mix plain transfers with ones carrying payload, so that the intrinsic
+		// gas differs between the transactions.
+		payloads := [][]byte{nil, {0x01, 0x02, 0x03}, nil, make([]byte, 32)}
+		for _, data := range payloads {
+			gas := params.TxGas + uint64(len(data))*params.TxDataNonZeroGas
+			tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(processorTestAddress), common.Address{0x01}, big.NewInt(1000), gas, nil, data), signer, processorTestKey)
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			gen.AddTx(tx)
+		}
+	})
+	statedb := newProcessorTestState(t, genesis, db)
+	receipts, _, usedGas, _, err := blockchain.Processor().Process(blocks[0], statedb, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to process block: %v", err)
+	}
+	if len(receipts) != len(blocks[0].Transactions()) {
+		t.Fatalf("receipt count mismatch: have %d, want %d", len(receipts), len(blocks[0].Transactions()))
+	}
+	var (
+		sum  uint64
+		prev uint64
+	)
+	for i, receipt := range receipts {
+		tx := blocks[0].Transactions()[i]
+		intrinsic, err := IntrinsicGas(tx.Data(), false, true)
+		if err != nil {
+			t.Fatalf("tx %d: failed to compute intrinsic gas: %v", i, err)
+		}
+		if receipt.GasUsed < intrinsic {
+			t.Errorf("tx %d: gas used below intrinsic gas: have %d, want >= %d", i, receipt.GasUsed, intrinsic)
+		}
+		if delta := receipt.CumulativeGasUsed - prev; receipt.GasUsed != delta {
+			t.Errorf("tx %d: gas used mismatch: have %d, want %d", i, receipt.GasUsed, delta)
+		}
+		sum += receipt.GasUsed
+		prev = receipt.CumulativeGasUsed
+	}
+	if sum != usedGas {
+		t.Fatalf("per-transaction gas sum mismatch: have %d, want %d", sum, usedGas)
+	}
+	if usedGas != blocks[0].GasUsed() {
+		t.Fatalf("block gas used mismatch: have %d, want %d", usedGas, blocks[0].GasUsed())
+	}
+}
+
+// opcodeCounter is a tracer counting the executed opcodes and the number of
+// top level executions it observed.
+type opcodeCounter struct {
+	starts  int
+	ends    int
+	opcodes int
+}
+
+func (c *opcodeCounter) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	c.starts++
+	return nil
+}
+
+func (c *opcodeCounter) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, rStack *vm.ReturnStack, rData []byte, contract *vm.Contract, depth int, err error) error {
+	c.opcodes++
+	return nil
+}
+
+func (c *opcodeCounter) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, rStack *vm.ReturnStack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+func (c *opcodeCounter) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	c.ends++
+	return nil
+}
+
+func (c *opcodeCounter) GetResult() (json.RawMessage, error) {
+	return json.Marshal(map[string]int{"starts": c.starts, "ends": c.ends, "opcodes": c.opcodes})
+}
+
+func TestApplyTransactionWithTracer(t *testing.T) {
+	blockchain, genesis, db := newProcessorTestChain(t, nil)
+	defer blockchain.Stop()
+
+	var (
+		signer = types.NewTIP1Signer(params.TestChainConfig.ChainID)
+		header = newProcessorTestHeader(genesis)
+	)
+	tx, err := types.SignTx(types.NewTransaction(0, common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), signer, processorTestKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	apply := func(tracer ResultTracer) (*types.Receipt, json.RawMessage, common.Hash) {
+		statedb := newProcessorTestState(t, genesis, db)
+		statedb.Prepare(tx.Hash(), common.Hash{}, 0)
+
+		var (
+			usedGas   uint64
+			receipt   *types.Receipt
+			result    json.RawMessage
+			gp        = new(GasPool).AddGas(header.GasLimit)
+			feeAmount = new(big.Int)
+		)
+		if tracer == nil {
+			receipt, err = ApplyTransaction(params.TestChainConfig, blockchain, gp, statedb, header, tx, &usedGas, feeAmount, vm.Config{})
+		} else {
+			receipt, result, err = ApplyTransactionWithTracer(params.TestChainConfig, blockchain, gp, statedb, header, tx, &usedGas, feeAmount, vm.Config{}, tracer)
+		}
+		if err != nil {
+			t.Fatalf("failed to apply transaction: %v", err)
+		}
+		return receipt, result, statedb.IntermediateRoot(false)
+	}
+	tracer := new(opcodeCounter)
+	plain, _, plainRoot := apply(nil)
+	traced, result, tracedRoot := apply(tracer)
+
+	if plainRoot != tracedRoot {
+		t.Errorf("state root mismatch: have %x, want %x", tracedRoot, plainRoot)
+	}
+	if traced.GasUsed != plain.GasUsed || traced.CumulativeGasUsed != plain.CumulativeGasUsed {
+		t.Errorf("gas mismatch: have %d/%d, want %d/%d", traced.GasUsed, traced.CumulativeGasUsed, plain.GasUsed, plain.CumulativeGasUsed)
+	}
+	if traced.Status != plain.Status || len(traced.Logs) != len(plain.Logs) || traced.Bloom != plain.Bloom {
+		t.Errorf("receipt mismatch: have %v, want %v", traced, plain)
+	}
+	// A plain transfer enters the EVM once but runs no code at all
+	if tracer.starts != 1 || tracer.ends != 1 {
+		t.Errorf("trace boundaries mismatch: have %d starts, %d ends, want 1 each", tracer.starts, tracer.ends)
+	}
+	if tracer.opcodes != 0 {
+		t.Errorf("opcode count mismatch: have %d, want 0", tracer.opcodes)
+	}
+	// The result of the trace is returned along with the receipt
+	if want := `{"ends":1,"opcodes":0,"starts":1}`; string(result) != want {
+		t.Errorf("trace result mismatch: have %s, want %s", result, want)
+	}
+}
+
+func TestReadTransactionAfter(t *testing.T) {
+	var (
+		beneficiary = common.Address{0x02}
+		contract    = common.Address{0x03}
+		reader, _   = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		signer      = types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	)
+	// The contract returns the balance of the beneficiary as a 32 byte word:
+	// PUSH20 <beneficiary> BALANCE PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+	code := append(append([]byte{byte(vm.PUSH20)}, beneficiary.Bytes()...),
+		byte(vm.BALANCE), byte(vm.PUSH1), 0, byte(vm.MSTORE), byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN))
+
+	blockchain, genesis, db := newProcessorTestChain(t, types.GenesisAlloc{contract: {Code: code, Balance: new(big.Int)}})
+	defer blockchain.Stop()
+
+	header := newProcessorTestHeader(genesis)
+	transfer, err := types.SignTx(types.NewTransaction(0, beneficiary, big.NewInt(1000), params.TxGas, nil, nil), signer, processorTestKey)
+	if err != nil {
+		t.Fatalf("failed to sign transfer: %v", err)
+	}
+	read, err := types.SignTx(types.NewTransaction(0, contract, new(big.Int), 100000, nil, nil), signer, reader)
+	if err != nil {
+		t.Fatalf("failed to sign read: %v", err)
+	}
+	tests := []struct {
+		preTxs []*types.Transaction
+		want   *big.Int
+	}{
+		{nil, new(big.Int)},
+		{[]*types.Transaction{transfer}, big.NewInt(1000)},
+	}
+	for i, tt := range tests {
+		statedb := newProcessorTestState(t, genesis, db)
+		ret, _, err := ReadTransactionAfter(params.TestChainConfig, blockchain, statedb, header, tt.preTxs, read, vm.Config{})
+		if err != nil {
+			t.Fatalf("test %d: failed to read: %v", i, err)
+		}
+		if have := new(big.Int).SetBytes(ret); have.Cmp(tt.want) != 0 {
+			t.Errorf("test %d: balance mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+	// Preceding transactions are bound by the block gas limit
+	statedb := newProcessorTestState(t, genesis, db)
+	limited := types.CopyHeader(header)
+	limited.GasLimit = params.TxGas - 1
+	if _, _, err := ReadTransactionAfter(params.TestChainConfig, blockchain, statedb, limited, []*types.Transaction{transfer}, read, vm.Config{}); err == nil {
+		t.Errorf("expected gas limit failure for preceding transaction")
+	}
+}
+
+func TestReadTransactionWithGasCap(t *testing.T) {
+	var (
+		contract  = common.Address{0x03}
+		reader, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		signer    = types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	)
+	// The contract stores a non-zero word, costing 20000 gas on top of the
+	// intrinsic gas: PUSH1 1 PUSH1 0 SSTORE STOP
+	code := []byte{byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)}
+
+	blockchain, genesis, db := newProcessorTestChain(t, types.GenesisAlloc{contract: {Code: code, Balance: new(big.Int)}})
+	defer blockchain.Stop()
+
+	header := newProcessorTestHeader(genesis)
+	tx, err := types.SignTx(types.NewTransaction(0, contract, new(big.Int), 100000, nil, nil), signer, reader)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	newState := func() *state.StateDB {
+		statedb := newProcessorTestState(t, genesis, db)
+		return statedb
+	}
+	// The uncapped read succeeds within the gas of the transaction
+	_, used, err := ReadTransaction(params.TestChainConfig, blockchain, newState(), header, tx, vm.Config{})
+	if err != nil {
+		t.Fatalf("uncapped read failed: %v", err)
+	}
+	if _, capped, err := ReadTransactionWithGasCap(params.TestChainConfig, blockchain, newState(), header, tx, tx.Gas(), vm.Config{}); err != nil || capped != used {
+		t.Fatalf("read capped at the transaction gas mismatch: have %d gas, err %v, want %d gas", capped, err, used)
+	}
+	// A cap below the gas needed runs out of gas and consumes all of it
+	gasCap := params.TxGas + 10000
+	_, capped, err := ReadTransactionWithGasCap(params.TestChainConfig, blockchain, newState(), header, tx, gasCap, vm.Config{})
+	if err != vm.ErrOutOfGas {
+		t.Fatalf("capped read error mismatch: have %v, want %v", err, vm.ErrOutOfGas)
+	}
+	if capped != gasCap {
+		t.Errorf("capped read gas mismatch: have %d, want %d", capped, gasCap)
+	}
+}
+
+func TestReadTransactionResult(t *testing.T) {
+	var (
+		reverter  = common.Address{0x03}
+		silent    = common.Address{0x04}
+		succeeder = common.Address{0x05}
+		signer    = types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	)
+	// The revert payload is the ABI encoding of Error("boom")
+	payload := append([]byte{}, crypto.Keccak256([]byte("Error(string)"))[:4]...)
+	payload = append(payload, common.LeftPadBytes([]byte{0x20}, 32)...)
+	payload = append(payload, common.LeftPadBytes([]byte{4}, 32)...)
+	payload = append(payload, common.RightPadBytes([]byte("boom"), 32)...)
+
+	// PUSH1 <len> PUSH1 12 PUSH1 0 CODECOPY PUSH1 <len> PUSH1 0 REVERT <payload>
+	reverting := append([]byte{
+		byte(vm.PUSH1), byte(len(payload)), byte(vm.PUSH1), 12, byte(vm.PUSH1), 0, byte(vm.CODECOPY),
+		byte(vm.PUSH1), byte(len(payload)), byte(vm.PUSH1), 0, byte(vm.REVERT),
+	}, payload...)
+	// PUSH1 0 PUSH1 0 REVERT
+	empty := []byte{byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.REVERT)}
+	// PUSH1 42 PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+	returning := []byte{byte(vm.PUSH1), 42, byte(vm.PUSH1), 0, byte(vm.MSTORE), byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN)}
+
+	blockchain, genesis, db := newProcessorTestChain(t, types.GenesisAlloc{
+		reverter:  {Code: reverting, Balance: new(big.Int)},
+		silent:    {Code: empty, Balance: new(big.Int)},
+		succeeder: {Code: returning, Balance: new(big.Int)},
+	})
+	defer blockchain.Stop()
+
+	header := newProcessorTestHeader(genesis)
+	tests := []struct {
+		to       common.Address
+		reverted bool
+		reason   string
+		ret      []byte
+	}{
+		{reverter, true, "boom", payload},
+		{silent, true, "", nil},
+		{succeeder, false, "", common.LeftPadBytes([]byte{42}, 32)},
+	}
+	for i, tt := range tests {
+		statedb := newProcessorTestState(t, genesis, db)
+		tx, err := types.SignTx(types.NewTransaction(0, tt.to, new(big.Int), 100000, nil, nil), signer, processorTestKey)
+		if err != nil {
+			t.Fatalf("test %d: failed to sign transaction: %v", i, err)
+		}
+		result, err := ReadTransactionResult(params.TestChainConfig, blockchain, statedb, header, tx, vm.Config{})
+		if err != nil {
+			t.Fatalf("test %d: failed to read: %v", i, err)
+		}
+		if result.Reverted != tt.reverted {
+			t.Errorf("test %d: reverted mismatch: have %v, want %v", i, result.Reverted, tt.reverted)
+		}
+		if result.RevertReason != tt.reason {
+			t.Errorf("test %d: revert reason mismatch: have %q, want %q", i, result.RevertReason, tt.reason)
+		}
+		if !bytes.Equal(result.ReturnData, tt.ret) {
+			t.Errorf("test %d: return data mismatch: have %x, want %x", i, result.ReturnData, tt.ret)
+		}
+		if result.UsedGas == 0 {
+			t.Errorf("test %d: no gas used", i)
+		}
+	}
+}
+
+func TestReadTransactionWithOverrides(t *testing.T) {
+	var (
+		contract = common.Address{0x03}
+		slot     = common.Hash{0x01}
+		signer   = types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	)
+	// The deployed contract returns 1 as a 32 byte word:
+	// PUSH1 1 PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+	original := []byte{byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.MSTORE), byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN)}
+	// The override returns the value of the slot:
+	// PUSH32 <slot> SLOAD PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+	loading := append(append([]byte{byte(vm.PUSH32)}, slot.Bytes()...),
+		byte(vm.SLOAD), byte(vm.PUSH1), 0, byte(vm.MSTORE), byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN))
+
+	blockchain, genesis, db := newProcessorTestChain(t, types.GenesisAlloc{contract: {Code: original, Balance: new(big.Int)}})
+	defer blockchain.Stop()
+
+	header := newProcessorTestHeader(genesis)
+	read, err := types.SignTx(types.NewTransaction(0, contract, new(big.Int), 100000, nil, nil), signer, processorTestKey)
+	if err != nil {
+		t.Fatalf("failed to sign read: %v", err)
+	}
+	tests := []struct {
+		overrides StateOverride
+		want      int64
+	}{
+		{nil, 1},
+		{StateOverride{contract: {Code: &loading, State: map[common.Hash]common.Hash{slot: common.BigToHash(big.NewInt(42))}}}, 42},
+		{StateOverride{contract: {Code: &loading, StateDiff: map[common.Hash]common.Hash{slot: common.BigToHash(big.NewInt(7))}}}, 7},
+	}
+	statedb := newProcessorTestState(t, genesis, db)
+	for i, tt := range tests {
+		ret, _, err := ReadTransactionWithOverrides(params.TestChainConfig, blockchain, statedb, header, read, tt.overrides, vm.Config{})
+		if err != nil {
+			t.Fatalf("test %d: failed to read: %v", i, err)
+		}
+		if have := new(big.Int).SetBytes(ret); have.Int64() != tt.want {
+			t.Errorf("test %d: result mismatch: have %v, want %d", i, have, tt.want)
+		}
+	}
+	// The overrides must not leak into the original state
+	if code := statedb.GetCode(contract); !bytes.Equal(code, original) {
+		t.Errorf("code override leaked: have %x, want %x", code, original)
+	}
+	if value := statedb.GetState(contract, slot); value != (common.Hash{}) {
+		t.Errorf("storage override leaked: have %x", value)
+	}
+	if nonce := statedb.GetNonce(processorTestAddress); nonce != 0 {
+		t.Errorf("sender nonce leaked: have %d, want 0", nonce)
+	}
+	// Replacing and patching the storage at the same time is rejected
+	conflict := StateOverride{contract: {State: map[common.Hash]common.Hash{}, StateDiff: map[common.Hash]common.Hash{}}}
+	if _, _, err := ReadTransactionWithOverrides(params.TestChainConfig, blockchain, statedb, header, read, conflict, vm.Config{}); err == nil {
+		t.Errorf("expected conflicting override failure")
+	}
+}
+
+func TestApplyTransactionForbiddenAddress(t *testing.T) {
+	blockchain, genesis, db := newProcessorTestChain(t, nil)
+	defer blockchain.Stop()
+
+	types.ForbiddenAddresses.Add(processorTestAddress)
+	defer types.ForbiddenAddresses.Remove(processorTestAddress)
+
+	config := *params.TestChainConfig
+	config.ForbidBlock = big.NewInt(10)
+
+	signer := types.NewTIP1Signer(config.ChainID)
+	tx, err := types.SignTx(types.NewTransaction(0, common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), signer, processorTestKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	// Forbidden senders are only rejected after the configured height
+	tests := []struct {
+		config *params.ChainConfig
+		number int64
+		err    error
+	}{
+		{&config, 9, nil},
+		{&config, 10, nil},
+		{&config, 11, types.ErrForbidAddress},
+		{&config, 1000, types.ErrForbidAddress},
+		{params.TestChainConfig, 1000, nil},
+	}
+	for i, tt := range tests {
+		statedb := newProcessorTestState(t, genesis, db)
+		statedb.Prepare(tx.Hash(), common.Hash{}, 0)
+
+		var (
+			usedGas uint64
+			header  = &types.Header{
+				ParentHash:  genesis.Hash(),
+				Number:      big.NewInt(tt.number),
+				SnailNumber: new(big.Int),
+				GasLimit:    genesis.GasLimit(),
+				Time:        new(big.Int).Add(genesis.Time(), big.NewInt(10)),
+			}
+			gp = new(GasPool).AddGas(header.GasLimit)
+		)
+		_, err = ApplyTransaction(tt.config, blockchain, gp, statedb, header, tx, &usedGas, new(big.Int), vm.Config{})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("test %d: error mismatch at block %d: have %v, want %v", i, tt.number, err, tt.err)
+		}
+	}
+}
+
+// cancellingTracer cancels a context once the first transaction finished.
+type cancellingTracer struct {
+	opcodeCounter
+	cancel context.CancelFunc
+}
+
+func (c *cancellingTracer) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	c.ends++
+	c.cancel()
+	return nil
+}
+
+func TestReadTransactionCallTrace(t *testing.T) {
+	// The transaction calls outer, which calls middle, which in turn calls first
+	// a contract stopping right away and then one reverting. Failures of inner
+	// calls are ignored by their callers.
+	var (
+		outer  = common.Address{0xa1}
+		middle = common.Address{0xa2}
+		good   = common.Address{0xa3}
+		bad    = common.Address{0xa4}
+		signer = types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	)
+	call := func(addr common.Address) []byte {
+		code := []byte{byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH20)}
+		return append(append(code, addr.Bytes()...), byte(vm.GAS), byte(vm.CALL), byte(vm.POP))
+	}
+	alloc := types.GenesisAlloc{
+		outer:  {Code: append(call(middle), byte(vm.STOP)), Balance: new(big.Int)},
+		middle: {Code: append(append(call(good), call(bad)...), byte(vm.STOP)), Balance: new(big.Int)},
+		good:   {Code: []byte{byte(vm.STOP)}, Balance: new(big.Int)},
+		bad:    {Code: []byte{byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.REVERT)}, Balance: new(big.Int)},
+	}
+	blockchain, genesis, db := newProcessorTestChain(t, alloc)
+	defer blockchain.Stop()
+
+	header := newProcessorTestHeader(genesis)
+	tx, err := types.SignTx(types.NewTransaction(0, outer, new(big.Int), 200000, nil, nil), signer, processorTestKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	read, tree, err := ReadTransactionCallTrace(params.TestChainConfig, blockchain, newProcessorTestState(t, genesis, db), header, tx, 0, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to read transaction: %v", err)
+	}
+	if read.Reverted {
+		t.Fatalf("transaction reverted")
+	}
+	if tree.To != outer || tree.Err != nil {
+		t.Fatalf("root frame mismatch: have to %x err %v, want to %x err nil", tree.To, tree.Err, outer)
+	}
+	if len(tree.Calls) != 1 {
+		t.Fatalf("root inner call count mismatch: have %d, want 1", len(tree.Calls))
+	}
+	inner := tree.Calls[0]
+	if inner.Type != vm.CALL || inner.From != outer || inner.To != middle || inner.Err != nil {
+		t.Fatalf("middle frame mismatch: have %v %x -> %x err %v", inner.Type, inner.From, inner.To, inner.Err)
+	}
+	if inner.GasUsed == 0 || inner.GasUsed > inner.Gas {
+		t.Errorf("middle frame gas mismatch: used %d of %d", inner.GasUsed, inner.Gas)
+	}
+	if len(inner.Calls) != 2 {
+		t.Fatalf("middle inner call count mismatch: have %d, want 2", len(inner.Calls))
+	}
+	if frame := inner.Calls[0]; frame.From != middle || frame.To != good || frame.Err != nil {
+		t.Errorf("first leaf frame mismatch: have %x -> %x err %v", frame.From, frame.To, frame.Err)
+	}
+	if frame := inner.Calls[1]; frame.From != middle || frame.To != bad || frame.Err != vm.ErrExecutionReverted {
+		t.Errorf("failing frame mismatch: have %x -> %x err %v, want err %v", frame.From, frame.To, frame.Err, vm.ErrExecutionReverted)
+	}
+	// Limiting the depth keeps only the calls made by the outermost contract
+	_, tree, err = ReadTransactionCallTrace(params.TestChainConfig, blockchain, newProcessorTestState(t, genesis, db), header, tx, 1, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to read transaction: %v", err)
+	}
+	if len(tree.Calls) != 1 || len(tree.Calls[0].Calls) != 0 {
+		t.Errorf("depth limited tree mismatch: have %d calls below root", len(tree.Calls))
+	}
+}
+
+func TestProcessWithContextCancel(t *testing.T) {
+	blockchain, genesis, db := newProcessorTestChain(t, nil)
+	defer blockchain.Stop()
+
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, minerva.NewFaker(), db, 1, func(i int, gen *BlockGen) {
+		for j := 0; j < 4; j++ {
+			tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(processorTestAddress), common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), signer, processorTestKey)
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			gen.AddTx(tx)
+		}
+	})
+	statedb := newProcessorTestState(t, genesis, db)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tracer := &cancellingTracer{cancel: cancel}
+	processor := NewStateProcessor(params.TestChainConfig, blockchain, minerva.NewFaker())
+	receipts, logs, usedGas, _, err := processor.ProcessWithContext(ctx, blocks[0], statedb, vm.Config{Debug: true, Tracer: tracer})
+	if err != context.Canceled {
+		t.Fatalf("error mismatch: have %v, want %v", err, context.Canceled)
+	}
+	if receipts != nil || logs != nil || usedGas != 0 {
+		t.Fatalf("partial results returned: %d receipts, %d logs, %d gas", len(receipts), len(logs), usedGas)
+	}
+	if tracer.ends != 1 {
+		t.Fatalf("executed transaction count mismatch: have %d, want 1", tracer.ends)
+	}
+}
+
+func TestEstimateGas(t *testing.T) {
+	var (
+		storer   = common.Address{0x04}
+		reverter = common.Address{0x05}
+		signer   = types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	)
+	blockchain, genesis, db := newProcessorTestChain(t, types.GenesisAlloc{
+		// PUSH1 1 PUSH1 0 SSTORE STOP
+		storer: {Code: []byte{byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)}, Balance: new(big.Int)},
+		// PUSH1 0 PUSH1 0 REVERT
+		reverter: {Code: []byte{byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.REVERT)}, Balance: new(big.Int)},
+	})
+	defer blockchain.Stop()
+
+	header := newProcessorTestHeader(genesis)
+	estimate := func(to common.Address) (uint64, error) {
+		tx, err := types.SignTx(types.NewTransaction(0, to, big.NewInt(1), 0, nil, nil), signer, processorTestKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		statedb := newProcessorTestState(t, genesis, db)
+		return EstimateGas(params.TestChainConfig, blockchain, statedb, header, tx, vm.Config{})
+	}
+	// succeeds reports whether the transaction executes with the given gas limit
+	succeeds := func(to common.Address, gas uint64) bool {
+		tx, _ := types.SignTx(types.NewTransaction(0, to, big.NewInt(1), gas, nil, nil), signer, processorTestKey)
+		statedb := newProcessorTestState(t, genesis, db)
+		statedb.Prepare(tx.Hash(), common.Hash{}, 0)
+
+		var usedGas uint64
+		receipt, err := ApplyTransaction(params.TestChainConfig, blockchain, new(GasPool).AddGas(gas), statedb, header, tx, &usedGas, new(big.Int), vm.Config{})
+		return err == nil && receipt.Status == types.ReceiptStatusSuccessful
+	}
+	// A plain transfer costs exactly the intrinsic gas
+	gas, err := estimate(common.Address{0x01})
+	if err != nil {
+		t.Fatalf("transfer: failed to estimate gas: %v", err)
+	}
+	if gas != params.TxGas {
+		t.Errorf("transfer: estimate mismatch: have %d, want %d", gas, params.TxGas)
+	}
+	// A storage write needs more, and exactly the estimate
+	gas, err = estimate(storer)
+	if err != nil {
+		t.Fatalf("storage write: failed to estimate gas: %v", err)
+	}
+	if gas <= params.TxGas {
+		t.Errorf("storage write: estimate too low: %d", gas)
+	}
+	if !succeeds(storer, gas) {
+		t.Errorf("storage write: failed with estimated gas %d", gas)
+	}
+	if succeeds(storer, gas-1) {
+		t.Errorf("storage write: succeeded below estimated gas %d", gas)
+	}
+	// A reverting call has no estimate at all
+	if _, err := estimate(reverter); !errors.Is(err, vm.ErrExecutionReverted) {
+		t.Errorf("revert: error mismatch: have %v, want %v", err, vm.ErrExecutionReverted)
+	}
+	// The search is bounded by the intrinsic gas schedule of the chain, so a
+	// cheaper transfer than on mainnet is estimated as such, and a gas limit
+	// below the mainnet minimum bounds the search
+	custom := *params.TestChainConfig
+	custom.IntrinsicGas = &params.IntrinsicGasConfig{TxGas: 15000}
+
+	for _, limit := range []uint64{0, 18000} {
+		tx, err := types.SignTx(types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), limit, nil, nil), signer, processorTestKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		statedb := newProcessorTestState(t, genesis, db)
+		gas, err := EstimateGas(&custom, blockchain, statedb, header, tx, vm.Config{})
+		if err != nil {
+			t.Fatalf("custom transfer with limit %d: failed to estimate gas: %v", limit, err)
+		}
+		if gas != 15000 {
+			t.Errorf("custom transfer with limit %d: estimate mismatch: have %d, want 15000", limit, gas)
+		}
+	}
+}
+
+func TestApplyTransactionIntrinsicGasSchedule(t *testing.T) {
+	blockchain, genesis, db := newProcessorTestChain(t, nil)
+	defer blockchain.Stop()
+
+	custom := *params.TestChainConfig
+	custom.IntrinsicGas = &params.IntrinsicGasConfig{TxGas: 30000, TxDataNonZeroGas: 100}
+
+	var (
+		data   = []byte{0x01, 0x02, 0x00}
+		signer = types.NewTIP1Signer(params.TestChainConfig.ChainID)
+		header = newProcessorTestHeader(genesis)
+	)
+	tests := []struct {
+		config *params.ChainConfig
+		want   uint64
+	}{
+		{params.TestChainConfig, params.TxGas + 2*params.TxDataNonZeroGas + params.TxDataZeroGas},
+		{&custom, 30000 + 2*100 + params.TxDataZeroGas},
+	}
+	for i, tt := range tests {
+		if gas, err := IntrinsicGasAt(tt.config, data, false, true); err != nil || gas != tt.want {
+			t.Errorf("test %d: intrinsic gas mismatch: have %d (%v), want %d", i, gas, err, tt.want)
+		}
+		statedb := newProcessorTestState(t, genesis, db)
+		tx, err := types.SignTx(types.NewTransaction(0, common.Address{0x01}, big.NewInt(1000), 100000, nil, data), signer, processorTestKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		var usedGas uint64
+		receipt, err := ApplyTransaction(tt.config, blockchain, new(GasPool).AddGas(header.GasLimit), statedb, header, tx, &usedGas, new(big.Int), vm.Config{})
+		if err != nil {
+			t.Fatalf("test %d: failed to apply transaction: %v", i, err)
+		}
+		if receipt.GasUsed != tt.want {
+			t.Errorf("test %d: gas used mismatch: have %d, want %d", i, receipt.GasUsed, tt.want)
+		}
+	}
+	// The mainnet schedule is unaffected by the overrides of another chain
+	if gas, err := IntrinsicGas(data, false, true); err != nil || gas != tests[0].want {
+		t.Errorf("mainnet intrinsic gas mismatch: have %d (%v), want %d", gas, err, tests[0].want)
+	}
+	// A transaction covering the mainnet intrinsic gas falls short of the custom one
+	statedb := newProcessorTestState(t, genesis, db)
+	tx, err := types.SignTx(types.NewTransaction(0, common.Address{0x01}, big.NewInt(1000), tests[0].want, nil, data), signer, processorTestKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	var usedGas uint64
+	if _, err := ApplyTransaction(&custom, blockchain, new(GasPool).AddGas(header.GasLimit), statedb, header, tx, &usedGas, new(big.Int), vm.Config{}); err != ErrIntrinsicGas {
+		t.Errorf("short transaction error mismatch: have %v, want %v", err, ErrIntrinsicGas)
+	}
+}
+
+// Tests that transactions signed for another chain are rejected ahead of their
+// execution, and that unprotected ones are only executed if the chain allows.
+func TestApplyTransactionChainID(t *testing.T) {
+	blockchain, genesis, db := newProcessorTestChain(t, nil)
+	defer blockchain.Stop()
+
+	lenient := *params.TestChainConfig
+	lenient.AllowUnprotectedTxs = true
+
+	header := newProcessorTestHeader(genesis)
+	otherChain := new(big.Int).Add(params.TestChainConfig.ChainID, common.Big1)
+	tests := []struct {
+		name   string
+		signer types.Signer
+		config *params.ChainConfig
+		err    error
+	}{
+		{"protected", types.NewTIP1Signer(params.TestChainConfig.ChainID), params.TestChainConfig, nil},
+		{"other chain", types.NewTIP1Signer(otherChain), params.TestChainConfig, ErrTxChainIDMismatch},
+		{"other chain, lenient", types.NewTIP1Signer(otherChain), &lenient, ErrTxChainIDMismatch},
+		{"unprotected", types.NewTIP1Signer(nil), params.TestChainConfig, ErrUnprotectedTx},
+		{"unprotected, lenient", types.NewTIP1Signer(nil), &lenient, nil},
+	}
+	for _, tt := range tests {
+		statedb := newProcessorTestState(t, genesis, db)
+		tx, err := types.SignTx(types.NewTransaction(0, common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), tt.signer, processorTestKey)
+		if err != nil {
+			t.Fatalf("%s: failed to sign transaction: %v", tt.name, err)
+		}
+		var usedGas uint64
+		_, err = ApplyTransaction(tt.config, blockchain, new(GasPool).AddGas(header.GasLimit), statedb, header, tx, &usedGas, new(big.Int), vm.Config{})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error mismatch: have %v, want %v", tt.name, err, tt.err)
+		}
+		if tt.err == nil && statedb.GetBalance(common.Address{0x01}).Cmp(big.NewInt(1000)) != 0 {
+			t.Errorf("%s: transaction not executed", tt.name)
+		}
+	}
+}
+
+func TestApplyTransactionFeeReceipt(t *testing.T) {
+	blockchain, genesis, db := newProcessorTestChain(t, nil)
+	defer blockchain.Stop()
+
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	fee := big.NewInt(12345)
+	tests := []struct {
+		tx  *types.Transaction
+		fee *big.Int
+	}{
+		{types.NewTransaction(0, common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), nil},
+		{types.NewTransaction_Payment(0, common.Address{0x01}, big.NewInt(1000), fee, params.TxGas, nil, nil, common.Address{}), fee},
+	}
+	for i, tt := range tests {
+		tx, err := types.SignTx(tt.tx, signer, processorTestKey)
+		if err != nil {
+			t.Fatalf("test %d: failed to sign transaction: %v", i, err)
+		}
+		statedb := newProcessorTestState(t, genesis, db)
+		statedb.Prepare(tx.Hash(), common.Hash{}, 0)
+
+		var (
+			usedGas uint64
+			header  = &types.Header{
+				ParentHash:  genesis.Hash(),
+				Number:      big.NewInt(1),
+				SnailNumber: new(big.Int),
+				GasLimit:    genesis.GasLimit(),
+				Time:        new(big.Int).Add(genesis.Time(), big.NewInt(10)),
+			}
+			gp = new(GasPool).AddGas(header.GasLimit)
+		)
+		receipt, err := ApplyTransaction(params.TestChainConfig, blockchain, gp, statedb, header, tx, &usedGas, new(big.Int), vm.Config{})
+		if err != nil {
+			t.Fatalf("test %d: failed to apply transaction: %v", i, err)
+		}
+		if tt.fee == nil {
+			if receipt.FeePaid != nil {
+				t.Errorf("test %d: unexpected fee paid: %v", i, receipt.FeePaid)
+			}
+		} else if receipt.FeePaid == nil || receipt.FeePaid.Cmp(tt.fee) != 0 {
+			t.Errorf("test %d: fee paid mismatch: have %v, want %v", i, receipt.FeePaid, tt.fee)
+		}
+		if receipt.Payment != (common.Address{}) {
+			t.Errorf("test %d: payment mismatch: have %x, want zero", i, receipt.Payment)
+		}
+	}
+}
+
+// countingInterpreter runs the byte code with the built-in interpreter while
+// counting the contract runs.
+type countingInterpreter struct {
+	vm.Interpreter
+	runs *int
+}
+
+func (in *countingInterpreter) Run(contract *vm.Contract, input []byte, static bool) ([]byte, error) {
+	*in.runs++
+	return in.Interpreter.Run(contract, input, static)
+}
+
+func TestApplyTransactionInterpreter(t *testing.T) {
+	var runs int
+	vm.RegisterInterpreter("counting", func(evm *vm.EVM, cfg vm.Config) vm.Interpreter {
+		return &countingInterpreter{Interpreter: vm.NewEVMInterpreter(evm, cfg), runs: &runs}
+	})
+	// The contract stores a non-zero word and emits an empty log:
+	// PUSH1 1 PUSH1 0 SSTORE PUSH1 0 PUSH1 0 LOG0 STOP
+	contract := common.Address{0x03}
+	code := []byte{byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.LOG0), byte(vm.STOP)}
+
+	blockchain, genesis, db := newProcessorTestChain(t, types.GenesisAlloc{contract: {Code: code, Balance: new(big.Int)}})
+	defer blockchain.Stop()
+
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	tx, err := types.SignTx(types.NewTransaction(0, contract, new(big.Int), 100000, nil, nil), signer, processorTestKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	header := newProcessorTestHeader(genesis)
+	apply := func(name string) (*types.Receipt, uint64, common.Hash, error) {
+		statedb := newProcessorTestState(t, genesis, db)
+		statedb.Prepare(tx.Hash(), common.Hash{}, 0)
+
+		var usedGas uint64
+		gp := new(GasPool).AddGas(header.GasLimit)
+		receipt, err := ApplyTransaction(params.TestChainConfig, blockchain, gp, statedb, header, tx, &usedGas, new(big.Int), vm.Config{Interpreter: name})
+		return receipt, usedGas, statedb.IntermediateRoot(true), err
+	}
+	want, wantGas, wantRoot, err := apply("")
+	if err != nil {
+		t.Fatalf("failed to apply with the default interpreter: %v", err)
+	}
+	if runs != 0 {
+		t.Fatalf("default interpreter ran the counting one %d times", runs)
+	}
+	for _, name := range []string{vm.DefaultInterpreter, "counting"} {
+		receipt, gas, root, err := apply(name)
+		if err != nil {
+			t.Fatalf("%q: failed to apply transaction: %v", name, err)
+		}
+		if gas != wantGas || receipt.GasUsed != want.GasUsed || receipt.Status != want.Status {
+			t.Errorf("%q: gas mismatch: have %d (receipt %d, status %d), want %d (receipt %d, status %d)",
+				name, gas, receipt.GasUsed, receipt.Status, wantGas, want.GasUsed, want.Status)
+		}
+		if receipt.Bloom != want.Bloom || !reflect.DeepEqual(receipt.Logs, want.Logs) {
+			t.Errorf("%q: logs mismatch: have %v, want %v", name, receipt.Logs, want.Logs)
+		}
+		if root != wantRoot {
+			t.Errorf("%q: state root mismatch: have %x, want %x", name, root, wantRoot)
+		}
+	}
+	if runs != 1 {
+		t.Errorf("counting interpreter runs mismatch: have %d, want 1", runs)
+	}
+	// Unknown interpreters are refused rather than silently replaced
+	if _, _, _, err := apply("missing"); !errors.Is(err, vm.ErrUnknownInterpreter) {
+		t.Errorf("unknown interpreter error mismatch: have %v, want %v", err, vm.ErrUnknownInterpreter)
+	}
+	if _, err := NewBlockChain(db, nil, params.TestChainConfig, minerva.NewFaker(), vm.Config{Interpreter: "missing"}); !errors.Is(err, vm.ErrUnknownInterpreter) {
+		t.Errorf("unknown chain interpreter error mismatch: have %v, want %v", err, vm.ErrUnknownInterpreter)
+	}
+}
+
+func TestProcessTxHook(t *testing.T) {
+	blockchain, genesis, db := newProcessorTestChain(t, nil)
+	defer blockchain.Stop()
+
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, minerva.NewFaker(), db, 1, func(i int, gen *BlockGen) {
+		for j := 0; j < 3; j++ {
+			tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(processorTestAddress), common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), signer, processorTestKey)
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			gen.AddTx(tx)
+		}
+	})
+	process := func(hook TxHook) types.Receipts {
+		statedb := newProcessorTestState(t, genesis, db)
+		processor := NewStateProcessor(params.TestChainConfig, blockchain, minerva.NewFaker())
+		processor.SetTxHook(hook)
+		receipts, _, _, _, err := processor.Process(blocks[0], statedb, vm.Config{})
+		if err != nil {
+			t.Fatalf("failed to process block: %v", err)
+		}
+		return receipts
+	}
+	var (
+		indices []int
+		roots   = make(map[common.Hash]bool)
+	)
+	hooked := process(func(index int, root common.Hash) {
+		indices = append(indices, index)
+		roots[root] = true
+	})
+	if len(indices) != 3 {
+		t.Fatalf("hook invocation count mismatch: have %d, want 3", len(indices))
+	}
+	for i, index := range indices {
+		if index != i {
+			t.Errorf("invocation %d: index mismatch: have %d, want %d", i, index, i)
+		}
+	}
+	if len(roots) != 3 {
+		t.Errorf("distinct intermediate root count mismatch: have %d, want 3", len(roots))
+	}
+	// The hook must not influence the processing result
+	plain := process(nil)
+	for i := range plain {
+		if hooked[i].CumulativeGasUsed != plain[i].CumulativeGasUsed || hooked[i].Status != plain[i].Status {
+			t.Errorf("receipt %d mismatch: have %+v, want %+v", i, hooked[i], plain[i])
+		}
+	}
+}
+
+// storageWrite is a storage write reported to a StorageWriteHook.
+type storageWrite struct {
+	addr              common.Address
+	slot, prev, value common.Hash
+}
+
+func TestProcessStorageWriteHook(t *testing.T) {
+	// The contract overwrites slot 0 and fills slot 1:
+	// PUSH1 0x11 PUSH1 0 SSTORE PUSH1 0x22 PUSH1 1 SSTORE STOP
+	contract := common.Address{0x03}
+	code := []byte{byte(vm.PUSH1), 0x11, byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.PUSH1), 0x22, byte(vm.PUSH1), 1, byte(vm.SSTORE), byte(vm.STOP)}
+	storage := map[common.Hash]common.Hash{{}: common.BigToHash(big.NewInt(0x10))}
+
+	blockchain, genesis, db := newProcessorTestChain(t, types.GenesisAlloc{contract: {Code: code, Storage: storage, Balance: new(big.Int)}})
+	defer blockchain.Stop()
+
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, minerva.NewFaker(), db, 1, func(i int, gen *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(processorTestAddress), contract, new(big.Int), 100000, nil, nil), signer, processorTestKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		gen.AddTx(tx)
+	})
+	process := func(hook StorageWriteHook) common.Hash {
+		statedb := newProcessorTestState(t, genesis, db)
+		processor := NewStateProcessor(params.TestChainConfig, blockchain, minerva.NewFaker())
+		processor.SetStorageWriteHook(hook)
+		if _, _, _, _, err := processor.Process(blocks[0], statedb, vm.Config{}); err != nil {
+			t.Fatalf("failed to process block: %v", err)
+		}
+		return statedb.IntermediateRoot(true)
+	}
+	var writes []storageWrite
+	hooked := process(func(addr common.Address, slot, prev, value common.Hash) {
+		writes = append(writes, storageWrite{addr, slot, prev, value})
+	})
+	want := []storageWrite{
+		{contract, common.Hash{}, common.BigToHash(big.NewInt(0x10)), common.BigToHash(big.NewInt(0x11))},
+		{contract, common.BigToHash(big.NewInt(1)), common.Hash{}, common.BigToHash(big.NewInt(0x22))},
+	}
+	if !reflect.DeepEqual(writes, want) {
+		t.Errorf("storage writes mismatch: have %v, want %v", writes, want)
+	}
+	// The hook must not influence the processing result
+	if plain := process(nil); hooked != plain {
+		t.Errorf("state root mismatch: have %x, want %x", hooked, plain)
+	}
+	// Without a hook no tracer is installed
+	if cfg := NewStateProcessor(params.TestChainConfig, blockchain, minerva.NewFaker()).vmConfig(vm.Config{}); cfg.Debug || cfg.Tracer != nil {
+		t.Errorf("tracer installed without a hook")
+	}
+}
+
+func TestProcessRange(t *testing.T) {
+	// The contract stores its call data in slot 0 and emits an empty log, the
+	// cost of the store depending on the slot left by the preceding calls:
+	// PUSH1 0 CALLDATALOAD PUSH1 0 SSTORE PUSH1 0 PUSH1 0 LOG0 STOP
+	contract := common.Address{0x03}
+	code := []byte{byte(vm.PUSH1), 0, byte(vm.CALLDATALOAD), byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.LOG0), byte(vm.STOP)}
+
+	blockchain, genesis, db := newProcessorTestChain(t, types.GenesisAlloc{contract: {Code: code, Balance: new(big.Int)}})
+	defer blockchain.Stop()
+
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, minerva.NewFaker(), db, 1, func(i int, gen *BlockGen) {
+		for _, word := range []byte{1, 2, 0, 3, 0} {
+			var tx *types.Transaction
+			if word == 0 {
+				tx = types.NewTransaction(gen.TxNonce(processorTestAddress), common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil)
+			} else {
+				tx = types.NewTransaction(gen.TxNonce(processorTestAddress), contract, new(big.Int), 100000, nil, common.LeftPadBytes([]byte{word}, 32))
+			}
+			signed, err := types.SignTx(tx, signer, processorTestKey)
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			gen.AddTx(signed)
+		}
+	})
+	block := blocks[0]
+	processor := NewStateProcessor(params.TestChainConfig, blockchain, minerva.NewFaker())
+
+	newState := func() *state.StateDB {
+		statedb := newProcessorTestState(t, genesis, db)
+		return statedb
+	}
+	full, _, _, _, err := processor.Process(block, newState(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to process block: %v", err)
+	}
+	// Bring the state up to the range by processing the transaction ahead of it
+	statedb := newState()
+	if _, _, err := processor.ProcessRange(block, statedb, vm.Config{}, 0, 1); err != nil {
+		t.Fatalf("failed to process the transactions ahead of the range: %v", err)
+	}
+	receipts, used, err := processor.ProcessRange(block, statedb, vm.Config{}, 1, 3)
+	if err != nil {
+		t.Fatalf("failed to process range: %v", err)
+	}
+	if len(receipts) != 2 {
+		t.Fatalf("receipt count mismatch: have %d, want 2", len(receipts))
+	}
+	if want := full[2].CumulativeGasUsed - full[0].CumulativeGasUsed; used != want {
+		t.Errorf("used gas mismatch: have %d, want %d", used, want)
+	}
+	for i, receipt := range receipts {
+		want := full[i+1]
+		if receipt.TxHash != want.TxHash || receipt.TransactionIndex != want.TransactionIndex {
+			t.Errorf("receipt %d: transaction mismatch: have %x at %d, want %x at %d", i+1, receipt.TxHash, receipt.TransactionIndex, want.TxHash, want.TransactionIndex)
+		}
+		if receipt.GasUsed != want.GasUsed || receipt.Status != want.Status {
+			t.Errorf("receipt %d: gas mismatch: have %d (status %d), want %d (status %d)", i+1, receipt.GasUsed, receipt.Status, want.GasUsed, want.Status)
+		}
+		if receipt.CumulativeGasUsed != want.CumulativeGasUsed-full[0].CumulativeGasUsed {
+			t.Errorf("receipt %d: cumulative gas mismatch: have %d, want %d", i+1, receipt.CumulativeGasUsed, want.CumulativeGasUsed-full[0].CumulativeGasUsed)
+		}
+		if receipt.Bloom != want.Bloom || !reflect.DeepEqual(receipt.Logs, want.Logs) {
+			t.Errorf("receipt %d: logs mismatch: have %v, want %v", i+1, receipt.Logs, want.Logs)
+		}
+	}
+	// Ranges outside of the block are refused
+	for _, bounds := range [][2]int{{-1, 1}, {3, 2}, {4, 6}} {
+		if _, _, err := processor.ProcessRange(block, newState(), vm.Config{}, bounds[0], bounds[1]); !errors.Is(err, ErrInvalidTxRange) {
+			t.Errorf("range %v: error mismatch: have %v, want %v", bounds, err, ErrInvalidTxRange)
+		}
+	}
+}
+
+// Tests that lenient processing reports the transactions failing to apply and
+// still applies the others, while strict processing rejects the block.
+func TestProcessLenient(t *testing.T) {
+	blockchain, genesis, db := newProcessorTestChain(t, nil)
+	defer blockchain.Stop()
+
+	valid, _ := newSenderTestBlock(genesis, db, 2)
+
+	// Slip a transaction with a nonce gap in between the valid ones
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	invalid, err := types.SignTx(types.NewTransaction(5, common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), signer, processorTestKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	txs := types.Transactions{valid.Transactions()[0], invalid, valid.Transactions()[1]}
+	block := types.NewBlock(valid.Header(), txs, nil, nil, nil)
+
+	processor := NewStateProcessor(params.TestChainConfig, blockchain, minerva.NewFaker())
+	newState := func() *state.StateDB {
+		statedb := newProcessorTestState(t, genesis, db)
+		return statedb
+	}
+	if _, _, _, _, err := processor.Process(block, newState(), vm.Config{}); !errors.Is(err, ErrNonceTooHigh) {
+		t.Fatalf("strict error mismatch: have %v, want %v", err, ErrNonceTooHigh)
+	}
+	statedb := newState()
+	receipts, _, usedGas, _, failures, err := processor.ProcessLenient(block, statedb, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to process block: %v", err)
+	}
+	if len(failures) != 1 {
+		t.Fatalf("failure count mismatch: have %d, want 1", len(failures))
+	}
+	if f := failures[0]; f.Index != 1 || f.Hash != CanonicalTxHash(params.TestChainConfig, block.Number(), invalid) || !errors.Is(f.Err, ErrNonceTooHigh) {
+		t.Errorf("failure mismatch: have %d %x %v, want 1 %x %v", f.Index, f.Hash, f.Err, invalid.Hash(), ErrNonceTooHigh)
+	}
+	if len(receipts) != 2 {
+		t.Fatalf("receipt count mismatch: have %d, want 2", len(receipts))
+	}
+	for i, index := range []uint{0, 2} {
+		if receipts[i].TransactionIndex != index || receipts[i].TxHash != CanonicalTxHash(params.TestChainConfig, block.Number(), txs[index]) {
+			t.Errorf("receipt %d: transaction mismatch: have %x at %d, want %x at %d", i, receipts[i].TxHash, receipts[i].TransactionIndex, txs[index].Hash(), index)
+		}
+	}
+	if want := 2 * params.TxGas; usedGas != want || receipts[1].CumulativeGasUsed != want {
+		t.Errorf("used gas mismatch: have %d (cumulative %d), want %d", usedGas, receipts[1].CumulativeGasUsed, want)
+	}
+	if nonce := statedb.GetNonce(processorTestAddress); nonce != 2 {
+		t.Errorf("sender nonce mismatch: have %d, want 2", nonce)
+	}
+	if balance := statedb.GetBalance(common.Address{0x01}); balance.Cmp(big.NewInt(2000)) != 0 {
+		t.Errorf("recipient balance mismatch: have %v, want 2000", balance)
+	}
+}
+
+func TestProcessTouched(t *testing.T) {
+	var (
+		contract  = common.Address{0x03}
+		recipient = common.Address{0x04}
+		untouched = common.Address{0x05}
+		signer    = types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	)
+	// PUSH1 1 PUSH1 0 SSTORE STOP
+	code := []byte{byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)}
+
+	blockchain, genesis, db := newProcessorTestChain(t, types.GenesisAlloc{
+		contract:  {Code: code, Balance: new(big.Int)},
+		untouched: {Balance: big.NewInt(1)},
+	})
+	defer blockchain.Stop()
+
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, minerva.NewFaker(), db, 1, func(i int, gen *BlockGen) {
+		// A plain transfer followed by a contract call
+		for _, to := range []common.Address{recipient, contract} {
+			tx := types.NewTransaction(gen.TxNonce(processorTestAddress), to, big.NewInt(1000), 100000, nil, nil)
+			signed, err := types.SignTx(tx, signer, processorTestKey)
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			gen.AddTx(signed)
+		}
+	})
+	statedb := newProcessorTestState(t, genesis, db)
+	processor := NewStateProcessor(params.TestChainConfig, blockchain, minerva.NewFaker())
+	receipts, _, _, _, touched, err := processor.ProcessTouched(blocks[0], statedb, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to process block: %v", err)
+	}
+	if len(receipts) != 2 {
+		t.Fatalf("receipt count mismatch: have %d, want 2", len(receipts))
+	}
+	set := make(map[common.Address]bool)
+	for _, addr := range touched {
+		set[addr] = true
+	}
+	for _, addr := range []common.Address{processorTestAddress, recipient, contract} {
+		if !set[addr] {
+			t.Errorf("account %x missing from the touched set %x", addr, touched)
+		}
+	}
+	if set[untouched] {
+		t.Errorf("untouched account %x reported as touched", untouched)
+	}
+	// Tracking stops with the processing
+	statedb.GetBalance(untouched)
+	if addrs := statedb.AccessedAddresses(); len(addrs) != 0 {
+		t.Errorf("accesses recorded after processing: %x", addrs)
+	}
+}
+
+// burningDistributor is a fee distributor burning half of the fees of a block.
+type burningDistributor struct {
+	fees *big.Int // Fees of the last distributed block
+}
+
+func (d *burningDistributor) DistributeFees(header *types.Header, statedb *state.StateDB, fees *big.Int) *big.Int {
+	d.fees = new(big.Int).Set(fees)
+	return new(big.Int).Sub(fees, new(big.Int).Div(fees, big.NewInt(2)))
+}
+
+func TestProcessFeeDistributor(t *testing.T) {
+	blockchain, genesis, db := newProcessorTestChain(t, nil)
+	defer blockchain.Stop()
+
+	var (
+		engine   = minerva.NewFaker()
+		signer   = types.NewTIP1Signer(params.TestChainConfig.ChainID)
+		gasPrice = big.NewInt(params.GWei)
+	)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, db, 1, func(i int, gen *BlockGen) {
+		for j := 0; j < 3; j++ {
+			tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(processorTestAddress), common.Address{0x01}, big.NewInt(1000), params.TxGas, gasPrice, nil), signer, processorTestKey)
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			gen.AddTx(tx)
+		}
+	})
+	committee := engine.GetElection().GetCommittee(blocks[0].Number())
+	if len(committee) == 0 {
+		t.Fatalf("empty committee")
+	}
+	// process runs the block with the given distributor, returning the receipts,
+	// the balance of the sender and the fees credited to the committee.
+	process := func(distributor FeeDistributor) (types.Receipts, *big.Int, *big.Int) {
+		statedb := newProcessorTestState(t, genesis, db)
+		credited := new(big.Int)
+		for _, member := range committee {
+			credited.Sub(credited, statedb.GetBalance(member.Coinbase))
+		}
+		processor := NewStateProcessor(params.TestChainConfig, blockchain, engine)
+		processor.SetFeeDistributor(distributor)
+		receipts, _, _, _, err := processor.Process(blocks[0], statedb, vm.Config{})
+		if err != nil {
+			t.Fatalf("failed to process block: %v", err)
+		}
+		for _, member := range committee {
+			credited.Add(credited, statedb.GetBalance(member.Coinbase))
+		}
+		return receipts, statedb.GetBalance(processorTestAddress), credited
+	}
+	var (
+		fees    = new(big.Int).Mul(gasPrice, big.NewInt(3*int64(params.TxGas)))
+		members = big.NewInt(int64(len(committee)))
+		share   = func(amount *big.Int) *big.Int { return new(big.Int).Mul(new(big.Int).Div(amount, members), members) }
+		burner  = new(burningDistributor)
+		burnt   = new(big.Int).Div(fees, big.NewInt(2))
+	)
+	plainRcpts, plainBalance, plainCredit := process(nil)
+	burnRcpts, burnBalance, burnCredit := process(burner)
+
+	if plainCredit.Cmp(share(fees)) != 0 {
+		t.Fatalf("committee credit mismatch without distributor: have %v, want %v", plainCredit, share(fees))
+	}
+	if burner.fees == nil || burner.fees.Cmp(fees) != 0 {
+		t.Fatalf("distributed fees mismatch: have %v, want %v", burner.fees, fees)
+	}
+	if want := share(new(big.Int).Sub(fees, burnt)); burnCredit.Cmp(want) != 0 {
+		t.Fatalf("committee credit mismatch with burning distributor: have %v, want %v", burnCredit, want)
+	}
+	// The sender pays the same, and the receipts are identical
+	if burnBalance.Cmp(plainBalance) != 0 {
+		t.Errorf("sender balance mismatch: have %v, want %v", burnBalance, plainBalance)
+	}
+	if len(burnRcpts) != len(plainRcpts) {
+		t.Fatalf("receipt count mismatch: have %d, want %d", len(burnRcpts), len(plainRcpts))
+	}
+	for i := range plainRcpts {
+		if burnRcpts[i].CumulativeGasUsed != plainRcpts[i].CumulativeGasUsed || burnRcpts[i].Status != plainRcpts[i].Status ||
+			burnRcpts[i].TxHash != plainRcpts[i].TxHash {
+			t.Errorf("receipt %d mismatch: have %+v, want %+v", i, burnRcpts[i], plainRcpts[i])
+		}
+	}
+}
+
+func TestProcessSystemTx(t *testing.T) {
+	// Contract recording its callers in call order, keeping the call count in
+	// slot 0 and the caller of the n-th call in slot n, and emitting an empty log:
+	//
+	//   PUSH1 0 SLOAD DUP1 PUSH1 1 ADD DUP1 PUSH1 0 SSTORE CALLER SWAP1 SSTORE POP
+	//   PUSH1 0 DUP1 LOG0 STOP
+	var (
+		contract  = common.Address{0xac}
+		sysKey, _ = crypto.GenerateKey()
+		sysAddr   = crypto.PubkeyToAddress(sysKey.PublicKey)
+		signer    = types.NewTIP1Signer(params.TestChainConfig.ChainID)
+		code      = []byte{
+			byte(vm.PUSH1), 0, byte(vm.SLOAD), byte(vm.DUP1), byte(vm.PUSH1), 1, byte(vm.ADD),
+			byte(vm.DUP1), byte(vm.PUSH1), 0, byte(vm.SSTORE),
+			byte(vm.CALLER), byte(vm.SWAP1), byte(vm.SSTORE), byte(vm.POP),
+			byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.LOG0), byte(vm.STOP),
+		}
+	)
+	blockchain, genesis, db := newProcessorTestChain(t, types.GenesisAlloc{
+		contract: {Code: code, Balance: new(big.Int)},
+		sysAddr:  {Balance: big.NewInt(params.Ether)},
+	})
+	defer blockchain.Stop()
+
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, minerva.NewFaker(), db, 1, func(i int, gen *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(processorTestAddress), contract, new(big.Int), 100000, nil, nil), signer, processorTestKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		gen.AddTx(tx)
+	})
+	sysTx, err := types.SignTx(types.NewTransaction(0, contract, new(big.Int), 100000, big.NewInt(params.GWei), nil), signer, sysKey)
+	if err != nil {
+		t.Fatalf("failed to sign system transaction: %v", err)
+	}
+	// The fees of the block are recorded to check the ones of the system
+	// transaction are left out
+	distributor := new(burningDistributor)
+
+	// process runs the block with the given system transaction source, returning
+	// the resulting state along with the receipts and gas used.
+	process := func(source SystemTxFunc) (*state.StateDB, *types.Receipt, types.Receipts, uint64) {
+		statedb := newProcessorTestState(t, genesis, db)
+		processor := NewStateProcessor(params.TestChainConfig, blockchain, minerva.NewFaker())
+		processor.SetSystemTx(source)
+		processor.SetFeeDistributor(distributor)
+		sysReceipt, receipts, _, usedGas, _, err := processor.ProcessWithSystemReceipt(blocks[0], statedb, vm.Config{})
+		if err != nil {
+			t.Fatalf("failed to process block: %v", err)
+		}
+		return statedb, sysReceipt, receipts, usedGas
+	}
+	slot := func(n int64) common.Hash { return common.BigToHash(big.NewInt(n)) }
+
+	// Without a system transaction the user transaction is the first caller
+	statedb, sysReceipt, _, baseGas := process(nil)
+	if sysReceipt != nil {
+		t.Fatalf("system receipt reported without a system transaction")
+	}
+	if have := statedb.GetState(contract, slot(1)); have != processorTestAddress.Hash() {
+		t.Fatalf("first caller mismatch: have %x, want %x", have, processorTestAddress)
+	}
+	// A source returning no transaction leaves the block untouched as well
+	if _, sysReceipt, _, usedGas := process(func(*types.Header) *types.Transaction { return nil }); sysReceipt != nil || usedGas != baseGas {
+		t.Fatalf("empty system transaction source altered the block: receipt %v, gas %d, want %d", sysReceipt, usedGas, baseGas)
+	}
+	// With a system transaction it runs first, and the user transaction sees its
+	// effects on the call count
+	statedb, sysReceipt, receipts, usedGas := process(func(header *types.Header) *types.Transaction {
+		if header.Number.Cmp(blocks[0].Number()) != 0 {
+			t.Errorf("system transaction requested for block %v, want %v", header.Number, blocks[0].Number())
+		}
+		return sysTx
+	})
+	if sysReceipt == nil || sysReceipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("system transaction failed: %+v", sysReceipt)
+	}
+	if want := CanonicalTxHash(params.TestChainConfig, blocks[0].Number(), sysTx); sysReceipt.TxHash != want {
+		t.Errorf("system receipt hash mismatch: have %x, want %x", sysReceipt.TxHash, want)
+	}
+	if have := statedb.GetState(contract, slot(1)); have != sysAddr.Hash() {
+		t.Errorf("first caller mismatch: have %x, want system account %x", have, sysAddr)
+	}
+	if have := statedb.GetState(contract, slot(2)); have != processorTestAddress.Hash() {
+		t.Errorf("second caller mismatch: have %x, want %x", have, processorTestAddress)
+	}
+	if have := statedb.GetState(contract, slot(0)); have != slot(2) {
+		t.Errorf("call count mismatch: have %x, want 2", have)
+	}
+	// The system transaction stays out of the receipts and gas of the block
+	if len(receipts) != 1 || receipts[0].TxHash != CanonicalTxHash(params.TestChainConfig, blocks[0].Number(), blocks[0].Transactions()[0]) {
+		t.Fatalf("block receipts mismatch: have %d receipts", len(receipts))
+	}
+	if usedGas != receipts[0].GasUsed {
+		t.Errorf("block gas mismatch: have %d, want %d", usedGas, receipts[0].GasUsed)
+	}
+	if distributor.fees.Sign() != 0 {
+		t.Errorf("block fees mismatch: have %v, want 0", distributor.fees)
+	}
+	// The system transaction is indexed apart from the user transactions, whose
+	// logs are numbered from zero as without it
+	if sysReceipt.TransactionIndex != SystemTxIndex {
+		t.Errorf("system receipt index mismatch: have %d, want %d", sysReceipt.TransactionIndex, SystemTxIndex)
+	}
+	if len(sysReceipt.Logs) != 1 || sysReceipt.Logs[0].TxIndex != SystemTxIndex {
+		t.Errorf("system log index mismatch: have %+v", sysReceipt.Logs)
+	}
+	if len(receipts[0].Logs) != 1 {
+		t.Fatalf("user log count mismatch: have %d, want 1", len(receipts[0].Logs))
+	}
+	if log := receipts[0].Logs[0]; log.TxIndex != 0 || log.Index != 0 {
+		t.Errorf("user log index mismatch: have tx %d log %d, want tx 0 log 0", log.TxIndex, log.Index)
+	}
+}
+
+func TestApplyTxTimer(t *testing.T) {
+	if _, ok := applyTxTimer.(metrics.NilTimer); ok {
+		t.Skip("metrics disabled")
+	}
+	blockchain, genesis, db := newProcessorTestChain(t, nil)
+	defer blockchain.Stop()
+
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, minerva.NewFaker(), db, 1, func(i int, gen *BlockGen) {
+		for j := 0; j < 3; j++ {
+			tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(processorTestAddress), common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), signer, processorTestKey)
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			gen.AddTx(tx)
+		}
+	})
+	statedb := newProcessorTestState(t, genesis, db)
+	before := applyTxTimer.Count()
+	processor := NewStateProcessor(params.TestChainConfig, blockchain, minerva.NewFaker())
+	if _, _, _, _, err := processor.Process(blocks[0], statedb, vm.Config{}); err != nil {
+		t.Fatalf("failed to process block: %v", err)
+	}
+	if have, want := applyTxTimer.Count()-before, int64(len(blocks[0].Transactions())); have != want {
+		t.Fatalf("timed transaction count mismatch: have %d, want %d", have, want)
+	}
+}
+
+func TestProcessEmptyBlockMetrics(t *testing.T) {
+	if _, ok := emptyBlockCounter.(metrics.NilCounter); ok {
+		t.Skip("metrics disabled")
+	}
+	blockchain, genesis, db := newProcessorTestChain(t, nil)
+	defer blockchain.Stop()
+
+	// The first block is empty, the second one transfers value
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, minerva.NewFaker(), db, 2, func(i int, gen *BlockGen) {
+		if i == 0 {
+			return
+		}
+		tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(processorTestAddress), common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), signer, processorTestKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		gen.AddTx(tx)
+	})
+	statedb := newProcessorTestState(t, genesis, db)
+	processor := NewStateProcessor(params.TestChainConfig, blockchain, minerva.NewFaker())
+
+	tests := []struct {
+		block           *types.Block
+		empty, nonEmpty int64
+		executions      int64
+	}{
+		{block: blocks[0], empty: 1},
+		{block: blocks[1], nonEmpty: 1, executions: 1},
+	}
+	for i, tt := range tests {
+		var (
+			empty      = emptyBlockCounter.Count()
+			nonEmpty   = nonEmptyBlockCounter.Count()
+			executions = blockExecutionTxTimer.Count()
+			finalized  = blockFinalizeTimer.Count()
+		)
+		if _, _, _, _, err := processor.Process(tt.block, statedb, vm.Config{}); err != nil {
+			t.Fatalf("block %d: failed to process: %v", i, err)
+		}
+		if have := emptyBlockCounter.Count() - empty; have != tt.empty {
+			t.Errorf("block %d: empty block count mismatch: have %d, want %d", i, have, tt.empty)
+		}
+		if have := nonEmptyBlockCounter.Count() - nonEmpty; have != tt.nonEmpty {
+			t.Errorf("block %d: non-empty block count mismatch: have %d, want %d", i, have, tt.nonEmpty)
+		}
+		if have := blockExecutionTxTimer.Count() - executions; have != tt.executions {
+			t.Errorf("block %d: timed execution count mismatch: have %d, want %d", i, have, tt.executions)
+		}
+		// Every block is finalized, empty or not
+		if have := blockFinalizeTimer.Count() - finalized; have != 1 {
+			t.Errorf("block %d: timed finalization count mismatch: have %d, want 1", i, have)
+		}
+	}
+}
+
+func TestProcessGasRateMetric(t *testing.T) {
+	if _, ok := blockGasRateGauge.(metrics.NilGauge); ok {
+		t.Skip("metrics disabled")
+	}
+	blockchain, genesis, db := newProcessorTestChain(t, nil)
+	defer blockchain.Stop()
+
+	block, _ := newSenderTestBlock(genesis, db, 10)
+	statedb := newProcessorTestState(t, genesis, db)
+	blockGasRateGauge.Update(0)
+
+	start := time.Now()
+	_, _, usedGas, _, err := NewStateProcessor(params.TestChainConfig, blockchain, minerva.NewFaker()).Process(block, statedb, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to process block: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if usedGas != 10*params.TxGas {
+		t.Fatalf("used gas mismatch: have %d, want %d", usedGas, 10*params.TxGas)
+	}
+	// The execution took at most as long as the whole processing, and longer than
+	// a nanosecond
+	rate := blockGasRateGauge.Value()
+	if min := int64(float64(usedGas) / elapsed.Seconds()); rate < min || rate > int64(usedGas)*int64(time.Second) {
+		t.Errorf("gas rate implausible: have %d gas/s, want between %d and %d", rate, min, int64(usedGas)*int64(time.Second))
+	}
+}
+
+func TestGenerateAccessList(t *testing.T) {
+	// Contract reading storage slots 1, 2 and 5, as well as the balances of its
+	// caller and of a precompiled contract
+	var (
+		contract = common.Address{0xac}
+		code     = []byte{
+			byte(vm.PUSH1), 0x01, byte(vm.SLOAD), byte(vm.POP),
+			byte(vm.PUSH1), 0x02, byte(vm.SLOAD), byte(vm.POP),
+			byte(vm.PUSH1), 0x05, byte(vm.SLOAD), byte(vm.POP),
+			byte(vm.CALLER), byte(vm.BALANCE), byte(vm.POP),
+			byte(vm.PUSH1), 0x01, byte(vm.BALANCE), byte(vm.POP),
+			byte(vm.STOP),
+		}
+	)
+	blockchain, genesis, db := newProcessorTestChain(t, types.GenesisAlloc{contract: {Code: code, Balance: new(big.Int)}})
+	defer blockchain.Stop()
+
+	statedb := newProcessorTestState(t, genesis, db)
+	header := newProcessorTestHeader(genesis)
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	tx, err := types.SignTx(types.NewTransaction(0, contract, new(big.Int), 100000, nil, nil), signer, processorTestKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	acl, err := GenerateAccessList(params.TestChainConfig, blockchain, statedb, header, tx, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to generate access list: %v", err)
+	}
+	want := types.AccessList{{
+		Address:     contract,
+		StorageKeys: []common.Hash{common.BigToHash(big.NewInt(1)), common.BigToHash(big.NewInt(2)), common.BigToHash(big.NewInt(5))},
+	}}
+	if !reflect.DeepEqual(acl, want) {
+		t.Fatalf("access list mismatch: have %+v, want %+v", acl, want)
+	}
+	// The state handed in must not be modified
+	if nonce := statedb.GetNonce(processorTestAddress); nonce != 0 {
+		t.Errorf("sender nonce modified: have %d, want 0", nonce)
+	}
+}
+
+func TestCreateAccessListFixedPoint(t *testing.T) {
+	// Contract reading storage slot 1 when sent with plenty of gas and slot 2
+	// otherwise:
+	//
+	//   GAS PUSH3 50000 GT PUSH1 14 JUMPI PUSH1 1 SLOAD POP STOP
+	//   JUMPDEST PUSH1 2 SLOAD POP STOP
+	var (
+		contract = common.Address{0xac}
+		code     = []byte{
+			byte(vm.GAS), byte(vm.PUSH3), 0x00, 0xc3, 0x50, byte(vm.GT), byte(vm.PUSH1), 0x0e, byte(vm.JUMPI),
+			byte(vm.PUSH1), 0x01, byte(vm.SLOAD), byte(vm.POP), byte(vm.STOP),
+			byte(vm.JUMPDEST), byte(vm.PUSH1), 0x02, byte(vm.SLOAD), byte(vm.POP), byte(vm.STOP),
+		}
+		signer = types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	)
+	blockchain, genesis, db := newProcessorTestChain(t, types.GenesisAlloc{contract: {Code: code, Balance: new(big.Int)}})
+	defer blockchain.Stop()
+
+	statedb := newProcessorTestState(t, genesis, db)
+	header := newProcessorTestHeader(genesis)
+	tx, err := types.SignTx(types.NewTransaction(0, contract, new(big.Int), 100000, nil, nil), signer, processorTestKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	slot := func(n int64) types.AccessList {
+		return types.AccessList{{Address: contract, StorageKeys: []common.Hash{common.BigToHash(big.NewInt(n))}}}
+	}
+	// A single pass with the gas limit of the transaction takes the expensive branch
+	acl, err := GenerateAccessList(params.TestChainConfig, blockchain, statedb, header, tx, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to generate access list: %v", err)
+	}
+	if !reflect.DeepEqual(acl, slot(1)) {
+		t.Fatalf("single pass access list mismatch: have %+v, want %+v", acl, slot(1))
+	}
+	// The list is generated with the estimated gas, taking the cheap branch
+	acl, gas, err := CreateAccessListFixedPoint(params.TestChainConfig, blockchain, statedb, header, tx, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create access list: %v", err)
+	}
+	if !reflect.DeepEqual(acl, slot(2)) {
+		t.Fatalf("access list mismatch: have %+v, want %+v", acl, slot(2))
+	}
+	estimate, err := EstimateGas(params.TestChainConfig, blockchain, statedb, header, tx, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to estimate gas: %v", err)
+	}
+	if gas != estimate {
+		t.Errorf("gas mismatch: have %d, want %d", gas, estimate)
+	}
+	// Sending the transaction with the returned gas touches exactly the returned list
+	resent, err := types.SignTx(types.NewTransaction(0, contract, new(big.Int), gas, nil, nil), signer, processorTestKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	stable, err := GenerateAccessList(params.TestChainConfig, blockchain, statedb, header, resent, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to generate access list: %v", err)
+	}
+	if !reflect.DeepEqual(stable, acl) {
+		t.Errorf("access list not stable: have %+v, want %+v", stable, acl)
+	}
+}
+
+func TestSimulateBundle(t *testing.T) {
+	// Contract storing a flag when called with data, and otherwise emitting a
+	// log if the flag is set or reverting if not:
+	//
+	//   CALLDATASIZE PUSH1 20 JUMPI
+	//   PUSH1 0 SLOAD PUSH1 14 JUMPI PUSH1 0 DUP1 REVERT
+	//   14: JUMPDEST PUSH1 0 DUP1 LOG0 STOP
+	//   20: JUMPDEST PUSH1 1 PUSH1 0 SSTORE STOP
+	var (
+		contract = common.Address{0xac}
+		code     = []byte{
+			byte(vm.CALLDATASIZE), byte(vm.PUSH1), 20, byte(vm.JUMPI),
+			byte(vm.PUSH1), 0, byte(vm.SLOAD), byte(vm.PUSH1), 14, byte(vm.JUMPI), byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.REVERT),
+			byte(vm.JUMPDEST), byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.LOG0), byte(vm.STOP),
+			byte(vm.JUMPDEST), byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP),
+		}
+		signer = types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	)
+	blockchain, genesis, db := newProcessorTestChain(t, types.GenesisAlloc{contract: {Code: code, Balance: new(big.Int)}})
+	defer blockchain.Stop()
+
+	statedb := newProcessorTestState(t, genesis, db)
+	header := newProcessorTestHeader(genesis)
+	newTx := func(nonce uint64, data []byte) *types.Transaction {
+		tx, err := types.SignTx(types.NewTransaction(nonce, contract, new(big.Int), 100000, nil, data), signer, processorTestKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		return tx
+	}
+	tests := []struct {
+		txs          []*types.Transaction
+		stopOnRevert bool
+		failed       []bool
+		logs         []int
+	}{
+		// The check succeeds on the flag stored by the preceding transaction
+		{[]*types.Transaction{newTx(0, []byte{1}), newTx(1, nil)}, true, []bool{false, false}, []int{0, 1}},
+		// Without the flag the check reverts, ending the bundle if requested
+		{[]*types.Transaction{newTx(0, nil), newTx(1, []byte{1})}, true, []bool{true}, []int{0}},
+		{[]*types.Transaction{newTx(0, nil), newTx(1, []byte{1})}, false, []bool{true, false}, []int{0, 0}},
+	}
+	for i, tt := range tests {
+		results, err := SimulateBundle(params.TestChainConfig, blockchain, statedb, header, tt.txs, vm.Config{}, tt.stopOnRevert)
+		if err != nil {
+			t.Fatalf("test %d: failed to simulate bundle: %v", i, err)
+		}
+		if len(results) != len(tt.failed) {
+			t.Fatalf("test %d: result count mismatch: have %d, want %d", i, len(results), len(tt.failed))
+		}
+		for j, result := range results {
+			if want := CanonicalTxHash(params.TestChainConfig, header.Number, tt.txs[j]); result.TxHash != want {
+				t.Errorf("test %d, tx %d: hash mismatch: have %x, want %x", i, j, result.TxHash, want)
+			}
+			if result.Failed != tt.failed[j] {
+				t.Errorf("test %d, tx %d: failure mismatch: have %v, want %v", i, j, result.Failed, tt.failed[j])
+			}
+			if len(result.Logs) != tt.logs[j] {
+				t.Errorf("test %d, tx %d: log count mismatch: have %d, want %d", i, j, len(result.Logs), tt.logs[j])
+			}
+			if result.GasUsed < params.TxGas {
+				t.Errorf("test %d, tx %d: gas used too low: %d", i, j, result.GasUsed)
+			}
+		}
+	}
+	// The state handed in must not be modified
+	if flag := statedb.GetState(contract, common.Hash{}); flag != (common.Hash{}) {
+		t.Errorf("contract storage modified: %x", flag)
+	}
+	// The bundle shares the block gas limit
+	limited := types.CopyHeader(header)
+	limited.GasLimit = 120000
+	if _, err := SimulateBundle(params.TestChainConfig, blockchain, statedb, limited, tests[0].txs, vm.Config{}, false); err == nil {
+		t.Errorf("expected gas limit failure for bundle")
+	}
+}
+
+// Tests that bundles whose transactions ask for more gas in total than the cap
+// are rejected before running, while the ones within it are simulated.
+func TestSimulateBundleWithGasCap(t *testing.T) {
+	blockchain, genesis, db := newProcessorTestChain(t, nil)
+	defer blockchain.Stop()
+
+	statedb := newProcessorTestState(t, genesis, db)
+	header := newProcessorTestHeader(genesis)
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	var txs []*types.Transaction
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		tx, err := types.SignTx(types.NewTransaction(nonce, common.Address{0x01}, big.NewInt(1), params.TxGas, nil, nil), signer, processorTestKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		txs = append(txs, tx)
+	}
+	// Every transaction fits the cap alone, but not the bundle as a whole
+	results, err := SimulateBundleWithGasCap(params.TestChainConfig, blockchain, statedb, header, txs, 2*params.TxGas, vm.Config{}, false)
+	if !errors.Is(err, ErrGasCapExceeded) {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrGasCapExceeded)
+	}
+	if results != nil {
+		t.Fatalf("capped bundle simulated: %v", results)
+	}
+	for _, gasCap := range []uint64{3 * params.TxGas, 0} {
+		results, err := SimulateBundleWithGasCap(params.TestChainConfig, blockchain, statedb, header, txs, gasCap, vm.Config{}, false)
+		if err != nil {
+			t.Fatalf("cap %d: failed to simulate bundle: %v", gasCap, err)
+		}
+		if len(results) != len(txs) {
+			t.Fatalf("cap %d: result count mismatch: have %d, want %d", gasCap, len(results), len(txs))
+		}
+	}
+}
+
+func TestSimulateTransaction(t *testing.T) {
+	// Contract forwarding 600 wei of the value it receives to a third account:
+	//
+	//   PUSH1 0 PUSH1 0 PUSH1 0 PUSH1 0 PUSH2 600 PUSH20 <third> GAS CALL STOP
+	var (
+		contract = common.Address{0xac}
+		third    = common.Address{0xbc}
+		code     = append([]byte{
+			byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+			byte(vm.PUSH2), 0x02, 0x58, byte(vm.PUSH20)}, append(third.Bytes(), byte(vm.GAS), byte(vm.CALL), byte(vm.STOP))...)
+		signer = types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	)
+	blockchain, genesis, db := newProcessorTestChain(t, types.GenesisAlloc{contract: {Code: code, Balance: new(big.Int)}})
+	defer blockchain.Stop()
+
+	statedb := newProcessorTestState(t, genesis, db)
+	header := newProcessorTestHeader(genesis)
+	tx, err := types.SignTx(types.NewTransaction(0, contract, big.NewInt(1000), 100000, big.NewInt(2), nil), signer, processorTestKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	diffs, err := SimulateTransaction(params.TestChainConfig, blockchain, statedb, header, tx, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to simulate transaction: %v", err)
+	}
+	// Apply the transaction for real to learn the fee paid
+	var usedGas uint64
+	applied := statedb.Copy()
+	if _, err := ApplyTransaction(params.TestChainConfig, blockchain, new(GasPool).AddGas(header.GasLimit), applied, header, tx, &usedGas, new(big.Int), vm.Config{}); err != nil {
+		t.Fatalf("failed to apply transaction: %v", err)
+	}
+	fee := new(big.Int).Mul(new(big.Int).SetUint64(usedGas), tx.GasPrice())
+
+	want := map[common.Address]*big.Int{
+		processorTestAddress: new(big.Int).Neg(new(big.Int).Add(big.NewInt(1000), fee)),
+		contract:             big.NewInt(400),
+		third:                big.NewInt(600),
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("balance diff count mismatch: have %v, want %v", diffs, want)
+	}
+	for addr, diff := range want {
+		if have := diffs[addr]; have == nil || have.Cmp(diff) != 0 {
+			t.Errorf("balance diff of %x mismatch: have %v, want %v", addr, have, diff)
+		}
+	}
+	// The simulation leaves the original state untouched
+	if balance := statedb.GetBalance(third); balance.Sign() != 0 {
+		t.Errorf("simulation leaked into the state: balance of %x is %v", third, balance)
+	}
+}
+
+// newLogHeavyReceipts creates receipts each carrying the given number of logs
+// with four topics, as emitted by token transfer heavy blocks.
+func newLogHeavyReceipts(count, logs int) types.Receipts {
+	receipts := make(types.Receipts, count)
+	for i := range receipts {
+		receipt := &types.Receipt{Logs: make([]*types.Log, logs)}
+		for j := range receipt.Logs {
+			seed := []byte{byte(i >> 8), byte(i), byte(j)}
+			receipt.Logs[j] = &types.Log{
+				Address: common.BytesToAddress(crypto.Keccak256(seed)),
+				Topics: []common.Hash{
+					crypto.Keccak256Hash(seed, []byte{0}),
+					crypto.Keccak256Hash(seed, []byte{1}),
+					crypto.Keccak256Hash(seed, []byte{2}),
+					crypto.Keccak256Hash(seed, []byte{3}),
+				},
+			}
+		}
+		receipts[i] = receipt
+	}
+	return receipts
+}
+
+func TestDeriveReceiptBlooms(t *testing.T) {
+	for _, count := range []int{0, 1, 3, 100} {
+		receipts := newLogHeavyReceipts(count, 10)
+		// Receipts without logs must end up with an empty bloom as well
+		if count > 1 {
+			receipts[1].Logs = nil
+		}
+		want := make([]types.Bloom, count)
+		for i, receipt := range receipts {
+			want[i] = types.CreateBloom(types.Receipts{receipt})
+		}
+		wantBlock := types.CreateBloom(receipts)
+
+		deriveReceiptBlooms(receipts)
+		for i, receipt := range receipts {
+			if receipt.Bloom != want[i] {
+				t.Fatalf("%d receipts: bloom %d mismatch: have %x, want %x", count, i, receipt.Bloom, want[i])
+			}
+		}
+		var block types.Bloom
+		for _, receipt := range receipts {
+			for k := range block {
+				block[k] |= receipt.Bloom[k]
+			}
+		}
+		if block != wantBlock {
+			t.Fatalf("%d receipts: block bloom mismatch: have %x, want %x", count, block, wantBlock)
+		}
+	}
+}
+
+func TestProcessReceiptBlooms(t *testing.T) {
+	// A contract logging twice with two topics on every call
+	code := []byte{
+		byte(vm.PUSH1), 0x01, byte(vm.PUSH1), 0x02, byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x00, byte(vm.LOG2),
+		byte(vm.CALLER), byte(vm.PUSH1), 0x03, byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x00, byte(vm.LOG2),
+		byte(vm.STOP),
+	}
+	var (
+		contract = common.Address{0xc0}
+		signer   = types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	)
+	blockchain, genesis, db := newProcessorTestChain(t, types.GenesisAlloc{contract: {Code: code, Balance: new(big.Int)}})
+	defer blockchain.Stop()
+
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, minerva.NewFaker(), db, 1, func(i int, gen *BlockGen) {
+		for j := 0; j < 8; j++ {
+			tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(processorTestAddress), contract, new(big.Int), 100000, nil, nil), signer, processorTestKey)
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			gen.AddTx(tx)
+		}
+	})
+	statedb := newProcessorTestState(t, genesis, db)
+	receipts, _, _, _, err := blockchain.Processor().Process(blocks[0], statedb, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to process block: %v", err)
+	}
+	for i, receipt := range receipts {
+		if len(receipt.Logs) != 2 {
+			t.Fatalf("receipt %d: log count mismatch: have %d, want 2", i, len(receipt.Logs))
+		}
+		if want := types.CreateBloom(types.Receipts{receipt}); receipt.Bloom != want {
+			t.Fatalf("receipt %d: bloom mismatch: have %x, want %x", i, receipt.Bloom, want)
+		}
+	}
+	if bloom := types.CreateBloom(receipts); bloom != blocks[0].Bloom() {
+		t.Fatalf("block bloom mismatch: have %x, want %x", bloom, blocks[0].Bloom())
+	}
+}
+
+func BenchmarkReceiptBloomsSequential(b *testing.B) {
+	receipts := newLogHeavyReceipts(500, 20)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, receipt := range receipts {
+			receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+		}
+	}
+}
+
+func BenchmarkReceiptBloomsParallel(b *testing.B) {
+	receipts := newLogHeavyReceipts(500, 20)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		deriveReceiptBlooms(receipts)
+	}
+}
+
+// Tests that blocks processed concurrently, sharing the recycled scratch states
+// of the processor, yield the same results as processed one after the other.
+func TestProcessConcurrent(t *testing.T) {
+	// The contract stores the hash of the genesis block
+	contract := common.Address{0xbb}
+	code := []byte{byte(vm.PUSH1), 0, byte(vm.BLOCKHASH), byte(vm.PUSH1), 0, byte(vm.SSTORE)}
+	blockchain, genesis, db := newProcessorTestChain(t, types.GenesisAlloc{contract: {Code: code, Balance: new(big.Int)}})
+	defer blockchain.Stop()
+
+	// Build two competing blocks on top of the genesis, one of transfers and one
+	// of contract calls
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	newBlock := func(to common.Address, txs int, gas uint64) *types.Block {
+		blocks, _ := GenerateChain(params.TestChainConfig, genesis, minerva.NewFaker(), db, 1, func(i int, gen *BlockGen) {
+			for j := 0; j < txs; j++ {
+				tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(processorTestAddress), to, big.NewInt(1000), gas, nil, nil), signer, processorTestKey)
+				if err != nil {
+					t.Fatalf("failed to sign transaction: %v", err)
+				}
+				gen.AddTx(tx)
+			}
+		})
+		return blocks[0]
+	}
+	blocks := []*types.Block{newBlock(common.Address{0x01}, 5, params.TxGas), newBlock(contract, 3, 100000)}
+
+	type result struct {
+		root    common.Hash
+		usedGas uint64
+		gas     []uint64
+	}
+	processor := NewStateProcessor(params.TestChainConfig, blockchain, minerva.NewFaker())
+	process := func(block *types.Block) (*result, error) {
+		statedb, err := state.New(genesis.Root(), state.NewDatabase(db))
+		if err != nil {
+			return nil, err
+		}
+		receipts, _, usedGas, _, err := processor.Process(block, statedb, vm.Config{})
+		if err != nil {
+			return nil, err
+		}
+		res := &result{root: statedb.IntermediateRoot(true), usedGas: usedGas}
+		for _, receipt := range receipts {
+			res.gas = append(res.gas, receipt.CumulativeGasUsed)
+		}
+		return res, nil
+	}
+	var want []*result
+	for i, block := range blocks {
+		res, err := process(block)
+		if err != nil {
+			t.Fatalf("block %d: failed to process: %v", i, err)
+		}
+		want = append(want, res)
+	}
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			for i := 0; i < 16; i++ {
+				index := (n + i) % len(blocks)
+				have, err := process(blocks[index])
+				if err != nil {
+					t.Errorf("worker %d: block %d: failed to process: %v", n, index, err)
+					return
+				}
+				if !reflect.DeepEqual(have, want[index]) {
+					t.Errorf("worker %d: block %d: result mismatch: have %+v, want %+v", n, index, have, want[index])
+					return
+				}
+			}
+		}(n)
+	}
+	wg.Wait()
+}
+
+func BenchmarkProcessScratch(b *testing.B) {
+	chain := new(BlockChain)
+	b.Run("fresh", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			gp, usedGas, cached := new(GasPool).AddGas(params.GenesisGasLimit), new(uint64), newHeaderCachingChain(chain)
+			if gp.Gas() == 0 || *usedGas != 0 || cached == nil {
+				b.Fatal("invalid scratch state")
+			}
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			scratch := newProcessScratch(chain, params.GenesisGasLimit)
+			if scratch.gp.Gas() == 0 || scratch.usedGas != 0 {
+				b.Fatal("invalid scratch state")
+			}
+			scratch.release()
+		}
+	})
+}
+
+func BenchmarkProcessBlock(b *testing.B) {
+	gspec := &Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  types.GenesisAlloc{processorTestAddress: {Balance: big.NewInt(params.Ether)}},
+	}
+	db := abeydb.NewMemDatabase()
+	genesis := gspec.MustFastCommit(db)
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, minerva.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, minerva.NewFaker(), db, 1, func(i int, gen *BlockGen) {
+		for j := 0; j < 100; j++ {
+			tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(processorTestAddress), common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), signer, processorTestKey)
+			gen.AddTx(tx)
+		}
+	})
+	processor := NewStateProcessor(params.TestChainConfig, blockchain, minerva.NewFaker())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		statedb, _ := state.New(genesis.Root(), state.NewDatabase(db))
+		if _, _, _, _, err := processor.Process(blocks[0], statedb, vm.Config{}); err != nil {
+			b.Fatalf("failed to process block: %v", err)
+		}
+	}
+}
+
+// senderMap is a SenderCache backed by a map of transaction hashes to senders.
+type senderMap map[common.Hash]common.Address
+
+func (m senderMap) Sender(signer types.Signer, hash common.Hash) (common.Address, bool) {
+	from, ok := m[hash]
+	return from, ok
+}
+
+// newSenderTestBlock creates a block of transfers on top of the genesis, returning
+// it along with a cache of its senders.
+func newSenderTestBlock(genesis *types.Block, db abeydb.Database, txs int) (*types.Block, senderMap) {
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, minerva.NewFaker(), db, 1, func(i int, gen *BlockGen) {
+		for j := 0; j < txs; j++ {
+			tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(processorTestAddress), common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), signer, processorTestKey)
+			gen.AddTx(tx)
+		}
+	})
+	senders := make(senderMap)
+	for _, tx := range blocks[0].Transactions() {
+		senders[tx.Hash()] = processorTestAddress
+	}
+	return blocks[0], senders
+}
+
+// copyBlock returns a copy of block whose transactions carry no cached senders.
+func copyBlock(block *types.Block) *types.Block {
+	enc, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		panic(err)
+	}
+	cpy := new(types.Block)
+	if err := rlp.DecodeBytes(enc, cpy); err != nil {
+		panic(err)
+	}
+	return cpy
+}
+
+// Tests that processing a block with the senders taken from a sender cache yields
+// the same results as recovering them.
+func TestProcessCachedSenders(t *testing.T) {
+	blockchain, genesis, db := newProcessorTestChain(t, nil)
+	defer blockchain.Stop()
+
+	block, senders := newSenderTestBlock(genesis, db, 5)
+	processor := NewStateProcessor(params.TestChainConfig, blockchain, minerva.NewFaker())
+	process := func() (types.Receipts, uint64, common.Hash, error) {
+		statedb := newProcessorTestState(t, genesis, db)
+		receipts, _, usedGas, _, err := processor.Process(copyBlock(block), statedb, vm.Config{})
+		return receipts, usedGas, statedb.IntermediateRoot(true), err
+	}
+	wantReceipts, wantGas, wantRoot, err := process()
+	if err != nil {
+		t.Fatalf("failed to process block: %v", err)
+	}
+	processor.SetSenderCache(senders)
+	receipts, usedGas, root, err := process()
+	if err != nil {
+		t.Fatalf("failed to process block with cached senders: %v", err)
+	}
+	if !reflect.DeepEqual(receipts, wantReceipts) {
+		t.Errorf("receipts mismatch: have %v, want %v", receipts, wantReceipts)
+	}
+	if usedGas != wantGas || root != wantRoot {
+		t.Errorf("result mismatch: have gas %d root %x, want gas %d root %x", usedGas, root, wantGas, wantRoot)
+	}
+	// Cached senders are trusted as is, so an unfunded one must fail the block
+	processor.SetSenderCache(senderMap{block.Transactions()[0].Hash(): {0xff}})
+	if _, _, _, err := process(); err == nil {
+		t.Fatalf("cached sender not used")
+	}
+}
+
+func BenchmarkProcessCachedSenders(b *testing.B) {
+	gspec := &Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  types.GenesisAlloc{processorTestAddress: {Balance: big.NewInt(params.Ether)}},
+	}
+	db := abeydb.NewMemDatabase()
+	genesis := gspec.MustFastCommit(db)
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, minerva.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	block, senders := newSenderTestBlock(genesis, db, 100)
+	for _, cache := range []struct {
+		name    string
+		senders SenderCache
+	}{{"recovered", nil}, {"cached", senders}} {
+		b.Run(cache.name, func(b *testing.B) {
+			processor := NewStateProcessor(params.TestChainConfig, blockchain, minerva.NewFaker())
+			processor.SetSenderCache(cache.senders)
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				fresh := copyBlock(block)
+				statedb, _ := state.New(genesis.Root(), state.NewDatabase(db))
+				b.StartTimer()
+
+				if _, _, _, _, err := processor.Process(fresh, statedb, vm.Config{}); err != nil {
+					b.Fatalf("failed to process block: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// headerCountingChain is a ChainContext counting the header lookups served by
+// the wrapped blockchain.
+type headerCountingChain struct {
+	*BlockChain
+	lookups int
+}
+
+func (c *headerCountingChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	c.lookups++
+	return c.BlockChain.GetHeader(hash, number)
+}
+
+func TestHeaderCachingChainBlockHash(t *testing.T) {
+	// A contract storing BLOCKHASH(NUMBER-k) into slot k for k = 1..5
+	var code []byte
+	for k := byte(1); k <= 5; k++ {
+		code = append(code, byte(vm.PUSH1), k, byte(vm.NUMBER), byte(vm.SUB), byte(vm.BLOCKHASH), byte(vm.PUSH1), k, byte(vm.SSTORE))
+	}
+	code = append(code, byte(vm.STOP))
+
+	contract := common.Address{0xc1}
+	blockchain, genesis, db := newProcessorTestChain(t, types.GenesisAlloc{contract: {Code: code, Balance: new(big.Int)}})
+	defer blockchain.Stop()
+
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, minerva.NewFaker(), db, 5, nil)
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	var (
+		parent = blocks[len(blocks)-1]
+		header = &types.Header{
+			ParentHash: parent.Hash(),
+			Number:     new(big.Int).Add(parent.Number(), common.Big1),
+			GasLimit:   parent.GasLimit(),
+			Time:       new(big.Int).Add(parent.Time(), big.NewInt(10)),
+		}
+		signer = types.NewTIP1Signer(params.TestChainConfig.ChainID)
+		txs    []*types.Transaction
+	)
+	for i := uint64(0); i < 4; i++ {
+		tx, err := types.SignTx(types.NewTransaction(i, contract, new(big.Int), 200000, nil, nil), signer, processorTestKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		txs = append(txs, tx)
+	}
+	// Applies all the transactions against the given chain context, checking
+	// the stored hashes and returning the number of header lookups.
+	run := func(counter *headerCountingChain, chain ChainContext) int {
+		statedb, err := state.New(parent.Root(), state.NewDatabase(db))
+		if err != nil {
+			t.Fatalf("failed to create state: %v", err)
+		}
+		var (
+			usedGas uint64
+			gp      = new(GasPool).AddGas(header.GasLimit)
+		)
+		for i, tx := range txs {
+			statedb.Prepare(tx.HashOld(), common.Hash{}, i)
+			receipt, err := ApplyTransaction(params.TestChainConfig, chain, gp, statedb, header, tx, &usedGas, new(big.Int), vm.Config{})
+			if err != nil {
+				t.Fatalf("tx %d: failed to apply: %v", i, err)
+			}
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				t.Fatalf("tx %d: execution failed", i)
+			}
+		}
+		for k := uint64(1); k <= 5; k++ {
+			want := blockchain.GetHeaderByNumber(header.Number.Uint64() - k).Hash()
+			if have := statedb.GetState(contract, common.BigToHash(new(big.Int).SetUint64(k))); have != want {
+				t.Fatalf("BLOCKHASH(NUMBER-%d) mismatch: have %x, want %x", k, have, want)
+			}
+		}
+		return counter.lookups
+	}
+	plain := &headerCountingChain{BlockChain: blockchain}
+	uncached := run(plain, plain)
+
+	counter := &headerCountingChain{BlockChain: blockchain}
+	cached := run(counter, newHeaderCachingChain(counter))
+
+	// Every transaction walks the four ancestors beyond the parent on its own,
+	// while the cache fetches each of them only once.
+	if uncached != 4*len(txs) {
+		t.Fatalf("uncached lookup count mismatch: have %d, want %d", uncached, 4*len(txs))
+	}
+	if cached != 4 {
+		t.Fatalf("cached lookup count mismatch: have %d, want %d", cached, 4)
+	}
+}
+
+// Tests that the canonical hash of a transaction is the legacy one below the
+// TIP10 activation and the current one from it on.
+func TestCanonicalTxHash(t *testing.T) {
+	config := *params.TestChainConfig
+	config.TIP10 = &params.BlockConfig{FastNumber: big.NewInt(100)}
+
+	tx, err := types.SignTx(types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), params.TxGas, nil, nil), types.NewTIP1Signer(config.ChainID), processorTestKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if tx.HashOld() == tx.Hash() {
+		t.Fatalf("legacy and current transaction hashes coincide")
+	}
+	tests := []struct {
+		number int64
+		legacy bool
+	}{
+		{0, true},
+		{99, true},
+		{100, false},
+		{101, false},
+	}
+	for _, tt := range tests {
+		want := tx.Hash()
+		if tt.legacy {
+			want = tx.HashOld()
+		}
+		if have := CanonicalTxHash(&config, big.NewInt(tt.number), tx); have != want {
+			t.Errorf("block %d: hash mismatch: have %x, want %x", tt.number, have, want)
+		}
+	}
+	// Chains never activating TIP10 keep the legacy hash
+	config.TIP10 = nil
+	if have := CanonicalTxHash(&config, big.NewInt(101), tx); have != tx.HashOld() {
+		t.Errorf("TIP10 unset: hash mismatch: have %x, want %x", have, tx.HashOld())
+	}
+}
+
+// Tests that receipts of blocks on either side of the TIP10 activation identify
+// their transactions by the hash valid at their height, while the receipts root
+// committed to by the header is unaffected by the switch.
+func TestDeriveReceiptsRootTIP10(t *testing.T) {
+	config := *params.TestChainConfig
+	config.TIP10 = &params.BlockConfig{FastNumber: big.NewInt(2)}
+
+	blockchain, genesis, db := newProcessorTestChain(t, nil)
+	defer blockchain.Stop()
+
+	statedb := newProcessorTestState(t, genesis, db)
+	signer := types.NewTIP1Signer(config.ChainID)
+	for nonce, number := range []int64{1, 2} {
+		header := &types.Header{
+			ParentHash:  genesis.Hash(),
+			Number:      big.NewInt(number),
+			SnailNumber: new(big.Int),
+			GasLimit:    genesis.GasLimit(),
+			Time:        new(big.Int).Add(genesis.Time(), big.NewInt(10*number)),
+		}
+		tx, err := types.SignTx(types.NewTransaction(uint64(nonce), common.Address{0x01}, big.NewInt(1), params.TxGas, nil, nil), signer, processorTestKey)
+		if err != nil {
+			t.Fatalf("block %d: failed to sign transaction: %v", number, err)
+		}
+		want, other := tx.HashOld(), tx.Hash()
+		if number >= config.TIP10.FastNumber.Int64() {
+			want, other = other, want
+		}
+		if want == other {
+			t.Fatalf("block %d: legacy and current transaction hashes coincide", number)
+		}
+		if have := CanonicalTxHash(&config, header.Number, tx); have != want {
+			t.Errorf("block %d: transaction hash mismatch: have %x, want %x", number, have, want)
+		}
+		var (
+			usedGas uint64
+			gp      = new(GasPool).AddGas(header.GasLimit)
+		)
+		statedb.Prepare(want, common.Hash{}, 0)
+		receipt, err := ApplyTransaction(&config, blockchain, gp, statedb, header, tx, &usedGas, new(big.Int), vm.Config{})
+		if err != nil {
+			t.Fatalf("block %d: failed to apply transaction: %v", number, err)
+		}
+		if receipt.TxHash != want {
+			t.Errorf("block %d: receipt transaction hash mismatch: have %x, want %x", number, receipt.TxHash, want)
+		}
+		receipts := types.Receipts{receipt}
+		block := types.NewBlock(header, []*types.Transaction{tx}, receipts, nil, nil)
+		if root := DeriveReceiptsRoot(receipts); root != block.ReceiptHash() {
+			t.Errorf("block %d: receipts root mismatch: have %x, want %x", number, root, block.ReceiptHash())
+		}
+		// Identifying the transaction by the hash of the other side of the switch
+		// must not change the root
+		receipt.TxHash = other
+		if root := DeriveReceiptsRoot(receipts); root != block.ReceiptHash() {
+			t.Errorf("block %d: receipts root depends on the transaction hash: have %x, want %x", number, root, block.ReceiptHash())
+		}
+	}
+}
+
+func TestResolveName(t *testing.T) {
+	// The resolver answers addr(bytes32) with the address stored under the name
+	// hash, ignoring the selector
+	var (
+		resolver = common.Address{0xe5}
+		node     = crypto.Keccak256Hash([]byte("wallet.abey"))
+		owner    = common.HexToAddress("0x00000000000000000000000000000000c0ffee01")
+		code     = []byte{
+			byte(vm.PUSH1), 4, byte(vm.CALLDATALOAD), byte(vm.SLOAD),
+			byte(vm.PUSH1), 0, byte(vm.MSTORE),
+			byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN),
+		}
+	)
+	// The calls are sent from the zero address, whose nonce must not matter
+	alloc := types.GenesisAlloc{
+		resolver:         {Code: code, Storage: map[common.Hash]common.Hash{node: common.BytesToHash(owner.Bytes())}, Balance: new(big.Int)},
+		common.Address{}: {Nonce: 3, Balance: new(big.Int)},
+	}
+	blockchain, genesis, db := newProcessorTestChain(t, alloc)
+	defer blockchain.Stop()
+
+	header := newProcessorTestHeader(genesis)
+	resolve := func(resolver common.Address, node common.Hash) (common.Address, error) {
+		statedb := newProcessorTestState(t, genesis, db)
+		return ResolveName(params.TestChainConfig, blockchain, statedb, header, resolver, node, vm.Config{})
+	}
+	if addr, err := resolve(resolver, node); err != nil || addr != owner {
+		t.Errorf("resolved address mismatch: have %x, %v, want %x", addr, err, owner)
+	}
+	// Unknown names resolve to the zero address
+	if addr, err := resolve(resolver, common.Hash{0x01}); err != nil || addr != (common.Address{}) {
+		t.Errorf("unknown name mismatch: have %x, %v, want zero address", addr, err)
+	}
+	// Accounts without code return nothing to decode
+	if _, err := resolve(common.Address{0xe6}, node); !errors.Is(err, ErrInvalidResolverOutput) {
+		t.Errorf("codeless resolver error mismatch: have %v, want %v", err, ErrInvalidResolverOutput)
+	}
+}
@@ -116,12 +116,20 @@ func (result *ExecutionResult) Revert() []byte {
 
 // IntrinsicGas computes the 'intrinsic gas' for a message with the given data.
 func IntrinsicGas(data []byte, contractCreation, homestead bool) (uint64, error) {
+	return IntrinsicGasAt(nil, data, contractCreation, homestead)
+}
+
+// IntrinsicGasAt computes the intrinsic gas like IntrinsicGas, following the
+// schedule of the given chain, or the mainnet one if config is nil.
+func IntrinsicGasAt(config *params.ChainConfig, data []byte, contractCreation, homestead bool) (uint64, error) {
+	schedule := config.IntrinsicGasSchedule()
+
 	// Set the starting gas for the raw transaction
 	var gas uint64
 	if contractCreation && homestead {
-		gas = params.TxGasContractCreation
+		gas = schedule.TxGasContractCreation
 	} else {
-		gas = params.TxGas
+		gas = schedule.TxGas
 	}
 	// Bump the required gas by the amount of transactional data
 	if len(data) > 0 {
@@ -133,16 +141,16 @@ func IntrinsicGas(data []byte, contractCreation, homestead bool) (uint64, error)
 			}
 		}
 		// Make sure we don't exceed uint64 for all data combinations
-		if (math.MaxUint64-gas)/params.TxDataNonZeroGas < nz {
+		if (math.MaxUint64-gas)/schedule.TxDataNonZeroGas < nz {
 			return 0, ErrGasUintOverflow
 		}
-		gas += nz * params.TxDataNonZeroGas
+		gas += nz * schedule.TxDataNonZeroGas
 
 		z := uint64(len(data)) - nz
-		if (math.MaxUint64-gas)/params.TxDataZeroGas < z {
+		if (math.MaxUint64-gas)/schedule.TxDataZeroGas < z {
 			return 0, ErrGasUintOverflow
 		}
-		gas += z * params.TxDataZeroGas
+		gas += z * schedule.TxDataZeroGas
 	}
 	return gas, nil
 }
@@ -247,7 +255,7 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 	contractCreation := msg.To() == nil
 
 	// Pay intrinsic gas
-	gas, err := IntrinsicGas(st.data, contractCreation, true)
+	gas, err := IntrinsicGasAt(st.evm.ChainConfig(), st.data, contractCreation, true)
 	if err != nil {
 		return nil, err
 	}
@@ -47,6 +47,10 @@ const (
 )
 
 var (
+	// ErrAlreadyKnown is returned if the transaction is already contained within
+	// the pool.
+	ErrAlreadyKnown = errors.New("known transaction")
+
 	// ErrInvalidSender is returned if the transaction contains an invalid signature.
 	ErrInvalidSender = errors.New("invalid sender")
 
@@ -97,6 +101,75 @@ var (
 	ErrOversizedData = errors.New("oversized data")
 )
 
+// TxRejectReason categorises why a transaction was refused by a transaction
+// pool, telling clients what to change to get it admitted.
+type TxRejectReason string
+
+const (
+	TxRejectKnown              TxRejectReason = "already known"           // Transaction already pooled
+	TxRejectUnderpriced        TxRejectReason = "underpriced"             // Gas price below the pool minimum
+	TxRejectReplaceUnderpriced TxRejectReason = "replacement underpriced" // Price bump too low to replace a pooled transaction
+	TxRejectNonceTooLow        TxRejectReason = "nonce too low"           // Nonce already used on chain
+	TxRejectInsufficientFunds  TxRejectReason = "insufficient funds"      // Sender or payer unable to cover the cost
+	TxRejectIntrinsicGas       TxRejectReason = "intrinsic gas too low"   // Gas below the intrinsic cost
+	TxRejectGasLimit           TxRejectReason = "exceeds gas limit"       // Gas above the block gas limit
+	TxRejectOversized          TxRejectReason = "oversized"               // Encoding above the pool size limit
+	TxRejectInvalidSignature   TxRejectReason = "invalid signature"       // Sender or payer not recoverable
+	TxRejectInvalidValue       TxRejectReason = "invalid value"           // Negative value or fee
+)
+
+// txRejectReasons maps the admission errors of the transaction pools onto the
+// reasons they are reported with.
+var txRejectReasons = []struct {
+	err    error
+	reason TxRejectReason
+}{
+	{ErrAlreadyKnown, TxRejectKnown},
+	{ErrUnderpriced, TxRejectUnderpriced},
+	{ErrReplaceUnderpriced, TxRejectReplaceUnderpriced},
+	{ErrNonceTooLow, TxRejectNonceTooLow},
+	{ErrInsufficientFunds, TxRejectInsufficientFunds},
+	{ErrInsufficientFundsForPayer, TxRejectInsufficientFunds},
+	{ErrInsufficientFundsForSender, TxRejectInsufficientFunds},
+	{ErrIntrinsicGas, TxRejectIntrinsicGas},
+	{ErrGasLimit, TxRejectGasLimit},
+	{ErrOversizedData, TxRejectOversized},
+	{ErrInvalidSender, TxRejectInvalidSignature},
+	{ErrInvalidPayer, TxRejectInvalidSignature},
+	{ErrNegativeValue, TxRejectInvalidValue},
+	{ErrNegativeFee, TxRejectInvalidValue},
+}
+
+// TxRejectedError is returned by the transaction submission of the API backends
+// if a transaction pool refuses a transaction, wrapping the error of the pool.
+type TxRejectedError struct {
+	Reason TxRejectReason // Category of the rejection
+	Err    error          // Error the pool refused the transaction with
+}
+
+func (e *TxRejectedError) Error() string { return e.Err.Error() }
+
+func (e *TxRejectedError) Unwrap() error { return e.Err }
+
+// ClassifyTxError wraps an admission error of a transaction pool into a
+// TxRejectedError carrying its reason. Errors of no known reason, including
+// nil, are returned as they are.
+func ClassifyTxError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var rejected *TxRejectedError
+	if errors.As(err, &rejected) {
+		return err
+	}
+	for _, r := range txRejectReasons {
+		if errors.Is(err, r.err) {
+			return &TxRejectedError{Reason: r.reason, Err: err}
+		}
+	}
+	return err
+}
+
 var (
 	evictionInterval      = time.Minute     // Time interval to check for evictable transactions
 	statsReportInterval   = 8 * time.Second // Time interval to report transaction pool stats
@@ -545,6 +618,26 @@ func (pool *TxPool) State() *state.ManagedState {
 	return pool.pendingState
 }
 
+// NonceGap reports the nonces missing between the executable transactions of an
+// account and its queued ones, which keep the queued transactions from ever
+// executing. The missing nonces are [start, end), starting at the pending nonce
+// of the account, and gap is false if no queued transaction waits for them.
+func (pool *TxPool) NonceGap(addr common.Address) (start, end uint64, gap bool) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	start = pool.pendingState.GetNonce(addr)
+	list := pool.queue[addr]
+	if list == nil || list.Empty() {
+		return start, start, false
+	}
+	end = list.Flatten()[0].Nonce()
+	if end <= start {
+		return start, start, false
+	}
+	return start, end, true
+}
+
 // Stats retrieves the current pool stats, namely the number of pending and the
 // number of queued (non-executable) transactions.
 func (pool *TxPool) Stats() (int, int) {
@@ -585,6 +678,22 @@ func (pool *TxPool) Content() (map[common.Address]types.Transactions, map[common
 	return pending, queued
 }
 
+// ContentFrom retrieves the pending and queued transactions of the given account,
+// each sorted by nonce, leaving the other accounts of the pool untouched.
+func (pool *TxPool) ContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	var pending, queued types.Transactions
+	if list, ok := pool.pending[addr]; ok {
+		pending = list.Flatten()
+	}
+	if list, ok := pool.queue[addr]; ok {
+		queued = list.Flatten()
+	}
+	return pending, queued
+}
+
 // Pending retrieves all currently processable transactions, groupped by origin
 // account and sorted by nonce. The returned transaction set is a copy and can be
 // freely modified by calling code.
@@ -599,6 +708,24 @@ func (pool *TxPool) Pending() (map[common.Address]types.Transactions, error) {
 	return pending, nil
 }
 
+// PendingPriceHistogram counts the pending transactions by gas price, bucketed by
+// the given ascending bounds. The count at index i covers the prices from
+// bounds[i-1] up to but excluding bounds[i], the first one the prices below
+// bounds[0] and the last one the prices of bounds[len(bounds)-1] and above.
+func (pool *TxPool) PendingPriceHistogram(bounds []*big.Int) []int {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	counts := make([]int, len(bounds)+1)
+	for _, list := range pool.pending {
+		for _, tx := range list.txs.items {
+			price := tx.GasPrice()
+			counts[sort.Search(len(bounds), func(i int) bool { return bounds[i].Cmp(price) > 0 })]++
+		}
+	}
+	return counts
+}
+
 // local retrieves all currently known local transactions, groupped by origin
 // account and sorted by nonce. The returned transaction set is a copy and can be
 // freely modified by calling code.
@@ -643,7 +770,7 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 		//return fmt.Errorf("%v err is:%v", ErrInvalidSender, err)
 	}
 
-	if pool.chain.CurrentBlock().Number().Cmp(big.NewInt(6638000)) > 0 {
+	if pool.chainconfig.IsForbid(pool.chain.CurrentBlock().Number()) {
 		if err := types.ForbidAddress(from); err != nil {
 			return err
 		}
@@ -686,7 +813,7 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 			//return fmt.Errorf("%v your balance:%d;tx.Cost():%d", ErrInsufficientFunds, pool.currentState.GetBalance(from), tx.Cost())
 		}
 	}
-	intrGas, err := IntrinsicGas(tx.Data(), tx.To() == nil, true)
+	intrGas, err := IntrinsicGasAt(pool.chainconfig, tx.Data(), tx.To() == nil, true)
 	if err != nil {
 		return err
 	}
@@ -710,7 +837,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 	hash := tx.Hash()
 	if pool.all.Get(hash) != nil {
 		log.Trace("Discarding already known transaction", "hash", hash)
-		return false, fmt.Errorf("known transaction: %x", hash)
+		return false, fmt.Errorf("%w: %x", ErrAlreadyKnown, hash)
 	}
 	// If the transaction fails basic validation, discard it
 	if err := pool.validateTx(tx, local); err != nil {
@@ -871,6 +998,31 @@ func (pool *TxPool) AddLocal(tx *types.Transaction) error {
 	return pool.addTx(tx, !pool.config.NoLocals)
 }
 
+// ValidateTransaction checks whether a transaction would be accepted into the
+// pool as a local one, without adding it. On top of the pool rules, the sender
+// is checked against the forbidden addresses at the height of the next block,
+// as the state processor will when executing it.
+func (pool *TxPool) ValidateTransaction(tx *types.Transaction) error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	hash := tx.Hash()
+	if pool.all.Get(hash) != nil {
+		return fmt.Errorf("%w: %x", ErrAlreadyKnown, hash)
+	}
+	if err := pool.validateTx(tx, !pool.config.NoLocals); err != nil {
+		return err
+	}
+	next := new(big.Int).Add(pool.chain.CurrentBlock().Number(), common.Big1)
+	if pool.chainconfig.IsForbid(next) {
+		from, _ := types.Sender(pool.signer, tx) // already validated
+		if err := types.ForbidAddress(from); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // AddRemote enqueues a single transaction into the pool if it is valid. If the
 // sender is not among the locally tracked ones, full pricing constraints will
 // apply.
@@ -992,6 +1144,17 @@ func (pool *TxPool) Get(hash common.Hash) *types.Transaction {
 	return pool.all.Get(hash)
 }
 
+// Sender returns the sender of a pooled transaction as verified on admission,
+// if it was derived with a signer equal to the given one. It implements the
+// SenderCache of the state processor.
+func (pool *TxPool) Sender(signer types.Signer, hash common.Hash) (common.Address, bool) {
+	tx := pool.all.Get(hash)
+	if tx == nil {
+		return common.Address{}, false
+	}
+	return types.CachedSender(signer, tx)
+}
+
 // removeTx removes a single transaction from the queue, moving all subsequent
 // transactions back to the future queue.
 func (pool *TxPool) removeTx(hash common.Hash, outofbound bool) {
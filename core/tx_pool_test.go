@@ -18,11 +18,13 @@ package core
 
 import (
 	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/big"
 	"math/rand"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -79,7 +81,7 @@ func transaction(nonce uint64, gaslimit uint64, key *ecdsa.PrivateKey) *types.Tr
 
 func pricedTransaction(nonce uint64, gaslimit uint64, gasprice *big.Int, key *ecdsa.PrivateKey) *types.Transaction {
 	rawTx := types.NewTransaction(nonce, common.Address{}, big.NewInt(100), gaslimit, gasprice, nil)
-	tx, _ := types.SignTx(rawTx, types.NewTIP1Signer(rawTx.ChainId()), key)
+	tx, _ := types.SignTx(rawTx, types.NewTIP1Signer(params.TestChainConfig.ChainID), key)
 	return tx
 }
 
@@ -726,6 +728,45 @@ func TestTransactionGapFilling(t *testing.T) {
 	}
 }
 
+// Tests that nonce gaps keeping queued transactions from executing are reported
+// together with the range of missing nonces.
+func TestTransactionNonceGap(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	pool.SetGasPrice(big.NewInt(1))
+	account, _ := deriveSender(transaction(0, 0, key))
+	pool.currentState.AddBalance(account, big.NewInt(100000000000000))
+
+	// Without queued transactions there's no gap
+	if err := pool.AddRemote(transaction(0, 100000, key)); err != nil {
+		t.Fatalf("failed to add pending transaction: %v", err)
+	}
+	if start, end, gap := pool.NonceGap(account); gap || start != 1 || end != 1 {
+		t.Fatalf("unexpected gap: have [%d, %d) %v, want [1, 1) false", start, end, gap)
+	}
+	// Queue transactions behind missing nonces 1 and 2
+	for _, nonce := range []uint64{3, 5} {
+		if err := pool.AddRemote(transaction(nonce, 100000, key)); err != nil {
+			t.Fatalf("failed to add queued transaction %d: %v", nonce, err)
+		}
+	}
+	if start, end, gap := pool.NonceGap(account); !gap || start != 1 || end != 3 {
+		t.Fatalf("gap mismatch: have [%d, %d) %v, want [1, 3) true", start, end, gap)
+	}
+	// Filling the first gap exposes the one behind it
+	for _, nonce := range []uint64{1, 2} {
+		if err := pool.AddRemote(transaction(nonce, 100000, key)); err != nil {
+			t.Fatalf("failed to add gapped transaction %d: %v", nonce, err)
+		}
+	}
+	if start, end, gap := pool.NonceGap(account); !gap || start != 4 || end != 5 {
+		t.Fatalf("gap mismatch: have [%d, %d) %v, want [4, 5) true", start, end, gap)
+	}
+}
+
 // Tests that if the transaction count belonging to a single account goes above
 // some threshold, the higher transactions are dropped to prevent DOS attacks.
 func TestTransactionQueueAccountLimiting(t *testing.T) {
@@ -1779,6 +1820,132 @@ func TestTransactionStatusCheck(t *testing.T) {
 	}
 }
 
+// Tests that transactions can be validated against the pool rules and the
+// forbidden senders without being added to the pool.
+func TestTransactionValidation(t *testing.T) {
+	t.Parallel()
+
+	// Forbid the listed senders from the first block on
+	config := *params.TestChainConfig
+	config.ForbidBlock = big.NewInt(0)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(abeydb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool := NewTxPool(testTxPoolConfig, &config, blockchain)
+	defer pool.Stop()
+	pool.SetGasPrice(big.NewInt(1000))
+
+	key, _ := crypto.GenerateKey()
+	forbidden, _ := crypto.GenerateKey()
+	for _, k := range []*ecdsa.PrivateKey{key, forbidden} {
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(k.PublicKey), big.NewInt(1000000000000))
+	}
+	pool.currentState.SetNonce(crypto.PubkeyToAddress(key.PublicKey), 2)
+
+	types.ForbiddenAddresses.Add(crypto.PubkeyToAddress(forbidden.PublicKey))
+	defer types.ForbiddenAddresses.Remove(crypto.PubkeyToAddress(forbidden.PublicKey))
+
+	tests := []struct {
+		tx  *types.Transaction
+		err error
+	}{
+		{pricedTransaction(2, 100000, big.NewInt(1000), key), nil},
+		{pricedTransaction(2, 100000, big.NewInt(999), key), ErrUnderpriced},
+		{pricedTransaction(1, 100000, big.NewInt(1000), key), ErrNonceTooLow},
+		{pricedTransaction(0, 100000, big.NewInt(1000), forbidden), types.ErrForbidAddress},
+	}
+	for i, tt := range tests {
+		if err := pool.ValidateTransaction(tt.tx); !errors.Is(err, tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	// Validation must leave the pool untouched
+	if pending, queued := pool.Stats(); pending != 0 || queued != 0 {
+		t.Fatalf("validated transactions pooled: have %d pending, %d queued", pending, queued)
+	}
+}
+
+// Tests that the pending transactions are counted in the gas price bucket they
+// belong to, leaving the queued ones out.
+func TestTransactionPendingPriceHistogram(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	pool.SetGasPrice(big.NewInt(1))
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000000))
+
+	var txs []*types.Transaction
+	for nonce, price := range []int64{1, 5, 5, 10, 20, 20, 49, 100} {
+		txs = append(txs, pricedTransaction(uint64(nonce), 100000, big.NewInt(price), key))
+	}
+	txs = append(txs, pricedTransaction(10, 100000, big.NewInt(100), key))
+	for i, err := range pool.AddLocals(txs) {
+		if err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	if pending, queued := pool.Stats(); pending != 8 || queued != 1 {
+		t.Fatalf("pool content mismatch: have %d pending, %d queued, want 8 and 1", pending, queued)
+	}
+	bounds := []*big.Int{big.NewInt(5), big.NewInt(20), big.NewInt(50)}
+	if have, want := pool.PendingPriceHistogram(bounds), []int{1, 3, 3, 1}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("histogram mismatch: have %v, want %v", have, want)
+	}
+	// Without bounds all pending transactions share a single bucket
+	if have := pool.PendingPriceHistogram(nil); !reflect.DeepEqual(have, []int{8}) {
+		t.Fatalf("unbounded histogram mismatch: have %v, want [8]", have)
+	}
+}
+
+// Tests that the content of a single account is retrieved in nonce order, apart
+// from the transactions of the other accounts.
+func TestTransactionContentFrom(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	pool.SetGasPrice(big.NewInt(1))
+	other, _ := crypto.GenerateKey()
+	for _, k := range []*ecdsa.PrivateKey{key, other} {
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(k.PublicKey), big.NewInt(1000000000000))
+	}
+	// Interleave the accounts, pooling nonces out of order and past a gap
+	var txs []*types.Transaction
+	for _, nonce := range []uint64{2, 0, 5, 1} {
+		txs = append(txs, transaction(nonce, 100000, key), transaction(nonce, 100000, other))
+	}
+	for i, err := range pool.AddLocals(txs) {
+		if err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	pending, queued := pool.ContentFrom(crypto.PubkeyToAddress(key.PublicKey))
+	check := func(kind string, txs types.Transactions, nonces []uint64) {
+		if len(txs) != len(nonces) {
+			t.Fatalf("%s count mismatch: have %d, want %d", kind, len(txs), len(nonces))
+		}
+		for i, tx := range txs {
+			if from, _ := deriveSender(tx); from != crypto.PubkeyToAddress(key.PublicKey) {
+				t.Errorf("%s %d: sender mismatch: have %x", kind, i, from)
+			}
+			if tx.Nonce() != nonces[i] {
+				t.Errorf("%s %d: nonce mismatch: have %d, want %d", kind, i, tx.Nonce(), nonces[i])
+			}
+		}
+	}
+	check("pending", pending, []uint64{0, 1, 2})
+	check("queued", queued, []uint64{5})
+
+	// Accounts unknown to the pool have no content
+	if pending, queued := pool.ContentFrom(common.Address{0x01}); len(pending) != 0 || len(queued) != 0 {
+		t.Errorf("unknown account content mismatch: have %d pending, %d queued", len(pending), len(queued))
+	}
+}
+
 // Benchmarks the speed of validating the contents of the pending queue of the
 // transaction pool.
 func BenchmarkPendingDemotion100(b *testing.B)   { benchmarkPendingDemotion(b, 100) }
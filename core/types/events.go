@@ -44,6 +44,23 @@ type PendingStateEvent struct{}
 // RemovedLogsEvent is posted when a reorg happens
 type RemovedLogsEvent struct{ Logs []*Log }
 
+// FilteredLogEvent is posted for a single log added to, or removed from the
+// canonical chain by a reorganisation.
+type FilteredLogEvent struct {
+	Log     *Log
+	Removed bool
+}
+
+// ReorgEvent is posted when a reorganisation replaces canonical blocks. Depth
+// is the number of blocks dropped from the old chain down to the common
+// ancestor.
+type ReorgEvent struct {
+	OldHead  *Block
+	NewHead  *Block
+	Ancestor *Block
+	Depth    int
+}
+
 type FastChainEvent struct {
 	Block *Block
 	Hash  common.Hash
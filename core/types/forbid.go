@@ -0,0 +1,108 @@
+package types
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/AbeyFoundation/go-abey/common"
+)
+
+// ForbiddenAddresses is the registry of accounts whose transactions are
+// rejected once the forbid height of the chain config has been reached. It is
+// populated with the built-in list and can be amended at runtime, taking effect
+// for all subsequently validated transactions.
+var ForbiddenAddresses = NewAddressRegistry(
+	common.HexToAddress("0xA218B46345B13b0c5E3E5625a1e1bb0b025FDD13"),
+	common.HexToAddress("0xd4f226f45a4030FB060e3cDc584D2eD0d3b474FE"),
+	common.HexToAddress("0x574e7b464340787A9de1A68784a89edF616768Fe"),
+	common.HexToAddress("0x7cb1024f02394CcE5F51dCdE0bb07e6B4358489b"),
+	common.HexToAddress("0x36840bBcF8bEfE91BDCc05046D76a5ba970c9317"),
+	common.HexToAddress("0x29Cd0c9385604f39966a3D1Ac88eFEca4309272f"),
+	common.HexToAddress("0x34b73756bde8d9ba0d3efa090838f8e71ce14bca"),
+	common.HexToAddress("0x8436c9882b6dDa3df48d673a623EAd675373914c"),
+	common.HexToAddress("0x8818d143773426071068C514Db25106338009363"),
+	common.HexToAddress("0x4eD71f64C4Dbd037B02BC4E1bD6Fd6900fcFd396"),
+	common.HexToAddress("0x36939d3324bd522Baba28b3F142Fed395A9751B9"),
+	common.HexToAddress("0x76fC12940EC8022D0F6D4d570d5cd685D223B29e"),
+
+	common.HexToAddress("0xB1eD4ba75082a753d87718422959A5499bE93a81"),
+	common.HexToAddress("0x3992Ff9a342d315E94A23Eccd235de3Fddf16fB6"),
+	common.HexToAddress("0x1BCc6e3d7d24E0fBBa57199e1F6889881e4f5a44"),
+	common.HexToAddress("0xa33830D226729927431FA3b98B8d3a45121942D5"),
+	common.HexToAddress("0x21BE07741842B76574b441EF79EfFAbe224AD7A3"),
+	common.HexToAddress("0x139C472a7C072079977552BfF0E194ACaDE48dA7"),
+	common.HexToAddress("0x7DB213cb13d9263f6D86C756ba2b8bDF1C86Aa62"),
+	common.HexToAddress("0xD1F3C3A7f18fe337793738060948Be6213a1f1B3"),
+	common.HexToAddress("0xFa5f818Fce5337B554A29195343A195E4CaBA477"),
+	common.HexToAddress("0xfF5Ca61dD101f4E01F394581148b71DccEe7DDaE"),
+	common.HexToAddress("0x5BB961f3f0A1253265e9a91EfE8AE5BFa2682419"),
+	common.HexToAddress("0xBf6a08f9EEc8640f67A4dbb970C6808852ed7769"),
+	common.HexToAddress("0x9423b473dbb50A0242FffA973786DdD5664736C9"),
+	common.HexToAddress("0xe2567E9222C96B3f6EB1b51d87CA0Cb3e22a338C"),
+	common.HexToAddress("0x954c5Fd1582DAae58fF5b583C505EE02DA163D3d"),
+	common.HexToAddress("0x5331043BA75A7d23D1776049f33EB1c4950a6892"),
+)
+
+// AddressRegistry is a set of addresses which is safe for concurrent use.
+type AddressRegistry struct {
+	addrs map[common.Address]struct{}
+	lock  sync.RWMutex
+}
+
+// NewAddressRegistry creates a registry populated with the given addresses.
+func NewAddressRegistry(addrs ...common.Address) *AddressRegistry {
+	r := &AddressRegistry{addrs: make(map[common.Address]struct{}, len(addrs))}
+	for _, addr := range addrs {
+		r.addrs[addr] = struct{}{}
+	}
+	return r
+}
+
+// Add inserts an address into the registry, returning whether it was new.
+func (r *AddressRegistry) Add(addr common.Address) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.addrs[addr]; ok {
+		return false
+	}
+	r.addrs[addr] = struct{}{}
+	return true
+}
+
+// Remove deletes an address from the registry, returning whether it was present.
+func (r *AddressRegistry) Remove(addr common.Address) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.addrs[addr]; !ok {
+		return false
+	}
+	delete(r.addrs, addr)
+	return true
+}
+
+// Contains reports whether the address is part of the registry.
+func (r *AddressRegistry) Contains(addr common.Address) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	_, ok := r.addrs[addr]
+	return ok
+}
+
+// List returns the addresses in the registry in ascending byte order.
+func (r *AddressRegistry) List() []common.Address {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	list := make([]common.Address, 0, len(r.addrs))
+	for addr := range r.addrs {
+		list = append(list, addr)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return bytes.Compare(list[i][:], list[j][:]) < 0
+	})
+	return list
+}
@@ -0,0 +1,56 @@
+package types
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/AbeyFoundation/go-abey/common"
+)
+
+func TestAddressRegistry(t *testing.T) {
+	var (
+		addr1 = common.HexToAddress("0x01")
+		addr2 = common.HexToAddress("0x02")
+		addr3 = common.HexToAddress("0x03")
+	)
+	registry := NewAddressRegistry(addr3, addr1)
+
+	if !registry.Contains(addr1) || !registry.Contains(addr3) || registry.Contains(addr2) {
+		t.Fatalf("initial membership mismatch")
+	}
+	if !registry.Add(addr2) {
+		t.Fatalf("new address reported as present")
+	}
+	if registry.Add(addr2) {
+		t.Fatalf("duplicate address reported as new")
+	}
+	list := registry.List()
+	if len(list) != 3 || list[0] != addr1 || list[1] != addr2 || list[2] != addr3 {
+		t.Fatalf("list mismatch: have %v", list)
+	}
+	if !registry.Remove(addr1) {
+		t.Fatalf("present address reported as missing")
+	}
+	if registry.Remove(addr1) {
+		t.Fatalf("removed address reported as present")
+	}
+	if registry.Contains(addr1) {
+		t.Fatalf("removed address still contained")
+	}
+}
+
+func TestForbidAddressRegistry(t *testing.T) {
+	addr := common.HexToAddress("0xdeadbeef")
+	if err := ForbidAddress(addr); err != nil {
+		t.Fatalf("address forbidden before registration: %v", err)
+	}
+	ForbiddenAddresses.Add(addr)
+	defer ForbiddenAddresses.Remove(addr)
+
+	if err := ForbidAddress(addr); !errors.Is(err, ErrForbidAddress) {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrForbidAddress)
+	}
+	if err := ForbidAddress(StakingAddress); !errors.Is(err, ErrForbidAddress) {
+		t.Fatalf("staking address error mismatch: have %v, want %v", err, ErrForbidAddress)
+	}
+}
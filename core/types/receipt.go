@@ -55,7 +55,9 @@ type Receipt struct {
 	// Implementation fields (don't reorder!)
 	TxHash          common.Hash    `json:"transactionHash" gencodec:"required"`
 	ContractAddress common.Address `json:"contractAddress"`
-	GasUsed         uint64         `json:"gasUsed" gencodec:"required"`
+	GasUsed         uint64         `json:"gasUsed" gencodec:"required"` // Gas consumed by this transaction alone, intrinsic gas included
+	FeePaid         *big.Int       `json:"feePaid,omitempty"`           // Fee carried by the transaction on top of the gas cost, nil if none
+	Payment         common.Address `json:"payment"`                     // Account paying for the transaction, zero if the sender paid
 
 	// Inclusion information: These fields provide information about the inclusion of the
 	// transaction corresponding to this receipt.
@@ -69,6 +71,7 @@ type receiptMarshaling struct {
 	Status            hexutil.Uint64
 	CumulativeGasUsed hexutil.Uint64
 	GasUsed           hexutil.Uint64
+	FeePaid           *hexutil.Big
 	BlockNumber       *hexutil.Big
 	TransactionIndex  hexutil.Uint
 }
@@ -90,6 +93,18 @@ type receiptStorageRLP struct {
 	ContractAddress   common.Address
 	Logs              []*LogForStorage
 	GasUsed           uint64
+
+	// Accounting holds the fee paid and the payment account of receipts that
+	// carry them. It is empty for receipts stored before these fields existed
+	// and for receipts without either, keeping their encoding unchanged.
+	Accounting []rlp.RawValue `rlp:"tail"`
+}
+
+// receiptAccountingRLP is the storage encoding of the accounting fields of a
+// receipt, appended to its storage encoding when present.
+type receiptAccountingRLP struct {
+	FeePaid *big.Int
+	Payment common.Address
 }
 
 // NewReceipt creates a barebone transaction receipt, copying the init fields.
@@ -179,6 +194,17 @@ func (r *ReceiptForStorage) EncodeRLP(w io.Writer) error {
 	for i, log := range r.Logs {
 		enc.Logs[i] = (*LogForStorage)(log)
 	}
+	if r.FeePaid != nil || r.Payment != (common.Address{}) {
+		acc := receiptAccountingRLP{FeePaid: r.FeePaid, Payment: r.Payment}
+		if acc.FeePaid == nil {
+			acc.FeePaid = new(big.Int)
+		}
+		blob, err := rlp.EncodeToBytes(&acc)
+		if err != nil {
+			return err
+		}
+		enc.Accounting = []rlp.RawValue{blob}
+	}
 	return rlp.Encode(w, enc)
 }
 
@@ -201,6 +227,13 @@ func (r *ReceiptForStorage) DecodeRLP(s *rlp.Stream) error {
 	}
 	// Assign the implementation fields
 	r.TxHash, r.ContractAddress, r.GasUsed = dec.TxHash, dec.ContractAddress, dec.GasUsed
+	if len(dec.Accounting) > 0 {
+		var acc receiptAccountingRLP
+		if err := rlp.DecodeBytes(dec.Accounting[0], &acc); err != nil {
+			return err
+		}
+		r.FeePaid, r.Payment = acc.FeePaid, acc.Payment
+	}
 	return nil
 }
 
@@ -0,0 +1,129 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/AbeyFoundation/go-abey/common"
+	"github.com/AbeyFoundation/go-abey/rlp"
+)
+
+// legacyReceiptStorageRLP is the storage encoding of receipts written before
+// the accounting fields were added.
+type legacyReceiptStorageRLP struct {
+	PostStateOrStatus []byte
+	Status            uint64
+	CumulativeGasUsed uint64
+	Bloom             Bloom
+	TxHash            common.Hash
+	ContractAddress   common.Address
+	Logs              []*LogForStorage
+	GasUsed           uint64
+}
+
+func TestReceiptStorageAccounting(t *testing.T) {
+	tests := []struct {
+		fee     *big.Int
+		payment common.Address
+	}{
+		{nil, common.Address{}},
+		{big.NewInt(12345), common.Address{}},
+		{nil, common.Address{0x02}},
+		{big.NewInt(1), common.Address{0x02}},
+	}
+	for i, tt := range tests {
+		receipt := &Receipt{
+			Status:            ReceiptStatusSuccessful,
+			CumulativeGasUsed: 21000,
+			TxHash:            common.Hash{0x01},
+			GasUsed:           21000,
+			Logs:              []*Log{},
+			FeePaid:           tt.fee,
+			Payment:           tt.payment,
+		}
+		enc, err := rlp.EncodeToBytes((*ReceiptForStorage)(receipt))
+		if err != nil {
+			t.Fatalf("test %d: failed to encode receipt: %v", i, err)
+		}
+		var dec ReceiptForStorage
+		if err := rlp.DecodeBytes(enc, &dec); err != nil {
+			t.Fatalf("test %d: failed to decode receipt: %v", i, err)
+		}
+		if dec.Payment != tt.payment {
+			t.Errorf("test %d: payment mismatch: have %x, want %x", i, dec.Payment, tt.payment)
+		}
+		switch {
+		case tt.fee == nil && tt.payment == (common.Address{}):
+			if dec.FeePaid != nil {
+				t.Errorf("test %d: unexpected fee paid: %v", i, dec.FeePaid)
+			}
+		case tt.fee == nil:
+			if dec.FeePaid == nil || dec.FeePaid.Sign() != 0 {
+				t.Errorf("test %d: fee paid mismatch: have %v, want 0", i, dec.FeePaid)
+			}
+		default:
+			if dec.FeePaid == nil || dec.FeePaid.Cmp(tt.fee) != 0 {
+				t.Errorf("test %d: fee paid mismatch: have %v, want %v", i, dec.FeePaid, tt.fee)
+			}
+		}
+		// The consensus encoding must not be affected by the accounting fields
+		plain := *receipt
+		plain.FeePaid, plain.Payment = nil, common.Address{}
+		have, _ := rlp.EncodeToBytes(receipt)
+		want, _ := rlp.EncodeToBytes(&plain)
+		if !bytes.Equal(have, want) {
+			t.Errorf("test %d: consensus encoding mismatch: have %x, want %x", i, have, want)
+		}
+	}
+}
+
+func TestReceiptStorageLegacyDecoding(t *testing.T) {
+	legacy := &legacyReceiptStorageRLP{
+		PostStateOrStatus: receiptStatusSuccessfulRLP,
+		Status:            ReceiptStatusSuccessful,
+		CumulativeGasUsed: 42000,
+		TxHash:            common.Hash{0x01},
+		ContractAddress:   common.Address{0x03},
+		Logs:              []*LogForStorage{},
+		GasUsed:           21000,
+	}
+	enc, err := rlp.EncodeToBytes(legacy)
+	if err != nil {
+		t.Fatalf("failed to encode legacy receipt: %v", err)
+	}
+	var dec ReceiptForStorage
+	if err := rlp.DecodeBytes(enc, &dec); err != nil {
+		t.Fatalf("failed to decode legacy receipt: %v", err)
+	}
+	if dec.GasUsed != legacy.GasUsed || dec.TxHash != legacy.TxHash || dec.ContractAddress != legacy.ContractAddress {
+		t.Errorf("implementation fields mismatch: have %+v", dec)
+	}
+	if dec.FeePaid != nil || dec.Payment != (common.Address{}) {
+		t.Errorf("unexpected accounting fields: fee %v, payment %x", dec.FeePaid, dec.Payment)
+	}
+	// Receipts without accounting fields must keep the legacy storage encoding
+	reenc, err := rlp.EncodeToBytes(&dec)
+	if err != nil {
+		t.Fatalf("failed to re-encode receipt: %v", err)
+	}
+	if !bytes.Equal(reenc, enc) {
+		t.Errorf("storage encoding changed: have %x, want %x", reenc, enc)
+	}
+}
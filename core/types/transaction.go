@@ -633,6 +633,25 @@ func (t *TransactionsByPriceAndNonce) Pop() {
 	heap.Pop(&t.heads)
 }
 
+// Runs drains the set in execution order, grouping the transactions into runs
+// of consecutive transactions sent by the same account.
+func (t *TransactionsByPriceAndNonce) Runs() [][]*Transaction {
+	var (
+		runs [][]*Transaction
+		last common.Address
+	)
+	for tx := t.Peek(); tx != nil; tx = t.Peek() {
+		from, _ := Sender(t.signer, tx)
+		if len(runs) == 0 || from != last {
+			runs = append(runs, nil)
+			last = from
+		}
+		runs[len(runs)-1] = append(runs[len(runs)-1], tx)
+		t.Shift()
+	}
+	return runs
+}
+
 // Message is a fully derived transaction and implements core.Message
 //
 // NOTE: In a future PR this will be removed.
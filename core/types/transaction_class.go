@@ -0,0 +1,88 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/AbeyFoundation/go-abey/common"
+)
+
+// TxClass is the kind of a transaction as shown to users.
+type TxClass uint8
+
+const (
+	TxClassTransfer         TxClass = iota // Value transfer without call data
+	TxClassContractCreation                // Deployment of a new contract
+	TxClassContractCall                    // Call carrying data to an account
+	TxClassPayment                         // Transaction whose gas is paid by a third party
+)
+
+func (c TxClass) String() string {
+	switch c {
+	case TxClassTransfer:
+		return "transfer"
+	case TxClassContractCreation:
+		return "contract creation"
+	case TxClassContractCall:
+		return "contract call"
+	case TxClassPayment:
+		return "payment"
+	default:
+		return fmt.Sprintf("unknown class %d", uint8(c))
+	}
+}
+
+// TxClassification is the class of a transaction together with the fields worth
+// highlighting when displaying it.
+type TxClassification struct {
+	Class   TxClass
+	To      *common.Address // Recipient, nil for contract creations
+	Value   *big.Int        // Value transferred to the recipient
+	Payment common.Address  // Account paying for the gas, empty if the sender pays
+	Fee     *big.Int        // Fee paid on top of the gas, nil if none
+	Creates bool            // Whether the transaction deploys a contract
+}
+
+// ClassifyTransaction classifies a transaction. A transaction paid for by a third
+// party is classified as such whatever it does, the payer being verified against
+// its payment signature; the other highlights still tell what it does.
+func ClassifyTransaction(signer Signer, tx *Transaction) (*TxClassification, error) {
+	payment, err := Payer(signer, tx)
+	if err != nil {
+		return nil, err
+	}
+	class := &TxClassification{
+		To:      tx.To(),
+		Value:   tx.Value(),
+		Payment: payment,
+		Fee:     tx.Fee(),
+		Creates: tx.To() == nil,
+	}
+	switch {
+	case payment != (common.Address{}):
+		class.Class = TxClassPayment
+	case class.Creates:
+		class.Class = TxClassContractCreation
+	case len(tx.data.Payload) > 0:
+		class.Class = TxClassContractCall
+	default:
+		class.Class = TxClassTransfer
+	}
+	return class, nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/AbeyFoundation/go-abey/common"
+	"github.com/AbeyFoundation/go-abey/crypto"
+)
+
+func TestClassifyTransaction(t *testing.T) {
+	var (
+		senderKey, _ = crypto.GenerateKey()
+		payerKey, _  = crypto.GenerateKey()
+		payer        = crypto.PubkeyToAddress(payerKey.PublicKey)
+		to           = common.HexToAddress("0x0a")
+		signer       = NewTIP1Signer(big.NewInt(1))
+	)
+	sign := func(tx *Transaction) *Transaction {
+		tx, err := SignTx(tx, signer, senderKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		return tx
+	}
+	paid, err := SignTx_Payment(sign(NewTransaction_Payment(0, to, big.NewInt(1), big.NewInt(5), 50000, big.NewInt(1), []byte{0x01}, payer)), signer, payerKey)
+	if err != nil {
+		t.Fatalf("failed to sign payment: %v", err)
+	}
+	tests := []struct {
+		tx      *Transaction
+		class   TxClass
+		to      *common.Address
+		payment common.Address
+		fee     *big.Int
+	}{
+		{sign(NewTransaction(0, to, big.NewInt(1), 21000, big.NewInt(1), nil)), TxClassTransfer, &to, common.Address{}, nil},
+		{sign(NewContractCreation(0, big.NewInt(1), 50000, big.NewInt(1), []byte{0x00})), TxClassContractCreation, nil, common.Address{}, nil},
+		{sign(NewTransaction(0, to, big.NewInt(1), 50000, big.NewInt(1), []byte{0x01})), TxClassContractCall, &to, common.Address{}, nil},
+		{paid, TxClassPayment, &to, payer, big.NewInt(5)},
+	}
+	for i, tt := range tests {
+		class, err := ClassifyTransaction(signer, tt.tx)
+		if err != nil {
+			t.Fatalf("test %d: failed to classify transaction: %v", i, err)
+		}
+		if class.Class != tt.class {
+			t.Errorf("test %d: class mismatch: have %v, want %v", i, class.Class, tt.class)
+		}
+		if (class.To == nil) != (tt.to == nil) || (tt.to != nil && *class.To != *tt.to) {
+			t.Errorf("test %d: recipient mismatch: have %v, want %v", i, class.To, tt.to)
+		}
+		if class.Creates != (tt.to == nil) {
+			t.Errorf("test %d: creation flag mismatch: have %v", i, class.Creates)
+		}
+		if class.Value.Cmp(big.NewInt(1)) != 0 {
+			t.Errorf("test %d: value mismatch: have %v, want 1", i, class.Value)
+		}
+		if class.Payment != tt.payment {
+			t.Errorf("test %d: payment mismatch: have %x, want %x", i, class.Payment, tt.payment)
+		}
+		if (class.Fee == nil) != (tt.fee == nil) || (tt.fee != nil && class.Fee.Cmp(tt.fee) != 0) {
+			t.Errorf("test %d: fee mismatch: have %v, want %v", i, class.Fee, tt.fee)
+		}
+	}
+}
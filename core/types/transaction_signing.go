@@ -120,6 +120,26 @@ func Sender(signer Signer, tx *Transaction) (common.Address, error) {
 	return addr, nil
 }
 
+// CachedSender returns the sender of tx cached by an earlier derivation with an
+// equal signer, without recovering it from the signature.
+func CachedSender(signer Signer, tx *Transaction) (common.Address, bool) {
+	if sc := tx.from.Load(); sc != nil {
+		sigCache := sc.(sigCache)
+		if sigCache.signer.Equal(signer) {
+			return sigCache.from, true
+		}
+	}
+	return common.Address{}, false
+}
+
+// CacheSender caches from as the sender of tx derived with signer, so that
+// Sender returns it without recovering the signature. The sender must have been
+// verified beforehand, an unverified one lets the transaction act on behalf of
+// any account.
+func CacheSender(signer Signer, tx *Transaction, from common.Address) {
+	tx.from.Store(sigCache{signer: signer, from: from})
+}
+
 // Signer encapsulates transaction signature handling. Note that this interface is not a
 // stable API and may change at any time to accommodate new protocol rules.
 type Signer interface {
@@ -177,6 +197,31 @@ func (s TIP1Signer) Payer(tx *Transaction) (common.Address, error) {
 	return recoverPlain(s.Hash_Payment(tx), tx.data.PR, tx.data.PS, PV, true)
 }
 
+// UnprotectedSigner recovers the senders of transactions signed without a chain
+// ID, whose V holds the plain recovery id as set by a TIP1Signer with a zero
+// chain ID. It leaves checking whether such transactions are acceptable at all
+// to the caller.
+type UnprotectedSigner struct {
+	TIP1Signer
+}
+
+func NewUnprotectedSigner() UnprotectedSigner {
+	return UnprotectedSigner{NewTIP1Signer(nil)}
+}
+
+func (s UnprotectedSigner) Equal(s2 Signer) bool {
+	_, ok := s2.(UnprotectedSigner)
+	return ok
+}
+
+func (s UnprotectedSigner) Sender(tx *Transaction) (common.Address, error) {
+	return recoverPlain(s.Hash(tx), tx.data.R, tx.data.S, tx.data.V, true)
+}
+
+func (s UnprotectedSigner) Payer(tx *Transaction) (common.Address, error) {
+	return recoverPlain(s.Hash_Payment(tx), tx.data.PR, tx.data.PS, tx.data.PV, true)
+}
+
 // WithSignature returns a new transaction with the given signature. This signature
 // needs to be in the [R || S || V] format where V is 0 or 1.
 func (s TIP1Signer) SignatureValues(tx *Transaction, sig []byte) (R, S, V *big.Int, err error) {
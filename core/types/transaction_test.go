@@ -67,3 +67,46 @@ func encodeDecodeJSON(tx *Transaction) (*Transaction, error) {
 	}
 	return parsedTx, nil
 }
+
+// Tests that the transactions are drained in price and nonce sorted order,
+// grouped into runs of the same sender.
+func TestTransactionPriceNonceRuns(t *testing.T) {
+	signer := NewTIP1Signer(big.NewInt(1))
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	sign := func(key *ecdsa.PrivateKey, nonce uint64, price int64) *Transaction {
+		tx, _ := SignTx(NewTransaction(nonce, common.Address{}, big.NewInt(100), 21000, big.NewInt(price), nil), signer, key)
+		return tx
+	}
+	var (
+		a0, a1 = sign(keys[0], 0, 1), sign(keys[0], 1, 5)
+		b0, b1 = sign(keys[1], 0, 3), sign(keys[1], 1, 3)
+		c0     = sign(keys[2], 0, 2)
+	)
+	groups := map[common.Address]Transactions{
+		crypto.PubkeyToAddress(keys[0].PublicKey): {a0, a1},
+		crypto.PubkeyToAddress(keys[1].PublicKey): {b0, b1},
+		crypto.PubkeyToAddress(keys[2].PublicKey): {c0},
+	}
+	// The expensive second transaction of the first account must wait for its
+	// cheap predecessor
+	want := [][]*Transaction{{b0, b1}, {c0}, {a0, a1}}
+
+	runs := NewTransactionsByPriceAndNonce(signer, groups).Runs()
+	if len(runs) != len(want) {
+		t.Fatalf("run count mismatch: have %d, want %d", len(runs), len(want))
+	}
+	for i := range want {
+		if len(runs[i]) != len(want[i]) {
+			t.Fatalf("run %d: length mismatch: have %d, want %d", i, len(runs[i]), len(want[i]))
+		}
+		for j := range want[i] {
+			if runs[i][j] != want[i][j] {
+				t.Errorf("run %d, tx %d: have nonce %d price %v, want nonce %d price %v", i, j,
+					runs[i][j].Nonce(), runs[i][j].GasPrice(), want[i][j].Nonce(), want[i][j].GasPrice())
+			}
+		}
+	}
+}
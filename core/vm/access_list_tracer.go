@@ -0,0 +1,115 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/AbeyFoundation/go-abey/common"
+	"github.com/AbeyFoundation/go-abey/core/types"
+)
+
+// AccessListTracer is a tracer collecting the accounts and storage slots
+// accessed during execution, in order to build an access list. Precompiled
+// contracts and the explicitly excluded accounts are left out of the list.
+type AccessListTracer struct {
+	excl map[common.Address]struct{}
+	list map[common.Address]map[common.Hash]struct{}
+}
+
+// NewAccessListTracer creates a tracer collecting an access list, leaving out
+// the given accounts, typically the sender of the traced transaction.
+func NewAccessListTracer(exclude ...common.Address) *AccessListTracer {
+	excl := make(map[common.Address]struct{})
+	for _, addr := range exclude {
+		excl[addr] = struct{}{}
+	}
+	return &AccessListTracer{
+		excl: excl,
+		list: make(map[common.Address]map[common.Hash]struct{}),
+	}
+}
+
+// addAddress adds an account to the access list unless it is excluded.
+func (a *AccessListTracer) addAddress(addr common.Address) {
+	if _, ok := a.excl[addr]; ok {
+		return
+	}
+	if _, ok := PrecompiledContractsYoloPos[addr]; ok {
+		return
+	}
+	if _, ok := a.list[addr]; !ok {
+		a.list[addr] = make(map[common.Hash]struct{})
+	}
+}
+
+// addSlot adds a storage slot of an account to the access list unless the
+// account is excluded.
+func (a *AccessListTracer) addSlot(addr common.Address, slot common.Hash) {
+	a.addAddress(addr)
+	if slots, ok := a.list[addr]; ok {
+		slots[slot] = struct{}{}
+	}
+}
+
+func (a *AccessListTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+// CaptureState records the accounts and storage slots accessed by the opcode.
+func (a *AccessListTracer) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, rStack *ReturnStack, rData []byte, contract *Contract, depth int, err error) error {
+	size := len(stack.data)
+	switch {
+	case (op == SLOAD || op == SSTORE) && size >= 1:
+		a.addSlot(contract.Address(), common.Hash(stack.data[size-1].Bytes32()))
+	case (op == EXTCODECOPY || op == EXTCODEHASH || op == EXTCODESIZE || op == BALANCE || op == SELFDESTRUCT) && size >= 1:
+		a.addAddress(common.Address(stack.data[size-1].Bytes20()))
+	case (op == DELEGATECALL || op == CALL || op == STATICCALL || op == CALLCODE) && size >= 5:
+		a.addAddress(common.Address(stack.data[size-2].Bytes20()))
+	}
+	return nil
+}
+
+func (a *AccessListTracer) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, rStack *ReturnStack, contract *Contract, depth int, err error) error {
+	return nil
+}
+
+func (a *AccessListTracer) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	return nil
+}
+
+// AccessList returns the collected access list, sorted by account and slot.
+func (a *AccessListTracer) AccessList() types.AccessList {
+	acl := make(types.AccessList, 0, len(a.list))
+	for addr, slots := range a.list {
+		tuple := types.AccessTuple{Address: addr, StorageKeys: make([]common.Hash, 0, len(slots))}
+		for slot := range slots {
+			tuple.StorageKeys = append(tuple.StorageKeys, slot)
+		}
+		sort.Slice(tuple.StorageKeys, func(i, j int) bool {
+			return bytes.Compare(tuple.StorageKeys[i][:], tuple.StorageKeys[j][:]) < 0
+		})
+		acl = append(acl, tuple)
+	}
+	sort.Slice(acl, func(i, j int) bool {
+		return bytes.Compare(acl[i].Address[:], acl[j].Address[:]) < 0
+	})
+	return acl
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/AbeyFoundation/go-abey/common"
+)
+
+// BalanceTracer is a tracer collecting the accounts whose balance execution may
+// have changed: the accounts on both ends of the transaction, the contracts run
+// and the recipients of internal value transfers and self-destructs.
+type BalanceTracer struct {
+	accounts map[common.Address]struct{}
+}
+
+// NewBalanceTracer creates a tracer collecting the accounts of value transfers.
+func NewBalanceTracer() *BalanceTracer {
+	return &BalanceTracer{accounts: make(map[common.Address]struct{})}
+}
+
+func (b *BalanceTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	b.accounts[from] = struct{}{}
+	b.accounts[to] = struct{}{}
+	return nil
+}
+
+// CaptureState records the running contract and the recipient of any value the
+// opcode transfers.
+func (b *BalanceTracer) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, rStack *ReturnStack, rData []byte, contract *Contract, depth int, err error) error {
+	b.accounts[contract.Address()] = struct{}{}
+
+	size := len(stack.data)
+	switch {
+	case op == SELFDESTRUCT && size >= 1:
+		b.accounts[common.Address(stack.data[size-1].Bytes20())] = struct{}{}
+	case op == CALL && size >= 3 && !stack.data[size-3].IsZero():
+		b.accounts[common.Address(stack.data[size-2].Bytes20())] = struct{}{}
+	}
+	return nil
+}
+
+func (b *BalanceTracer) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, rStack *ReturnStack, contract *Contract, depth int, err error) error {
+	return nil
+}
+
+func (b *BalanceTracer) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	return nil
+}
+
+// Accounts returns the collected accounts, sorted by address.
+func (b *BalanceTracer) Accounts() []common.Address {
+	addrs := make([]common.Address, 0, len(b.accounts))
+	for addr := range b.accounts {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i][:], addrs[j][:]) < 0
+	})
+	return addrs
+}
@@ -0,0 +1,188 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/AbeyFoundation/go-abey/common"
+	"github.com/AbeyFoundation/go-abey/params"
+)
+
+// errCallFailed is reported for an inner call that failed without executing
+// any code, such as one exceeding the call depth limit or the balance of the
+// caller, or a failing precompiled contract.
+var errCallFailed = errors.New("call failed")
+
+// CallFrame is a single call of the call tree captured by a CallTracer.
+type CallFrame struct {
+	Type    OpCode         // CALL, CALLCODE, DELEGATECALL, STATICCALL, CREATE or CREATE2
+	From    common.Address // Account running the call
+	To      common.Address // Called account, or the created contract
+	Input   []byte         // Call data, or the init code of a creation
+	Output  []byte         // Data returned, or the revert payload
+	Gas     uint64         // Gas made available to the call
+	GasUsed uint64         // Gas consumed by the call
+	Value   *big.Int       // Value transferred, nil for DELEGATECALL and STATICCALL
+	Err     error          // Error the call failed with, nil on success
+	Calls   []*CallFrame   // Inner calls, in execution order
+
+	depth   int    // Interpreter depth the frame runs at
+	gasIn   uint64 // Gas of the caller before the call opcode
+	gasCost uint64 // Cost of the call opcode, including the gas forwarded
+}
+
+// CallTracer is a tracer capturing the tree of calls a transaction makes. Calls
+// nested deeper than the configured maximum depth are executed as usual but left
+// out of the tree.
+type CallTracer struct {
+	maxDepth int
+	root     *CallFrame
+	stack    []*CallFrame
+}
+
+// NewCallTracer creates a tracer capturing the call tree of a transaction down to
+// maxDepth levels below the outermost call, or entirely if maxDepth is zero.
+func NewCallTracer(maxDepth int) *CallTracer {
+	return &CallTracer{maxDepth: maxDepth}
+}
+
+func (c *CallTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	typ := CALL
+	if create {
+		typ = CREATE
+	}
+	c.root = &CallFrame{
+		Type:  typ,
+		From:  from,
+		To:    to,
+		Input: common.CopyBytes(input),
+		Gas:   gas,
+		Value: new(big.Int).Set(value),
+		depth: 1,
+	}
+	c.stack = []*CallFrame{c.root}
+	return nil
+}
+
+// CaptureState closes the inner calls that returned before the opcode and opens
+// a new frame if the opcode calls into another account.
+func (c *CallTracer) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, rStack *ReturnStack, rData []byte, contract *Contract, depth int, err error) error {
+	if c.root == nil {
+		return nil
+	}
+	for len(c.stack) > 1 && c.stack[len(c.stack)-1].depth > depth {
+		c.exit(stack, rData, gas)
+	}
+	frame := c.stack[len(c.stack)-1]
+	if err != nil {
+		frame.Err = err
+		return nil
+	}
+	if op == REVERT {
+		frame.Err = ErrExecutionReverted
+		return nil
+	}
+	call := &CallFrame{
+		Type:    op,
+		From:    contract.Address(),
+		depth:   depth + 1,
+		gasIn:   gas,
+		gasCost: cost,
+	}
+	switch op {
+	case CALL, CALLCODE:
+		call.To = common.Address(stack.Back(1).Bytes20())
+		call.Value = stack.Back(2).ToBig()
+		call.Input = memory.GetCopy(int64(stack.Back(3).Uint64()), int64(stack.Back(4).Uint64()))
+		call.Gas = env.callGasTemp
+		if call.Value.Sign() != 0 {
+			call.Gas += params.CallStipend
+		}
+	case DELEGATECALL, STATICCALL:
+		call.To = common.Address(stack.Back(1).Bytes20())
+		call.Input = memory.GetCopy(int64(stack.Back(2).Uint64()), int64(stack.Back(3).Uint64()))
+		call.Gas = env.callGasTemp
+	case CREATE, CREATE2:
+		call.Value = stack.Back(0).ToBig()
+		call.Input = memory.GetCopy(int64(stack.Back(1).Uint64()), int64(stack.Back(2).Uint64()))
+		call.Gas = gas - cost
+		call.Gas -= call.Gas / 64
+	default:
+		return nil
+	}
+	if c.maxDepth == 0 || depth <= c.maxDepth {
+		frame.Calls = append(frame.Calls, call)
+	}
+	c.stack = append(c.stack, call)
+	return nil
+}
+
+// CaptureFault records the error of the failing frame.
+func (c *CallTracer) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, rStack *ReturnStack, contract *Contract, depth int, err error) error {
+	if c.root == nil {
+		return nil
+	}
+	for len(c.stack) > 1 && c.stack[len(c.stack)-1].depth > depth {
+		c.exit(stack, nil, gas)
+	}
+	c.stack[len(c.stack)-1].Err = err
+	return nil
+}
+
+// CaptureEnd finalizes the outermost call.
+func (c *CallTracer) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	if c.root == nil {
+		return nil
+	}
+	c.root.Output = common.CopyBytes(output)
+	c.root.GasUsed = gasUsed
+	c.root.Err = err
+	c.stack = c.stack[:1]
+	return nil
+}
+
+// exit closes the innermost open frame, reading its outcome off the state of the
+// caller right after the call opcode returned.
+func (c *CallTracer) exit(stack *Stack, rData []byte, gas uint64) {
+	call := c.stack[len(c.stack)-1]
+	c.stack = c.stack[:len(c.stack)-1]
+
+	// The caller got back the gas the call left unused
+	if returned := gas - (call.gasIn - call.gasCost); returned <= call.Gas {
+		call.GasUsed = call.Gas - returned
+	}
+	call.Output = common.CopyBytes(rData)
+
+	if stack.len() == 0 || stack.Back(0).IsZero() {
+		if call.Err == nil {
+			call.Err = errCallFailed
+		}
+		return
+	}
+	// A successful creation pushes the address of the new contract
+	if call.Type == CREATE || call.Type == CREATE2 {
+		call.To = common.Address(stack.Back(0).Bytes20())
+	}
+}
+
+// CallTree returns the captured call tree, or nil if nothing was executed.
+func (c *CallTracer) CallTree() *CallFrame {
+	return c.root
+}
@@ -20,9 +20,11 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"github.com/AbeyFoundation/go-abey/crypto/blake2b"
 	"github.com/AbeyFoundation/go-abey/crypto/bls12381"
 	"math/big"
+	"sync"
 
 	"github.com/AbeyFoundation/go-abey/accounts/abi"
 	"github.com/AbeyFoundation/go-abey/common"
@@ -102,6 +104,50 @@ var PrecompiledContractsYoloPos = map[common.Address]PrecompiledContract{
 	types.StakingAddress:              &staking{},
 }
 
+var (
+	customPrecompilesLock sync.RWMutex
+	customPrecompiles     = make(map[string]PrecompiledContract)
+)
+
+// RegisterPrecompile makes a precompiled contract available to the chains
+// scheduling it in their configuration under the given name, replacing any
+// contract previously registered under it.
+func RegisterPrecompile(name string, p PrecompiledContract) {
+	customPrecompilesLock.Lock()
+	defer customPrecompilesLock.Unlock()
+
+	customPrecompiles[name] = p
+}
+
+// ActivePrecompiles returns the custom precompiled contracts the chain has
+// enabled at block num, keyed by address, or nil if there are none. Contracts
+// not registered are left out and reported with ErrUnknownPrecompile.
+func ActivePrecompiles(config *params.ChainConfig, num *big.Int) (map[common.Address]PrecompiledContract, error) {
+	var (
+		active map[common.Address]PrecompiledContract
+		err    error
+	)
+	customPrecompilesLock.RLock()
+	defer customPrecompilesLock.RUnlock()
+
+	for i := range config.Precompiles {
+		scheduled := &config.Precompiles[i]
+		if !scheduled.IsActive(num) {
+			continue
+		}
+		p, ok := customPrecompiles[scheduled.Name]
+		if !ok {
+			err = fmt.Errorf("%w: %s", ErrUnknownPrecompile, scheduled.Name)
+			continue
+		}
+		if active == nil {
+			active = make(map[common.Address]PrecompiledContract)
+		}
+		active[scheduled.Address] = p
+	}
+	return active, err
+}
+
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
 // It returns
 // - the returned bytes,
@@ -19,6 +19,7 @@ package vm
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/AbeyFoundation/go-abey/params"
 	"io/ioutil"
@@ -26,7 +27,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/AbeyFoundation/go-abey/abeydb"
 	"github.com/AbeyFoundation/go-abey/common"
+	"github.com/AbeyFoundation/go-abey/core/state"
 )
 
 // precompiledTest defines the input/output pairs for precompiled contract tests.
@@ -371,3 +374,56 @@ func BenchmarkPrecompiledBLS12381G2MultiExpWorstCase(b *testing.B) {
 	}
 	benchmarkPrecompiled("0f", testcase, b)
 }
+
+// echoPrecompile is a custom precompiled contract returning its input.
+type echoPrecompile struct{}
+
+func (c *echoPrecompile) RequiredGas(evm *EVM, input []byte) uint64 { return 100 }
+
+func (c *echoPrecompile) Run(evm *EVM, contract *Contract, input []byte) ([]byte, error) {
+	return common.CopyBytes(input), nil
+}
+
+// Tests that a custom precompiled contract can only be called between the blocks
+// enabling and disabling it.
+func TestCustomPrecompile(t *testing.T) {
+	RegisterPrecompile("echo", new(echoPrecompile))
+
+	addr := common.Address{0xec}
+	config := *params.TestChainConfig
+	config.Precompiles = []params.PrecompileConfig{{Name: "echo", Address: addr, Block: big.NewInt(10), DisableBlock: big.NewInt(20)}}
+
+	input := []byte{0x01, 0x02, 0x03}
+	for _, tt := range []struct {
+		number int64
+		active bool
+	}{{9, false}, {10, true}, {19, true}, {20, false}} {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(abeydb.NewMemDatabase()))
+		vmctx := Context{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+			BlockNumber: big.NewInt(tt.number),
+		}
+		evm := NewEVM(vmctx, statedb, &config, Config{})
+		ret, gas, err := evm.Call(AccountRef(common.Address{}), addr, input, 1000, new(big.Int), nil)
+		if err != nil {
+			t.Fatalf("block %d: call failed: %v", tt.number, err)
+		}
+		want, wantGas := []byte(nil), uint64(1000)
+		if tt.active {
+			want, wantGas = input, 900
+		}
+		if !bytes.Equal(ret, want) || gas != wantGas {
+			t.Errorf("block %d: call mismatch: have %x with %d gas left, want %x with %d", tt.number, ret, gas, want, wantGas)
+		}
+	}
+	// Chains without custom precompiles have none, while unregistered ones are
+	// reported
+	if active, err := ActivePrecompiles(params.TestChainConfig, big.NewInt(10)); active != nil || err != nil {
+		t.Errorf("unconfigured precompiles mismatch: have %v, %v, want none", active, err)
+	}
+	config.Precompiles = append(config.Precompiles, params.PrecompileConfig{Name: "missing", Address: common.Address{0xee}, Block: big.NewInt(0)})
+	if active, err := ActivePrecompiles(&config, big.NewInt(10)); !errors.Is(err, ErrUnknownPrecompile) || len(active) != 1 {
+		t.Errorf("unregistered precompile mismatch: have %d active, %v, want 1 and %v", len(active), err, ErrUnknownPrecompile)
+	}
+}
@@ -42,6 +42,8 @@ var (
 	ErrReturnStackExceeded        = errors.New("return stack limit reached")
 	ErrStakingInvalidInput        = errors.New("invalid input for staking")
 	ErrStakingInsufficientBalance = errors.New("insufficient balance for staking transfer")
+	ErrUnknownInterpreter         = errors.New("unknown interpreter")
+	ErrUnknownPrecompile          = errors.New("unknown precompiled contract")
 )
 
 // ErrStackUnderflow wraps an evm error when the items on the stack less
@@ -25,6 +25,7 @@ import (
 
 	"github.com/AbeyFoundation/go-abey/common"
 	"github.com/AbeyFoundation/go-abey/crypto"
+	"github.com/AbeyFoundation/go-abey/log"
 	"github.com/AbeyFoundation/go-abey/params"
 )
 
@@ -54,6 +55,9 @@ func (evm *EVM) precompile(addr common.Address) (PrecompiledContract, bool) {
 	//	precompiles = PrecompiledContractsByzantium
 	//}
 	p, ok := precompiles[addr]
+	if !ok && evm.precompiles != nil {
+		p, ok = evm.precompiles[addr]
+	}
 	return p, ok
 }
 
@@ -119,6 +123,9 @@ type EVM struct {
 	chainConfig *params.ChainConfig
 	// chain rules contains the chain rules for the current epoch
 	chainRules params.Rules
+	// custom precompiled contracts of the chain enabled at the current block,
+	// which do not override the built-in ones
+	precompiles map[common.Address]PrecompiledContract
 	// virtual machine configuration options used to initialise the
 	// evm.
 	vmConfig Config
@@ -146,10 +153,20 @@ func NewEVM(ctx Context, statedb StateDB, chainConfig *params.ChainConfig, vmCon
 		chainRules:   chainConfig.Rules(ctx.BlockNumber),
 		interpreters: make([]Interpreter, 0, 1),
 	}
+	if len(chainConfig.Precompiles) > 0 {
+		evm.precompiles, _ = ActivePrecompiles(chainConfig, ctx.BlockNumber)
+	}
 
 	// vmConfig.EVMInterpreter will be used by EVM-C, it won't be checked here
 	// as we always want to have the built-in EVM as the failover option.
-	evm.interpreters = append(evm.interpreters, NewEVMInterpreter(evm, vmConfig))
+	// Unknown interpreter names fall back to it too, but are reported, as the
+	// configuration should have been rejected by Config.CheckInterpreter.
+	constructor, ok := lookupInterpreter(vmConfig.Interpreter)
+	if !ok {
+		log.Error("Unknown EVM interpreter, running the built-in one", "name", vmConfig.Interpreter)
+		constructor, _ = lookupInterpreter(DefaultInterpreter)
+	}
+	evm.interpreters = append(evm.interpreters, constructor(evm, vmConfig))
 	evm.interpreter = evm.interpreters[0]
 
 	return evm
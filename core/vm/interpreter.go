@@ -17,8 +17,10 @@
 package vm
 
 import (
+	"fmt"
 	"github.com/AbeyFoundation/go-abey/log"
 	"hash"
+	"sync"
 	"sync/atomic"
 
 	"github.com/AbeyFoundation/go-abey/common"
@@ -37,6 +39,8 @@ type Config struct {
 	EWASMInterpreter string // External EWASM interpreter options
 	EVMInterpreter   string // External EVM interpreter options
 
+	Interpreter string // Name of the registered interpreter to run the byte code with, the built-in one if empty
+
 	ExtraEips []int // Additional EIPS that are to be enabled
 }
 
@@ -62,6 +66,59 @@ type Interpreter interface {
 	CanRun([]byte) bool
 }
 
+// DefaultInterpreter is the name the built-in byte code interpreter is
+// registered under.
+const DefaultInterpreter = "evm"
+
+// InterpreterConstructor creates an interpreter running the contracts of evm.
+type InterpreterConstructor func(evm *EVM, cfg Config) Interpreter
+
+var (
+	interpretersLock sync.RWMutex
+	interpreters     = map[string]InterpreterConstructor{
+		DefaultInterpreter: func(evm *EVM, cfg Config) Interpreter { return NewEVMInterpreter(evm, cfg) },
+	}
+)
+
+// RegisterInterpreter makes an interpreter selectable through the Interpreter
+// field of the configuration under the given name, replacing any interpreter
+// previously registered under it.
+func RegisterInterpreter(name string, constructor InterpreterConstructor) {
+	interpretersLock.Lock()
+	defer interpretersLock.Unlock()
+
+	interpreters[name] = constructor
+}
+
+// lookupInterpreter returns the constructor of the interpreter registered under
+// name, the built-in one for an empty name.
+func lookupInterpreter(name string) (InterpreterConstructor, bool) {
+	if name == "" {
+		name = DefaultInterpreter
+	}
+	interpretersLock.RLock()
+	defer interpretersLock.RUnlock()
+
+	constructor, ok := interpreters[name]
+	return constructor, ok
+}
+
+// HasInterpreter reports whether an interpreter is registered under name. The
+// empty name always refers to the built-in interpreter.
+func HasInterpreter(name string) bool {
+	_, ok := lookupInterpreter(name)
+	return ok
+}
+
+// CheckInterpreter returns ErrUnknownInterpreter if no interpreter is registered
+// under the name set in the configuration.
+func (cfg Config) CheckInterpreter() error {
+	if !HasInterpreter(cfg.Interpreter) {
+		return fmt.Errorf("%w: %s", ErrUnknownInterpreter, cfg.Interpreter)
+	}
+	return nil
+}
+
 // callCtx contains the things that are per-call, such as stack and memory,
 // but not transients like pc and gas
 type callCtx struct {
@@ -0,0 +1,63 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/AbeyFoundation/go-abey/common"
+)
+
+// StorageWriteTracer is a tracer reporting every storage slot written by an
+// SSTORE as it executes. Writes of calls reverted later on are reported too.
+type StorageWriteTracer struct {
+	onWrite func(addr common.Address, slot, prev, value common.Hash)
+}
+
+// NewStorageWriteTracer creates a tracer calling onWrite with the account, the
+// slot, and the values before and after every storage write.
+func NewStorageWriteTracer(onWrite func(addr common.Address, slot, prev, value common.Hash)) *StorageWriteTracer {
+	return &StorageWriteTracer{onWrite: onWrite}
+}
+
+func (s *StorageWriteTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+// CaptureState reports the write of an SSTORE about to execute, reading the
+// value it overwrites off the state.
+func (s *StorageWriteTracer) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, rStack *ReturnStack, rData []byte, contract *Contract, depth int, err error) error {
+	if op != SSTORE || err != nil || stack.len() < 2 {
+		return nil
+	}
+	var (
+		addr  = contract.Address()
+		slot  = common.Hash(stack.Back(0).Bytes32())
+		value = common.Hash(stack.Back(1).Bytes32())
+	)
+	s.onWrite(addr, slot, env.StateDB.GetState(addr, slot), value)
+	return nil
+}
+
+func (s *StorageWriteTracer) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, rStack *ReturnStack, contract *Contract, depth int, err error) error {
+	return nil
+}
+
+func (s *StorageWriteTracer) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	return nil
+}
@@ -24,17 +24,21 @@ import (
 	"github.com/AbeyFoundation/go-abey/accounts/abi"
 	"github.com/AbeyFoundation/go-abey/metrics"
 	"math/big"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/AbeyFoundation/go-abey/abey/gasprice"
 	"github.com/AbeyFoundation/go-abey/accounts"
 	"github.com/AbeyFoundation/go-abey/accounts/keystore"
 	"github.com/AbeyFoundation/go-abey/common"
 	"github.com/AbeyFoundation/go-abey/common/hexutil"
 	"github.com/AbeyFoundation/go-abey/common/math"
+	"github.com/AbeyFoundation/go-abey/consensus"
 	ethash "github.com/AbeyFoundation/go-abey/consensus/minerva"
 	"github.com/AbeyFoundation/go-abey/core"
 	"github.com/AbeyFoundation/go-abey/core/rawdb"
+	"github.com/AbeyFoundation/go-abey/core/state"
 	"github.com/AbeyFoundation/go-abey/core/types"
 	"github.com/AbeyFoundation/go-abey/core/vm"
 	"github.com/AbeyFoundation/go-abey/crypto"
@@ -86,10 +90,10 @@ func (s *PublicABEYAPI) ProtocolVersion() hexutil.Uint {
 // - pulledStates:  number of state entries processed until now
 // - knownStates:   number of known state entries that still need to be pulled
 func (s *PublicABEYAPI) Syncing() (interface{}, error) {
-	progress := s.b.Downloader().Progress()
+	progress := s.b.SyncProgress()
 
-	// Return not syncing if the synchronisation already completed
-	if progress.CurrentSnailBlock >= progress.HighestSnailBlock {
+	// Return not syncing if the synchronisation of both chains already completed
+	if progress.CurrentSnailBlock >= progress.HighestSnailBlock && progress.CurrentFastBlock >= progress.HighestFastBlock {
 		return false, nil
 	}
 	// Otherwise gather the block sync stats
@@ -127,7 +131,7 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	for account, txs := range pending {
 		dump := make(map[string]*RPCTransaction)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx, true)
+			dump[fmt.Sprintf("%d", tx.Nonce())] = NewRPCPendingTransaction(tx, true)
 		}
 		content["pending"][account.Hex()] = dump
 	}
@@ -135,7 +139,7 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	for account, txs := range queue {
 		dump := make(map[string]*RPCTransaction)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx, true)
+			dump[fmt.Sprintf("%d", tx.Nonce())] = NewRPCPendingTransaction(tx, true)
 		}
 		content["queued"][account.Hex()] = dump
 	}
@@ -186,6 +190,112 @@ func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string]string {
 	return content
 }
 
+// AccountTxs is the pooled transactions of a single account.
+type AccountTxs struct {
+	Address common.Address
+	Txs     types.Transactions
+}
+
+// SortTxPoolContent orders the pending and queued content of a transaction pool
+// by account address, and the transactions of each account by nonce, ties being
+// broken by transaction hash. Equal pool contents thus always sort the same way.
+func SortTxPoolContent(pending, queued map[common.Address]types.Transactions) ([]AccountTxs, []AccountTxs) {
+	return sortAccountTxs(pending), sortAccountTxs(queued)
+}
+
+// sortAccountTxs flattens the transactions grouped by account into a slice
+// ordered by address and nonce.
+func sortAccountTxs(content map[common.Address]types.Transactions) []AccountTxs {
+	sorted := make([]AccountTxs, 0, len(content))
+	for addr, txs := range content {
+		txs = append(types.Transactions(nil), txs...)
+		sort.Slice(txs, func(i, j int) bool {
+			if txs[i].Nonce() != txs[j].Nonce() {
+				return txs[i].Nonce() < txs[j].Nonce()
+			}
+			hi, hj := txs[i].Hash(), txs[j].Hash()
+			return bytes.Compare(hi[:], hj[:]) < 0
+		})
+		sorted = append(sorted, AccountTxs{Address: addr, Txs: txs})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].Address[:], sorted[j].Address[:]) < 0
+	})
+	return sorted
+}
+
+// FeeBucket is a gas price range along with the number of pending transactions
+// priced within it.
+type FeeBucket struct {
+	MinPrice *hexutil.Big `json:"minPrice"`
+	MaxPrice *hexutil.Big `json:"maxPrice"` // Exclusive, nil for the topmost bucket
+	Count    int          `json:"count"`
+}
+
+// feeHistogramBounds are the bounds between the gas price buckets of the pending
+// pool histogram, a 1-2-5 series of gwei.
+var feeHistogramBounds = func() []*big.Int {
+	var bounds []*big.Int
+	for scale := int64(params.GWei); scale <= 100*params.GWei; scale *= 10 {
+		for _, step := range []int64{1, 2, 5} {
+			bounds = append(bounds, big.NewInt(step*scale))
+		}
+	}
+	return append(bounds, big.NewInt(1000*params.GWei))
+}()
+
+// FeeHistogram buckets the pending transactions of a pool by gas price, using the
+// bucket counts of the pool's PendingPriceHistogram. Buckets range from below
+// 1 gwei to 1000 gwei and above.
+func FeeHistogram(histogram func(bounds []*big.Int) []int) []FeeBucket {
+	counts := histogram(feeHistogramBounds)
+
+	buckets := make([]FeeBucket, len(counts))
+	for i, count := range counts {
+		buckets[i] = FeeBucket{MinPrice: (*hexutil.Big)(new(big.Int)), Count: count}
+		if i > 0 {
+			buckets[i].MinPrice = (*hexutil.Big)(feeHistogramBounds[i-1])
+		}
+		if i < len(feeHistogramBounds) {
+			buckets[i].MaxPrice = (*hexutil.Big)(feeHistogramBounds[i])
+		}
+	}
+	return buckets
+}
+
+// ExportTxPool serializes the pending and queued transactions of the pool into
+// an RLP list, ordered such that each account's transactions follow by nonce.
+func ExportTxPool(b Backend) ([]byte, error) {
+	pending, queued := b.TxPoolContentSorted()
+
+	var txs types.Transactions
+	for _, content := range [][]AccountTxs{pending, queued} {
+		for _, account := range content {
+			txs = append(txs, account.Txs...)
+		}
+	}
+	return rlp.EncodeToBytes(txs)
+}
+
+// ImportTxPool re-injects the transactions of a pool export, validating each of
+// them anew. Transactions no longer valid, such as ones with a nonce since used
+// or exceeding the balance of their sender, are skipped and their number is
+// returned.
+func ImportTxPool(ctx context.Context, b Backend, data []byte) (int, error) {
+	var txs types.Transactions
+	if err := rlp.DecodeBytes(data, &txs); err != nil {
+		return 0, err
+	}
+	skipped := 0
+	for _, tx := range txs {
+		if err := b.SendTx(ctx, tx); err != nil {
+			log.Debug("Skipped imported transaction", "hash", tx.Hash(), "err", err)
+			skipped++
+		}
+	}
+	return skipped, nil
+}
+
 // PublicAccountAPI provides an API to access accounts managed by this node.
 // It offers only methods that can retrieve accounts.
 type PublicAccountAPI struct {
@@ -459,6 +569,25 @@ func (s *PrivateAccountAPI) SendTransaction(ctx context.Context, args SendTxArgs
 	return submitTransaction(ctx, s.b, signed)
 }
 
+// ReplaceTransaction speeds up the pending transaction with the given hash by
+// replacing it with a copy paying the new gas price, returning the hash of the
+// replacement. The sender's mutex is held meanwhile, so that no transaction sent
+// concurrently through the API is assigned the nonce being replaced.
+func (s *PrivateAccountAPI) ReplaceTransaction(ctx context.Context, hash common.Hash, gasPrice hexutil.Big) (common.Hash, error) {
+	tx := s.b.GetPoolTransaction(hash)
+	if tx == nil {
+		return common.Hash{}, fmt.Errorf("%w: %#x", ErrTxNotPending, hash)
+	}
+	from, err := types.Sender(types.NewTIP1Signer(tx.ChainId()), tx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	s.nonceLock.LockAddr(from)
+	defer s.nonceLock.UnlockAddr(from)
+
+	return s.b.ReplaceTransaction(ctx, hash, (*big.Int)(&gasPrice))
+}
+
 // SignTransaction will create a transaction from the given arguments and
 // tries to sign it with the key associated with args.To. If the given passwd isn't
 // able to decrypt the key it fails. The transaction is returned in RLP-form, not broadcast
@@ -619,6 +748,21 @@ func (s *PublicBlockChainAPI) GetBalance(ctx context.Context, address common.Add
 	return (*hexutil.Big)(state.GetUnlockedBalance(address)), state.Error()
 }
 
+// GetBalances returns the amounts of wei of the given addresses in the state of
+// the given block number, in the order of the addresses. The state is resolved
+// once for all of them.
+func (s *PublicBlockChainAPI) GetBalances(ctx context.Context, addresses []common.Address, blockNr rpc.BlockNumber) ([]*hexutil.Big, error) {
+	balances, err := s.b.GetBalances(ctx, addresses, blockNr)
+	if balances == nil || err != nil {
+		return nil, err
+	}
+	result := make([]*hexutil.Big, len(balances))
+	for i, balance := range balances {
+		result[i] = (*hexutil.Big)(balance)
+	}
+	return result, nil
+}
+
 // GetLockBalance returns the amount of wei for the given address in pos state of the
 // given block number or hash. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta
 // block numbers are also allowed.
@@ -658,6 +802,13 @@ func (s *PublicBlockChainAPI) GetTotalBalance(ctx context.Context, address commo
 	return (*hexutil.Big)(state.GetBalance(address)), state.Error()
 }
 
+// GetHeaderFeeInfo returns the gas used, the gas limit and their ratio for the
+// requested block, together with a base fee proxy derived from the lowest gas
+// prices paid in the recent blocks, as the chain has no protocol base fee.
+func (s *PublicBlockChainAPI) GetHeaderFeeInfo(ctx context.Context, blockNr rpc.BlockNumber) (*gasprice.HeaderFeeInfo, error) {
+	return s.b.HeaderFeeInfo(ctx, blockNr)
+}
+
 // GetBlockByNumber returns the requested block. When blockNr is -1 the chain head is returned. When fullTx is true all
 // transactions in the block are returned in full detail, otherwise only the transaction hash is returned.
 func (s *PublicBlockChainAPI) GetBlockByNumber(ctx context.Context, blockNr rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
@@ -753,6 +904,199 @@ func (s *PublicBlockChainAPI) GetFruitByHash(ctx context.Context, blockHash comm
 	return nil, err
 }
 
+// GetFruitStatus returns whether the fast block with the given hash is included
+// as a fruit in the snail chain, and the number of the including snail block.
+func (s *PublicBlockChainAPI) GetFruitStatus(ctx context.Context, blockHash common.Hash) (map[string]interface{}, error) {
+	included, number, err := s.b.FruitStatus(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{
+		"included": included,
+	}
+	if included {
+		fields["snailNumber"] = hexutil.Uint64(number)
+	}
+	return fields, nil
+}
+
+// errNoCommittee is returned if the committee of a block cannot be resolved.
+var errNoCommittee = errors.New("no committee for block")
+
+// BlockSignatures is the committee signature status of a fast block, which is
+// final once more than two thirds of its committee agreed on it.
+type BlockSignatures struct {
+	Number    hexutil.Uint64   `json:"number"`
+	Hash      common.Hash      `json:"hash"`
+	Signers   []common.Address `json:"signers"`
+	Committee int              `json:"committee"`
+	Threshold int              `json:"threshold"`
+	Final     bool             `json:"final"`
+}
+
+// NewBlockSignatures tallies the agreeing signatures of the committee members
+// carried by block. Signatures over another block, by non members and repeated
+// ones are not counted.
+func NewBlockSignatures(block *types.Block, election consensus.CommitteeElection) (*BlockSignatures, error) {
+	members := election.GetCommittee(block.Number())
+	if len(members) == 0 {
+		return nil, errNoCommittee
+	}
+	status := &BlockSignatures{
+		Number:    hexutil.Uint64(block.NumberU64()),
+		Hash:      block.Hash(),
+		Signers:   []common.Address{},
+		Committee: len(members),
+		Threshold: len(members)*2/3 + 1,
+	}
+	var agreed []*types.PbftSign
+	for _, sign := range block.Signs() {
+		if sign.Result == types.VoteAgree && sign.FastHash == block.Hash() {
+			agreed = append(agreed, sign)
+		}
+	}
+	if len(agreed) == 0 {
+		return status, nil
+	}
+	signers, errs := election.VerifySigns(agreed)
+	seen := make(map[common.Address]bool)
+	for i, signer := range signers {
+		if errs[i] != nil || signer == nil || seen[signer.CommitteeBase] {
+			continue
+		}
+		seen[signer.CommitteeBase] = true
+		status.Signers = append(status.Signers, signer.CommitteeBase)
+	}
+	status.Final = len(status.Signers) >= status.Threshold
+	return status, nil
+}
+
+// GetBlockSignatures returns the committee members who signed the fast block
+// with the given number, along with the number of signers needed for finality.
+func (s *PublicBlockChainAPI) GetBlockSignatures(ctx context.Context, blockNr rpc.BlockNumber) (*BlockSignatures, error) {
+	return s.b.GetBlockSignatures(ctx, blockNr)
+}
+
+// CommitteeSchedule is the epoch of the committee serving the chain head, along
+// with the block the next committee takes over at.
+type CommitteeSchedule struct {
+	Head         hexutil.Uint64 `json:"head"`
+	Epoch        hexutil.Uint64 `json:"epoch"`
+	BeginNumber  hexutil.Uint64 `json:"beginNumber"`
+	EndNumber    hexutil.Uint64 `json:"endNumber"`
+	NextRotation hexutil.Uint64 `json:"nextRotation"`
+	Committee    int            `json:"committee,omitempty"` // Members serving the head, zero if unknown
+}
+
+// NewCommitteeSchedule derives the committee schedule at the given head from the
+// epoch parameters of the consensus. The size of the serving committee is only
+// reported if an election is given.
+func NewCommitteeSchedule(head uint64, election consensus.CommitteeElection) *CommitteeSchedule {
+	epoch := types.GetEpochFromHeight(head)
+	schedule := &CommitteeSchedule{
+		Head:         hexutil.Uint64(head),
+		Epoch:        hexutil.Uint64(epoch.EpochID),
+		BeginNumber:  hexutil.Uint64(epoch.BeginHeight),
+		EndNumber:    hexutil.Uint64(epoch.EndHeight),
+		NextRotation: hexutil.Uint64(epoch.EndHeight + 1),
+	}
+	if election != nil {
+		schedule.Committee = len(election.GetCommittee(new(big.Int).SetUint64(head)))
+	}
+	return schedule
+}
+
+// GetCommitteeSchedule returns the epoch of the committee serving the chain head
+// and the block the next committee takes over at.
+func (s *PublicBlockChainAPI) GetCommitteeSchedule(ctx context.Context) (*CommitteeSchedule, error) {
+	return s.b.GetCommitteeSchedule(ctx)
+}
+
+// errReceiptsMismatch is returned if the receipts of a block do not match its
+// transactions.
+var errReceiptsMismatch = errors.New("receipts do not match transactions")
+
+// RewardEntry is an amount rewarded to an account.
+type RewardEntry struct {
+	Address common.Address `json:"address"`
+	Amount  *hexutil.Big   `json:"amount"`
+}
+
+// RewardBreakdown itemizes the coins paid out by a fast block: the rewards minted
+// for the snail block it rewards, if any, and the fees of its transactions.
+type RewardBreakdown struct {
+	Number      hexutil.Uint64 `json:"number"`
+	Hash        common.Hash    `json:"hash"`
+	SnailNumber hexutil.Uint64 `json:"snailNumber"`     // Snail block rewarded, zero if none
+	Miner       *RewardEntry   `json:"miner,omitempty"` // Miner of the rewarded snail block
+	FruitMiners []RewardEntry  `json:"fruitMiners"`
+	Committee   []RewardEntry  `json:"committee"`         // Committee members and their delegators
+	Rewards     *hexutil.Big   `json:"rewards,omitempty"` // Coins minted by the block, nil if unknown
+	Fees        *hexutil.Big   `json:"fees"`              // Gas and transaction fees paid
+	Total       *hexutil.Big   `json:"total,omitempty"`   // Rewards and fees, nil if the rewards are unknown
+}
+
+// NewRewardBreakdown combines the fees paid by the transactions of block with the
+// reward payouts of infos, either of which may be nil if unknown. The amount of
+// coins minted by the block is the total of the payouts, unless given explicitly.
+func NewRewardBreakdown(block *types.Block, receipts types.Receipts, infos *types.ChainReward, minted *big.Int) (*RewardBreakdown, error) {
+	txs := block.Transactions()
+	if len(receipts) != len(txs) {
+		return nil, fmt.Errorf("%w: %d receipts for %d transactions", errReceiptsMismatch, len(receipts), len(txs))
+	}
+	fees := new(big.Int)
+	for i, tx := range txs {
+		fees.Add(fees, new(big.Int).Mul(tx.GasPrice(), new(big.Int).SetUint64(receipts[i].GasUsed)))
+		if fee := tx.Fee(); fee != nil {
+			fees.Add(fees, fee)
+		}
+	}
+	breakdown := &RewardBreakdown{
+		Number:      hexutil.Uint64(block.NumberU64()),
+		Hash:        block.Hash(),
+		SnailNumber: hexutil.Uint64(block.SnailNumber().Uint64()),
+		FruitMiners: []RewardEntry{},
+		Committee:   []RewardEntry{},
+		Fees:        (*hexutil.Big)(fees),
+	}
+	if infos != nil {
+		if infos.CoinBase != nil {
+			breakdown.Miner = newRewardEntry(infos.CoinBase)
+		}
+		for _, info := range infos.FruitBase {
+			breakdown.FruitMiners = append(breakdown.FruitMiners, *newRewardEntry(info))
+		}
+		for _, sa := range infos.CommitteeBase {
+			for _, info := range sa.Items {
+				breakdown.Committee = append(breakdown.Committee, *newRewardEntry(info))
+			}
+		}
+		if minted == nil {
+			minted = infos.Total()
+		}
+	}
+	if minted != nil {
+		breakdown.Rewards = (*hexutil.Big)(new(big.Int).Set(minted))
+		breakdown.Total = (*hexutil.Big)(new(big.Int).Add(minted, fees))
+	}
+	return breakdown, nil
+}
+
+// newRewardEntry converts a reward payout into its breakdown entry.
+func newRewardEntry(info *types.RewardInfo) *RewardEntry {
+	amount := new(big.Int)
+	if info.Amount != nil {
+		amount.Set(info.Amount)
+	}
+	return &RewardEntry{Address: info.Address, Amount: (*hexutil.Big)(amount)}
+}
+
+// GetRewardBreakdown returns the rewards and fees paid out by the fast block with
+// the given number, itemized by recipient.
+func (s *PublicBlockChainAPI) GetRewardBreakdown(ctx context.Context, blockNr rpc.BlockNumber) (*RewardBreakdown, error) {
+	return s.b.GetRewardBreakdown(ctx, blockNr)
+}
+
 // GetUncleByBlockNumberAndIndex returns the uncle block for the given block hash and index. When fullTx is true
 // all transactions in the block are returned in full detail, otherwise only the transaction hash is returned.
 func (s *PublicBlockChainAPI) GetUncleByBlockNumberAndIndex(ctx context.Context, blockNr rpc.BlockNumber, index hexutil.Uint) (map[string]interface{}, error) {
@@ -863,10 +1207,16 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockHr
 			}
 		}
 	}
-	// Set default gas & gas price if none were set
-	gas, gasPrice := uint64(args.Gas), args.GasPrice.ToInt()
+	// Set default gas & gas price if none were set, within the gas cap
+	gas, gasPrice, gasCap := uint64(args.Gas), args.GasPrice.ToInt(), s.b.RPCGasCap()
 	if gas == 0 {
 		gas = math.MaxUint64 / 2
+		if gasCap != 0 {
+			gas = gasCap
+		}
+	}
+	if gasCap != 0 && gas > gasCap {
+		return nil, fmt.Errorf("%w: call of %d gas, cap %d", core.ErrGasCapExceeded, gas, gasCap)
 	}
 	if gasPrice.Sign() == 0 {
 		gasPrice = new(big.Int).SetUint64(defaultGasPrice)
@@ -952,6 +1302,10 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 		}
 		hi = block.GasLimit()
 	}
+	// Search within the gas cap, if any
+	if gasCap := s.b.RPCGasCap(); gasCap != 0 && hi > gasCap {
+		hi = gasCap
+	}
 	cap = hi
 
 	// Create a helper to check if a gas allowance results in an executable transaction
@@ -1465,8 +1819,8 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 	return result
 }
 
-// newRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
-func newRPCPendingTransaction(tx *types.Transaction, newhash bool) *RPCTransaction {
+// NewRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
+func NewRPCPendingTransaction(tx *types.Transaction, newhash bool) *RPCTransaction {
 	return newRPCTransaction(tx, common.Hash{}, 0, 0, true, newhash)
 }
 
@@ -1646,12 +2000,28 @@ func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, has
 	}
 	// No finalized transaction, try to retrieve it from the pool
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
-		return newRPCPendingTransaction(tx, true)
+		return NewRPCPendingTransaction(tx, true)
 	}
 	// Transaction unknown, return as such
 	return nil
 }
 
+// GetAddressTransactions returns the transactions sent from or to addr between
+// the blocks from and to, at most filters.MaxHistoryRange blocks apart. Internal
+// calls involving the address are not included.
+func (s *PublicTransactionPoolAPI) GetAddressTransactions(ctx context.Context, addr common.Address, from, to rpc.BlockNumber) ([]*RPCTransaction, error) {
+	found, err := s.b.GetAddressTransactions(ctx, addr, from, to)
+	if err != nil {
+		return nil, err
+	}
+	txs := make([]*RPCTransaction, len(found))
+	for i, tx := range found {
+		newhash := s.config.IsTIP10(new(big.Int).SetUint64(tx.BlockNumber))
+		txs[i] = newRPCTransaction(tx.Tx, tx.BlockHash, tx.BlockNumber, tx.Index, true, newhash)
+	}
+	return txs, nil
+}
+
 // GetRawTransactionByHash returns the bytes of the transaction for the given hash.
 func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context, hash common.Hash) (hexutil.Bytes, error) {
 	var tx *types.Transaction
@@ -1924,6 +2294,17 @@ func (s *PublicTransactionPoolAPI) SendRawTransaction(ctx context.Context, encod
 	return submitTransaction(ctx, s.b, tx)
 }
 
+// ValidateRawTransaction checks whether the given signed transaction would be
+// accepted by SendRawTransaction, returning the validation error if not. The
+// transaction is neither pooled nor broadcast.
+func (s *PublicTransactionPoolAPI) ValidateRawTransaction(ctx context.Context, encodedTx hexutil.Bytes) error {
+	rawTx := new(types.RawTransaction)
+	if err := rlp.DecodeBytes(encodedTx, rawTx); err != nil {
+		return err
+	}
+	return s.b.ValidateTransaction(ctx, rawTx.ConvertTransaction())
+}
+
 func (s *PublicTransactionPoolAPI) SendAbeyRawTransaction(ctx context.Context, encodedTx hexutil.Bytes) (common.Hash, error) {
 	tx := new(types.Transaction)
 	if err := rlp.DecodeBytes(encodedTx, tx); err != nil {
@@ -2038,7 +2419,7 @@ func (s *PublicTransactionPoolAPI) PendingTransactions() ([]*RPCTransaction, err
 		var signer types.Signer = types.NewTIP1Signer(tx.ChainId())
 		from, _ := types.Sender(signer, tx)
 		if _, exists := accounts[from]; exists {
-			transactions = append(transactions, newRPCPendingTransaction(tx, true))
+			transactions = append(transactions, NewRPCPendingTransaction(tx, true))
 		}
 	}
 	return transactions, nil
@@ -2093,6 +2474,282 @@ func (s *PublicTransactionPoolAPI) Resend(ctx context.Context, sendArgs SendTxAr
 	return common.Hash{}, fmt.Errorf("Transaction %#x not found", matchTx.Hash())
 }
 
+var (
+	// ErrTxNotPending is returned if the transaction to replace is not pending
+	// in the transaction pool.
+	ErrTxNotPending = errors.New("transaction not pending")
+
+	// ErrReplacePayment is returned if the transaction to replace is sponsored
+	// by a payer, whose signature cannot be renewed.
+	ErrReplacePayment = errors.New("cannot replace transaction with payment or fee")
+)
+
+// ReplaceTransaction rebuilds the pending transaction with the given hash with
+// a higher gas price, re-signs it with the wallet of its sender and submits it
+// through the backend. The new price must exceed the old one by at least
+// priceBump percent, as the transaction pool would otherwise refuse the
+// replacement. Transactions whose sender has no wallet in the account manager
+// are rejected.
+func ReplaceTransaction(ctx context.Context, b Backend, hash common.Hash, gasPrice *big.Int, priceBump uint64) (common.Hash, error) {
+	tx := b.GetPoolTransaction(hash)
+	if tx == nil {
+		return common.Hash{}, fmt.Errorf("%w: %#x", ErrTxNotPending, hash)
+	}
+	if tx.Payer() != nil || (tx.Fee() != nil && tx.Fee().Sign() != 0) {
+		return common.Hash{}, ErrReplacePayment
+	}
+	threshold := new(big.Int).Div(new(big.Int).Mul(tx.GasPrice(), big.NewInt(100+int64(priceBump))), big.NewInt(100))
+	if tx.GasPrice().Cmp(gasPrice) >= 0 || threshold.Cmp(gasPrice) > 0 {
+		return common.Hash{}, fmt.Errorf("%w: have %v, want at least %v", core.ErrReplaceUnderpriced, gasPrice, threshold)
+	}
+	from, err := types.Sender(types.NewTIP1Signer(tx.ChainId()), tx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	var replacement *types.Transaction
+	if to := tx.To(); to == nil {
+		replacement = types.NewContractCreation(tx.Nonce(), tx.Value(), tx.Gas(), gasPrice, tx.Data())
+	} else {
+		replacement = types.NewTransaction(tx.Nonce(), *to, tx.Value(), tx.Gas(), gasPrice, tx.Data())
+	}
+	// Look up the wallet of the sender to re-sign the replacement
+	account := accounts.Account{Address: from}
+	wallet, err := b.AccountManager().Find(account)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	signed, err := wallet.SignTx(account, replacement, b.ChainConfig().ChainID)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := b.SendTx(ctx, signed); err != nil {
+		return common.Hash{}, err
+	}
+	return signed.Hash(), nil
+}
+
+// AddressTransaction is a transaction involving an account along with the
+// position it was included at.
+type AddressTransaction struct {
+	Tx          *types.Transaction
+	BlockHash   common.Hash
+	BlockNumber uint64
+	Index       uint64
+}
+
+// TxConfirmations is the position of a transaction on the canonical chain along
+// with the number of blocks built on top of it, or the mark of a transaction
+// still waiting in the pool.
+type TxConfirmations struct {
+	Tx            *types.Transaction
+	BlockHash     common.Hash // Hash of the including block, empty if pending
+	BlockNumber   uint64      // Number of the including block
+	Index         uint64      // Index of the transaction in the block
+	Confirmations uint64      // Number of canonical blocks on top of the including one
+	Finalized     bool        // Whether the including block is final
+	Pending       bool        // Whether the transaction is pooled instead of mined
+}
+
+// NewTxConfirmations counts the confirmations of a transaction mined in the given
+// canonical block against the current and finalized heads of the backend.
+func NewTxConfirmations(b Backend, tx *types.Transaction, blockHash common.Hash, number uint64, index uint64) *TxConfirmations {
+	confs := &TxConfirmations{
+		Tx:          tx,
+		BlockHash:   blockHash,
+		BlockNumber: number,
+		Index:       index,
+		Finalized:   number <= b.CurrentFinalizedBlock().NumberU64(),
+	}
+	if head := b.CurrentBlock().NumberU64(); head > number {
+		confs.Confirmations = head - number
+	}
+	return confs
+}
+
+// EffectiveGasPrice returns the price per gas a mined transaction paid, which is
+// its gas price raised by the fee it carried spread over the gas it used. The fee
+// is taken from the receipt, or from the transaction for receipts stored before
+// they recorded it.
+func EffectiveGasPrice(tx *types.Transaction, receipt *types.Receipt) *big.Int {
+	price := new(big.Int).Set(tx.GasPrice())
+	fee := receipt.FeePaid
+	if fee == nil {
+		fee = tx.Fee()
+	}
+	if fee == nil || fee.Sign() == 0 || receipt.GasUsed == 0 {
+		return price
+	}
+	gas := new(big.Int).SetUint64(receipt.GasUsed)
+	price.Mul(price, gas).Add(price, fee)
+	return price.Div(price, gas)
+}
+
+// inclusionBlocks is the number of recent blocks EstimateInclusion samples the
+// gas limits and usage of.
+const inclusionBlocks = 20
+
+// EstimateInclusion estimates the number of blocks until a transaction paying
+// gasPrice gets included, one meaning the next block. It is a heuristic only:
+// blocks are assumed to take pending transactions in price order up to the
+// average gas limit of recent blocks, minus the gas of newly arriving
+// transactions outbidding it. Arrivals are taken to fill blocks as much as the
+// recent ones were filled, with prices distributed like the pending pool.
+func EstimateInclusion(ctx context.Context, b Backend, gasPrice *big.Int) (uint64, error) {
+	head, err := b.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if head == nil || err != nil {
+		return 0, err
+	}
+	// Average the gas limits and usage of the recent blocks
+	limit, used, sampled := head.GasLimit, head.GasUsed, uint64(1)
+	for number := head.Number.Uint64(); number > 0 && sampled < inclusionBlocks; sampled++ {
+		number--
+		header, err := b.HeaderByNumber(ctx, rpc.BlockNumber(number))
+		if err != nil {
+			return 0, err
+		}
+		if header == nil {
+			break
+		}
+		limit, used = limit+header.GasLimit, used+header.GasUsed
+	}
+	limit, used = limit/sampled, used/sampled
+
+	// Sum the pending gas queued ahead of the transaction
+	var ahead, total uint64
+	pending, _ := b.TxPoolContent()
+	for _, txs := range pending {
+		for _, tx := range txs {
+			if tx.GasPrice().Cmp(gasPrice) >= 0 {
+				ahead += tx.Gas()
+			}
+			total += tx.Gas()
+		}
+	}
+	// Leave out the arrivals outbidding the transaction, keeping at least a tenth
+	// of every block for the pending ones
+	room := limit
+	if total > 0 {
+		outbid := new(big.Int).Mul(new(big.Int).SetUint64(used), new(big.Int).SetUint64(ahead))
+		room -= outbid.Div(outbid, new(big.Int).SetUint64(total)).Uint64()
+	}
+	if room < limit/10 {
+		room = limit / 10
+	}
+	if room == 0 {
+		return 0, errors.New("recent blocks have no gas limit")
+	}
+	return 1 + ahead/room, nil
+}
+
+// HeaderByTimestamp returns the last canonical header whose timestamp is not
+// after unixTime, binary searching the chain up to the latest header. Times
+// before the genesis block yield the genesis header, times after the latest
+// header yield the latest header.
+func HeaderByTimestamp(ctx context.Context, b Backend, unixTime int64) (*types.Header, error) {
+	head, err := b.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if head == nil || err != nil {
+		return nil, err
+	}
+	target := big.NewInt(unixTime)
+	if head.Time.Cmp(target) <= 0 || head.Number.Sign() == 0 {
+		return head, nil
+	}
+	genesis, err := b.HeaderByNumber(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	if genesis == nil {
+		return nil, errors.New("missing genesis header")
+	}
+	if genesis.Time.Cmp(target) >= 0 {
+		return genesis, nil
+	}
+	// The target lies between the headers lo (inclusive) and hi (exclusive)
+	lo, hi := genesis, head
+	for hi.Number.Uint64()-lo.Number.Uint64() > 1 {
+		number := (lo.Number.Uint64() + hi.Number.Uint64()) / 2
+		header, err := b.HeaderByNumber(ctx, rpc.BlockNumber(number))
+		if err != nil {
+			return nil, err
+		}
+		if header == nil {
+			return nil, fmt.Errorf("missing header #%d", number)
+		}
+		if header.Time.Cmp(target) <= 0 {
+			lo = header
+		} else {
+			hi = header
+		}
+	}
+	return lo, nil
+}
+
+// AccountResult is the merkle proof of an account and of some of its storage
+// slots against a state root, in the layout of eth_getProof.
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []string        `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+// StorageResult is the merkle proof of a storage slot against the storage root
+// of its account.
+type StorageResult struct {
+	Key   string       `json:"key"`
+	Value *hexutil.Big `json:"value"`
+	Proof []string     `json:"proof"`
+}
+
+// NewAccountResult proves an account and the given storage slots of it against
+// the root of the state. Slots of an account without storage, or of a missing
+// account, are reported empty with an empty proof.
+func NewAccountResult(statedb *state.StateDB, addr common.Address, storageKeys []common.Hash) (*AccountResult, error) {
+	accountProof, err := statedb.GetProof(addr)
+	if err != nil {
+		return nil, err
+	}
+	result := &AccountResult{
+		Address:      addr,
+		AccountProof: toHexSlice(accountProof),
+		Balance:      (*hexutil.Big)(statedb.GetBalance(addr)),
+		CodeHash:     crypto.Keccak256Hash(nil),
+		Nonce:        hexutil.Uint64(statedb.GetNonce(addr)),
+		StorageHash:  types.EmptyRootHash,
+		StorageProof: make([]StorageResult, len(storageKeys)),
+	}
+	storageTrie := statedb.StorageTrie(addr)
+	if storageTrie != nil {
+		result.CodeHash = statedb.GetCodeHash(addr)
+		result.StorageHash = storageTrie.Hash()
+	}
+	for i, key := range storageKeys {
+		if storageTrie == nil {
+			result.StorageProof[i] = StorageResult{Key: key.Hex(), Value: new(hexutil.Big), Proof: []string{}}
+			continue
+		}
+		proof, err := statedb.GetStorageProof(addr, key)
+		if err != nil {
+			return nil, err
+		}
+		value := statedb.GetState(addr, key)
+		result.StorageProof[i] = StorageResult{Key: key.Hex(), Value: (*hexutil.Big)(value.Big()), Proof: toHexSlice(proof)}
+	}
+	return result, statedb.Error()
+}
+
+// toHexSlice encodes the nodes of a merkle proof as hex strings.
+func toHexSlice(b [][]byte) []string {
+	r := make([]string, len(b))
+	for i := range b {
+		r[i] = hexutil.Encode(b[i])
+	}
+	return r
+}
+
 // PublicDebugAPI is the collection of True APIs exposed over the public
 // debugging endpoint.
 type PublicDebugAPI struct {
@@ -2350,7 +3007,7 @@ func (s *PublicTransactionPoolAPI2) GetTransactionByHash(ctx context.Context, ha
 	}
 	// No finalized transaction, try to retrieve it from the pool
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
-		return newRPCPendingTransaction(tx, true)
+		return NewRPCPendingTransaction(tx, true)
 	}
 	// Transaction unknown, return as such
 	return nil
@@ -2656,7 +3313,7 @@ func (s *PublicTransactionPoolAPI2) PendingTransactions() ([]*RPCTransaction, er
 		var signer types.Signer = types.NewTIP1Signer(tx.ChainId())
 		from, _ := types.Sender(signer, tx)
 		if _, exists := accounts[from]; exists {
-			transactions = append(transactions, newRPCPendingTransaction(tx, true))
+			transactions = append(transactions, NewRPCPendingTransaction(tx, true))
 		}
 	}
 	return transactions, nil
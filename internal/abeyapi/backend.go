@@ -21,7 +21,9 @@ import (
 	"context"
 	"math/big"
 
+	abeychain "github.com/AbeyFoundation/go-abey"
 	"github.com/AbeyFoundation/go-abey/abey/downloader"
+	"github.com/AbeyFoundation/go-abey/abey/gasprice"
 	"github.com/AbeyFoundation/go-abey/abeydb"
 	"github.com/AbeyFoundation/go-abey/accounts"
 	"github.com/AbeyFoundation/go-abey/common"
@@ -39,52 +41,90 @@ import (
 type Backend interface {
 	// General ABEY API
 	Downloader() *downloader.Downloader
+	SyncProgress() abeychain.SyncProgress
 	ProtocolVersion() int
 	SuggestPrice(ctx context.Context) (*big.Int, error)
+	HeaderFeeInfo(ctx context.Context, blockNr rpc.BlockNumber) (*gasprice.HeaderFeeInfo, error)
 	ChainDb() abeydb.Database
 	EventMux() *event.TypeMux
 	AccountManager() *accounts.Manager
+	RPCGasCap() uint64 // Maximum gas of a call or a simulated bundle, zero if uncapped
 
 	// BlockChain API
-	SetHead(number uint64)
+	SetHead(number uint64) error
 	SetSnailHead(number uint64)
 	HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error)
 	SnailHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.SnailHeader, error)
+	HeaderByTimestamp(ctx context.Context, unixTime int64) (*types.Header, error)
 	BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error)
 	SnailBlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.SnailBlock, error)
 	GetFruit(ctx context.Context, fastblockHash common.Hash) (*types.SnailBlock, error)
+	FruitStatus(ctx context.Context, fastHash common.Hash) (included bool, snailNumber uint64, err error)
 	StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error)
 	StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error)
 	StateAndHeaderByHash(ctx context.Context, hash common.Hash) (*state.StateDB, *types.Header, error)
+	GetBalances(ctx context.Context, addrs []common.Address, blockNr rpc.BlockNumber) ([]*big.Int, error)
+	GetCode(ctx context.Context, addr common.Address, blockNr rpc.BlockNumber) ([]byte, error)
+	GetStorageAt(ctx context.Context, addr common.Address, key common.Hash, blockNr rpc.BlockNumber) (common.Hash, error)
+	GetProof(ctx context.Context, addr common.Address, storageKeys []common.Hash, blockNr rpc.BlockNumber) (*AccountResult, error)
 	GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error)
 	GetSnailBlock(ctx context.Context, blockHash common.Hash) (*types.SnailBlock, error)
 	GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error)
+	GetAddressTransactions(ctx context.Context, addr common.Address, from, to rpc.BlockNumber) ([]*AddressTransaction, error)
 	GetTd(blockHash common.Hash) *big.Int
 	GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmCfg vm.Config) (*vm.EVM, func() error, error)
+	SimulateBundle(ctx context.Context, txs []*types.Transaction, blockNr rpc.BlockNumber, stopOnRevert bool) ([]core.BundleTxResult, error)
+	SimulateTransaction(ctx context.Context, tx *types.Transaction, blockNr rpc.BlockNumber) (map[common.Address]*big.Int, error)
+	CreateAccessListFixedPoint(ctx context.Context, tx *types.Transaction, blockNr rpc.BlockNumber) (types.AccessList, uint64, error)
+	ClassifyTransaction(tx *types.Transaction) (*types.TxClassification, error)
+	ResolveName(ctx context.Context, registry common.Address, node common.Hash, blockNr rpc.BlockNumber) (common.Address, error)
 	SubscribeChainEvent(ch chan<- types.FastChainEvent) event.Subscription
 	SubscribeChainHeadEvent(ch chan<- types.FastChainHeadEvent) event.Subscription
 	SubscribeChainSideEvent(ch chan<- types.FastChainSideEvent) event.Subscription
+	SubscribeReorgEvent(ch chan<- types.ReorgEvent) event.Subscription
+	SubscribeNewSnailBlockEvent(ch chan<- types.SnailChainEvent) event.Subscription
+	SubscribeFilteredLogs(ch chan<- types.FilteredLogEvent) event.Subscription
 	GetReward(number int64) *types.BlockReward
 	GetCommittee(id rpc.BlockNumber) (map[string]interface{}, error)
 	GetCurrentCommitteeNumber() *big.Int
+	GetBlockSignatures(ctx context.Context, blockNr rpc.BlockNumber) (*BlockSignatures, error)
+	GetCommitteeSchedule(ctx context.Context) (*CommitteeSchedule, error)
 
 	GetStateChangeByFastNumber(fastNumber rpc.BlockNumber) *types.BlockBalance
 	GetBalanceChangeBySnailNumber(snailNumber rpc.BlockNumber) *types.BalanceChangeContent
 
 	GetSnailRewardContent(blockNr rpc.BlockNumber) *types.SnailRewardContenet
 	GetChainRewardContent(blockNr rpc.BlockNumber) *types.ChainReward
+	GetIssuance(ctx context.Context, blockNr rpc.BlockNumber) (*big.Int, error)
+	GetRewardBreakdown(ctx context.Context, blockNr rpc.BlockNumber) (*RewardBreakdown, error)
 
 	// TxPool API
 	SendTx(ctx context.Context, signedTx *types.Transaction) error
+	ValidateTransaction(ctx context.Context, tx *types.Transaction) error
+	ReplaceTransaction(ctx context.Context, oldHash common.Hash, newGasPrice *big.Int) (common.Hash, error)
 	GetPoolTransactions() (types.Transactions, error)
 	GetPoolTransaction(txHash common.Hash) *types.Transaction
+	GetRawTransaction(ctx context.Context, txHash common.Hash) ([]byte, error)
+	GetTransactionWithConfirmations(ctx context.Context, txHash common.Hash) (*TxConfirmations, error)
+	GetRawReceipt(ctx context.Context, txHash common.Hash) ([]byte, error)
+	EffectiveGasPrice(ctx context.Context, txHash common.Hash) (*big.Int, error)
 	GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error)
+	NextNonceAndReserve(ctx context.Context, addr common.Address) (uint64, error)
+	PendingNonceGap(ctx context.Context, addr common.Address) (gapStart, gapEnd uint64, hasGap bool, err error)
 	Stats() (pending int, queued int)
 	TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions)
+	TxPoolContentSorted() (pending, queued []AccountTxs)
+	PendingTransactionsOf(addr common.Address, queued bool) (types.Transactions, error)
+	TxPoolFeeHistogram() []FeeBucket
+	ExportTxPool() ([]byte, error)
+	ImportTxPool(data []byte) (int, error)
+	EstimateInclusion(ctx context.Context, gasPrice *big.Int) (uint64, error)
+	TxPoolOrdered() [][]*types.Transaction
 	SubscribeNewTxsEvent(chan<- types.NewTxsEvent) event.Subscription
 
 	ChainConfig() *params.ChainConfig
 	CurrentBlock() *types.Block
+	CurrentFinalizedBlock() *types.Block
 	CurrentSnailBlock() *types.SnailBlock
 
 	// SnailPool API
@@ -0,0 +1,97 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package abeyapi
+
+import (
+	"sync"
+	"time"
+
+	"github.com/AbeyFoundation/go-abey/common"
+)
+
+// DefaultNonceReservation is the time a reserved nonce is withheld from other
+// senders if the reserver has no explicit timeout.
+const DefaultNonceReservation = time.Minute
+
+// NonceReserver hands out the nonces of accounts signing transactions
+// concurrently, so that no two senders are given the same one. A nonce stays
+// reserved until the pool nonce of the account moves past it, meaning its
+// transaction was submitted, or until the reservation expires, after which it
+// is handed out again instead of leaving a permanent gap.
+//
+// The zero value is ready to use.
+type NonceReserver struct {
+	Timeout time.Duration    // Lifetime of a reservation, DefaultNonceReservation if zero
+	Now     func() time.Time // Clock the reservations expire by, time.Now if nil
+
+	locker   AddrLocker                              // Serialises the reservations of an account
+	reserved map[common.Address]map[uint64]time.Time // Expiry of the reserved nonces per account
+	lock     sync.Mutex
+}
+
+// Reserve returns the lowest nonce of the account not reserved yet, starting at
+// its pool nonce, and reserves it. The pool nonce is retrieved while no other
+// nonce of the account is being reserved, so a stale pool nonce can't hand out
+// the nonce of a transaction submitted in the meantime.
+func (r *NonceReserver) Reserve(addr common.Address, poolNonce func() (uint64, error)) (uint64, error) {
+	r.locker.LockAddr(addr)
+	defer r.locker.UnlockAddr(addr)
+
+	base, err := poolNonce()
+	if err != nil {
+		return 0, err
+	}
+	now, timeout := time.Now(), r.Timeout
+	if r.Now != nil {
+		now = r.Now()
+	}
+	if timeout == 0 {
+		timeout = DefaultNonceReservation
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.reserved == nil {
+		r.reserved = make(map[common.Address]map[uint64]time.Time)
+	}
+	// Drop the expired reservations of all accounts, and those of the submitted
+	// transactions of this one, forgetting the accounts left without any
+	for account, reserved := range r.reserved {
+		for nonce, expiry := range reserved {
+			if (account == addr && nonce < base) || !now.Before(expiry) {
+				delete(reserved, nonce)
+			}
+		}
+		if len(reserved) == 0 {
+			delete(r.reserved, account)
+		}
+	}
+	reserved := r.reserved[addr]
+	if reserved == nil {
+		reserved = make(map[uint64]time.Time)
+		r.reserved[addr] = reserved
+	}
+	nonce := base
+	for {
+		if _, ok := reserved[nonce]; !ok {
+			break
+		}
+		nonce++
+	}
+	reserved[nonce] = now.Add(timeout)
+	return nonce, nil
+}
@@ -19,10 +19,16 @@ package les
 import (
 	"context"
 	"errors"
+	"fmt"
+	abeychain "github.com/AbeyFoundation/go-abey"
 	"github.com/AbeyFoundation/go-abey/abey/downloader"
 	"github.com/AbeyFoundation/go-abey/abey/fastdownloader"
+	"github.com/AbeyFoundation/go-abey/abey/filters"
 	"github.com/AbeyFoundation/go-abey/light"
 	"math/big"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/AbeyFoundation/go-abey/abey/gasprice"
 	"github.com/AbeyFoundation/go-abey/abeydb"
@@ -32,68 +38,599 @@ import (
 	"github.com/AbeyFoundation/go-abey/core"
 	"github.com/AbeyFoundation/go-abey/core/bloombits"
 	"github.com/AbeyFoundation/go-abey/core/rawdb"
+	snaildb "github.com/AbeyFoundation/go-abey/core/snailchain/rawdb"
 	"github.com/AbeyFoundation/go-abey/core/state"
 	"github.com/AbeyFoundation/go-abey/core/types"
 	"github.com/AbeyFoundation/go-abey/core/vm"
+	"github.com/AbeyFoundation/go-abey/crypto"
 	"github.com/AbeyFoundation/go-abey/event"
+	"github.com/AbeyFoundation/go-abey/internal/abeyapi"
+	"github.com/AbeyFoundation/go-abey/log"
 	"github.com/AbeyFoundation/go-abey/params"
+	"github.com/AbeyFoundation/go-abey/rlp"
 	"github.com/AbeyFoundation/go-abey/rpc"
+	"github.com/AbeyFoundation/go-abey/trie"
+	lru "github.com/hashicorp/golang-lru"
 )
 
 type LesApiBackend struct {
 	abey *LightAbey
 	gpo  *gasprice.Oracle
+
+	cache          *odrCache  // Cache of network retrieved headers, receipts and code sharing a memory budget
+	committeeCache *lru.Cache // Cache of network retrieved committee members keyed by epoch
+	rewardCache    *lru.Cache // Cache of snail reward contents keyed by snail number
+	fruitCache     *lru.Cache // Cache of the including snail numbers of fruits keyed by fast hash
+
+	minGasPrice     *big.Int // Explicit gas price floor of sent transactions, nil if derived from the oracle
+	minGasPriceLock sync.RWMutex
+
+	snailHead     *types.SnailHeader // Cached snail head of the serving peers, nil if stale
+	snailHeadLock sync.Mutex
+
+	filterThreads  int           // Number of goroutines multiplexing the bloom retrievals of a filter
+	retrievalBatch int           // Maximum number of bloom bit retrievals serviced in a batch
+	retrievalWait  time.Duration // Maximum time to wait for a batch of bloom bit retrievals to fill
+	logsLimit      int           // Maximum number of logs returned by a range log query
+	filterLock     sync.RWMutex
+
+	readTimeout     time.Duration // Timeout of ODR reads whose caller context carries no deadline
+	readTimeoutLock sync.RWMutex
+
+	maxHeadAge  time.Duration    // Maximum age of the head served to latest reads, zero if unlimited
+	now         func() time.Time // Clock the head age is measured against
+	headAgeLock sync.RWMutex
+
+	nonces abeyapi.NonceReserver // Nonces handed out to concurrent senders
+}
+
+// newLesApiBackend creates the light API backend, caching up to cacheSize
+// headers retrieved through ODR within the default cache budget. Cached headers
+// are invalidated whenever the light chain head reorganises.
+func newLesApiBackend(abey *LightAbey, cacheSize int) *LesApiBackend {
+	if cacheSize <= 0 {
+		cacheSize = defaultHeaderCacheSize
+	}
+	cache := newODRCache(defaultCacheBudget)
+	cache.setLimit(cachedHeader, cacheSize)
+	committeeCache, _ := lru.New(committeeCacheLimit)
+	rewardCache, _ := lru.New(rewardContentCacheSize)
+	fruitCache, _ := lru.New(fruitStatusCacheSize)
+	b := &LesApiBackend{
+		abey:           abey,
+		cache:          cache,
+		committeeCache: committeeCache,
+		rewardCache:    rewardCache,
+		fruitCache:     fruitCache,
+		filterThreads:  bloomFilterThreads,
+		retrievalBatch: bloomRetrievalBatch,
+		retrievalWait:  bloomRetrievalWait,
+		logsLimit:      filters.DefaultLogsLimit,
+		readTimeout:    defaultReadTimeout,
+		now:            time.Now,
+	}
+	if abey.blockchain != nil {
+		go b.invalidateLoop()
+	}
+	return b
+}
+
+// invalidateLoop drops the cached headers replaced by a reorganisation of the
+// light chain, until the light client is shut down.
+func (b *LesApiBackend) invalidateLoop() {
+	heads := make(chan types.FastChainHeadEvent, 10)
+	sub := b.abey.blockchain.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case ev := <-heads:
+			b.invalidateHeaders(ev.Block.Header())
+			b.invalidateSnailHead()
+		case <-sub.Err():
+			return
+		case <-b.abey.shutdownChan:
+			return
+		}
+	}
+}
+
+// invalidateHeaders purges the header cache if it contradicts the new head.
+func (b *LesApiBackend) invalidateHeaders(head *types.Header) {
+	number := head.Number.Uint64()
+	if cached, ok := b.cache.peek(cachedHeader, number); ok && cached.(*types.Header).Hash() != head.Hash() {
+		b.cache.purge(cachedHeader)
+		return
+	}
+	if number > 0 {
+		if cached, ok := b.cache.peek(cachedHeader, number-1); ok && cached.(*types.Header).Hash() != head.ParentHash {
+			b.cache.purge(cachedHeader)
+		}
+	}
+}
+
+// invalidateSnailHead drops the cached snail head, so that it is retrieved
+// again on the next request. Light peers don't announce snail heads, new fast
+// heads are taken as the hint that the snail chain may have advanced too.
+func (b *LesApiBackend) invalidateSnailHead() {
+	b.snailHeadLock.Lock()
+	b.snailHead = nil
+	b.snailHeadLock.Unlock()
+}
+
+// headerByNumberOdr retrieves a canonical header by number, serving it from the
+// header cache if it was retrieved before.
+func (b *LesApiBackend) headerByNumberOdr(ctx context.Context, number uint64) (*types.Header, error) {
+	if cached, ok := b.cache.get(cachedHeader, number); ok {
+		return cached.(*types.Header), nil
+	}
+	header, err := b.abey.blockchain.GetHeaderByNumberOdr(ctx, number)
+	if header != nil && err == nil {
+		b.cache.add(cachedHeader, number, header, uint64(header.Size()))
+	}
+	return header, err
 }
 
 var (
+	// NotSupportOnLes is the base error of all backend methods the light client
+	// cannot serve, ErrNotSupportedOnLes errors match it with errors.Is.
 	NotSupportOnLes = errors.New("not support on les protocol")
+
+	// ErrGasPriceTooLow is returned by SendTx if the gas price of a transaction
+	// is below the minimum, since full peers would reject it.
+	ErrGasPriceTooLow = errors.New("gas price below minimum")
+
+	// ErrInvalidStateProof is returned by GetVerifiedNonce and GetStorageAt if a
+	// proof served by a peer does not match the root of the trie it proves.
+	ErrInvalidStateProof = errors.New("invalid state proof")
+
+	// ErrInvalidCode is returned by GetCode if the code served by a peer does
+	// not hash to the code hash of the account.
+	ErrInvalidCode = errors.New("code does not match code hash")
+
+	errNonCanonicalHash = errors.New("hash is not currently canonical")
+	errInvalidHead      = errors.New("invalid head")
 )
 
+const (
+	// tdRetrievalTimeout is the maximum time GetTd waits for an ODR total
+	// difficulty retrieval, since the backend API carries no caller context.
+	tdRetrievalTimeout = 5 * time.Second
+
+	// committeeRetrievalTimeout is the maximum time GetCommittee waits for the
+	// ODR retrieval of the block announcing a committee.
+	committeeRetrievalTimeout = 5 * time.Second
+
+	// snailHeadRetrievalTimeout is the maximum time CurrentSnailBlock waits for
+	// the ODR retrieval of the current snail head.
+	snailHeadRetrievalTimeout = 5 * time.Second
+
+	// finalizedRetrievalTimeout is the maximum time CurrentFinalizedBlock waits
+	// for the ODR retrieval of the last header of the latest CHT section.
+	finalizedRetrievalTimeout = 5 * time.Second
+
+	// rewardRetrievalTimeout is the maximum time GetReward waits for the ODR
+	// retrieval of a block reward and its proving header, and the time
+	// GetSnailRewardContent waits for the rewarded snail block.
+	rewardRetrievalTimeout = 5 * time.Second
+
+	// balanceRetrievalTimeout is the maximum time GetStateChangeByFastNumber
+	// waits for the ODR retrieval of the balance changes and their proofs.
+	balanceRetrievalTimeout = 5 * time.Second
+
+	// rewardContentCacheSize is the number of snail reward contents cached.
+	rewardContentCacheSize = 128
+
+	// fruitStatusCacheSize is the number of fruit inclusions cached.
+	fruitStatusCacheSize = 1024
+
+	// gasPriceFloorPercent is the percentage of the suggested gas price below
+	// which SendTx rejects transactions if no explicit minimum is set.
+	gasPriceFloorPercent = 50
+
+	// headerBatchWorkers is the maximum number of concurrent ODR header
+	// retrievals issued by HeaderByNumbers.
+	headerBatchWorkers = 16
+
+	// balanceBatchWorkers is the maximum number of concurrent ODR account
+	// retrievals issued by GetBalances.
+	balanceBatchWorkers = 16
+
+	// receiptsBatchWorkers is the maximum number of concurrent ODR receipt
+	// retrievals issued by GetReceiptsMulti.
+	receiptsBatchWorkers = 8
+
+	// defaultHeaderCacheSize is the number of ODR retrieved headers cached if
+	// no explicit size is configured.
+	defaultHeaderCacheSize = 256
+
+	// defaultCacheBudget is the memory in bytes the headers, receipts and code
+	// retrieved through ODR are cached in if no explicit budget is configured.
+	defaultCacheBudget = 32 * 1024 * 1024
+
+	// defaultReadTimeout is the time GetBlock and GetReceipts wait for an ODR
+	// retrieval if neither the caller nor the operator set a deadline.
+	defaultReadTimeout = 10 * time.Second
+)
+
+// ErrNotSupportedOnLes is returned by the backend methods the light client
+// cannot serve, naming the unsupported method.
+type ErrNotSupportedOnLes struct {
+	Method string
+}
+
+func (e *ErrNotSupportedOnLes) Error() string {
+	return fmt.Sprintf("%s: %v", e.Method, NotSupportOnLes)
+}
+
+// Unwrap returns NotSupportOnLes, allowing callers to match it with errors.Is.
+func (e *ErrNotSupportedOnLes) Unwrap() error {
+	return NotSupportOnLes
+}
+
+// StaleHeadError is returned by the reads of the latest block if the light chain
+// head is older than the configured maximum age, as happens when the client lost
+// its peers. The data served might be outdated, so it is withheld.
+type StaleHeadError struct {
+	Number uint64        // Number of the stale head
+	Age    time.Duration // Age of the head by its timestamp
+	MaxAge time.Duration // Maximum age allowed
+}
+
+func (e *StaleHeadError) Error() string {
+	return fmt.Sprintf("stale head %d: %v old, at most %v allowed", e.Number, e.Age, e.MaxAge)
+}
+
+// notSupported returns the error of the named unsupported method.
+func notSupported(method string) error {
+	return &ErrNotSupportedOnLes{Method: method}
+}
+
+// HeaderBatchError is returned by HeaderByNumbers if some of the requested
+// headers could not be retrieved, it holds the failure of each position.
+type HeaderBatchError []error
+
+func (e HeaderBatchError) Error() string {
+	var (
+		failed int
+		first  error
+	)
+	for _, err := range e {
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			failed++
+		}
+	}
+	return fmt.Sprintf("%d of %d headers unavailable, first error: %v", failed, len(e), first)
+}
+
+// ReceiptsBatchError is returned by GetReceiptsMulti if the receipts of some of
+// the requested blocks could not be retrieved, it holds the failure of each
+// position.
+type ReceiptsBatchError []error
+
+func (e ReceiptsBatchError) Error() string {
+	var (
+		failed int
+		first  error
+	)
+	for _, err := range e {
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			failed++
+		}
+	}
+	return fmt.Sprintf("receipts of %d of %d blocks unavailable, first error: %v", failed, len(e), first)
+}
+
 // ////////////////////////////////////////////////////////////
 func (b *LesApiBackend) SetSnailHead(number uint64) {
 
 }
+
+// SnailHeaderByNumber returns the snail header with the given number, retrieving
+// it on demand through ODR. The latest and pending numbers resolve to the snail
+// head of the serving peer since the light client does not follow the snail chain.
 func (b *LesApiBackend) SnailHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.SnailHeader, error) {
-	return nil, NotSupportOnLes
+	var (
+		header *types.SnailHeader
+		err    error
+	)
+	if blockNr == rpc.LatestBlockNumber || blockNr == rpc.PendingBlockNumber {
+		header, err = light.GetCurrentSnailHeader(ctx, b.abey.blockchain.Odr(), b.abey.chainConfig, b.abey.engine)
+	} else {
+		header, err = light.GetSnailHeaderByNumber(ctx, b.abey.blockchain.Odr(), b.abey.chainConfig, b.abey.engine, uint64(blockNr))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("snail header %d unavailable: %w", blockNr, err)
+	}
+	return header, nil
 }
 func (b *LesApiBackend) SnailBlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.SnailBlock, error) {
-	return nil, NotSupportOnLes
+	return nil, notSupported("SnailBlockByNumber")
 }
 func (b *LesApiBackend) GetFruit(ctx context.Context, fastblockHash common.Hash) (*types.SnailBlock, error) {
-	return nil, NotSupportOnLes
+	return nil, notSupported("GetFruit")
 }
+
+// FruitStatus reports whether the fast block with the given hash is included as
+// a fruit in the snail chain, along with the number of the including snail block.
+// The light client does not follow the snail chain, but fruits are included in
+// ascending fast number order, so the including block is found by a binary search
+// over snail blocks retrieved through ODR. Inclusions are permanent, so the ones
+// found in snail blocks linked to the fast chain by their reward are cached. The
+// ones found in more recent snail blocks are only vouched for by the serving peer
+// and are looked up again every time.
+func (b *LesApiBackend) FruitStatus(ctx context.Context, fastHash common.Hash) (bool, uint64, error) {
+	if cached, ok := b.fruitCache.Get(fastHash); ok {
+		return true, cached.(uint64), nil
+	}
+	header, err := b.HeaderByHash(ctx, fastHash)
+	if header == nil || err != nil {
+		return false, 0, err
+	}
+	odr := b.abey.blockchain.Odr()
+	head, err := light.GetCurrentSnailHeader(ctx, odr, b.abey.chainConfig, b.abey.engine)
+	if err != nil {
+		return false, 0, err
+	}
+	number := header.Number.Uint64()
+	for lo, hi := uint64(1), head.Number.Uint64(); lo <= hi; {
+		mid := lo + (hi-lo)/2
+		snailHeader, err := light.GetSnailHeaderByNumber(ctx, odr, b.abey.chainConfig, b.abey.engine, mid)
+		if err != nil {
+			return false, 0, err
+		}
+		block, err := light.GetSnailBlock(ctx, odr, snailHeader.Hash(), mid)
+		if err != nil {
+			return false, 0, err
+		}
+		fruits := block.Fruits()
+		if len(fruits) == 0 {
+			return false, 0, fmt.Errorf("snail block %d has no fruits", mid)
+		}
+		switch {
+		case number < fruits[0].FastNumber().Uint64():
+			hi = mid - 1
+		case number > fruits[len(fruits)-1].FastNumber().Uint64():
+			lo = mid + 1
+		default:
+			for _, fruit := range fruits {
+				if fruit.FastHash() == fastHash {
+					if snaildb.ReadCanonicalHash(odr.Database(), mid) == snailHeader.Hash() {
+						b.fruitCache.Add(fastHash, mid)
+					}
+					return true, mid, nil
+				}
+			}
+			return false, 0, nil
+		}
+	}
+	return false, 0, nil
+}
+
+// StateAndHeaderByNumberOrHash returns the ODR backed state and the header of the
+// block with the given number or hash. A hash required to be canonical is checked
+// against the canonical header at its number, retrieved through ODR if needed.
 func (b *LesApiBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
-	return nil, nil, NotSupportOnLes
+	if blockNr, ok := blockNrOrHash.Number(); ok {
+		return b.StateAndHeaderByNumber(ctx, blockNr)
+	}
+	hash, ok := blockNrOrHash.Hash()
+	if !ok {
+		return nil, nil, errors.New("invalid arguments; neither block nor hash specified")
+	}
+	statedb, header, err := b.StateAndHeaderByHash(ctx, hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if blockNrOrHash.RequireCanonical {
+		canonical, err := b.headerByNumberOdr(ctx, header.Number.Uint64())
+		if err != nil {
+			return nil, nil, err
+		}
+		if canonical.Hash() != hash {
+			return nil, nil, errNonCanonicalHash
+		}
+	}
+	return statedb, header, nil
 }
+
+// StateAndHeaderByHash returns the ODR backed state and the header of the block
+// with the given hash, which must be known by the light chain.
 func (b *LesApiBackend) StateAndHeaderByHash(ctx context.Context, hash common.Hash) (*state.StateDB, *types.Header, error) {
-	return nil, nil, NotSupportOnLes
+	header, err := b.HeaderByHash(ctx, hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if header == nil {
+		return nil, nil, errors.New("header for hash not found")
+	}
+	return light.NewState(ctx, header, b.abey.odr), header, nil
 }
 func (b *LesApiBackend) GetSnailBlock(ctx context.Context, blockHash common.Hash) (*types.SnailBlock, error) {
-	return nil, NotSupportOnLes
+	return nil, notSupported("GetSnailBlock")
 }
+
+// GetReward returns the reward of the snail block with the given number, the
+// latest one rewarded by the light chain for negative numbers. Rewards missing
+// locally are retrieved through ODR and proven by the canonical fast header
+// that paid them.
 func (b *LesApiBackend) GetReward(number int64) *types.BlockReward {
-	return nil
+	odr := b.abey.blockchain.Odr()
+	if number < 0 {
+		number = int64(rawdb.ReadHeadRewardNumber(odr.Database()))
+		if number == 0 {
+			return nil
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), rewardRetrievalTimeout)
+	defer cancel()
+
+	reward, err := light.GetBlockReward(ctx, odr, uint64(number))
+	if err != nil {
+		log.Debug("Failed to retrieve block reward", "snail", number, "err", err)
+		return nil
+	}
+	return reward
 }
+
+// GetCommittee returns the committee elected for the given epoch, the current
+// one for the latest and pending numbers. The members are taken from the switch
+// infos of the first block of the epoch, retrieved from a full node through ODR.
 func (b *LesApiBackend) GetCommittee(id rpc.BlockNumber) (map[string]interface{}, error) {
-	return nil, NotSupportOnLes
+	var epochID uint64
+	if id == rpc.LatestBlockNumber || id == rpc.PendingBlockNumber {
+		epochID = b.GetCurrentCommitteeNumber().Uint64()
+	} else {
+		epochID = uint64(id.Int64())
+	}
+	epoch := types.GetEpochFromID(epochID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), committeeRetrievalTimeout)
+	defer cancel()
+	members, err := b.committeeMembers(ctx, epoch)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"id":          epochID,
+		"memberCount": len(members),
+		"members":     membersDisplay(members),
+		"beginNumber": epoch.BeginHeight,
+		"endNumber":   epoch.EndHeight,
+	}, nil
 }
+
+// GetCurrentCommitteeNumber returns the epoch of the light chain head, which
+// numbers the committee validating it.
 func (b *LesApiBackend) GetCurrentCommitteeNumber() *big.Int {
-	return nil
+	head := b.abey.blockchain.CurrentHeader().Number.Uint64()
+	return new(big.Int).SetUint64(types.GetEpochFromHeight(head).EpochID)
+}
+
+// GetBlockSignatures returns the committee signature status of the fast block
+// with the given number. The block is retrieved through ODR if needed, as its
+// body carries the signatures, and they are checked against the committee known
+// to the light election.
+func (b *LesApiBackend) GetBlockSignatures(ctx context.Context, blockNr rpc.BlockNumber) (*abeyapi.BlockSignatures, error) {
+	block, err := b.BlockByNumber(ctx, blockNr)
+	if block == nil || err != nil {
+		return nil, err
+	}
+	return abeyapi.NewBlockSignatures(block, b.abey.election)
+}
+
+// GetCommitteeSchedule returns the epoch of the light chain head and the block of
+// the next rotation. It is a limited view: the epoch boundaries follow from the
+// consensus parameters alone, but the size of the serving committee is left out,
+// as it is only known once the committee is retrieved from a full node.
+func (b *LesApiBackend) GetCommitteeSchedule(ctx context.Context) (*abeyapi.CommitteeSchedule, error) {
+	head := b.abey.blockchain.CurrentHeader().Number.Uint64()
+	return abeyapi.NewCommitteeSchedule(head, nil), nil
 }
+
+// committeeMembers retrieves the members of the committee elected for the given
+// epoch, falling back to the default members if the first block of the epoch
+// carries no switch infos. Committees change once per epoch only, so they are
+// cached after a successful retrieval.
+func (b *LesApiBackend) committeeMembers(ctx context.Context, epoch *types.EpochIDInfo) ([]*types.CommitteeMember, error) {
+	if cached, ok := b.committeeCache.Get(epoch.EpochID); ok {
+		return cached.([]*types.CommitteeMember), nil
+	}
+	block, err := b.abey.blockchain.GetBlockByNumber(ctx, epoch.BeginHeight)
+	if err != nil {
+		return nil, fmt.Errorf("committee %d unavailable: %v", epoch.EpochID, err)
+	}
+	if block == nil {
+		return nil, fmt.Errorf("committee %d unavailable: %v", epoch.EpochID, ErrCommittee)
+	}
+	members := block.SwitchInfos()
+	if len(members) == 0 {
+		members = b.abey.election.defaultMembers
+	}
+	b.committeeCache.Add(epoch.EpochID, members)
+	return members, nil
+}
+
+// GetStateChangeByFastNumber returns the balance changes produced by the fast
+// block with the given number. The changes are retrieved through ODR and every
+// entry is proven against the state of the block before it is returned, though
+// entries left out by the serving peer can't be detected.
 func (b *LesApiBackend) GetStateChangeByFastNumber(fastNumber rpc.BlockNumber) *types.BlockBalance {
-	return nil
+	number := uint64(fastNumber)
+	if fastNumber == rpc.LatestBlockNumber || fastNumber == rpc.PendingBlockNumber {
+		number = b.abey.blockchain.CurrentHeader().Number.Uint64()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), balanceRetrievalTimeout)
+	defer cancel()
+
+	balance, err := light.GetBalanceChange(ctx, b.abey.blockchain.Odr(), number)
+	if err != nil {
+		log.Debug("Failed to retrieve balance changes", "number", number, "err", err)
+		return nil
+	}
+	return balance
 }
 func (b *LesApiBackend) GetBalanceChangeBySnailNumber(snailNumber rpc.BlockNumber) *types.BalanceChangeContent {
 	return nil
 }
+
+// GetSnailRewardContent returns the reward breakdown of the snail block with the
+// given number, the latest rewarded one for negative numbers. The snail block is
+// retrieved through ODR and proven by the hash recorded in its reward, then the
+// content is derived from it by the consensus engine as on full nodes. Contents
+// of rewarded blocks never change, so they are cached.
 func (b *LesApiBackend) GetSnailRewardContent(blockNr rpc.BlockNumber) *types.SnailRewardContenet {
-	return nil
+	reward := b.GetReward(int64(blockNr))
+	if reward == nil {
+		return nil
+	}
+	number := reward.SnailNumber.Uint64()
+	if cached, ok := b.rewardCache.Get(number); ok {
+		return cached.(*types.SnailRewardContenet)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), rewardRetrievalTimeout)
+	defer cancel()
+
+	block, err := light.GetSnailBlock(ctx, b.abey.blockchain.Odr(), reward.SnailHash, number)
+	if err != nil {
+		log.Debug("Failed to retrieve rewarded snail block", "snail", number, "err", err)
+		return nil
+	}
+	content := b.abey.engine.GetRewardContentBySnailNumber(block)
+	if content != nil {
+		b.rewardCache.Add(number, content)
+	}
+	return content
 }
 func (b *LesApiBackend) GetChainRewardContent(blockNr rpc.BlockNumber) *types.ChainReward {
 	return nil
 }
+
+// CurrentSnailBlock returns a header-only block of the current snail head of
+// the serving peers, or nil if it cannot be retrieved. The head is cached until
+// the light chain advances.
 func (b *LesApiBackend) CurrentSnailBlock() *types.SnailBlock {
-	return nil
+	b.snailHeadLock.Lock()
+	defer b.snailHeadLock.Unlock()
+
+	if b.snailHead == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), snailHeadRetrievalTimeout)
+		defer cancel()
+
+		head, err := light.GetCurrentSnailHeader(ctx, b.abey.blockchain.Odr(), b.abey.chainConfig, b.abey.engine)
+		if err != nil {
+			log.Debug("Failed to retrieve current snail head", "err", err)
+			return nil
+		}
+		b.snailHead = head
+	}
+	return types.NewSnailBlockWithHeader(b.snailHead)
 }
 func (b *LesApiBackend) SnailPoolContent() []*types.SnailBlock {
 	return nil
@@ -113,22 +650,137 @@ func (b *LesApiBackend) ChainConfig() *params.ChainConfig {
 	return b.abey.chainConfig
 }
 
+// RPCGasCap returns the maximum gas of a call.
+func (b *LesApiBackend) RPCGasCap() uint64 {
+	return b.abey.config.RPCGasCap
+}
+
 func (b *LesApiBackend) CurrentBlock() *types.Block {
 	return types.NewBlockWithHeader(b.abey.blockchain.CurrentHeader())
 }
 
-func (b *LesApiBackend) SetHead(number uint64) {
+// CurrentFinalizedBlock returns a header-only block of the last block proven by
+// the latest trusted CHT section, the genesis block without one. The light
+// client does not follow the snail chain confirming fast blocks, so it only
+// vouches for the finality of blocks sealed into a CHT.
+func (b *LesApiBackend) CurrentFinalizedBlock() *types.Block {
+	odr := b.abey.blockchain.Odr()
+	if indexer := odr.ChtIndexer(); indexer != nil {
+		if sections, number, _ := indexer.Sections(); sections > 0 {
+			ctx, cancel := context.WithTimeout(context.Background(), finalizedRetrievalTimeout)
+			defer cancel()
+
+			header, err := b.headerByNumberOdr(ctx, number)
+			if err == nil {
+				return types.NewBlockWithHeader(header)
+			}
+			log.Debug("Failed to retrieve finalized header", "number", number, "err", err)
+		}
+	}
+	return b.abey.blockchain.Genesis()
+}
+
+// SetHead rewinds the light chain to the given number. The target must not be
+// above the current head and its header must be known locally, otherwise the
+// head is left unchanged.
+func (b *LesApiBackend) SetHead(number uint64) error {
+	if current := b.abey.blockchain.CurrentHeader().Number.Uint64(); number > current {
+		return fmt.Errorf("%w: number %d above current head %d", errInvalidHead, number, current)
+	}
+	if b.abey.blockchain.GetHeaderByNumber(number) == nil {
+		return fmt.Errorf("%w: unknown header %d", errInvalidHead, number)
+	}
 	b.abey.protocolManager.downloader.Cancel()
 	b.abey.blockchain.SetHead(number)
+	b.cache.purge(cachedHeader)
+
+	if b.abey.blockchain.CurrentHeader() == nil {
+		return fmt.Errorf("%w: no header after rewinding to %d", errInvalidHead, number)
+	}
+	return nil
 }
 
+// HeaderByNumber returns the header with the given number, retrieving it through
+// ODR if needed. The latest and pending numbers resolve to the light chain head,
+// failing with a StaleHeadError if it is older than the configured maximum age.
 func (b *LesApiBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error) {
 	if blockNr == rpc.LatestBlockNumber || blockNr == rpc.PendingBlockNumber {
-		return b.abey.blockchain.CurrentHeader(), nil
+		head := b.abey.blockchain.CurrentHeader()
+		if err := b.checkHeadAge(head); err != nil {
+			return nil, err
+		}
+		return head, nil
 	}
 
-	return b.abey.blockchain.GetHeaderByNumberOdr(ctx, uint64(blockNr))
+	return b.headerByNumberOdr(ctx, uint64(blockNr))
 }
+
+// HeaderByTimestamp returns the last header of the light chain not after the
+// given time, retrieving the searched headers through ODR if needed, see
+// abeyapi.HeaderByTimestamp.
+func (b *LesApiBackend) HeaderByTimestamp(ctx context.Context, unixTime int64) (*types.Header, error) {
+	return abeyapi.HeaderByTimestamp(ctx, b, unixTime)
+}
+
+// HeaderByNumbers retrieves the headers of all the requested block numbers. Headers
+// known locally are served directly, the rest are fetched through ODR concurrently
+// by a bounded set of workers. The returned slice matches the order of the input,
+// if some headers are unavailable the partial result is returned together with a
+// HeaderBatchError describing the failure of each position.
+func (b *LesApiBackend) HeaderByNumbers(ctx context.Context, blockNrs []rpc.BlockNumber) ([]*types.Header, error) {
+	var (
+		headers = make([]*types.Header, len(blockNrs))
+		errs    = make(HeaderBatchError, len(blockNrs))
+		pending []int
+	)
+	for i, blockNr := range blockNrs {
+		if blockNr == rpc.LatestBlockNumber || blockNr == rpc.PendingBlockNumber {
+			headers[i] = b.abey.blockchain.CurrentHeader()
+			continue
+		}
+		if header := b.abey.blockchain.GetHeaderByNumber(uint64(blockNr)); header != nil {
+			headers[i] = header
+			continue
+		}
+		pending = append(pending, i)
+	}
+	if len(pending) > 0 {
+		var (
+			wg    sync.WaitGroup
+			tasks = make(chan int)
+		)
+		workers := headerBatchWorkers
+		if len(pending) < workers {
+			workers = len(pending)
+		}
+		for n := 0; n < workers; n++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range tasks {
+					headers[i], errs[i] = b.headerByNumberOdr(ctx, uint64(blockNrs[i]))
+				}
+			}()
+		}
+		for _, i := range pending {
+			tasks <- i
+		}
+		close(tasks)
+		wg.Wait()
+	}
+	for i := range errs {
+		if errs[i] == nil && headers[i] == nil {
+			errs[i] = light.ErrNoHeader
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return headers, errs
+		}
+	}
+	return headers, nil
+}
+
 func (b *LesApiBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
 	return b.abey.blockchain.GetHeaderByHash(hash), nil
 }
@@ -149,27 +801,408 @@ func (b *LesApiBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.
 	return light.NewState(ctx, header, b.abey.odr), header, nil
 }
 
+// GetBalances returns the unlocked balances of the given accounts in the state
+// of the block with the given number, in the order of addrs. The header is only
+// resolved once, while the account proofs are retrieved through ODR concurrently.
+// Each account is read from a state of its own, so a failed retrieval does not
+// poison the others, but trie nodes already retrieved are shared through the
+// database.
+func (b *LesApiBackend) GetBalances(ctx context.Context, addrs []common.Address, blockNr rpc.BlockNumber) ([]*big.Int, error) {
+	header, err := b.HeaderByNumber(ctx, blockNr)
+	if header == nil || err != nil {
+		return nil, err
+	}
+	var (
+		balances = make([]*big.Int, len(addrs))
+		errs     = make([]error, len(addrs))
+		wg       sync.WaitGroup
+		tasks    = make(chan int)
+	)
+	workers := balanceBatchWorkers
+	if len(addrs) < workers {
+		workers = len(addrs)
+	}
+	for n := 0; n < workers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range tasks {
+				statedb := light.NewState(ctx, header, b.abey.odr)
+				balances[i], errs[i] = statedb.GetUnlockedBalance(addrs[i]), statedb.Error()
+			}
+		}()
+	}
+	for i := range addrs {
+		tasks <- i
+	}
+	close(tasks)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("balance of %x unavailable: %v", addrs[i], err)
+		}
+	}
+	return balances, nil
+}
+
+// GetCode returns the code of the account in the state of the block with the
+// given number, retrieving the account proof and the code through ODR. The code
+// is checked against the code hash of the proven account, and is empty for
+// accounts without code.
+func (b *LesApiBackend) GetCode(ctx context.Context, addr common.Address, blockNr rpc.BlockNumber) ([]byte, error) {
+	header, err := b.HeaderByNumber(ctx, blockNr)
+	if header == nil || err != nil {
+		return nil, err
+	}
+	statedb := light.NewState(ctx, header, b.abey.blockchain.Odr())
+	hash := statedb.GetCodeHash(addr)
+	if err := statedb.Error(); err != nil {
+		return nil, err
+	}
+	if cached, ok := b.cache.get(cachedCode, hash); ok {
+		return common.CopyBytes(cached.([]byte)), nil
+	}
+	code := statedb.GetCode(addr)
+	if err := statedb.Error(); err != nil {
+		return nil, err
+	}
+	if len(code) == 0 {
+		return []byte{}, nil
+	}
+	if crypto.Keccak256Hash(code) != hash {
+		return nil, fmt.Errorf("%w: code of %x", ErrInvalidCode, addr)
+	}
+	b.cache.add(cachedCode, hash, common.CopyBytes(code), uint64(len(code)))
+	return code, nil
+}
+
+// GetStorageAt returns the value of the storage slot of the account in the state
+// of the block with the given number, the zero hash if the slot is unset. The
+// account and the slot are taken from proofs retrieved through ODR, which are
+// checked against the state root of the header and the storage root of the
+// account regardless of the checks of the ODR backend.
+func (b *LesApiBackend) GetStorageAt(ctx context.Context, addr common.Address, key common.Hash, blockNr rpc.BlockNumber) (common.Hash, error) {
+	header, err := b.HeaderByNumber(ctx, blockNr)
+	if header == nil || err != nil {
+		return common.Hash{}, err
+	}
+	id := light.StateTrieID(header)
+	account, err := b.proveAccount(ctx, id, addr)
+	if err != nil || account == nil || account.Root == types.EmptyRootHash {
+		return common.Hash{}, err
+	}
+	enc, err := b.proveTrieValue(ctx, light.StorageTrieID(id, crypto.Keccak256Hash(addr[:]), account.Root), crypto.Keccak256(key[:]))
+	if err != nil || len(enc) == 0 {
+		return common.Hash{}, err
+	}
+	_, content, _, err := rlp.Split(enc)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("%w: %v", ErrInvalidStateProof, err)
+	}
+	return common.BytesToHash(content), nil
+}
+
+// proveAccount returns the account in the state trie identified by id, proven
+// through ODR, or nil if the proof shows it does not exist.
+func (b *LesApiBackend) proveAccount(ctx context.Context, id *light.TrieID, addr common.Address) (*state.Account, error) {
+	enc, err := b.proveTrieValue(ctx, id, crypto.Keccak256(addr[:]))
+	if err != nil || len(enc) == 0 {
+		return nil, err
+	}
+	account := new(state.Account)
+	if err := rlp.DecodeBytes(enc, account); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidStateProof, err)
+	}
+	return account, nil
+}
+
+// proveTrieValue retrieves a proof of the key in the trie identified by id
+// through ODR and returns the value it proves, empty if the key is absent.
+func (b *LesApiBackend) proveTrieValue(ctx context.Context, id *light.TrieID, key []byte) ([]byte, error) {
+	req := &light.TrieRequest{Id: id, Key: key}
+	if err := b.abey.blockchain.Odr().Retrieve(ctx, req); err != nil {
+		return nil, err
+	}
+	if req.Proof == nil {
+		return nil, fmt.Errorf("%w: no proof of %x", ErrInvalidStateProof, key)
+	}
+	enc, _, err := trie.VerifyProof(id.Root, key, req.Proof)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidStateProof, err)
+	}
+	return enc, nil
+}
+
+// GetProof returns the merkle proofs of the account and of the given storage
+// slots of it against the state root of the block with the given number, the
+// trie nodes on their paths being retrieved through ODR.
+func (b *LesApiBackend) GetProof(ctx context.Context, addr common.Address, storageKeys []common.Hash, blockNr rpc.BlockNumber) (*abeyapi.AccountResult, error) {
+	header, err := b.HeaderByNumber(ctx, blockNr)
+	if header == nil || err != nil {
+		return nil, err
+	}
+	return abeyapi.NewAccountResult(light.NewState(ctx, header, b.abey.blockchain.Odr()), addr, storageKeys)
+}
+
+// SetReadTimeout sets the time GetBlock and GetReceipts wait for an ODR retrieval
+// if the caller context carries no deadline, so a stalled peer cannot hang a
+// call indefinitely. A non-positive timeout restores the default.
+func (b *LesApiBackend) SetReadTimeout(timeout time.Duration) {
+	b.readTimeoutLock.Lock()
+	defer b.readTimeoutLock.Unlock()
+
+	if timeout <= 0 {
+		timeout = defaultReadTimeout
+	}
+	b.readTimeout = timeout
+}
+
+// SetCacheBudget sets the memory in bytes the headers, receipts and code
+// retrieved through ODR are cached in, evicting the least recently used objects
+// whatever their kind once their estimated size goes over it. Shrinking the
+// budget evicts right away. A zero budget restores the default.
+func (b *LesApiBackend) SetCacheBudget(budget uint64) {
+	if budget == 0 {
+		budget = defaultCacheBudget
+	}
+	b.cache.setBudget(budget)
+}
+
+// TrimCaches evicts the least recently used headers, receipts and code until
+// the cached ones take at most target bytes, returning the number of bytes
+// freed. It allows shedding memory on demand, such as when the system runs low,
+// without lowering the budget the caches grow back to.
+func (b *LesApiBackend) TrimCaches(target uint64) uint64 {
+	return b.cache.trim(target)
+}
+
+// SetMaxHeadAge sets the maximum age of the light chain head served to reads of
+// the latest block, by the timestamp of the head. Older heads fail such reads
+// with a StaleHeadError, while reads of explicit heights are served regardless.
+// A non-positive age disables the check, the default.
+func (b *LesApiBackend) SetMaxHeadAge(age time.Duration) {
+	b.headAgeLock.Lock()
+	defer b.headAgeLock.Unlock()
+
+	if age < 0 {
+		age = 0
+	}
+	b.maxHeadAge = age
+}
+
+// checkHeadAge returns a StaleHeadError if head is older than the configured
+// maximum age.
+func (b *LesApiBackend) checkHeadAge(head *types.Header) error {
+	b.headAgeLock.RLock()
+	maxAge, now := b.maxHeadAge, b.now
+	b.headAgeLock.RUnlock()
+
+	if maxAge == 0 {
+		return nil
+	}
+	age := now().Sub(time.Unix(head.Time.Int64(), 0))
+	if age <= maxAge {
+		return nil
+	}
+	return &StaleHeadError{Number: head.Number.Uint64(), Age: age, MaxAge: maxAge}
+}
+
+// withReadTimeout derives a context bounded by the configured read timeout from
+// ctx, unless ctx carries a deadline already, which then takes precedence.
+func (b *LesApiBackend) withReadTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	b.readTimeoutLock.RLock()
+	timeout := b.readTimeout
+	b.readTimeoutLock.RUnlock()
+
+	return context.WithTimeout(ctx, timeout)
+}
+
 func (b *LesApiBackend) GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error) {
+	ctx, cancel := b.withReadTimeout(ctx)
+	defer cancel()
+
 	return b.abey.blockchain.GetBlockByHash(ctx, blockHash)
 }
 
+// GetReceipts returns the receipts of the given block, retrieving them through
+// ODR if they are not cached yet. The caller gets its own copy of the receipts,
+// free to modify them without corrupting the cached ones.
 func (b *LesApiBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	if cached, ok := b.cache.get(cachedReceipts, hash); ok {
+		return copyReceipts(cached.(types.Receipts)), nil
+	}
 	if number := rawdb.ReadHeaderNumber(b.abey.chainDb, hash); number != nil {
-		return light.GetBlockReceipts(ctx, b.abey.odr, hash, *number)
+		ctx, cancel := b.withReadTimeout(ctx)
+		defer cancel()
+
+		receipts, err := light.GetBlockReceipts(ctx, b.abey.blockchain.Odr(), hash, *number)
+		if err != nil {
+			return nil, err
+		}
+		var size common.StorageSize
+		for _, receipt := range receipts {
+			size += receipt.Size()
+		}
+		b.cache.add(cachedReceipts, hash, copyReceipts(receipts), uint64(size))
+		return receipts, nil
 	}
 	return nil, nil
 }
 
+// copyReceipts returns a deep copy of the given receipts and their logs.
+func copyReceipts(receipts types.Receipts) types.Receipts {
+	cpy := make(types.Receipts, len(receipts))
+	for i, receipt := range receipts {
+		r := *receipt
+		r.PostState = common.CopyBytes(receipt.PostState)
+		if receipt.FeePaid != nil {
+			r.FeePaid = new(big.Int).Set(receipt.FeePaid)
+		}
+		if receipt.BlockNumber != nil {
+			r.BlockNumber = new(big.Int).Set(receipt.BlockNumber)
+		}
+		if receipt.Logs != nil {
+			r.Logs = make([]*types.Log, len(receipt.Logs))
+			for j, entry := range receipt.Logs {
+				l := *entry
+				l.Topics = append([]common.Hash(nil), entry.Topics...)
+				l.Data = common.CopyBytes(entry.Data)
+				r.Logs[j] = &l
+			}
+		}
+		cpy[i] = &r
+	}
+	return cpy
+}
+
+// GetReceiptsMulti retrieves the receipts of all the given blocks, fetching them
+// through ODR concurrently by a bounded set of workers. The returned slice matches
+// the order of the input, with nil receipts for unknown blocks as GetReceipts. If
+// some retrievals fail the partial result is returned together with a
+// ReceiptsBatchError describing the failure of each position.
+func (b *LesApiBackend) GetReceiptsMulti(ctx context.Context, hashes []common.Hash) ([]types.Receipts, error) {
+	var (
+		receipts = make([]types.Receipts, len(hashes))
+		errs     = make(ReceiptsBatchError, len(hashes))
+		wg       sync.WaitGroup
+		tasks    = make(chan int)
+	)
+	workers := receiptsBatchWorkers
+	if len(hashes) < workers {
+		workers = len(hashes)
+	}
+	for n := 0; n < workers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range tasks {
+				receipts[i], errs[i] = b.GetReceipts(ctx, hashes[i])
+			}
+		}()
+	}
+	for i := range hashes {
+		tasks <- i
+	}
+	close(tasks)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return receipts, errs
+		}
+	}
+	return receipts, nil
+}
+
+// GetReceiptsWithProof returns the receipts of the given block together with
+// the trie nodes proving them against the receipt root of its header, allowing
+// callers to verify each receipt independently.
+func (b *LesApiBackend) GetReceiptsWithProof(ctx context.Context, hash common.Hash) (types.Receipts, light.NodeList, error) {
+	if number := rawdb.ReadHeaderNumber(b.abey.chainDb, hash); number != nil {
+		return light.GetBlockReceiptsWithProof(ctx, b.abey.odr, hash, *number)
+	}
+	return nil, nil, nil
+}
+
 func (b *LesApiBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*types.Log, error) {
 	if number := rawdb.ReadHeaderNumber(b.abey.chainDb, hash); number != nil {
-		return light.GetBlockLogs(ctx, b.abey.odr, hash, *number)
+		return light.GetBlockLogs(ctx, b.abey.blockchain.Odr(), hash, *number)
 	}
 	return nil, nil
 }
 
+// GetLogsInRange returns the logs of the blocks between from and to matching the
+// given addresses and topics. Candidate blocks are prefiltered through the bloom
+// bits index where available and the header blooms beyond it, so only the logs of
+// blocks which may contain a match are retrieved through ODR. A query matching
+// more logs than the configured limit fails with a filters.LimitExceededError.
+func (b *LesApiBackend) GetLogsInRange(ctx context.Context, from, to rpc.BlockNumber, addresses []common.Address, topics [][]common.Hash) ([]*types.Log, error) {
+	begin, end := from.Int64(), to.Int64()
+	if from == rpc.PendingBlockNumber {
+		begin = rpc.LatestBlockNumber.Int64()
+	}
+	if to == rpc.PendingBlockNumber {
+		end = rpc.LatestBlockNumber.Int64()
+	}
+	b.filterLock.RLock()
+	limit := b.logsLimit
+	b.filterLock.RUnlock()
+
+	filter := filters.NewRangeFilter(b, begin, end, addresses, topics)
+	filter.SetLimit(limit)
+	return filter.Logs(ctx)
+}
+
+// SetLogsLimit sets the maximum number of logs GetLogsInRange returns. A
+// non-positive limit restores the default.
+func (b *LesApiBackend) SetLogsLimit(limit int) {
+	b.filterLock.Lock()
+	defer b.filterLock.Unlock()
+
+	if limit <= 0 {
+		limit = filters.DefaultLogsLimit
+	}
+	b.logsLimit = limit
+}
+
+// GetAddressTransactions returns the transactions sent from or to addr between
+// the blocks from and to, at most filters.MaxHistoryRange blocks apart. See
+// filters.AddressTransactions for the transactions it cannot find.
+func (b *LesApiBackend) GetAddressTransactions(ctx context.Context, addr common.Address, from, to rpc.BlockNumber) ([]*abeyapi.AddressTransaction, error) {
+	begin, end := from.Int64(), to.Int64()
+	if from == rpc.PendingBlockNumber {
+		begin = rpc.LatestBlockNumber.Int64()
+	}
+	if to == rpc.PendingBlockNumber {
+		end = rpc.LatestBlockNumber.Int64()
+	}
+	return filters.AddressTransactions(ctx, b, addr, begin, end)
+}
+
+// GetTd returns the total difficulty of the block with the given hash. The block
+// number is resolved through the header chain, as peers serve headers by number
+// only, then the total difficulty is retrieved on demand through ODR if it is not
+// stored locally. Nil is returned if the hash is unknown or cannot be retrieved.
 func (b *LesApiBackend) GetTd(hash common.Hash) *big.Int {
-	return big.NewInt(0)
-	//return b.abey.blockchain.GetTdByHash(hash)
+	header := b.abey.blockchain.GetHeaderByHash(hash)
+	if header == nil {
+		return nil
+	}
+	number := header.Number.Uint64()
+
+	ctx, cancel := context.WithTimeout(context.Background(), tdRetrievalTimeout)
+	defer cancel()
+
+	td, err := light.GetTd(ctx, b.abey.blockchain.Odr(), hash, number)
+	if err != nil {
+		log.Debug("Failed to retrieve total difficulty", "hash", hash, "number", number, "err", err)
+		return nil
+	}
+	return td
 }
 
 func (b *LesApiBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmCfg vm.Config) (*vm.EVM, func() error, error) {
@@ -178,14 +1211,131 @@ func (b *LesApiBackend) GetEVM(ctx context.Context, msg core.Message, state *sta
 	return vm.NewEVM(context, state, b.abey.chainConfig, vmCfg), state.Error, nil
 }
 
+func (b *LesApiBackend) SimulateBundle(ctx context.Context, txs []*types.Transaction, blockNr rpc.BlockNumber, stopOnRevert bool) ([]core.BundleTxResult, error) {
+	return nil, notSupported("SimulateBundle")
+}
+
+func (b *LesApiBackend) SimulateTransaction(ctx context.Context, tx *types.Transaction, blockNr rpc.BlockNumber) (map[common.Address]*big.Int, error) {
+	return nil, notSupported("SimulateTransaction")
+}
+
+func (b *LesApiBackend) CreateAccessListFixedPoint(ctx context.Context, tx *types.Transaction, blockNr rpc.BlockNumber) (types.AccessList, uint64, error) {
+	return nil, 0, notSupported("CreateAccessListFixedPoint")
+}
+
+func (b *LesApiBackend) ResolveName(ctx context.Context, registry common.Address, node common.Hash, blockNr rpc.BlockNumber) (common.Address, error) {
+	return common.Address{}, notSupported("ResolveName")
+}
+
+func (b *LesApiBackend) ClassifyTransaction(tx *types.Transaction) (*types.TxClassification, error) {
+	return types.ClassifyTransaction(types.MakeSigner(b.ChainConfig(), b.CurrentBlock().Number()), tx)
+}
+
+func (b *LesApiBackend) GetIssuance(ctx context.Context, blockNr rpc.BlockNumber) (*big.Int, error) {
+	return nil, notSupported("GetIssuance")
+}
+
+// GetRewardBreakdown returns the fees paid out by the fast block with the given
+// number, its body and receipts being retrieved through ODR if needed. It is a
+// limited view: the reward payouts and issuance are not served to light clients,
+// so the rewards of the breakdown are left unknown.
+func (b *LesApiBackend) GetRewardBreakdown(ctx context.Context, blockNr rpc.BlockNumber) (*abeyapi.RewardBreakdown, error) {
+	block, err := b.BlockByNumber(ctx, blockNr)
+	if block == nil || err != nil {
+		return nil, err
+	}
+	receipts, err := b.GetReceipts(ctx, block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	return abeyapi.NewRewardBreakdown(block, receipts, nil, nil)
+}
+
+// SendTx adds a transaction to the pool to be relayed to full peers. Transactions
+// priced below the minimum gas price are rejected without being relayed.
 func (b *LesApiBackend) SendTx(ctx context.Context, signedTx *types.Transaction) error {
-	return b.abey.txPool.Add(ctx, signedTx)
+	if err := b.checkGasPriceFloor(ctx, signedTx); err != nil {
+		return err
+	}
+	return core.ClassifyTxError(b.abey.txPool.Add(ctx, signedTx))
+}
+
+// ValidateTransaction checks whether SendTx would accept the transaction into
+// the pool, including the gas price floor, without submitting or relaying it.
+func (b *LesApiBackend) ValidateTransaction(ctx context.Context, tx *types.Transaction) error {
+	if err := b.checkGasPriceFloor(ctx, tx); err != nil {
+		return err
+	}
+	return core.ClassifyTxError(b.abey.txPool.ValidateTransaction(ctx, tx))
+}
+
+// checkGasPriceFloor rejects transactions priced below the gas price floor as
+// underpriced.
+func (b *LesApiBackend) checkGasPriceFloor(ctx context.Context, tx *types.Transaction) error {
+	if floor := b.gasPriceFloor(ctx); floor != nil && tx.GasPrice().Cmp(floor) < 0 {
+		return &core.TxRejectedError{
+			Reason: core.TxRejectUnderpriced,
+			Err:    fmt.Errorf("%w: have %v, want %v", ErrGasPriceTooLow, tx.GasPrice(), floor),
+		}
+	}
+	return nil
+}
+
+// ReplaceTransaction replaces the pending transaction with the given hash by a
+// re-signed copy paying the new gas price. The light pool tracks transactions
+// by hash only, so the replaced one is dropped from it once the replacement
+// has been sent.
+func (b *LesApiBackend) ReplaceTransaction(ctx context.Context, oldHash common.Hash, newGasPrice *big.Int) (common.Hash, error) {
+	hash, err := abeyapi.ReplaceTransaction(ctx, b, oldHash, newGasPrice, core.DefaultTxPoolConfig.PriceBump)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	b.abey.txPool.RemoveTx(oldHash)
+	return hash, nil
+}
+
+// SetMinGasPrice sets the minimum gas price of transactions sent through the
+// backend. A nil price derives the minimum from the gas price oracle again.
+func (b *LesApiBackend) SetMinGasPrice(price *big.Int) {
+	b.minGasPriceLock.Lock()
+	defer b.minGasPriceLock.Unlock()
+
+	if price != nil {
+		price = new(big.Int).Set(price)
+	}
+	b.minGasPrice = price
+}
+
+// gasPriceFloor returns the minimum gas price of sent transactions, which is
+// gasPriceFloorPercent of the suggested price unless set explicitly. No floor
+// is enforced if the oracle fails to suggest a price.
+func (b *LesApiBackend) gasPriceFloor(ctx context.Context) *big.Int {
+	b.minGasPriceLock.RLock()
+	floor := b.minGasPrice
+	b.minGasPriceLock.RUnlock()
+
+	if floor != nil || b.gpo == nil {
+		return floor
+	}
+	price, err := b.gpo.SuggestPrice(ctx)
+	if err != nil {
+		log.Debug("Failed to suggest gas price floor", "err", err)
+		return nil
+	}
+	floor = new(big.Int).Mul(price, big.NewInt(gasPriceFloorPercent))
+	return floor.Div(floor, big.NewInt(100))
 }
 
 func (b *LesApiBackend) RemoveTx(txHash common.Hash) {
 	b.abey.txPool.RemoveTx(txHash)
 }
 
+// RemoveTxs removes a batch of transactions from the pool at once, returning the
+// number of transactions actually removed.
+func (b *LesApiBackend) RemoveTxs(txHashes []common.Hash) int {
+	return b.abey.txPool.RemoveTxs(txHashes)
+}
+
 func (b *LesApiBackend) GetPoolTransactions() (types.Transactions, error) {
 	return b.abey.txPool.GetTransactions()
 }
@@ -194,10 +1344,115 @@ func (b *LesApiBackend) GetPoolTransaction(txHash common.Hash) *types.Transactio
 	return b.abey.txPool.GetTransaction(txHash)
 }
 
+// GetRawTransaction returns the RLP encoding of the transaction with the given
+// hash, nil if it is unknown. Mined transactions are looked up locally or through
+// ODR, pending ones are served from the local pool.
+func (b *LesApiBackend) GetRawTransaction(ctx context.Context, txHash common.Hash) ([]byte, error) {
+	tx, _, _, _, err := light.GetTransaction(ctx, b.abey.blockchain.Odr(), txHash)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil && b.abey.txPool != nil {
+		tx = b.abey.txPool.GetTransaction(txHash)
+	}
+	if tx == nil {
+		return nil, nil
+	}
+	return rlp.EncodeToBytes(tx)
+}
+
+// GetTransactionWithConfirmations locates the transaction with the given hash on
+// the canonical chain, through ODR if it is not available locally, and counts its
+// confirmations. Transactions whose block is not canonical are reported as
+// pending if they are in the local pool, and as unknown with a nil result
+// otherwise.
+func (b *LesApiBackend) GetTransactionWithConfirmations(ctx context.Context, txHash common.Hash) (*abeyapi.TxConfirmations, error) {
+	tx, blockHash, number, index, err := light.GetTransaction(ctx, b.abey.blockchain.Odr(), txHash)
+	if err != nil {
+		return nil, err
+	}
+	if tx != nil {
+		if header := b.abey.blockchain.GetHeaderByNumber(number); header != nil && header.Hash() == blockHash {
+			return abeyapi.NewTxConfirmations(b, tx, blockHash, number, index), nil
+		}
+	}
+	if b.abey.txPool != nil {
+		if tx := b.abey.txPool.GetTransaction(txHash); tx != nil {
+			return &abeyapi.TxConfirmations{Tx: tx, Pending: true}, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetRawReceipt returns the receipt of the mined transaction with the given hash
+// in the RLP storage encoding of the full node, retrieved through ODR if it is
+// not available locally, nil if the transaction is unknown.
+func (b *LesApiBackend) GetRawReceipt(ctx context.Context, txHash common.Hash) ([]byte, error) {
+	receipt, _, _, _, err := light.GetTransactionReceipt(ctx, b.abey.blockchain.Odr(), txHash)
+	if receipt == nil || err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes((*types.ReceiptForStorage)(receipt))
+}
+
+// EffectiveGasPrice returns the price per gas the mined transaction with the
+// given hash paid including its fee, retrieving the transaction and its receipt
+// through ODR if they are not available locally, nil if it is unknown. See
+// abeyapi.EffectiveGasPrice.
+func (b *LesApiBackend) EffectiveGasPrice(ctx context.Context, txHash common.Hash) (*big.Int, error) {
+	tx, _, _, _, err := light.GetTransaction(ctx, b.abey.blockchain.Odr(), txHash)
+	if tx == nil || err != nil {
+		return nil, err
+	}
+	receipt, _, _, _, err := light.GetTransactionReceipt(ctx, b.abey.blockchain.Odr(), txHash)
+	if receipt == nil || err != nil {
+		return nil, err
+	}
+	return abeyapi.EffectiveGasPrice(tx, receipt), nil
+}
+
 func (b *LesApiBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
 	return b.abey.txPool.GetNonce(ctx, addr)
 }
 
+// NextNonceAndReserve returns the next nonce of the account not handed out to
+// another sender yet and reserves it, see abeyapi.NonceReserver.
+func (b *LesApiBackend) NextNonceAndReserve(ctx context.Context, addr common.Address) (uint64, error) {
+	return b.nonces.Reserve(addr, func() (uint64, error) {
+		return b.GetPoolNonce(ctx, addr)
+	})
+}
+
+// GetVerifiedNonce returns the nonce of the account in the state of the latest
+// header along with the pending nonce of the transaction pool, so that callers
+// can tell a pool nonce running behind the chain. The on-chain nonce is taken
+// from an account proof retrieved through ODR, which is checked against the
+// state root of the header regardless of the checks of the ODR backend.
+func (b *LesApiBackend) GetVerifiedNonce(ctx context.Context, addr common.Address) (nonce, poolNonce uint64, err error) {
+	ctx, cancel := b.withReadTimeout(ctx)
+	defer cancel()
+
+	header := b.abey.blockchain.CurrentHeader()
+	account, err := b.proveAccount(ctx, light.StateTrieID(header), addr)
+	if err != nil {
+		return 0, 0, err
+	}
+	if account != nil {
+		nonce = account.Nonce
+	}
+	poolNonce, err = b.GetPoolNonce(ctx, addr)
+	if err != nil {
+		return 0, 0, err
+	}
+	return nonce, poolNonce, nil
+}
+
+// PendingNonceGap returns the nonces [gapStart, gapEnd) missing in the pool
+// before the pending transactions of the account above them can execute.
+func (b *LesApiBackend) PendingNonceGap(ctx context.Context, addr common.Address) (gapStart, gapEnd uint64, hasGap bool, err error) {
+	return b.abey.txPool.NonceGap(ctx, addr)
+}
+
 func (b *LesApiBackend) Stats() (pending int, queued int) {
 	return b.abey.txPool.Stats(), 0
 }
@@ -206,6 +1461,54 @@ func (b *LesApiBackend) TxPoolContent() (map[common.Address]types.Transactions,
 	return b.abey.txPool.Content()
 }
 
+// TxPoolContentSorted returns the content of the transaction pool ordered by
+// account address and nonce, so that equal pool states yield equal output.
+func (b *LesApiBackend) TxPoolContentSorted() (pending, queued []abeyapi.AccountTxs) {
+	return abeyapi.SortTxPoolContent(b.TxPoolContent())
+}
+
+// PendingTransactionsOf returns the pending transactions of the account sorted by
+// nonce. The light pool queues no transactions, so queued makes no difference.
+func (b *LesApiBackend) PendingTransactionsOf(addr common.Address, queued bool) (types.Transactions, error) {
+	return b.abey.txPool.ContentFrom(addr), nil
+}
+
+// TxPoolFeeHistogram returns the number of pending transactions of the pool per
+// gas price range, see abeyapi.FeeHistogram.
+func (b *LesApiBackend) TxPoolFeeHistogram() []abeyapi.FeeBucket {
+	return abeyapi.FeeHistogram(b.abey.txPool.PendingPriceHistogram)
+}
+
+// ExportTxPool serializes the pending and queued transactions of the pool, so
+// that they can be restored with ImportTxPool after a restart.
+func (b *LesApiBackend) ExportTxPool() ([]byte, error) {
+	return abeyapi.ExportTxPool(b)
+}
+
+// ImportTxPool re-injects the transactions of a pool export, returning the
+// number of them skipped as no longer valid.
+func (b *LesApiBackend) ImportTxPool(data []byte) (int, error) {
+	return abeyapi.ImportTxPool(context.Background(), b, data)
+}
+
+// EstimateInclusion heuristically estimates the number of blocks until a
+// transaction paying gasPrice gets included, see abeyapi.EstimateInclusion.
+func (b *LesApiBackend) EstimateInclusion(ctx context.Context, gasPrice *big.Int) (uint64, error) {
+	return abeyapi.EstimateInclusion(ctx, b, gasPrice)
+}
+
+// TxPoolOrdered returns the pending transactions of the light pool in the price
+// and nonce sorted order a miner would execute them, grouped into runs of
+// consecutive transactions sent by the same account.
+func (b *LesApiBackend) TxPoolOrdered() [][]*types.Transaction {
+	pending, _ := b.abey.txPool.Content()
+	for _, txs := range pending {
+		sort.Sort(types.TxByNonce(txs))
+	}
+	signer := types.NewTIP1Signer(b.abey.chainConfig.ChainID)
+	return types.NewTransactionsByPriceAndNonce(signer, pending).Runs()
+}
+
 func (b *LesApiBackend) SubscribeNewTxsEvent(ch chan<- types.NewTxsEvent) event.Subscription {
 	return b.abey.txPool.SubscribeNewTxsEvent(ch)
 }
@@ -222,6 +1525,35 @@ func (b *LesApiBackend) SubscribeChainSideEvent(ch chan<- types.FastChainSideEve
 	return b.abey.blockchain.SubscribeChainSideEvent(ch)
 }
 
+// SubscribeReorgEvent returns a subscription that never delivers any event, as
+// the light chain does not track the blocks its header reorganisations drop. The
+// subscription only ends once unsubscribed.
+func (b *LesApiBackend) SubscribeReorgEvent(ch chan<- types.ReorgEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
+// SubscribeNewSnailBlockEvent returns a subscription that never delivers any
+// event, as light clients do not follow the snail chain. The subscription only
+// ends once unsubscribed.
+func (b *LesApiBackend) SubscribeNewSnailBlockEvent(ch chan<- types.SnailChainEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
+// SubscribeFilteredLogs returns a subscription failing right away with an
+// ErrNotSupportedOnLes error, as the light chain neither processes blocks nor
+// announces their logs, added or removed.
+func (b *LesApiBackend) SubscribeFilteredLogs(ch chan<- types.FilteredLogEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		return notSupported("SubscribeFilteredLogs")
+	})
+}
+
 func (b *LesApiBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription {
 	return b.abey.blockchain.SubscribeLogsEvent(ch)
 }
@@ -234,6 +1566,17 @@ func (b *LesApiBackend) FastDownloader() *fastdownloader.Downloader {
 	return b.abey.Downloader()
 }
 
+// SyncProgress returns the synchronisation progress of the fast chain headers.
+// Light clients do not sync the snail chain, its progress is left zero.
+func (b *LesApiBackend) SyncProgress() abeychain.SyncProgress {
+	fast := b.abey.Downloader().Progress()
+	return abeychain.SyncProgress{
+		StartingFastBlock: fast.StartingFastBlock,
+		CurrentFastBlock:  fast.CurrentFastBlock,
+		HighestFastBlock:  fast.HighestFastBlock,
+	}
+}
+
 func (b *LesApiBackend) ProtocolVersion() int {
 	return b.abey.LesVersion() + 10000
 }
@@ -242,6 +1585,30 @@ func (b *LesApiBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	return b.gpo.SuggestPrice(ctx)
 }
 
+// SetGasPriceBlocks changes the number of recent blocks the gas price oracle
+// samples, without a restart. It fails if blocks is not positive.
+func (b *LesApiBackend) SetGasPriceBlocks(blocks int) error {
+	return b.gpo.SetBlocks(blocks)
+}
+
+// SetGasPricePercentile changes the percentile of the sampled prices the gas
+// price oracle suggests, without a restart. It fails outside of 0 to 100.
+func (b *LesApiBackend) SetGasPricePercentile(percent int) error {
+	return b.gpo.SetPercentile(percent)
+}
+
+// HeaderFeeInfo returns the gas usage and the base fee proxy of a block, with
+// the sampled blocks retrieved through ODR.
+func (b *LesApiBackend) HeaderFeeInfo(ctx context.Context, blockNr rpc.BlockNumber) (*gasprice.HeaderFeeInfo, error) {
+	return b.gpo.HeaderFeeInfo(ctx, blockNr)
+}
+
+// SuggestPriceRange returns low, medium and high gas price recommendations.
+// With too few recently retrieved blocks all three tiers are the same.
+func (b *LesApiBackend) SuggestPriceRange(ctx context.Context) (low, med, high *big.Int, err error) {
+	return b.gpo.SuggestPriceRange(ctx)
+}
+
 func (b *LesApiBackend) ChainDb() abeydb.Database {
 	return b.abey.chainDb
 }
@@ -262,8 +1629,56 @@ func (b *LesApiBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocksClient, sections
 }
 
+// BloomIndexProgress returns the number of leading blocks covered by the bloom
+// index and the number of the light chain head, along with whether the index
+// caught up with the head. Without a bloom indexer the index is never ready.
+func (b *LesApiBackend) BloomIndexProgress() (indexed, head uint64, ready bool) {
+	head = b.abey.blockchain.CurrentHeader().Number.Uint64()
+	if b.abey.bloomIndexer == nil {
+		return 0, head, false
+	}
+	indexed, ready = b.abey.bloomIndexer.Progress(head)
+	return indexed, head, ready
+}
+
+// bloomMultiplexer multiplexes the bloom bit retrievals of a filter onto the
+// global servicing goroutines, implemented by bloombits.MatcherSession.
+type bloomMultiplexer interface {
+	Multiplex(batch int, wait time.Duration, mux chan chan *bloombits.Retrieval)
+}
+
+// SetBloomFilterParams sets the number of goroutines multiplexing the bloom bit
+// retrievals of each filter, together with the size of the retrieval batches
+// and the maximum time waited for them to fill. Non-positive values restore the
+// defaults.
+func (b *LesApiBackend) SetBloomFilterParams(threads, batch int, wait time.Duration) {
+	b.filterLock.Lock()
+	defer b.filterLock.Unlock()
+
+	if threads <= 0 {
+		threads = bloomFilterThreads
+	}
+	if batch <= 0 {
+		batch = bloomRetrievalBatch
+	}
+	if wait <= 0 {
+		wait = bloomRetrievalWait
+	}
+	b.filterThreads, b.retrievalBatch, b.retrievalWait = threads, batch, wait
+}
+
 func (b *LesApiBackend) ServiceFilter(ctx context.Context, session *bloombits.MatcherSession) {
-	for i := 0; i < bloomFilterThreads; i++ {
-		go session.Multiplex(bloomRetrievalBatch, bloomRetrievalWait, b.abey.bloomRequests)
+	b.serviceFilter(session)
+}
+
+// serviceFilter starts the configured number of goroutines multiplexing the
+// retrievals of the session.
+func (b *LesApiBackend) serviceFilter(session bloomMultiplexer) {
+	b.filterLock.RLock()
+	threads, batch, wait := b.filterThreads, b.retrievalBatch, b.retrievalWait
+	b.filterLock.RUnlock()
+
+	for i := 0; i < threads; i++ {
+		go session.Multiplex(batch, wait, b.abey.bloomRequests)
 	}
 }
@@ -0,0 +1,1843 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AbeyFoundation/go-abey/abey/filters"
+	"github.com/AbeyFoundation/go-abey/abeydb"
+	"github.com/AbeyFoundation/go-abey/common"
+	"github.com/AbeyFoundation/go-abey/common/hexutil"
+	"github.com/AbeyFoundation/go-abey/consensus/minerva"
+	"github.com/AbeyFoundation/go-abey/core"
+	"github.com/AbeyFoundation/go-abey/core/bloombits"
+	"github.com/AbeyFoundation/go-abey/core/rawdb"
+	snaildb "github.com/AbeyFoundation/go-abey/core/snailchain/rawdb"
+	"github.com/AbeyFoundation/go-abey/core/state"
+	"github.com/AbeyFoundation/go-abey/core/types"
+	"github.com/AbeyFoundation/go-abey/core/vm"
+	"github.com/AbeyFoundation/go-abey/crypto"
+	"github.com/AbeyFoundation/go-abey/light"
+	"github.com/AbeyFoundation/go-abey/params"
+	"github.com/AbeyFoundation/go-abey/rlp"
+	"github.com/AbeyFoundation/go-abey/rpc"
+	"github.com/AbeyFoundation/go-abey/trie"
+)
+
+// newTestApiBackend creates a light API backend on top of the given database
+// initialised with the les genesis block. The light chain retrieves data through
+// the given ODR backend, or through a disconnected les one if nil, in which case
+// all ODR retrievals fail with no peers.
+func newTestApiBackend(db abeydb.Database, chainOdr light.OdrBackend) *LesApiBackend {
+	config, _, err := core.SetupGenesisBlockForLes(db)
+	if err != nil {
+		panic(err)
+	}
+	odr := NewLesOdr(db, light.DefaultClientIndexerConfig, nil)
+	if chainOdr == nil {
+		chainOdr = odr
+	}
+	engine := minerva.NewFaker()
+	blockchain, err := light.NewLightChain(chainOdr, config, engine, nil)
+	if err != nil {
+		panic(err)
+	}
+	labey := &LightAbey{
+		lesCommons:   lesCommons{chainDb: db, iConfig: light.DefaultClientIndexerConfig},
+		chainConfig:  config,
+		odr:          odr,
+		blockchain:   blockchain,
+		engine:       engine,
+		shutdownChan: make(chan bool),
+	}
+	labey.election = NewLightElection(blockchain)
+	return newLesApiBackend(labey, 0)
+}
+
+// writeTestHeaders stores a canonical chain of n headers with increasing total
+// difficulty on top of the given parent into the database.
+func writeTestHeaders(db abeydb.Database, parent *types.Header, n int) []*types.Header {
+	var (
+		headers []*types.Header
+		td      = new(big.Int)
+	)
+	for i := 0; i < n; i++ {
+		header := &types.Header{
+			ParentHash:  parent.Hash(),
+			Number:      new(big.Int).Add(parent.Number, common.Big1),
+			SnailNumber: new(big.Int),
+			Time:        new(big.Int).Add(parent.Time, big.NewInt(10)),
+			GasLimit:    8000000,
+			Extra:       []byte{},
+		}
+		td.Add(td, big.NewInt(int64(i+1)))
+		rawdb.WriteHeader(db, header)
+		rawdb.WriteTd(db, header.Hash(), header.Number.Uint64(), td)
+		rawdb.WriteCanonicalHash(db, header.Hash(), header.Number.Uint64())
+
+		headers = append(headers, header)
+		parent = header
+	}
+	return headers
+}
+
+func TestLesApiBackendGetTd(t *testing.T) {
+	db := abeydb.NewMemDatabase()
+	backend := newTestApiBackend(db, nil)
+	headers := writeTestHeaders(db, backend.CurrentBlock().Header(), 8)
+
+	var prev *big.Int
+	for _, header := range headers {
+		td := backend.GetTd(header.Hash())
+		if td == nil || td.Sign() <= 0 {
+			t.Fatalf("block %d: invalid total difficulty: %v", header.Number, td)
+		}
+		if prev != nil && td.Cmp(prev) <= 0 {
+			t.Fatalf("block %d: total difficulty not increasing: have %v, prev %v", header.Number, td, prev)
+		}
+		prev = td
+	}
+	if td := backend.GetTd(common.HexToHash("0xdeadbeef")); td != nil {
+		t.Fatalf("unknown hash: have total difficulty %v, want nil", td)
+	}
+}
+
+func TestLesApiBackendGetTdOdr(t *testing.T) {
+	// Store a header without its total difficulty, which peers serve instead
+	var (
+		db     = abeydb.NewMemDatabase()
+		header = &types.Header{Number: big.NewInt(100), SnailNumber: new(big.Int), Time: big.NewInt(1000), Extra: []byte{}}
+		odr    = newCountingOdr(db, []*types.Header{header})
+	)
+	defer odr.cht.Close()
+
+	backend := newTestApiBackend(db, odr)
+	defer close(backend.abey.shutdownChan)
+	rawdb.WriteHeader(db, header)
+
+	if td := backend.GetTd(header.Hash()); td == nil || td.Cmp(big.NewInt(1)) != 0 {
+		t.Fatalf("retrieved total difficulty mismatch: have %v, want 1", td)
+	}
+	if n := atomic.LoadInt32(&odr.retrievals); n != 1 {
+		t.Fatalf("retrieval count mismatch: have %d, want 1", n)
+	}
+	// Hashes unknown to the header chain can't be looked up on the network
+	if td := backend.GetTd(common.HexToHash("0xdeadbeef")); td != nil {
+		t.Fatalf("unknown hash: have total difficulty %v, want nil", td)
+	}
+	if n := atomic.LoadInt32(&odr.retrievals); n != 1 {
+		t.Fatalf("unknown hash retrieved: have %d retrievals, want 1", n)
+	}
+}
+
+func TestLesApiBackendHeaderByNumbers(t *testing.T) {
+	db := abeydb.NewMemDatabase()
+	backend := newTestApiBackend(db, nil)
+	headers := writeTestHeaders(db, backend.CurrentBlock().Header(), 8)
+
+	// Request the headers in reverse order together with an unknown one
+	var numbers []rpc.BlockNumber
+	for i := len(headers) - 1; i >= 0; i-- {
+		numbers = append(numbers, rpc.BlockNumber(headers[i].Number.Int64()))
+	}
+	unknown := headers[len(headers)-1].Number.Int64() + 1
+	numbers = append(numbers, rpc.BlockNumber(unknown))
+
+	result, err := backend.HeaderByNumbers(context.Background(), numbers)
+	errs, ok := err.(HeaderBatchError)
+	if !ok {
+		t.Fatalf("error type mismatch: have %T, want HeaderBatchError", err)
+	}
+	for i, header := range headers {
+		idx := len(headers) - 1 - i
+		if result[idx] == nil || result[idx].Hash() != header.Hash() {
+			t.Errorf("position %d: header mismatch", idx)
+		}
+		if errs[idx] != nil {
+			t.Errorf("position %d: unexpected error: %v", idx, errs[idx])
+		}
+	}
+	if result[len(headers)] != nil || errs[len(headers)] == nil {
+		t.Errorf("unknown header: have %v, error %v", result[len(headers)], errs[len(headers)])
+	}
+}
+
+func benchmarkHeaderRange(b *testing.B) (*LesApiBackend, []rpc.BlockNumber) {
+	db := abeydb.NewMemDatabase()
+	backend := newTestApiBackend(db, nil)
+	headers := writeTestHeaders(db, backend.CurrentBlock().Header(), 100)
+
+	numbers := make([]rpc.BlockNumber, len(headers))
+	for i, header := range headers {
+		numbers[i] = rpc.BlockNumber(header.Number.Int64())
+	}
+	return backend, numbers
+}
+
+func BenchmarkHeaderByNumberSequential(b *testing.B) {
+	backend, numbers := benchmarkHeaderRange(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, number := range numbers {
+			if _, err := backend.HeaderByNumber(context.Background(), number); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkHeaderByNumbersBatched(b *testing.B) {
+	backend, numbers := benchmarkHeaderRange(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := backend.HeaderByNumbers(context.Background(), numbers); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func newTestSnailHeader(number int64) *types.SnailHeader {
+	return &types.SnailHeader{
+		PointerNumber:   new(big.Int),
+		FastNumber:      new(big.Int),
+		Difficulty:      params.MainnetChainConfig.Minerva.MinimumDifficulty,
+		FruitDifficulty: big.NewInt(1),
+		Number:          big.NewInt(number),
+		Time:            big.NewInt(number * 600),
+		Extra:           []byte{},
+	}
+}
+
+// writeTestRewards extends the light chain with a fast header rewarding each of
+// the given snail headers, recording the rewards as the header chain does while
+// syncing.
+func writeTestRewards(db abeydb.Database, parent *types.Header, snails []*types.SnailHeader) {
+	for _, snail := range snails {
+		header := &types.Header{
+			ParentHash:  parent.Hash(),
+			Number:      new(big.Int).Add(parent.Number, common.Big1),
+			SnailNumber: snail.Number,
+			SnailHash:   snail.Hash(),
+			Time:        new(big.Int).Add(parent.Time, big.NewInt(10)),
+			GasLimit:    8000000,
+			Extra:       []byte{},
+		}
+		rawdb.WriteHeader(db, header)
+		rawdb.WriteCanonicalHash(db, header.Hash(), header.Number.Uint64())
+		rawdb.WriteBlockReward(db, &types.BlockReward{
+			FastHash:    header.Hash(),
+			FastNumber:  header.Number,
+			SnailHash:   header.SnailHash,
+			SnailNumber: header.SnailNumber,
+		})
+		rawdb.WriteHeadRewardNumber(db, snail.Number.Uint64())
+		parent = header
+	}
+}
+
+func TestLesApiBackendSnailHeaderByNumber(t *testing.T) {
+	// Create a full node database with a snail chain of 3 blocks
+	var (
+		fullDb = abeydb.NewMemDatabase()
+		snails []*types.SnailHeader
+		parent common.Hash
+	)
+	for i := int64(1); i <= 3; i++ {
+		header := newTestSnailHeader(i)
+		header.ParentHash = parent
+		snaildb.WriteHeader(fullDb, header)
+		snaildb.WriteCanonicalHash(fullDb, header.Hash(), uint64(i))
+		snaildb.WriteHeadBlockHash(fullDb, header.Hash())
+
+		snails = append(snails, header)
+		parent = header.Hash()
+	}
+	var (
+		db  = abeydb.NewMemDatabase()
+		odr = newCountingOdr(db, nil)
+	)
+	defer odr.cht.Close()
+
+	backend := newTestApiBackend(db, odr)
+	defer close(backend.abey.shutdownChan)
+
+	// Sync a light chain rewarding the first two snail blocks only
+	odr.server = &ProtocolManager{chainDb: fullDb}
+	writeTestRewards(db, backend.CurrentBlock().Header(), snails[:2])
+
+	head, err := backend.SnailHeaderByNumber(context.Background(), rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to read snail head: %v", err)
+	}
+	if head.Hash() != snails[2].Hash() {
+		t.Fatalf("snail head mismatch: have %d %x, want 3 %x", head.Number, head.Hash(), snails[2].Hash())
+	}
+	for i, snail := range snails {
+		number := uint64(i + 1)
+		header, err := backend.SnailHeaderByNumber(context.Background(), rpc.BlockNumber(number))
+		if err != nil {
+			t.Fatalf("snail header %d: failed to read: %v", number, err)
+		}
+		if header.Hash() != snail.Hash() {
+			t.Fatalf("snail header %d: hash mismatch: have %x, want %x", number, header.Hash(), snail.Hash())
+		}
+		// Only the headers linked to the fast chain by their reward are stored
+		stored := snaildb.ReadCanonicalHash(db, number) == snail.Hash()
+		if rewarded := number <= 2; stored != rewarded {
+			t.Fatalf("snail header %d: stored %v, rewarded %v", number, stored, rewarded)
+		}
+	}
+	// A sealed header that isn't the rewarded one must be rejected
+	forged := newTestSnailHeader(1)
+	forged.Extra = []byte("forged")
+	snaildb.WriteHeader(fullDb, forged)
+	snaildb.WriteCanonicalHash(fullDb, forged.Hash(), 1)
+	snaildb.DeleteCanonicalHash(db, 1)
+
+	if _, err := backend.SnailHeaderByNumber(context.Background(), rpc.BlockNumber(1)); !errors.Is(err, light.ErrSnailHeaderMismatch) {
+		t.Fatalf("forged snail header error mismatch: have %v, want %v", err, light.ErrSnailHeaderMismatch)
+	}
+	if hash := snaildb.ReadCanonicalHash(db, 1); hash != (common.Hash{}) {
+		t.Fatalf("forged snail header stored: %x", hash)
+	}
+}
+
+func TestLesApiBackendFruitStatus(t *testing.T) {
+	var (
+		db  = abeydb.NewMemDatabase()
+		odr = newCountingOdr(db, nil)
+	)
+	defer odr.cht.Close()
+
+	backend := newTestApiBackend(db, odr)
+	defer close(backend.abey.shutdownChan)
+
+	// Extend the light chain with two fast blocks, and create a full node snail
+	// chain including each of them as a fruit of its own snail block
+	var (
+		fullDb = abeydb.NewMemDatabase()
+		parent = backend.CurrentBlock().Header()
+		fasts  []*types.Header
+		snails []*types.SnailHeader
+	)
+	for i := 0; i < 2; i++ {
+		header := &types.Header{
+			ParentHash:  parent.Hash(),
+			Number:      new(big.Int).Add(parent.Number, common.Big1),
+			SnailNumber: new(big.Int),
+			Time:        new(big.Int).Add(parent.Time, big.NewInt(10)),
+			GasLimit:    8000000,
+			Extra:       []byte{},
+		}
+		rawdb.WriteHeader(db, header)
+		rawdb.WriteCanonicalHash(db, header.Hash(), header.Number.Uint64())
+
+		fruit := types.NewSnailBlock(&types.SnailHeader{Number: big.NewInt(int64(i + 1)), FastNumber: header.Number, FastHash: header.Hash()}, nil, nil, nil, params.TestChainConfig)
+		snail := newTestSnailHeader(int64(i + 1))
+		if len(snails) > 0 {
+			snail.ParentHash = snails[len(snails)-1].Hash()
+		}
+		block := types.NewSnailBlock(snail, []*types.SnailBlock{fruit}, nil, nil, params.TestChainConfig)
+		snaildb.WriteBlock(fullDb, block)
+		snaildb.WriteCanonicalHash(fullDb, block.Hash(), block.NumberU64())
+		snaildb.WriteHeadBlockHash(fullDb, block.Hash())
+		odr.snails[block.Hash()] = block
+
+		fasts, snails = append(fasts, header), append(snails, block.Header())
+		parent = header
+	}
+	// Reward the first snail block only, leaving the second one unproven
+	odr.server = &ProtocolManager{chainDb: fullDb}
+	writeTestRewards(db, parent, snails[:1])
+
+	for i, fast := range fasts {
+		included, number, err := backend.FruitStatus(context.Background(), fast.Hash())
+		if err != nil {
+			t.Fatalf("fruit %d: failed to read status: %v", i, err)
+		}
+		if !included || number != uint64(i+1) {
+			t.Fatalf("fruit %d: status mismatch: have %v in %d, want true in %d", i, included, number, i+1)
+		}
+		// Only the inclusion in the rewarded snail block is cached
+		if cached, rewarded := backend.fruitCache.Contains(fast.Hash()), i == 0; cached != rewarded {
+			t.Fatalf("fruit %d: cached %v, rewarded %v", i, cached, rewarded)
+		}
+	}
+	// An unknown fast block isn't included
+	if included, _, err := backend.FruitStatus(context.Background(), common.Hash{0x01}); included || err != nil {
+		t.Fatalf("unknown fast block status mismatch: included %v, err %v", included, err)
+	}
+}
+
+func TestSnailHeaderRequestValidate(t *testing.T) {
+	var (
+		config = params.MainnetChainConfig
+		engine = minerva.NewFaker()
+		easy   = newTestSnailHeader(5)
+	)
+	easy.Difficulty = big.NewInt(1)
+
+	tests := []struct {
+		req     *SnailHeaderRequest
+		headers []*types.SnailHeader
+		err     error
+	}{
+		{&SnailHeaderRequest{Config: config, Engine: engine, Number: 5}, []*types.SnailHeader{newTestSnailHeader(5)}, nil},
+		{&SnailHeaderRequest{Config: config, Engine: engine, Number: 5}, []*types.SnailHeader{newTestSnailHeader(6)}, errSnailNumberMismatch},
+		{&SnailHeaderRequest{Config: config, Engine: engine, Latest: true}, []*types.SnailHeader{newTestSnailHeader(42)}, nil},
+		{&SnailHeaderRequest{Config: config, Engine: engine, Number: 5}, nil, errInvalidEntryCount},
+		{&SnailHeaderRequest{Config: config, Engine: engine, Number: 5}, []*types.SnailHeader{easy}, errSnailDifficultyTooLow},
+	}
+	for i, tt := range tests {
+		err := tt.req.Validate(nil, &Msg{MsgType: MsgSnailHeaders, Obj: tt.headers})
+		if err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+			continue
+		}
+		if err == nil && tt.req.Header != tt.headers[0] {
+			t.Errorf("test %d: validated header not recorded", i)
+		}
+	}
+	// A header failing the seal verification of the engine must be rejected
+	req := &SnailHeaderRequest{Config: config, Engine: minerva.NewFakeFailer(7), Number: 7}
+	if err := req.Validate(nil, &Msg{MsgType: MsgSnailHeaders, Obj: []*types.SnailHeader{newTestSnailHeader(7)}}); err == nil {
+		t.Fatalf("accepted snail header with invalid seal")
+	}
+}
+
+// countingOdr is an ODR backend serving headers from a predefined set through
+// CHT requests, receipts by block hash, the current snail head, the headers
+// proving block rewards, transaction positions, snail blocks, balance changes
+// and state trie proofs and contract code of a full node database, counting the
+// retrievals. Snail headers are served by the full node handler if set.
+// Results apart from trie proofs are deliberately not stored in the database so
+// every uncached lookup hits the network.
+type countingOdr struct {
+	db         abeydb.Database
+	cht        *core.ChainIndexer
+	headers    map[uint64]*types.Header
+	receipts   map[common.Hash]types.Receipts
+	snailHead  *types.SnailHeader
+	server     *ProtocolManager
+	rewards    map[uint64]*types.Header
+	lookups    map[common.Hash]*rawdb.TxLookupEntry
+	snails     map[common.Hash]*types.SnailBlock
+	balances   map[uint64]*types.BlockBalance
+	states     abeydb.Database
+	retrievals int32
+}
+
+func newCountingOdr(db abeydb.Database, headers []*types.Header) *countingOdr {
+	odr := &countingOdr{db: db, headers: make(map[uint64]*types.Header), receipts: make(map[common.Hash]types.Receipts), rewards: make(map[uint64]*types.Header), lookups: make(map[common.Hash]*rawdb.TxLookupEntry), snails: make(map[common.Hash]*types.SnailBlock), balances: make(map[uint64]*types.BlockBalance)}
+	for _, header := range headers {
+		odr.headers[header.Number.Uint64()] = header
+	}
+	odr.cht = light.NewChtIndexer(db, odr, light.DefaultClientIndexerConfig.ChtSize, light.DefaultClientIndexerConfig.ChtConfirms)
+	odr.cht.AddCheckpoint(0, common.HexToHash("0x01"))
+	return odr
+}
+
+func (odr *countingOdr) Database() abeydb.Database            { return odr.db }
+func (odr *countingOdr) ChtIndexer() *core.ChainIndexer       { return odr.cht }
+func (odr *countingOdr) BloomTrieIndexer() *core.ChainIndexer { return nil }
+func (odr *countingOdr) BloomIndexer() *core.ChainIndexer     { return nil }
+func (odr *countingOdr) IndexerConfig() *light.IndexerConfig  { return light.DefaultClientIndexerConfig }
+func (odr *countingOdr) Retrieve(ctx context.Context, req light.OdrRequest) error {
+	atomic.AddInt32(&odr.retrievals, 1)
+
+	switch r := req.(type) {
+	case *light.ChtRequest:
+		header := odr.headers[r.BlockNum]
+		if header == nil {
+			return light.ErrNoHeader
+		}
+		r.Header, r.Td = header, big.NewInt(1)
+		return nil
+	case *light.ReceiptsRequest:
+		receipts, ok := odr.receipts[r.Hash]
+		if !ok {
+			return errors.New("unknown receipts")
+		}
+		r.Receipts = receipts
+		return nil
+	case *light.SnailHeaderRequest:
+		header := odr.snailHead
+		if odr.server != nil {
+			header = odr.server.getSnailHeader(SnailHeaderReq{Number: r.Number, Latest: r.Latest})
+		} else if !r.Latest {
+			header = nil
+		}
+		if header == nil {
+			return errors.New("unknown snail header")
+		}
+		return (*SnailHeaderRequest)(r).Validate(odr.db, &Msg{MsgType: MsgSnailHeaders, Obj: []*types.SnailHeader{header}})
+	case *light.BlockRewardRequest:
+		header, ok := odr.rewards[r.SnailNumber]
+		if !ok {
+			return errors.New("unknown block reward")
+		}
+		r.Header = header
+		return nil
+	case *light.TxStatusRequest:
+		r.Status = make([]light.TxStatus, len(r.Hashes))
+		for i, hash := range r.Hashes {
+			if lookup, ok := odr.lookups[hash]; ok {
+				r.Status[i] = light.TxStatus{Status: core.TxStatusIncluded, Lookup: lookup}
+			}
+		}
+		return nil
+	case *light.SnailBlockRequest:
+		block, ok := odr.snails[r.Hash]
+		if !ok {
+			return errors.New("unknown snail block")
+		}
+		return (*SnailBlockRequest)(r).Validate(odr.db, &Msg{MsgType: MsgSnailBlocks, Obj: []*types.SnailBlock{block}})
+	case *light.BalanceChangeRequest:
+		balance, ok := odr.balances[r.Number]
+		if !ok {
+			return errors.New("unknown balance changes")
+		}
+		return (*BalanceChangeRequest)(r).Validate(odr.db, &Msg{MsgType: MsgBalanceChanges, Obj: []*types.BlockBalance{balance}})
+	case *light.TrieRequest:
+		if odr.states == nil {
+			return errors.New("unknown state")
+		}
+		t, err := trie.New(r.Id.Root, trie.NewDatabase(odr.states))
+		if err != nil {
+			return err
+		}
+		nodes := light.NewNodeSet()
+		if err := t.Prove(r.Key, 0, nodes); err != nil {
+			return err
+		}
+		r.Proof = nodes
+		r.StoreResult(odr.db)
+		return nil
+	case *light.CodeRequest:
+		if odr.states == nil {
+			return errors.New("unknown state")
+		}
+		code, err := odr.states.Get(r.Hash[:])
+		if err != nil {
+			return err
+		}
+		r.Data = code
+		return nil
+	}
+	return errors.New("unsupported request")
+}
+
+func TestLesApiBackendHeaderCache(t *testing.T) {
+	var (
+		db      = abeydb.NewMemDatabase()
+		headers = []*types.Header{
+			{Number: big.NewInt(100), SnailNumber: new(big.Int), Time: big.NewInt(1000), Extra: []byte{}},
+			{Number: big.NewInt(101), SnailNumber: new(big.Int), Time: big.NewInt(1010), Extra: []byte{}},
+		}
+		odr = newCountingOdr(db, headers)
+	)
+	defer odr.cht.Close()
+
+	backend := newTestApiBackend(db, odr)
+	defer close(backend.abey.shutdownChan)
+
+	for i := 0; i < 2; i++ {
+		header, err := backend.HeaderByNumber(context.Background(), 100)
+		if err != nil {
+			t.Fatalf("attempt %d: failed to retrieve header: %v", i, err)
+		}
+		if header.Hash() != headers[0].Hash() {
+			t.Fatalf("attempt %d: header mismatch: have %x, want %x", i, header.Hash(), headers[0].Hash())
+		}
+		if n := atomic.LoadInt32(&odr.retrievals); n != 1 {
+			t.Fatalf("attempt %d: retrieval count mismatch: have %d, want 1", i, n)
+		}
+	}
+	// A different height is retrieved once more, a missing one is not cached
+	if _, err := backend.HeaderByNumber(context.Background(), 101); err != nil {
+		t.Fatalf("failed to retrieve header: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := backend.HeaderByNumber(context.Background(), 102); err == nil {
+			t.Fatalf("attempt %d: missing header retrieved", i)
+		}
+	}
+	if n := atomic.LoadInt32(&odr.retrievals); n != 4 {
+		t.Fatalf("retrieval count mismatch: have %d, want 4", n)
+	}
+	// A head contradicting the cached headers purges them
+	backend.invalidateHeaders(&types.Header{Number: big.NewInt(101), SnailNumber: new(big.Int), Time: big.NewInt(2000), Extra: []byte{}})
+	if _, err := backend.HeaderByNumber(context.Background(), 100); err != nil {
+		t.Fatalf("failed to retrieve header: %v", err)
+	}
+	if n := atomic.LoadInt32(&odr.retrievals); n != 5 {
+		t.Fatalf("retrieval count mismatch after reorg: have %d, want 5", n)
+	}
+}
+
+func TestLesApiBackendReceiptsWithProof(t *testing.T) {
+	db := abeydb.NewMemDatabase()
+	backend := newTestApiBackend(db, nil)
+
+	var receipts types.Receipts
+	for i := 0; i < 5; i++ {
+		receipt := types.NewReceipt(nil, i%2 == 1, uint64(21000*(i+1)))
+		receipt.TxHash = common.BigToHash(big.NewInt(int64(i + 1)))
+		receipt.GasUsed = 21000
+		receipts = append(receipts, receipt)
+	}
+	parent := backend.CurrentBlock().Header()
+	header := &types.Header{
+		ParentHash:  parent.Hash(),
+		Number:      new(big.Int).Add(parent.Number, common.Big1),
+		SnailNumber: new(big.Int),
+		Time:        new(big.Int).Add(parent.Time, big.NewInt(10)),
+		ReceiptHash: types.DeriveSha(receipts),
+		Extra:       []byte{},
+	}
+	rawdb.WriteHeader(db, header)
+	rawdb.WriteCanonicalHash(db, header.Hash(), header.Number.Uint64())
+	rawdb.WriteReceipts(db, header.Hash(), header.Number.Uint64(), receipts)
+
+	have, proof, err := backend.GetReceiptsWithProof(context.Background(), header.Hash())
+	if err != nil {
+		t.Fatalf("failed to retrieve receipts: %v", err)
+	}
+	if len(have) != len(receipts) {
+		t.Fatalf("receipt count mismatch: have %d, want %d", len(have), len(receipts))
+	}
+	nodes := proof.NodeSet()
+	for i := range receipts {
+		key, _ := rlp.EncodeToBytes(uint(i))
+		value, _, err := trie.VerifyProof(header.ReceiptHash, key, nodes)
+		if err != nil {
+			t.Fatalf("receipt %d: proof verification failed: %v", i, err)
+		}
+		if !bytes.Equal(value, receipts.GetRlp(i)) {
+			t.Fatalf("receipt %d: proven value mismatch", i)
+		}
+	}
+	// Receipts not matching the header must be rejected
+	receipts[0].CumulativeGasUsed++
+	rawdb.WriteReceipts(db, header.Hash(), header.Number.Uint64(), receipts)
+	if _, _, err := backend.GetReceiptsWithProof(context.Background(), header.Hash()); err != light.ErrReceiptsMismatch {
+		t.Fatalf("error mismatch: have %v, want %v", err, light.ErrReceiptsMismatch)
+	}
+}
+
+// Tests that the receipts returned by the light backend are copies, modifying
+// them leaving the cached ones untouched.
+func TestLesApiBackendGetReceiptsCopy(t *testing.T) {
+	db := abeydb.NewMemDatabase()
+	odr := newCountingOdr(db, nil)
+	defer odr.cht.Close()
+
+	backend := newTestApiBackend(db, odr)
+	defer close(backend.abey.shutdownChan)
+
+	parent := backend.CurrentBlock().Header()
+	header := &types.Header{
+		ParentHash:  parent.Hash(),
+		Number:      big.NewInt(1),
+		SnailNumber: new(big.Int),
+		Time:        new(big.Int).Add(parent.Time, big.NewInt(10)),
+		Extra:       []byte{},
+	}
+	rawdb.WriteHeader(db, header)
+	rawdb.WriteCanonicalHash(db, header.Hash(), 1)
+
+	receipt := types.NewReceipt(nil, false, 21000)
+	receipt.TxHash = common.Hash{0x01}
+	receipt.Logs = []*types.Log{{Address: common.Address{0x02}, Topics: []common.Hash{{0x03}}, Data: []byte{0x04}}}
+	odr.receipts[header.Hash()] = types.Receipts{receipt}
+
+	// Tamper with the receipts of both the retrieval and the cache hit
+	for i := 0; i < 2; i++ {
+		have, err := backend.GetReceipts(context.Background(), header.Hash())
+		if err != nil || len(have) != 1 || len(have[0].Logs) != 1 {
+			t.Fatalf("retrieval %d: receipts mismatch: have %v, %v", i, have, err)
+		}
+		if have[0].TxHash != (common.Hash{0x01}) || have[0].Logs[0].Topics[0] != (common.Hash{0x03}) || have[0].Logs[0].Data[0] != 0x04 {
+			t.Fatalf("retrieval %d: receipt modified by an earlier caller", i)
+		}
+		have[0].TxHash = common.Hash{0xff}
+		have[0].Logs[0].Topics[0] = common.Hash{0xff}
+		have[0].Logs[0].Data[0] = 0xff
+		have[0].Logs = nil
+	}
+	if retrievals := atomic.LoadInt32(&odr.retrievals); retrievals != 1 {
+		t.Errorf("retrieval count mismatch: have %d, want 1", retrievals)
+	}
+}
+
+func TestLesApiBackendGetReceiptsMulti(t *testing.T) {
+	db := abeydb.NewMemDatabase()
+	odr := newCountingOdr(db, nil)
+	defer odr.cht.Close()
+
+	backend := newTestApiBackend(db, odr)
+	defer close(backend.abey.shutdownChan)
+
+	// Every block but the fifth and the twelfth can be served by the network,
+	// the last hash is not known at all
+	var (
+		hashes []common.Hash
+		parent = backend.CurrentBlock().Header()
+	)
+	for i := 1; i <= 20; i++ {
+		receipt := types.NewReceipt(nil, false, uint64(21000*i))
+		receipt.TxHash = common.Hash{byte(i)}
+		header := &types.Header{
+			ParentHash:  parent.Hash(),
+			Number:      big.NewInt(int64(i)),
+			SnailNumber: new(big.Int),
+			Time:        new(big.Int).Add(parent.Time, big.NewInt(10)),
+			Extra:       []byte{},
+		}
+		rawdb.WriteHeader(db, header)
+		rawdb.WriteCanonicalHash(db, header.Hash(), header.Number.Uint64())
+		if i != 5 && i != 12 {
+			odr.receipts[header.Hash()] = types.Receipts{receipt}
+		}
+		hashes = append(hashes, header.Hash())
+		parent = header
+	}
+	hashes = append(hashes, common.Hash{0xff})
+
+	have, err := backend.GetReceiptsMulti(context.Background(), hashes)
+	errs, ok := err.(ReceiptsBatchError)
+	if !ok || len(errs) != len(hashes) {
+		t.Fatalf("batch error mismatch: have %v", err)
+	}
+	if len(have) != len(hashes) {
+		t.Fatalf("result count mismatch: have %d, want %d", len(have), len(hashes))
+	}
+	for i, hash := range hashes {
+		want, werr := backend.GetReceipts(context.Background(), hash)
+		if (errs[i] != nil) != (werr != nil) {
+			t.Errorf("block %d: error mismatch: have %v, want %v", i+1, errs[i], werr)
+		}
+		if len(have[i]) != len(want) {
+			t.Errorf("block %d: receipt count mismatch: have %d, want %d", i+1, len(have[i]), len(want))
+			continue
+		}
+		for j := range want {
+			if have[i][j].TxHash != want[j].TxHash || have[i][j].CumulativeGasUsed != want[j].CumulativeGasUsed {
+				t.Errorf("block %d: receipt %d mismatch", i+1, j)
+			}
+		}
+	}
+	for i, err := range errs {
+		if failed := i == 4 || i == 11; failed != (err != nil) {
+			t.Errorf("block %d: failure mismatch: have %v", i+1, err)
+		}
+	}
+	if have[len(hashes)-1] != nil {
+		t.Errorf("unknown block has receipts: %v", have[len(hashes)-1])
+	}
+}
+
+func TestLesApiBackendCommittee(t *testing.T) {
+	db := abeydb.NewMemDatabase()
+	backend := newTestApiBackend(db, nil)
+	defer close(backend.abey.shutdownChan)
+
+	// The committee number must match the epoch a full node derives for the head
+	head := backend.abey.blockchain.CurrentHeader()
+	want := types.GetEpochFromHeight(head.Number.Uint64()).EpochID
+	if have := backend.GetCurrentCommitteeNumber(); have == nil || have.Uint64() != want {
+		t.Fatalf("committee number mismatch: have %v, want %d", have, want)
+	}
+	genesis := backend.abey.blockchain.Genesis()
+	for i := 0; i < 2; i++ {
+		info, err := backend.GetCommittee(rpc.LatestBlockNumber)
+		if err != nil {
+			t.Fatalf("attempt %d: failed to retrieve committee: %v", i, err)
+		}
+		if info["id"] != want {
+			t.Errorf("attempt %d: committee id mismatch: have %v, want %d", i, info["id"], want)
+		}
+		if info["memberCount"] != len(genesis.SwitchInfos()) {
+			t.Errorf("attempt %d: member count mismatch: have %v, want %d", i, info["memberCount"], len(genesis.SwitchInfos()))
+		}
+		// Drop the announcing block, the committee must be served from the cache
+		rawdb.DeleteCanonicalHash(db, genesis.NumberU64())
+	}
+	// The schedule is derived for the light head, without the committee size
+	schedule, err := backend.GetCommitteeSchedule(context.Background())
+	if err != nil {
+		t.Fatalf("failed to retrieve committee schedule: %v", err)
+	}
+	if uint64(schedule.Epoch) != want || uint64(schedule.NextRotation) <= head.Number.Uint64() || schedule.Committee != 0 {
+		t.Fatalf("committee schedule mismatch: have %+v", schedule)
+	}
+}
+
+func TestLesApiBackendLogsBloomValidation(t *testing.T) {
+	var (
+		db  = abeydb.NewMemDatabase()
+		odr = newCountingOdr(db, nil)
+	)
+	defer odr.cht.Close()
+
+	backend := newTestApiBackend(db, odr)
+	defer close(backend.abey.shutdownChan)
+
+	receipts := types.Receipts{
+		&types.Receipt{Logs: []*types.Log{{Address: common.Address{0x01}, Topics: []common.Hash{{0x02}}}}},
+		&types.Receipt{Logs: []*types.Log{{Address: common.Address{0x03}}}},
+	}
+	header := &types.Header{Number: big.NewInt(100), SnailNumber: new(big.Int), Time: big.NewInt(1000), Extra: []byte{}, Bloom: types.CreateBloom(receipts)}
+	rawdb.WriteHeader(db, header)
+
+	// Receipts withholding a log must be rejected
+	odr.receipts[header.Hash()] = types.Receipts{receipts[0], &types.Receipt{}}
+	if _, err := backend.GetLogs(context.Background(), header.Hash()); err != light.ErrLogsBloomMismatch {
+		t.Fatalf("tampered logs error mismatch: have %v, want %v", err, light.ErrLogsBloomMismatch)
+	}
+	// Complete receipts must be accepted
+	odr.receipts[header.Hash()] = receipts
+	logs, err := backend.GetLogs(context.Background(), header.Hash())
+	if err != nil {
+		t.Fatalf("failed to retrieve logs: %v", err)
+	}
+	if len(logs) != 2 || len(logs[0]) != 1 || len(logs[1]) != 1 {
+		t.Fatalf("logs mismatch: have %v", logs)
+	}
+}
+
+func TestLesApiBackendGetLogsInRange(t *testing.T) {
+	var (
+		db     = abeydb.NewMemDatabase()
+		odr    = newCountingOdr(db, nil)
+		target = common.Address{0x01}
+		topic  = common.Hash{0x02}
+		other  = common.Address{0x03}
+	)
+	defer odr.cht.Close()
+
+	backend := newTestApiBackend(db, odr)
+	defer close(backend.abey.shutdownChan)
+
+	// Only blocks 3 and 7 contain the target event, every other block logs an
+	// unrelated one
+	parent := backend.CurrentBlock().Header()
+	for i := 1; i <= 10; i++ {
+		log := &types.Log{Address: other, TxHash: common.Hash{byte(i)}}
+		if i == 3 || i == 7 {
+			log = &types.Log{Address: target, Topics: []common.Hash{topic}, TxHash: common.Hash{byte(i)}}
+		}
+		receipts := types.Receipts{&types.Receipt{Logs: []*types.Log{log}}}
+		header := &types.Header{
+			ParentHash:  parent.Hash(),
+			Number:      big.NewInt(int64(i)),
+			SnailNumber: new(big.Int),
+			Time:        new(big.Int).Add(parent.Time, big.NewInt(10)),
+			Bloom:       types.CreateBloom(receipts),
+			Extra:       []byte{},
+		}
+		rawdb.WriteHeader(db, header)
+		rawdb.WriteCanonicalHash(db, header.Hash(), header.Number.Uint64())
+		odr.receipts[header.Hash()] = receipts
+		parent = header
+	}
+	logs, err := backend.GetLogsInRange(context.Background(), 1, 10, []common.Address{target}, [][]common.Hash{{topic}})
+	if err != nil {
+		t.Fatalf("failed to retrieve logs: %v", err)
+	}
+	if len(logs) != 2 || logs[0].TxHash != (common.Hash{3}) || logs[1].TxHash != (common.Hash{7}) {
+		t.Fatalf("logs mismatch: have %v", logs)
+	}
+	if n := atomic.LoadInt32(&odr.retrievals); n != 2 {
+		t.Fatalf("retrieval count mismatch: have %d, want 2", n)
+	}
+	// A query matching more logs than the limit fails at the block crossing it
+	backend.SetLogsLimit(1)
+	_, err = backend.GetLogsInRange(context.Background(), 1, 10, []common.Address{target}, [][]common.Hash{{topic}})
+	if lerr, ok := err.(*filters.LimitExceededError); !ok || lerr.Limit != 1 || lerr.Block != 7 {
+		t.Fatalf("limit error mismatch: have %v, want limit 1 at block 7", err)
+	}
+}
+
+func TestLesApiBackendSetHeadOutOfRange(t *testing.T) {
+	db := abeydb.NewMemDatabase()
+	backend := newTestApiBackend(db, nil)
+	defer close(backend.abey.shutdownChan)
+
+	// Headers stored beyond the light head must not become reachable
+	head := backend.abey.blockchain.CurrentHeader()
+	writeTestHeaders(db, head, 4)
+
+	if err := backend.SetHead(head.Number.Uint64() + 2); !errors.Is(err, errInvalidHead) {
+		t.Fatalf("error mismatch: have %v, want %v", err, errInvalidHead)
+	}
+	if have := backend.abey.blockchain.CurrentHeader(); have.Hash() != head.Hash() {
+		t.Fatalf("head changed: have %d [%x], want %d [%x]", have.Number, have.Hash(), head.Number, head.Hash())
+	}
+}
+
+// sendCountingRelay is a light transaction relay counting the transactions sent.
+type sendCountingRelay struct {
+	sent int
+}
+
+func (r *sendCountingRelay) Send(txs types.Transactions) { r.sent += len(txs) }
+func (r *sendCountingRelay) NewHead(head common.Hash, mined []common.Hash, rollback []common.Hash) {
+}
+func (r *sendCountingRelay) Discard(hashes []common.Hash) {}
+
+func TestLesApiBackendSendTxUnderpriced(t *testing.T) {
+	db := abeydb.NewMemDatabase()
+	backend := newTestApiBackend(db, nil)
+	defer close(backend.abey.shutdownChan)
+
+	relay := new(sendCountingRelay)
+	backend.abey.txPool = light.NewTxPool(backend.abey.chainConfig, backend.abey.blockchain, relay)
+	defer backend.abey.txPool.Stop()
+
+	backend.SetMinGasPrice(big.NewInt(1000))
+	tx := types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(999), nil)
+	if err := backend.SendTx(context.Background(), tx); !errors.Is(err, ErrGasPriceTooLow) {
+		t.Fatalf("underpriced transaction error mismatch: have %v, want %v", err, ErrGasPriceTooLow)
+	}
+	if pending := backend.abey.txPool.Stats(); pending != 0 {
+		t.Errorf("underpriced transaction reached the pool: %d pending", pending)
+	}
+	if relay.sent != 0 {
+		t.Errorf("underpriced transaction relayed: %d sent", relay.sent)
+	}
+}
+
+func TestLesApiBackendNotSupported(t *testing.T) {
+	backend := &LesApiBackend{}
+
+	_, err := backend.GetSnailBlock(context.Background(), common.Hash{})
+	if !errors.Is(err, NotSupportOnLes) {
+		t.Fatalf("error mismatch: have %v, want %v", err, NotSupportOnLes)
+	}
+	var unsupported *ErrNotSupportedOnLes
+	if !errors.As(err, &unsupported) || unsupported.Method != "GetSnailBlock" {
+		t.Fatalf("unsupported method mismatch: have %v, want GetSnailBlock", err)
+	}
+	if !strings.Contains(err.Error(), "GetSnailBlock") {
+		t.Errorf("error message %q misses the method name", err)
+	}
+	// Unsupported subscriptions fail through their error channel
+	sub := backend.SubscribeFilteredLogs(make(chan types.FilteredLogEvent))
+	defer sub.Unsubscribe()
+
+	select {
+	case err := <-sub.Err():
+		if !errors.As(err, &unsupported) || unsupported.Method != "SubscribeFilteredLogs" {
+			t.Fatalf("subscription error mismatch: have %v, want SubscribeFilteredLogs unsupported", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("unsupported subscription did not fail")
+	}
+}
+
+func TestLesApiBackendCurrentSnailBlock(t *testing.T) {
+	var (
+		db  = abeydb.NewMemDatabase()
+		odr = newCountingOdr(db, nil)
+	)
+	defer odr.cht.Close()
+
+	backend := newTestApiBackend(db, odr)
+	defer close(backend.abey.shutdownChan)
+
+	if block := backend.CurrentSnailBlock(); block != nil {
+		t.Fatalf("unexpected snail head without peers: %d", block.NumberU64())
+	}
+	odr.snailHead = newTestSnailHeader(100)
+	for i := 0; i < 2; i++ {
+		block := backend.CurrentSnailBlock()
+		if block == nil || block.NumberU64() != 100 {
+			t.Fatalf("attempt %d: snail head mismatch: have %v, want 100", i, block)
+		}
+	}
+	if n := atomic.LoadInt32(&odr.retrievals); n != 2 {
+		t.Fatalf("retrieval count mismatch: have %d, want 2", n)
+	}
+	// A new head of the light chain refreshes the snail head
+	odr.snailHead = newTestSnailHeader(101)
+	backend.invalidateSnailHead()
+	if block := backend.CurrentSnailBlock(); block == nil || block.NumberU64() != 101 {
+		t.Fatalf("refreshed snail head mismatch: have %v, want 101", block)
+	}
+}
+
+func TestLesApiBackendGetReward(t *testing.T) {
+	// Create a full node database rewarding snail block 5 in fast block 100
+	header := &types.Header{
+		Number:      big.NewInt(100),
+		SnailNumber: big.NewInt(5),
+		SnailHash:   common.HexToHash("0x05"),
+		Time:        big.NewInt(1000),
+		Extra:       []byte{},
+	}
+	fullDb := abeydb.NewMemDatabase()
+	rawdb.WriteHeader(fullDb, header)
+	rawdb.WriteCanonicalHash(fullDb, header.Hash(), 100)
+	rawdb.WriteBlockReward(fullDb, &types.BlockReward{
+		FastHash:    header.Hash(),
+		FastNumber:  header.Number,
+		SnailHash:   header.SnailHash,
+		SnailNumber: header.SnailNumber,
+	})
+	server := &ProtocolManager{chainDb: fullDb}
+	want := rawdb.ReadBlockReward(fullDb, 5)
+
+	// Serve the proving headers of the full node to the light client
+	var (
+		db  = abeydb.NewMemDatabase()
+		odr = newCountingOdr(db, []*types.Header{header})
+	)
+	defer odr.cht.Close()
+
+	backend := newTestApiBackend(db, odr)
+	defer close(backend.abey.shutdownChan)
+
+	odr.rewards[5] = server.getBlockRewardHeader(5)
+	for i := 0; i < 2; i++ {
+		have := backend.GetReward(5)
+		if have == nil {
+			t.Fatalf("attempt %d: missing block reward", i)
+		}
+		if have.FastHash != want.FastHash || have.FastNumber.Cmp(want.FastNumber) != 0 ||
+			have.SnailHash != want.SnailHash || have.SnailNumber.Cmp(want.SnailNumber) != 0 {
+			t.Fatalf("attempt %d: block reward mismatch: have %+v, want %+v", i, have, want)
+		}
+	}
+	// Reward retrieval and the CHT proof of its header, the second call is local
+	if n := atomic.LoadInt32(&odr.retrievals); n != 2 {
+		t.Fatalf("retrieval count mismatch: have %d, want 2", n)
+	}
+	// A header not on the canonical chain must not prove a reward
+	forged := types.CopyHeader(header)
+	forged.SnailNumber, forged.Extra = big.NewInt(6), []byte("forged")
+	odr.rewards[6] = forged
+	if reward := backend.GetReward(6); reward != nil {
+		t.Fatalf("accepted reward of non-canonical header: %+v", reward)
+	}
+	if reward := rawdb.ReadBlockReward(db, 6); reward != nil {
+		t.Fatalf("stored reward of non-canonical header: %+v", reward)
+	}
+}
+
+func TestLesApiBackendGetSnailRewardContent(t *testing.T) {
+	// Create a full node database with snail block 5 of signed fruits, rewarded
+	// in fast block 100
+	engine := minerva.NewFaker()
+
+	var fruits []*types.SnailBlock
+	for i := 0; i < 3; i++ {
+		fast := types.NewBlock(&types.Header{Number: big.NewInt(int64(i + 1)), SnailNumber: new(big.Int)}, nil, nil, nil, nil)
+		signs, err := engine.GetElection().GenerateFakeSigns(fast)
+		if err != nil {
+			t.Fatalf("fruit %d: failed to sign: %v", i, err)
+		}
+		header := &types.SnailHeader{Number: big.NewInt(5), FastNumber: fast.Number(), FastHash: fast.Hash(), Coinbase: common.Address{byte(0x10 + i)}}
+		fruits = append(fruits, types.NewSnailBlock(header, nil, signs, nil, params.TestChainConfig))
+	}
+	block := types.NewSnailBlock(&types.SnailHeader{Number: big.NewInt(5), Coinbase: common.Address{0x01}}, fruits, nil, nil, params.TestChainConfig)
+
+	fullDb := abeydb.NewMemDatabase()
+	snaildb.WriteBlock(fullDb, block)
+	server := &ProtocolManager{chainDb: fullDb}
+	want := engine.GetRewardContentBySnailNumber(snaildb.ReadBlock(fullDb, block.Hash(), 5))
+	if want == nil || len(want.CommitteeReward) == 0 {
+		t.Fatalf("full node reward content incomplete: %+v", want)
+	}
+	header := &types.Header{
+		Number:      big.NewInt(100),
+		SnailNumber: big.NewInt(5),
+		SnailHash:   block.Hash(),
+		Time:        big.NewInt(1000),
+		Extra:       []byte{},
+	}
+	// Serve the rewarding header and the snail block of the full node
+	var (
+		db  = abeydb.NewMemDatabase()
+		odr = newCountingOdr(db, []*types.Header{header})
+	)
+	defer odr.cht.Close()
+
+	backend := newTestApiBackend(db, odr)
+	defer close(backend.abey.shutdownChan)
+
+	odr.rewards[5] = header
+	odr.snails[block.Hash()] = server.getSnailBlock(block.Hash())
+	for i := 0; i < 2; i++ {
+		have := backend.GetSnailRewardContent(5)
+		if !reflect.DeepEqual(have, want) {
+			t.Fatalf("attempt %d: reward content mismatch: have %+v, want %+v", i, have, want)
+		}
+	}
+	// Reward, its CHT proof and the snail block, the second call is cached
+	if n := atomic.LoadInt32(&odr.retrievals); n != 3 {
+		t.Fatalf("retrieval count mismatch: have %d, want 3", n)
+	}
+	// A snail block with fruits not matching its header must be rejected
+	forged := types.NewSnailBlockWithHeader(block.Header()).WithBody(fruits[1:], nil)
+	odr.snails[block.Hash()] = forged
+	backend.rewardCache.Purge()
+	if have := backend.GetSnailRewardContent(5); have != nil {
+		t.Fatalf("accepted forged snail block: %+v", have)
+	}
+}
+
+func TestLesApiBackendGetStateChangeByFastNumber(t *testing.T) {
+	// Create a full node state where block 100 changed the balances of two
+	// accounts, one of them staking part of its balance
+	var (
+		fullDb  = abeydb.NewMemDatabase()
+		spender = common.Address{0x01}
+		staker  = common.Address{0x02}
+	)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(fullDb))
+	statedb.SetBalance(spender, big.NewInt(1000))
+	statedb.SetBalance(staker, big.NewInt(500))
+	statedb.SetPOSLocked(staker, big.NewInt(200))
+
+	// Keep the staking account holding the locks, like the genesis does, as it
+	// has no balance nor nonce of its own
+	statedb.Finalise(false)
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := statedb.Database().TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to flush state: %v", err)
+	}
+	rawdb.WriteBalanceInfo(fullDb, 100, &types.BlockBalance{Balance: types.ToBalanceInfos(statedb.BalancesChange())})
+	server := &ProtocolManager{chainDb: fullDb}
+	want := server.getBalanceChange(100)
+	if want == nil || len(want.Balance) != 2 {
+		t.Fatalf("full node balance changes incomplete: %+v", want)
+	}
+	header := &types.Header{Number: big.NewInt(100), SnailNumber: new(big.Int), Root: root, Time: big.NewInt(1000), Extra: []byte{}}
+
+	// Serve the balance changes and the state proofs of the full node
+	var (
+		db  = abeydb.NewMemDatabase()
+		odr = newCountingOdr(db, []*types.Header{header})
+	)
+	defer odr.cht.Close()
+	odr.states = fullDb
+
+	backend := newTestApiBackend(db, odr)
+	defer close(backend.abey.shutdownChan)
+
+	// Balance changes contradicting the state must be rejected, be it in the
+	// unlocked or in the locked balance
+	for i, forged := range []*types.BalanceInfo{
+		{Address: staker, Valid: big.NewInt(500), Lock: new(big.Int)},
+		{Address: staker, Valid: big.NewInt(300), Lock: new(big.Int)},
+		{Address: staker, Valid: big.NewInt(300), Lock: big.NewInt(300)},
+	} {
+		odr.balances[100] = &types.BlockBalance{Balance: []*types.BalanceInfo{forged}}
+		if have := backend.GetStateChangeByFastNumber(100); have != nil {
+			t.Fatalf("forgery %d: accepted forged balance changes: %+v", i, have)
+		}
+	}
+	// Balance changes leaving entries out can't be told apart, but must not be
+	// stored for later retrievals
+	odr.balances[100] = &types.BlockBalance{Balance: want.Balance[:1]}
+	if have := backend.GetStateChangeByFastNumber(100); have == nil || len(have.Balance) != 1 {
+		t.Fatalf("partial balance changes mismatch: have %+v", have)
+	}
+	if stored := rawdb.ReadBalanceInfo(db, 100); stored != nil {
+		t.Fatalf("unproven balance changes stored: %+v", stored)
+	}
+	odr.balances[100] = want
+	for i := 0; i < 2; i++ {
+		have := backend.GetStateChangeByFastNumber(100)
+		if have == nil {
+			t.Fatalf("attempt %d: missing balance changes", i)
+		}
+		if len(have.Balance) != len(want.Balance) {
+			t.Fatalf("attempt %d: balance change count mismatch: have %d, want %d", i, len(have.Balance), len(want.Balance))
+		}
+		haveInfos := have.ToMap()
+		for addr, info := range want.ToMap() {
+			got := haveInfos[addr]
+			if got == nil || got.Valid.Cmp(info.Valid) != 0 || got.Lock.Cmp(info.Lock) != 0 {
+				t.Fatalf("attempt %d: balance change of %x mismatch: have %+v, want %+v", i, addr, got, info)
+			}
+		}
+	}
+}
+
+// tamperingOdr serves account proofs taken from a forged state in place of the
+// requested one while forged is set, and forged code while forgedCode is set.
+type tamperingOdr struct {
+	*countingOdr
+	forged     common.Hash
+	forgedCode []byte
+}
+
+func (odr *tamperingOdr) Retrieve(ctx context.Context, req light.OdrRequest) error {
+	if r, ok := req.(*light.CodeRequest); ok && odr.forgedCode != nil {
+		r.Data = odr.forgedCode
+		return nil
+	}
+	r, ok := req.(*light.TrieRequest)
+	if !ok || odr.forged == (common.Hash{}) {
+		return odr.countingOdr.Retrieve(ctx, req)
+	}
+	t, err := trie.New(odr.forged, trie.NewDatabase(odr.states))
+	if err != nil {
+		return err
+	}
+	nodes := light.NewNodeSet()
+	if err := t.Prove(r.Key, 0, nodes); err != nil {
+		return err
+	}
+	r.Proof = nodes
+	return nil
+}
+
+func TestLesApiBackendGetVerifiedNonce(t *testing.T) {
+	var (
+		fullDb  = abeydb.NewMemDatabase()
+		account = common.Address{0x01}
+	)
+	// Commit the genuine state along with a forged one raising the nonce
+	commit := func(nonce uint64) common.Hash {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(fullDb))
+		statedb.SetNonce(account, nonce)
+		statedb.SetBalance(account, big.NewInt(1000))
+		root, err := statedb.Commit(true)
+		if err != nil {
+			t.Fatalf("failed to commit state: %v", err)
+		}
+		if err := statedb.Database().TrieDB().Commit(root, false); err != nil {
+			t.Fatalf("failed to flush state: %v", err)
+		}
+		return root
+	}
+	root, forged := commit(5), commit(9)
+
+	db := abeydb.NewMemDatabase()
+	odr := &tamperingOdr{countingOdr: newCountingOdr(db, nil)}
+	defer odr.cht.Close()
+	odr.states = fullDb
+
+	backend := newTestApiBackend(db, odr)
+	defer close(backend.abey.shutdownChan)
+
+	// Move the light head onto a header committing to the genuine state
+	parent := backend.abey.blockchain.CurrentHeader()
+	header := &types.Header{
+		ParentHash:  parent.Hash(),
+		Number:      new(big.Int).Add(parent.Number, common.Big1),
+		SnailNumber: new(big.Int),
+		Root:        root,
+		Time:        new(big.Int).Add(parent.Time, big.NewInt(10)),
+		Extra:       []byte{},
+	}
+	rawdb.WriteHeader(db, header)
+	rawdb.WriteCanonicalHash(db, header.Hash(), header.Number.Uint64())
+	rawdb.WriteHeadHeaderHash(db, header.Hash())
+	backend.abey.blockchain.LoadLastState()
+
+	backend.abey.txPool = light.NewTxPool(backend.abey.chainConfig, backend.abey.blockchain, new(sendCountingRelay))
+	defer backend.abey.txPool.Stop()
+
+	// A proof of the forged state must be rejected
+	odr.forged = forged
+	if _, _, err := backend.GetVerifiedNonce(context.Background(), account); !errors.Is(err, ErrInvalidStateProof) {
+		t.Fatalf("tampered proof error mismatch: have %v, want %v", err, ErrInvalidStateProof)
+	}
+	odr.forged = common.Hash{}
+
+	nonce, poolNonce, err := backend.GetVerifiedNonce(context.Background(), account)
+	if err != nil {
+		t.Fatalf("failed to retrieve verified nonce: %v", err)
+	}
+	if nonce != 5 || poolNonce != 5 {
+		t.Errorf("nonce mismatch: have %d (pool %d), want 5 (pool 5)", nonce, poolNonce)
+	}
+	// Missing accounts are proven absent
+	if nonce, _, err := backend.GetVerifiedNonce(context.Background(), common.Address{0x02}); err != nil || nonce != 0 {
+		t.Errorf("missing account nonce mismatch: have %d, err %v", nonce, err)
+	}
+}
+
+func TestLesApiBackendGetCode(t *testing.T) {
+	var (
+		fullDb   = abeydb.NewMemDatabase()
+		contract = common.Address{0xac}
+		eoa      = common.Address{0x01}
+		code     = []byte{byte(vm.PUSH1), 0x2a, byte(vm.PUSH1), 0x00, byte(vm.MSTORE), byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x00, byte(vm.RETURN)}
+	)
+	// Deploy the contract in the state of a full node
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(fullDb))
+	statedb.SetCode(contract, code)
+	statedb.SetBalance(eoa, big.NewInt(1000))
+	root, err := statedb.Commit(true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := statedb.Database().TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to flush state: %v", err)
+	}
+	db := abeydb.NewMemDatabase()
+	odr := &tamperingOdr{countingOdr: newCountingOdr(db, nil)}
+	defer odr.cht.Close()
+	odr.states = fullDb
+
+	backend := newTestApiBackend(db, odr)
+	defer close(backend.abey.shutdownChan)
+
+	// Move the light head onto a header committing to the state
+	parent := backend.abey.blockchain.CurrentHeader()
+	header := &types.Header{
+		ParentHash:  parent.Hash(),
+		Number:      new(big.Int).Add(parent.Number, common.Big1),
+		SnailNumber: new(big.Int),
+		Root:        root,
+		Time:        new(big.Int).Add(parent.Time, big.NewInt(10)),
+		Extra:       []byte{},
+	}
+	rawdb.WriteHeader(db, header)
+	rawdb.WriteCanonicalHash(db, header.Hash(), header.Number.Uint64())
+	rawdb.WriteHeadHeaderHash(db, header.Hash())
+	backend.abey.blockchain.LoadLastState()
+
+	// Code not matching the code hash of the account must be rejected
+	odr.forgedCode = []byte{byte(vm.STOP)}
+	if _, err := backend.GetCode(context.Background(), contract, rpc.LatestBlockNumber); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("forged code error mismatch: have %v, want %v", err, ErrInvalidCode)
+	}
+	odr.forgedCode = nil
+
+	have, err := backend.GetCode(context.Background(), contract, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to retrieve code: %v", err)
+	}
+	if !bytes.Equal(have, code) {
+		t.Errorf("code mismatch: have %x, want %x", have, code)
+	}
+	// Accounts without code have empty code
+	have, err = backend.GetCode(context.Background(), eoa, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to retrieve account code: %v", err)
+	}
+	if have == nil || len(have) != 0 {
+		t.Errorf("account code mismatch: have %x, want empty", have)
+	}
+}
+
+func TestLesApiBackendGetStorageAt(t *testing.T) {
+	var (
+		fullDb   = abeydb.NewMemDatabase()
+		contract = common.Address{0xac}
+		slot     = common.Hash{0x01}
+		value    = common.Hash{0x2a}
+	)
+	// Commit the genuine state of the full node along with a forged one altering
+	// the slot
+	commit := func(value common.Hash) (common.Hash, *state.StateDB) {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(fullDb))
+		statedb.SetCode(contract, []byte{byte(vm.STOP)})
+		statedb.SetState(contract, slot, value)
+		statedb.SetState(contract, common.Hash{0x02}, common.Hash{0x03})
+		statedb.SetBalance(common.Address{0x01}, big.NewInt(1000))
+		root, err := statedb.Commit(true)
+		if err != nil {
+			t.Fatalf("failed to commit state: %v", err)
+		}
+		if err := statedb.Database().TrieDB().Commit(root, false); err != nil {
+			t.Fatalf("failed to flush state: %v", err)
+		}
+		return root, statedb
+	}
+	root, full := commit(value)
+	forged, _ := commit(common.Hash{0x2b})
+
+	db := abeydb.NewMemDatabase()
+	odr := &tamperingOdr{countingOdr: newCountingOdr(db, nil)}
+	defer odr.cht.Close()
+	odr.states = fullDb
+
+	backend := newTestApiBackend(db, odr)
+	defer close(backend.abey.shutdownChan)
+
+	// Move the light head onto a header committing to the genuine state
+	parent := backend.abey.blockchain.CurrentHeader()
+	header := &types.Header{
+		ParentHash:  parent.Hash(),
+		Number:      new(big.Int).Add(parent.Number, common.Big1),
+		SnailNumber: new(big.Int),
+		Root:        root,
+		Time:        new(big.Int).Add(parent.Time, big.NewInt(10)),
+		Extra:       []byte{},
+	}
+	rawdb.WriteHeader(db, header)
+	rawdb.WriteCanonicalHash(db, header.Hash(), header.Number.Uint64())
+	rawdb.WriteHeadHeaderHash(db, header.Hash())
+	backend.abey.blockchain.LoadLastState()
+
+	// Proofs taken from another state must be rejected
+	odr.forged = forged
+	if _, err := backend.GetStorageAt(context.Background(), contract, slot, rpc.LatestBlockNumber); !errors.Is(err, ErrInvalidStateProof) {
+		t.Fatalf("forged proof error mismatch: have %v, want %v", err, ErrInvalidStateProof)
+	}
+	odr.forged = common.Hash{}
+
+	tests := []struct {
+		addr common.Address
+		key  common.Hash
+	}{
+		{contract, slot},              // Set slot
+		{contract, common.Hash{0x04}}, // Unset slot
+		{common.Address{0x01}, slot},  // Account without storage
+		{common.Address{0x05}, slot},  // Missing account
+	}
+	for i, tt := range tests {
+		have, err := backend.GetStorageAt(context.Background(), tt.addr, tt.key, rpc.LatestBlockNumber)
+		if err != nil {
+			t.Fatalf("test %d: failed to retrieve slot: %v", i, err)
+		}
+		if want := full.GetState(tt.addr, tt.key); have != want {
+			t.Errorf("test %d: slot mismatch: have %x, want %x", i, have, want)
+		}
+	}
+	if have, _ := backend.GetStorageAt(context.Background(), contract, slot, rpc.LatestBlockNumber); have != value {
+		t.Errorf("slot value mismatch: have %x, want %x", have, value)
+	}
+}
+
+// verifyTestProof checks a hex encoded merkle proof of the key against the root
+// and returns the proven value.
+func verifyTestProof(t *testing.T, root common.Hash, key []byte, proof []string) []byte {
+	t.Helper()
+
+	nodes := light.NewNodeSet()
+	for _, node := range proof {
+		blob, err := hexutil.Decode(node)
+		if err != nil {
+			t.Fatalf("failed to decode proof node: %v", err)
+		}
+		nodes.Put(crypto.Keccak256(blob), blob)
+	}
+	value, _, err := trie.VerifyProof(root, crypto.Keccak256(key), nodes)
+	if err != nil {
+		t.Fatalf("failed to verify proof of %x: %v", key, err)
+	}
+	return value
+}
+
+func TestLesApiBackendGetProof(t *testing.T) {
+	var (
+		fullDb  = abeydb.NewMemDatabase()
+		account = common.Address{0xac}
+		slot    = common.HexToHash("0x01")
+		missing = common.HexToHash("0x02")
+	)
+	// Fill a storage slot of an account in the state of a full node
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(fullDb))
+	statedb.SetBalance(account, big.NewInt(1000))
+	statedb.SetNonce(account, 3)
+	statedb.SetState(account, slot, common.HexToHash("0x2a"))
+	root, err := statedb.Commit(true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := statedb.Database().TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to flush state: %v", err)
+	}
+	db := abeydb.NewMemDatabase()
+	odr := newCountingOdr(db, nil)
+	defer odr.cht.Close()
+	odr.states = fullDb
+
+	backend := newTestApiBackend(db, odr)
+	defer close(backend.abey.shutdownChan)
+
+	// Move the light head onto a header committing to the state
+	parent := backend.abey.blockchain.CurrentHeader()
+	header := &types.Header{
+		ParentHash:  parent.Hash(),
+		Number:      new(big.Int).Add(parent.Number, common.Big1),
+		SnailNumber: new(big.Int),
+		Root:        root,
+		Time:        new(big.Int).Add(parent.Time, big.NewInt(10)),
+		Extra:       []byte{},
+	}
+	rawdb.WriteHeader(db, header)
+	rawdb.WriteCanonicalHash(db, header.Hash(), header.Number.Uint64())
+	rawdb.WriteHeadHeaderHash(db, header.Hash())
+	backend.abey.blockchain.LoadLastState()
+
+	result, err := backend.GetProof(context.Background(), account, []common.Hash{slot, missing}, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatalf("failed to retrieve proof: %v", err)
+	}
+	// The account proof must lead from the state root to the reported account
+	var acc state.Account
+	if err := rlp.DecodeBytes(verifyTestProof(t, header.Root, account[:], result.AccountProof), &acc); err != nil {
+		t.Fatalf("failed to decode proven account: %v", err)
+	}
+	if acc.Nonce != uint64(result.Nonce) || acc.Balance.Cmp(result.Balance.ToInt()) != 0 {
+		t.Errorf("proven account mismatch: have nonce %d balance %v, reported nonce %d balance %v", acc.Nonce, acc.Balance, result.Nonce, result.Balance)
+	}
+	if acc.Root != result.StorageHash || common.BytesToHash(acc.CodeHash) != result.CodeHash {
+		t.Errorf("proven account roots mismatch: have %x/%x, reported %x/%x", acc.Root, acc.CodeHash, result.StorageHash, result.CodeHash)
+	}
+	if len(result.StorageProof) != 2 {
+		t.Fatalf("storage proof count mismatch: have %d, want 2", len(result.StorageProof))
+	}
+	// The filled slot is proven with its value, the missing one proven absent
+	var value []byte
+	if err := rlp.DecodeBytes(verifyTestProof(t, result.StorageHash, slot[:], result.StorageProof[0].Proof), &value); err != nil {
+		t.Fatalf("failed to decode proven slot: %v", err)
+	}
+	if have := new(big.Int).SetBytes(value); have.Cmp(result.StorageProof[0].Value.ToInt()) != 0 || have.Int64() != 0x2a {
+		t.Errorf("proven slot mismatch: have %v, reported %v, want 42", have, result.StorageProof[0].Value)
+	}
+	if value := verifyTestProof(t, result.StorageHash, missing[:], result.StorageProof[1].Proof); value != nil {
+		t.Errorf("missing slot proven with value %x", value)
+	}
+	if result.StorageProof[1].Value.ToInt().Sign() != 0 {
+		t.Errorf("missing slot value mismatch: have %v, want 0", result.StorageProof[1].Value)
+	}
+}
+
+// countingMultiplexer is a bloom multiplexer recording the parameters it was
+// started with.
+type countingMultiplexer struct {
+	started chan struct{}
+	batch   int32
+	wait    int64
+}
+
+func (m *countingMultiplexer) Multiplex(batch int, wait time.Duration, mux chan chan *bloombits.Retrieval) {
+	atomic.StoreInt32(&m.batch, int32(batch))
+	atomic.StoreInt64(&m.wait, int64(wait))
+	m.started <- struct{}{}
+}
+
+func TestLesApiBackendServiceFilterThreads(t *testing.T) {
+	tests := []struct {
+		threads, batch int
+		wait           time.Duration
+		wantThreads    int
+		wantBatch      int
+		wantWait       time.Duration
+	}{
+		{0, 0, 0, bloomFilterThreads, bloomRetrievalBatch, bloomRetrievalWait},
+		{8, 32, time.Millisecond, 8, 32, time.Millisecond},
+	}
+	for i, tt := range tests {
+		backend := newLesApiBackend(&LightAbey{}, 0)
+		backend.SetBloomFilterParams(tt.threads, tt.batch, tt.wait)
+
+		session := &countingMultiplexer{started: make(chan struct{})}
+		backend.serviceFilter(session)
+		for j := 0; j < tt.wantThreads; j++ {
+			select {
+			case <-session.started:
+			case <-time.After(time.Second):
+				t.Fatalf("test %d: multiplexer count mismatch: have %d, want %d", i, j, tt.wantThreads)
+			}
+		}
+		select {
+		case <-session.started:
+			t.Fatalf("test %d: more than %d multiplexers started", i, tt.wantThreads)
+		case <-time.After(50 * time.Millisecond):
+		}
+		if batch := int(atomic.LoadInt32(&session.batch)); batch != tt.wantBatch {
+			t.Errorf("test %d: batch mismatch: have %d, want %d", i, batch, tt.wantBatch)
+		}
+		if wait := time.Duration(atomic.LoadInt64(&session.wait)); wait != tt.wantWait {
+			t.Errorf("test %d: wait mismatch: have %v, want %v", i, wait, tt.wantWait)
+		}
+	}
+}
+
+func TestLesApiBackendCurrentFinalizedBlock(t *testing.T) {
+	// The last block of the trusted CHT section is the finalized one
+	var (
+		db     = abeydb.NewMemDatabase()
+		number = light.DefaultClientIndexerConfig.ChtSize - 1
+		header = &types.Header{Number: new(big.Int).SetUint64(number), SnailNumber: new(big.Int), Time: big.NewInt(1000), Extra: []byte{}}
+		odr    = newCountingOdr(db, []*types.Header{header})
+	)
+	defer odr.cht.Close()
+
+	backend := newTestApiBackend(db, odr)
+	defer close(backend.abey.shutdownChan)
+
+	if block := backend.CurrentFinalizedBlock(); block.Hash() != header.Hash() {
+		t.Fatalf("finalized block mismatch: have #%d [%x], want #%d [%x]", block.NumberU64(), block.Hash(), number, header.Hash())
+	}
+	// Without a retrievable section head the genesis block is returned, the
+	// light client's one being the LES protocol genesis rather than block 0
+	delete(odr.headers, number)
+	backend.cache.purge(cachedHeader)
+	genesis := backend.abey.blockchain.Genesis()
+	if block := backend.CurrentFinalizedBlock(); block.Hash() != genesis.Hash() {
+		t.Fatalf("fallback block mismatch: have #%d [%x], want #%d [%x]", block.NumberU64(), block.Hash(), genesis.NumberU64(), genesis.Hash())
+	}
+}
+
+func TestLesApiBackendStateAndHeaderByNumberOrHash(t *testing.T) {
+	db := abeydb.NewMemDatabase()
+	backend := newTestApiBackend(db, nil)
+	defer close(backend.abey.shutdownChan)
+
+	headers := writeTestHeaders(db, backend.CurrentBlock().Header(), 4)
+	canonical := headers[2]
+
+	// Store a sibling of a canonical header, known but not canonical
+	side := types.CopyHeader(canonical)
+	side.Extra = []byte("side")
+	rawdb.WriteHeader(db, side)
+
+	tests := []struct {
+		blockNrOrHash rpc.BlockNumberOrHash
+		want          common.Hash
+		err           error
+	}{
+		{rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(canonical.Number.Int64())), canonical.Hash(), nil},
+		{rpc.BlockNumberOrHashWithHash(canonical.Hash(), true), canonical.Hash(), nil},
+		{rpc.BlockNumberOrHashWithHash(side.Hash(), false), side.Hash(), nil},
+		{rpc.BlockNumberOrHashWithHash(side.Hash(), true), common.Hash{}, errNonCanonicalHash},
+	}
+	for i, tt := range tests {
+		statedb, header, err := backend.StateAndHeaderByNumberOrHash(context.Background(), tt.blockNrOrHash)
+		if err != tt.err {
+			t.Fatalf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+		if tt.err != nil {
+			continue
+		}
+		if statedb == nil || header == nil || header.Hash() != tt.want {
+			t.Fatalf("test %d: header mismatch: have %v, want %x", i, header, tt.want)
+		}
+	}
+	if _, _, err := backend.StateAndHeaderByHash(context.Background(), common.HexToHash("0xdeadbeef")); err == nil {
+		t.Fatalf("unknown hash: expected failure")
+	}
+}
+
+func TestLesApiBackendRawTransactionAndReceipt(t *testing.T) {
+	var (
+		db  = abeydb.NewMemDatabase()
+		odr = newCountingOdr(db, nil)
+	)
+	defer odr.cht.Close()
+
+	backend := newTestApiBackend(db, odr)
+	defer close(backend.abey.shutdownChan)
+
+	key, _ := crypto.GenerateKey()
+	signer := types.NewTIP1Signer(params.TestChainConfig.ChainID)
+	tx, err := types.SignTx(types.NewTransaction(0, common.Address{0x01}, big.NewInt(1000), params.TxGas, big.NewInt(1), nil), signer, key)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	receipt := types.NewReceipt(nil, false, params.TxGas)
+	receipt.TxHash, receipt.GasUsed = tx.Hash(), params.TxGas
+
+	// The body is available locally, the position and receipts only from peers
+	block := types.NewBlock(&types.Header{Number: big.NewInt(1), SnailNumber: new(big.Int)}, []*types.Transaction{tx}, []*types.Receipt{receipt}, nil, nil)
+	rawdb.WriteBody(db, block.Hash(), 1, block.Body())
+	odr.lookups[tx.Hash()] = &rawdb.TxLookupEntry{BlockHash: block.Hash(), BlockIndex: 1, Index: 0}
+	odr.receipts[block.Hash()] = types.Receipts{receipt}
+
+	data, err := backend.GetRawTransaction(context.Background(), tx.Hash())
+	if err != nil {
+		t.Fatalf("failed to get raw transaction: %v", err)
+	}
+	decodedTx := new(types.Transaction)
+	if err := rlp.DecodeBytes(data, decodedTx); err != nil {
+		t.Fatalf("failed to decode raw transaction: %v", err)
+	}
+	if decodedTx.Hash() != tx.Hash() {
+		t.Errorf("transaction hash mismatch: have %x, want %x", decodedTx.Hash(), tx.Hash())
+	}
+	data, err = backend.GetRawReceipt(context.Background(), tx.Hash())
+	if err != nil {
+		t.Fatalf("failed to get raw receipt: %v", err)
+	}
+	decodedReceipt := new(types.ReceiptForStorage)
+	if err := rlp.DecodeBytes(data, decodedReceipt); err != nil {
+		t.Fatalf("failed to decode raw receipt: %v", err)
+	}
+	if decodedReceipt.TxHash != tx.Hash() {
+		t.Errorf("receipt hash mismatch: have %x, want %x", decodedReceipt.TxHash, tx.Hash())
+	}
+	// A peer reporting a wrong position must be caught by the body check
+	odr.lookups[common.Hash{0xff}] = &rawdb.TxLookupEntry{BlockHash: block.Hash(), BlockIndex: 1, Index: 0}
+	if _, err := backend.GetRawTransaction(context.Background(), common.Hash{0xff}); err != light.ErrTxLookupMismatch {
+		t.Errorf("misplaced transaction error mismatch: have %v, want %v", err, light.ErrTxLookupMismatch)
+	}
+	// Transactions unknown to peers yield no data
+	if data, err := backend.GetRawTransaction(context.Background(), common.Hash{0xfe}); data != nil || err != nil {
+		t.Errorf("unknown transaction: have %x, %v, want nil", data, err)
+	}
+}
+
+// stallingOdr is an ODR backend whose block body retrievals never complete,
+// returning only once the request context is done.
+type stallingOdr struct {
+	*countingOdr
+}
+
+func (odr *stallingOdr) Retrieve(ctx context.Context, req light.OdrRequest) error {
+	if _, ok := req.(*light.BlockRequest); !ok {
+		return odr.countingOdr.Retrieve(ctx, req)
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestLesApiBackendReadTimeout(t *testing.T) {
+	db := abeydb.NewMemDatabase()
+	odr := &stallingOdr{newCountingOdr(db, nil)}
+	defer odr.cht.Close()
+
+	backend := newTestApiBackend(db, odr)
+	defer close(backend.abey.shutdownChan)
+
+	headers := writeTestHeaders(db, backend.abey.blockchain.CurrentHeader(), 1)
+	hash := headers[0].Hash()
+
+	// Without a caller deadline the configured timeout must abort the retrieval
+	backend.SetReadTimeout(50 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := backend.GetBlock(context.Background(), hash); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error mismatch: have %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("retrieval aborted after %v, want 50ms", elapsed)
+	}
+	// An explicit caller deadline must take precedence, even if longer
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start = time.Now()
+	if _, err := backend.GetBlock(ctx, hash); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error mismatch: have %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("retrieval aborted after %v, before the caller deadline of 200ms", elapsed)
+	}
+}
+
+func TestLesApiBackendStaleHead(t *testing.T) {
+	db := abeydb.NewMemDatabase()
+	backend := newTestApiBackend(db, nil)
+	defer close(backend.abey.shutdownChan)
+
+	head := backend.abey.blockchain.CurrentHeader()
+	clock := time.Unix(head.Time.Int64(), 0).Add(30 * time.Second)
+	backend.now = func() time.Time { return clock }
+	backend.SetMaxHeadAge(time.Minute)
+
+	// A head within the maximum age must be served
+	if header, err := backend.HeaderByNumber(context.Background(), rpc.LatestBlockNumber); err != nil || header.Hash() != head.Hash() {
+		t.Fatalf("fresh head mismatch: have %v, %v, want %x", header, err, head.Hash())
+	}
+	// Past the maximum age latest reads must fail, explicit heights not
+	clock = clock.Add(time.Minute)
+
+	_, err := backend.HeaderByNumber(context.Background(), rpc.LatestBlockNumber)
+	serr, ok := err.(*StaleHeadError)
+	if !ok {
+		t.Fatalf("error mismatch: have %v, want stale head", err)
+	}
+	if serr.Number != head.Number.Uint64() || serr.Age != 90*time.Second || serr.MaxAge != time.Minute {
+		t.Fatalf("stale head error mismatch: have %+v", serr)
+	}
+	if _, _, err := backend.StateAndHeaderByNumber(context.Background(), rpc.LatestBlockNumber); err == nil {
+		t.Fatalf("stale state served")
+	} else if _, ok := err.(*StaleHeadError); !ok {
+		t.Fatalf("state error mismatch: have %v, want stale head", err)
+	}
+	if header, err := backend.HeaderByNumber(context.Background(), rpc.BlockNumber(head.Number.Int64())); err != nil || header.Hash() != head.Hash() {
+		t.Fatalf("explicit head mismatch: have %v, %v, want %x", header, err, head.Hash())
+	}
+	// Disabling the check must serve the stale head again
+	backend.SetMaxHeadAge(0)
+	if _, err := backend.HeaderByNumber(context.Background(), rpc.LatestBlockNumber); err != nil {
+		t.Fatalf("failed to read head with the check disabled: %v", err)
+	}
+}
+
+// Tests that the ODR caches stay within their shared memory budget, evicting the
+// least recently used objects whatever their kind, and that they can be trimmed
+// on demand.
+func TestLesApiBackendCacheBudget(t *testing.T) {
+	backend := newLesApiBackend(&LightAbey{}, 0)
+	backend.SetCacheBudget(4096)
+
+	// Fill the cache past the budget, alternating the kinds of the objects
+	for i := 0; i < 8; i++ {
+		key := common.Hash{byte(i)}
+		if i%2 == 0 {
+			backend.cache.add(cachedCode, key, make([]byte, 1024), 1024)
+		} else {
+			backend.cache.add(cachedReceipts, key, types.Receipts{}, 1024)
+		}
+	}
+	if _, size := backend.cache.stats(cachedCode); size != 4096 {
+		t.Fatalf("cache size mismatch: have %d, want %d", size, 4096)
+	}
+	for i := 0; i < 8; i++ {
+		kind := cachedCode
+		if i%2 == 1 {
+			kind = cachedReceipts
+		}
+		if _, ok := backend.cache.peek(kind, common.Hash{byte(i)}); ok != (i >= 4) {
+			t.Errorf("object %d: cached mismatch: have %v, want %v", i, ok, i >= 4)
+		}
+	}
+	// Using an object spares it from the next eviction
+	backend.cache.get(cachedCode, common.Hash{4})
+	backend.cache.add(cachedCode, common.Hash{8}, make([]byte, 1024), 1024)
+	if _, ok := backend.cache.peek(cachedCode, common.Hash{4}); !ok {
+		t.Errorf("recently used object evicted")
+	}
+	if _, ok := backend.cache.peek(cachedReceipts, common.Hash{5}); ok {
+		t.Errorf("least recently used object retained")
+	}
+	// Trimming drops the oldest objects down to the target
+	if freed := backend.TrimCaches(1024); freed != 3072 {
+		t.Errorf("freed bytes mismatch: have %d, want %d", freed, 3072)
+	}
+	if _, ok := backend.cache.peek(cachedCode, common.Hash{8}); !ok {
+		t.Errorf("most recently used object trimmed")
+	}
+	if count, size := backend.cache.stats(cachedReceipts); count != 0 || size != 1024 {
+		t.Errorf("trimmed cache mismatch: have %d receipts and %d bytes, want none and %d", count, size, 1024)
+	}
+}
@@ -148,7 +148,10 @@ func New(ctx *node.ServiceContext, config *abey.Config) (*LightAbey, error) {
 		chainDb, labey.odr, labey.relay, labey.serverPool, quitSync, &labey.wg, labey.genesisHash); err != nil {
 		return nil, err
 	}
-	labey.ApiBackend = &LesApiBackend{labey, nil}
+	labey.ApiBackend = newLesApiBackend(labey, config.LightHeaderCache)
+	labey.ApiBackend.SetLogsLimit(config.RPCLogsLimit)
+	labey.ApiBackend.SetMaxHeadAge(config.LightMaxHeadAge)
+	labey.ApiBackend.SetCacheBudget(config.LightCacheBudget)
 	gpoParams := config.GPO
 	if gpoParams.Default == nil {
 		gpoParams.Default = config.GasPrice
@@ -164,6 +167,8 @@ func lesTopic(genesisHash common.Hash, protocolVersion uint) discv5.Topic {
 		name = "LES"
 	case lpv2:
 		name = "LES2"
+	case lpv3:
+		name = "LES3"
 	default:
 		panic(nil)
 	}
@@ -210,7 +215,7 @@ func (s *LightAbey) APIs() []rpc.API {
 		{
 			Namespace: "eth",
 			Version:   "1.0",
-			Service:   filters.NewPublicFilterAPI(s.ApiBackend, true),
+			Service:   filters.NewPublicFilterAPI(s.ApiBackend, true, s.config.RPCLogsLimit),
 			Public:    true,
 		}, {
 			Namespace: "net",
@@ -19,6 +19,7 @@ package les
 import (
 	"bytes"
 	"context"
+	"encoding/hex"
 	"errors"
 	"math/big"
 
@@ -348,6 +349,21 @@ func (e *Election) getCommittee(id *big.Int) *types.ElectionCommittee {
 	return c
 }
 
+// membersDisplay converts committee members into the layout returned by the
+// committee API of full nodes.
+func membersDisplay(members []*types.CommitteeMember) []map[string]interface{} {
+	var attrs []map[string]interface{}
+	for _, member := range members {
+		attrs = append(attrs, map[string]interface{}{
+			"coinbase": member.Coinbase,
+			"PKey":     hex.EncodeToString(member.Publickey),
+			"flag":     member.Flag,
+			"type":     member.MType,
+		})
+	}
+	return attrs
+}
+
 // FinalizeCommittee upddate current committee state
 func (e *Election) FinalizeCommittee(block *types.Block) error {
 	return nil
@@ -0,0 +1,169 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/AbeyFoundation/go-abey/common/hexutil"
+	"github.com/AbeyFoundation/go-abey/core/types"
+	"github.com/AbeyFoundation/go-abey/rpc"
+)
+
+// maxFeeHistory is the maximum number of blocks a single fee history query
+// may span.
+const maxFeeHistory = 1024
+
+var errInvalidPercentile = errors.New("invalid reward percentile")
+
+// FeeHistoryResult is the fee history of a block range, following the layout of
+// the eth_feeHistory JSON schema.
+//
+// The chain has no protocol base fee, so the lowest gas price paid in a block
+// is reported as its base fee, zero for empty blocks. As in the schema, one
+// more base fee than blocks is returned, the last one repeating the proxy of
+// the newest block as the estimate for the next. Rewards are the gas prices
+// paid at the requested percentiles, weighted by the gas used of each
+// transaction.
+type FeeHistoryResult struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
+	BaseFee      []*hexutil.Big   `json:"baseFeePerGas,omitempty"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+// FeeHistory returns the fee history of up to blockCount blocks ending with
+// lastBlock, retrieving the headers, and the bodies and receipts of non-empty
+// blocks, through ODR.
+func (b *LesApiBackend) FeeHistory(ctx context.Context, blockCount int, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*FeeHistoryResult, error) {
+	for i, p := range rewardPercentiles {
+		if p < 0 || p > 100 || (i > 0 && p < rewardPercentiles[i-1]) {
+			return nil, fmt.Errorf("%w: %f", errInvalidPercentile, p)
+		}
+	}
+	last := uint64(lastBlock)
+	if lastBlock == rpc.LatestBlockNumber || lastBlock == rpc.PendingBlockNumber {
+		last = b.abey.blockchain.CurrentHeader().Number.Uint64()
+	}
+	if blockCount > maxFeeHistory {
+		blockCount = maxFeeHistory
+	}
+	if uint64(blockCount) > last+1 {
+		blockCount = int(last + 1)
+	}
+	if blockCount < 1 {
+		return &FeeHistoryResult{OldestBlock: (*hexutil.Big)(new(big.Int).SetUint64(last))}, nil
+	}
+	oldest := last + 1 - uint64(blockCount)
+
+	numbers := make([]rpc.BlockNumber, blockCount)
+	for i := range numbers {
+		numbers[i] = rpc.BlockNumber(oldest + uint64(i))
+	}
+	headers, err := b.HeaderByNumbers(ctx, numbers)
+	if err != nil {
+		return nil, err
+	}
+	result := &FeeHistoryResult{
+		OldestBlock:  (*hexutil.Big)(new(big.Int).SetUint64(oldest)),
+		BaseFee:      make([]*hexutil.Big, blockCount+1),
+		GasUsedRatio: make([]float64, blockCount),
+	}
+	if len(rewardPercentiles) > 0 {
+		result.Reward = make([][]*hexutil.Big, blockCount)
+	}
+	for i, header := range headers {
+		if header.GasLimit > 0 {
+			result.GasUsedRatio[i] = float64(header.GasUsed) / float64(header.GasLimit)
+		}
+		block, receipts, err := b.feeHistoryBlock(ctx, header)
+		if err != nil {
+			return nil, err
+		}
+		baseFee, rewards := blockFeeStats(block, receipts, rewardPercentiles)
+		result.BaseFee[i] = (*hexutil.Big)(baseFee)
+		if result.Reward != nil {
+			result.Reward[i] = rewards
+		}
+	}
+	result.BaseFee[blockCount] = result.BaseFee[blockCount-1]
+	return result, nil
+}
+
+// feeHistoryBlock retrieves the body and receipts of the block belonging to the
+// header, skipping the retrieval for blocks without transactions.
+func (b *LesApiBackend) feeHistoryBlock(ctx context.Context, header *types.Header) (*types.Block, types.Receipts, error) {
+	if header.TxHash == types.EmptyRootHash {
+		return types.NewBlockWithHeader(header), nil, nil
+	}
+	block, err := b.GetBlock(ctx, header.Hash())
+	if err != nil {
+		return nil, nil, err
+	}
+	receipts, err := b.GetReceipts(ctx, header.Hash())
+	if err != nil {
+		return nil, nil, err
+	}
+	return block, receipts, nil
+}
+
+// txGasAndPrice is the gas used and price paid by a single transaction.
+type txGasAndPrice struct {
+	gasUsed uint64
+	price   *big.Int
+}
+
+// blockFeeStats returns the base fee proxy of a block, the lowest gas price
+// paid, together with the gas prices at the given percentiles weighted by the
+// gas used of each transaction.
+func blockFeeStats(block *types.Block, receipts types.Receipts, percentiles []float64) (*big.Int, []*hexutil.Big) {
+	txs := block.Transactions()
+	if len(txs) == 0 || len(receipts) != len(txs) {
+		rewards := make([]*hexutil.Big, len(percentiles))
+		for i := range rewards {
+			rewards[i] = (*hexutil.Big)(new(big.Int))
+		}
+		return new(big.Int), rewards
+	}
+	sorted := make([]txGasAndPrice, len(txs))
+	for i, tx := range txs {
+		sorted[i] = txGasAndPrice{gasUsed: receipts[i].GasUsed, price: tx.GasPrice()}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].price.Cmp(sorted[j].price) < 0
+	})
+	rewards := make([]*hexutil.Big, len(percentiles))
+	if len(percentiles) > 0 {
+		var (
+			txIndex int
+			sumGas  = sorted[0].gasUsed
+		)
+		for i, p := range percentiles {
+			threshold := uint64(float64(block.GasUsed()) * p / 100)
+			for sumGas < threshold && txIndex < len(sorted)-1 {
+				txIndex++
+				sumGas += sorted[txIndex].gasUsed
+			}
+			rewards[i] = (*hexutil.Big)(sorted[txIndex].price)
+		}
+	}
+	return sorted[0].price, rewards
+}
@@ -0,0 +1,119 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/AbeyFoundation/go-abey/abeydb"
+	"github.com/AbeyFoundation/go-abey/common"
+	"github.com/AbeyFoundation/go-abey/core/rawdb"
+	"github.com/AbeyFoundation/go-abey/core/types"
+	"github.com/AbeyFoundation/go-abey/params"
+	"github.com/AbeyFoundation/go-abey/rpc"
+)
+
+// writeFeeHistoryChain stores a canonical chain on top of parent with the given
+// gas usage, where the last block contains transactions paying the given prices.
+func writeFeeHistoryChain(db abeydb.Database, parent *types.Header, gasUsed []uint64, prices []int64) []*types.Header {
+	var headers []*types.Header
+	for i, used := range gasUsed {
+		header := &types.Header{
+			ParentHash:  parent.Hash(),
+			Number:      new(big.Int).Add(parent.Number, common.Big1),
+			SnailNumber: new(big.Int),
+			Time:        new(big.Int).Add(parent.Time, big.NewInt(10)),
+			GasLimit:    8000000,
+			GasUsed:     used,
+			TxHash:      types.EmptyRootHash,
+			Extra:       []byte{},
+		}
+		var (
+			txs      types.Transactions
+			receipts types.Receipts
+		)
+		if i == len(gasUsed)-1 {
+			for j, price := range prices {
+				txs = append(txs, types.NewTransaction(uint64(j), common.Address{0x01}, big.NewInt(1), params.TxGas, big.NewInt(price), nil))
+
+				receipt := types.NewReceipt(nil, false, params.TxGas*uint64(j+1))
+				receipt.TxHash = txs[j].Hash()
+				receipt.GasUsed = params.TxGas
+				receipts = append(receipts, receipt)
+			}
+			header.TxHash = types.DeriveSha(txs)
+			header.GasUsed = params.TxGas * uint64(len(prices))
+		}
+		hash, number := header.Hash(), header.Number.Uint64()
+		rawdb.WriteHeader(db, header)
+		rawdb.WriteCanonicalHash(db, hash, number)
+		if len(txs) > 0 {
+			rawdb.WriteBody(db, hash, number, &types.Body{Transactions: txs})
+			rawdb.WriteReceipts(db, hash, number, receipts)
+		}
+		headers = append(headers, header)
+		parent = header
+	}
+	return headers
+}
+
+func TestLesApiBackendFeeHistory(t *testing.T) {
+	db := abeydb.NewMemDatabase()
+	backend := newTestApiBackend(db, nil)
+
+	prices := []int64{3 * params.GWei, 1 * params.GWei, 2 * params.GWei}
+	headers := writeFeeHistoryChain(db, backend.CurrentBlock().Header(), []uint64{0, 4000000, 8000000, 0}, prices)
+	last := headers[len(headers)-1].Number.Int64()
+
+	history, err := backend.FeeHistory(context.Background(), len(headers), rpc.BlockNumber(last), []float64{0, 50, 100})
+	if err != nil {
+		t.Fatalf("failed to retrieve fee history: %v", err)
+	}
+	if have, want := history.OldestBlock.ToInt().Int64(), headers[0].Number.Int64(); have != want {
+		t.Fatalf("oldest block mismatch: have %d, want %d", have, want)
+	}
+	if len(history.GasUsedRatio) != len(headers) || len(history.BaseFee) != len(headers)+1 || len(history.Reward) != len(headers) {
+		t.Fatalf("result shape mismatch: %d ratios, %d base fees, %d rewards", len(history.GasUsedRatio), len(history.BaseFee), len(history.Reward))
+	}
+	for i, ratio := range history.GasUsedRatio {
+		if ratio < 0 || ratio > 1 {
+			t.Errorf("block %d: gas used ratio out of range: %f", i, ratio)
+		}
+	}
+	if history.GasUsedRatio[1] != 0.5 || history.GasUsedRatio[2] != 1 {
+		t.Errorf("gas used ratio mismatch: have %v", history.GasUsedRatio)
+	}
+	// The last block pays 1G, 2G and 3G with equal gas each
+	newest := len(headers) - 1
+	if have := history.BaseFee[newest].ToInt(); have.Cmp(big.NewInt(params.GWei)) != 0 {
+		t.Errorf("base fee proxy mismatch: have %v, want %v", have, params.GWei)
+	}
+	for i, want := range []int64{1 * params.GWei, 2 * params.GWei, 3 * params.GWei} {
+		if have := history.Reward[newest][i].ToInt(); have.Int64() != want {
+			t.Errorf("reward %d mismatch: have %v, want %v", i, have, want)
+		}
+	}
+	if history.Reward[0][0].ToInt().Sign() != 0 {
+		t.Errorf("empty block reward mismatch: have %v, want 0", history.Reward[0][0])
+	}
+	if _, err := backend.FeeHistory(context.Background(), 2, rpc.BlockNumber(last), []float64{50, 10}); !errors.Is(err, errInvalidPercentile) {
+		t.Errorf("error mismatch: have %v, want %v", err, errInvalidPercentile)
+	}
+}
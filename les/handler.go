@@ -31,6 +31,7 @@ import (
 	"github.com/AbeyFoundation/go-abey/consensus"
 	"github.com/AbeyFoundation/go-abey/core"
 	"github.com/AbeyFoundation/go-abey/core/rawdb"
+	snaildb "github.com/AbeyFoundation/go-abey/core/snailchain/rawdb"
 	"github.com/AbeyFoundation/go-abey/core/state"
 	"github.com/AbeyFoundation/go-abey/core/types"
 	"github.com/AbeyFoundation/go-abey/event"
@@ -46,8 +47,9 @@ import (
 var errTooManyInvalidRequest = errors.New("too many invalid requests made")
 
 const (
-	softResponseLimit = 2 * 1024 * 1024 // Target maximum size of returned blocks, headers or node data.
-	estHeaderRlpSize  = 500             // Approximate size of an RLP encoded block header
+	softResponseLimit     = 2 * 1024 * 1024 // Target maximum size of returned blocks, headers or node data.
+	estHeaderRlpSize      = 500             // Approximate size of an RLP encoded block header
+	estBalanceInfoRlpSize = 64              // Approximate size of an RLP encoded account balance change
 
 	abeyVersion = 63 // equivalent abey version for the downloader
 
@@ -312,9 +314,10 @@ func (pm *ProtocolManager) handle(p *peer) error {
 }
 
 var (
-	reqList   = []uint64{GetBlockHeadersMsg, GetBlockBodiesMsg, GetCodeMsg, GetReceiptsMsg, GetProofsV1Msg, SendTxMsg, SendTxV2Msg, GetTxStatusMsg, GetHeaderProofsMsg, GetProofsV2Msg, GetHelperTrieProofsMsg}
+	reqList   = []uint64{GetBlockHeadersMsg, GetBlockBodiesMsg, GetCodeMsg, GetReceiptsMsg, GetProofsV1Msg, SendTxMsg, SendTxV2Msg, GetTxStatusMsg, GetHeaderProofsMsg, GetProofsV2Msg, GetHelperTrieProofsMsg, GetSnailHeadersMsg, GetBlockRewardsMsg, GetSnailBlocksMsg, GetBalanceChangesMsg}
 	reqListV1 = []uint64{GetBlockHeadersMsg, GetBlockBodiesMsg, GetCodeMsg, GetReceiptsMsg, GetProofsV1Msg, SendTxMsg, GetHeaderProofsMsg}
 	reqListV2 = []uint64{GetBlockHeadersMsg, GetBlockBodiesMsg, GetCodeMsg, GetReceiptsMsg, SendTxV2Msg, GetTxStatusMsg, GetProofsV2Msg, GetHelperTrieProofsMsg}
+	reqListV3 = []uint64{GetBlockHeadersMsg, GetBlockBodiesMsg, GetCodeMsg, GetReceiptsMsg, SendTxV2Msg, GetTxStatusMsg, GetProofsV2Msg, GetHelperTrieProofsMsg, GetSnailHeadersMsg, GetBlockRewardsMsg, GetSnailBlocksMsg, GetBalanceChangesMsg}
 )
 
 // handleMsg is invoked whenever an inbound message is received from a remote
@@ -1126,6 +1129,219 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		}
 
 		p.fcServer.GotReply(resp.ReqID, resp.BV)
+		deliverMsg = &Msg{
+			MsgType: MsgTxStatus,
+			ReqID:   resp.ReqID,
+			Obj:     resp.Status,
+		}
+
+	case GetSnailHeadersMsg:
+		p.Log().Trace("Received snail header request")
+		// Decode the retrieval message
+		var req struct {
+			ReqID uint64
+			Reqs  []SnailHeaderReq
+		}
+		if err := msg.Decode(&req); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Gather snail headers until the fetch or network limits is reached
+		var (
+			bytes   common.StorageSize
+			headers []*types.SnailHeader
+		)
+		reqCnt := len(req.Reqs)
+		if reject(uint64(reqCnt), MaxHeaderFetch) {
+			return errResp(ErrRequestRejected, "")
+		}
+		for _, req := range req.Reqs {
+			header := pm.getSnailHeader(req)
+			if header == nil || bytes >= softResponseLimit {
+				break
+			}
+			headers = append(headers, header)
+			bytes += estHeaderRlpSize
+		}
+		bv, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
+		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
+		return p.SendSnailHeaders(req.ReqID, bv, headers)
+
+	case SnailHeadersMsg:
+		if pm.odr == nil {
+			return errResp(ErrUnexpectedResponse, "")
+		}
+
+		p.Log().Trace("Received snail header response")
+		// A batch of snail headers arrived to one of our previous requests
+		var resp struct {
+			ReqID, BV uint64
+			Headers   []*types.SnailHeader
+		}
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.fcServer.GotReply(resp.ReqID, resp.BV)
+		deliverMsg = &Msg{
+			MsgType: MsgSnailHeaders,
+			ReqID:   resp.ReqID,
+			Obj:     resp.Headers,
+		}
+
+	case GetBlockRewardsMsg:
+		p.Log().Trace("Received block reward request")
+		// Decode the retrieval message
+		var req struct {
+			ReqID uint64
+			Reqs  []uint64
+		}
+		if err := msg.Decode(&req); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Gather the fast headers proving the rewards until the fetch or network limits is reached
+		var (
+			bytes   common.StorageSize
+			headers []*types.Header
+		)
+		reqCnt := len(req.Reqs)
+		if reject(uint64(reqCnt), MaxHeaderFetch) {
+			return errResp(ErrRequestRejected, "")
+		}
+		for _, number := range req.Reqs {
+			header := pm.getBlockRewardHeader(number)
+			if header == nil || bytes >= softResponseLimit {
+				break
+			}
+			headers = append(headers, header)
+			bytes += estHeaderRlpSize
+		}
+		bv, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
+		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
+		return p.SendBlockRewards(req.ReqID, bv, headers)
+
+	case BlockRewardsMsg:
+		if pm.odr == nil {
+			return errResp(ErrUnexpectedResponse, "")
+		}
+
+		p.Log().Trace("Received block reward response")
+		// A batch of reward headers arrived to one of our previous requests
+		var resp struct {
+			ReqID, BV uint64
+			Headers   []*types.Header
+		}
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.fcServer.GotReply(resp.ReqID, resp.BV)
+		deliverMsg = &Msg{
+			MsgType: MsgBlockRewards,
+			ReqID:   resp.ReqID,
+			Obj:     resp.Headers,
+		}
+
+	case GetSnailBlocksMsg:
+		p.Log().Trace("Received snail block request")
+		// Decode the retrieval message
+		var req struct {
+			ReqID  uint64
+			Hashes []common.Hash
+		}
+		if err := msg.Decode(&req); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Gather snail blocks until the fetch or network limits is reached
+		var (
+			bytes  common.StorageSize
+			blocks []*types.SnailBlock
+		)
+		reqCnt := len(req.Hashes)
+		if reject(uint64(reqCnt), MaxBodyFetch) {
+			return errResp(ErrRequestRejected, "")
+		}
+		for _, hash := range req.Hashes {
+			block := pm.getSnailBlock(hash)
+			if block == nil || bytes >= softResponseLimit {
+				break
+			}
+			blocks = append(blocks, block)
+			bytes += block.Size()
+		}
+		bv, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
+		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
+		return p.SendSnailBlocks(req.ReqID, bv, blocks)
+
+	case SnailBlocksMsg:
+		if pm.odr == nil {
+			return errResp(ErrUnexpectedResponse, "")
+		}
+
+		p.Log().Trace("Received snail block response")
+		// A batch of snail blocks arrived to one of our previous requests
+		var resp struct {
+			ReqID, BV uint64
+			Blocks    []*types.SnailBlock
+		}
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.fcServer.GotReply(resp.ReqID, resp.BV)
+		deliverMsg = &Msg{
+			MsgType: MsgSnailBlocks,
+			ReqID:   resp.ReqID,
+			Obj:     resp.Blocks,
+		}
+
+	case GetBalanceChangesMsg:
+		p.Log().Trace("Received balance change request")
+		// Decode the retrieval message
+		var req struct {
+			ReqID uint64
+			Reqs  []uint64
+		}
+		if err := msg.Decode(&req); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Gather the balance changes until the fetch or network limits is reached
+		var (
+			bytes    common.StorageSize
+			balances []*types.BlockBalance
+		)
+		reqCnt := len(req.Reqs)
+		if reject(uint64(reqCnt), MaxReceiptFetch) {
+			return errResp(ErrRequestRejected, "")
+		}
+		for _, number := range req.Reqs {
+			balance := pm.getBalanceChange(number)
+			if balance == nil || bytes >= softResponseLimit {
+				break
+			}
+			balances = append(balances, balance)
+			bytes += common.StorageSize(len(balance.Balance) * estBalanceInfoRlpSize)
+		}
+		bv, rcost := p.fcClient.RequestProcessed(costs.baseCost + uint64(reqCnt)*costs.reqCost)
+		pm.server.fcCostStats.update(msg.Code, uint64(reqCnt), rcost)
+		return p.SendBalanceChanges(req.ReqID, bv, balances)
+
+	case BalanceChangesMsg:
+		if pm.odr == nil {
+			return errResp(ErrUnexpectedResponse, "")
+		}
+
+		p.Log().Trace("Received balance change response")
+		// A batch of balance changes arrived to one of our previous requests
+		var resp struct {
+			ReqID, BV uint64
+			Balances  []*types.BlockBalance
+		}
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		p.fcServer.GotReply(resp.ReqID, resp.BV)
+		deliverMsg = &Msg{
+			MsgType: MsgBalanceChanges,
+			ReqID:   resp.ReqID,
+			Obj:     resp.Balances,
+		}
 
 	default:
 		p.Log().Trace("Received unknown message", "code", msg.Code)
@@ -1144,6 +1360,50 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 	return nil
 }
 
+// getSnailHeader retrieves the canonical snail header requested by a client
+// from the snail chain stored alongside the fast chain.
+func (pm *ProtocolManager) getSnailHeader(req SnailHeaderReq) *types.SnailHeader {
+	hash := snaildb.ReadCanonicalHash(pm.chainDb, req.Number)
+	if req.Latest {
+		hash = snaildb.ReadHeadBlockHash(pm.chainDb)
+	}
+	if hash == (common.Hash{}) {
+		return nil
+	}
+	number := snaildb.ReadHeaderNumber(pm.chainDb, hash)
+	if number == nil {
+		return nil
+	}
+	return snaildb.ReadHeader(pm.chainDb, hash, *number)
+}
+
+// getSnailBlock retrieves the snail block with the given hash together with its
+// fruits.
+func (pm *ProtocolManager) getSnailBlock(hash common.Hash) *types.SnailBlock {
+	number := snaildb.ReadHeaderNumber(pm.chainDb, hash)
+	if number == nil {
+		return nil
+	}
+	return snaildb.ReadBlock(pm.chainDb, hash, *number)
+}
+
+// balanceChangeReader is implemented by chains keeping the balance changes of
+// recent blocks in memory.
+type balanceChangeReader interface {
+	GetBalanceInfos(number uint64) *types.BlockBalance
+}
+
+// getBalanceChange retrieves the balance changes produced by the fast block with
+// the given number, from the chain's memory if available or the database.
+func (pm *ProtocolManager) getBalanceChange(number uint64) *types.BlockBalance {
+	if chain, ok := pm.blockchain.(balanceChangeReader); ok {
+		if balance := chain.GetBalanceInfos(number); balance != nil {
+			return balance
+		}
+	}
+	return rawdb.ReadBalanceInfo(pm.chainDb, number)
+}
+
 // getAccount retrieves an account from the state based at root.
 func (pm *ProtocolManager) getAccount(statedb *state.StateDB, root, hash common.Hash) (state.Account, error) {
 	trie, err := trie.New(root, statedb.Database().TrieDB())
@@ -1278,3 +1538,18 @@ func (d *downloaderPeerNotify) unregisterPeer(p *peer) {
 	pm := (*ProtocolManager)(d)
 	pm.downloader.UnregisterPeer(p.id)
 }
+
+// getBlockRewardHeader retrieves the canonical fast header that rewarded the
+// snail block with the given number. The reward record of the snail block is
+// derived from this header, so it serves as the proof of the reward.
+func (pm *ProtocolManager) getBlockRewardHeader(snailNumber uint64) *types.Header {
+	reward := rawdb.ReadBlockReward(pm.chainDb, snailNumber)
+	if reward == nil || reward.FastNumber == nil {
+		return nil
+	}
+	number := reward.FastNumber.Uint64()
+	if rawdb.ReadCanonicalHash(pm.chainDb, number) != reward.FastHash {
+		return nil
+	}
+	return rawdb.ReadHeader(pm.chainDb, reward.FastHash, number)
+}
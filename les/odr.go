@@ -88,6 +88,11 @@ const (
 	MsgProofsV2
 	MsgHeaderProofs
 	MsgHelperTrieProofs
+	MsgSnailHeaders
+	MsgBlockRewards
+	MsgTxStatus
+	MsgSnailBlocks
+	MsgBalanceChanges
 )
 
 // Msg encodes a LES message that delivers reply data for a request
@@ -0,0 +1,203 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"container/list"
+	"sync"
+)
+
+// odrCacheKind is the kind of the objects cached by an odrCache.
+type odrCacheKind uint8
+
+const (
+	cachedHeader   odrCacheKind = iota // Headers keyed by number
+	cachedReceipts                     // Block receipts keyed by block hash
+	cachedCode                         // Contract code keyed by code hash
+
+	odrCacheKinds
+)
+
+// odrCacheKey identifies a cached object.
+type odrCacheKey struct {
+	kind odrCacheKind
+	key  interface{}
+}
+
+// odrCacheEntry is a cached object along with its estimated memory size.
+type odrCacheEntry struct {
+	key   odrCacheKey
+	value interface{}
+	size  uint64
+}
+
+// odrCache is a least recently used cache of objects retrieved through ODR. All
+// kinds of objects share a memory budget, once their estimated total size goes
+// over it the least recently used objects are evicted whatever their kind. The
+// number of objects of a kind may be limited too.
+type odrCache struct {
+	budget uint64                        // Maximum total size of the cached objects
+	limits [odrCacheKinds]int            // Maximum number of cached objects per kind, zero if unlimited
+	size   uint64                        // Estimated total size of the cached objects
+	counts [odrCacheKinds]int            // Number of cached objects per kind
+	order  *list.List                    // Cached entries, the least recently used at the back
+	items  map[odrCacheKey]*list.Element // Elements of the cached entries by key
+	lock   sync.Mutex
+}
+
+// newODRCache creates a cache holding objects up to the given total size.
+func newODRCache(budget uint64) *odrCache {
+	return &odrCache{
+		budget: budget,
+		order:  list.New(),
+		items:  make(map[odrCacheKey]*list.Element),
+	}
+}
+
+// setLimit limits the number of cached objects of a kind, evicting the least
+// recently used ones beyond it. A zero limit removes it.
+func (c *odrCache) setLimit(kind odrCacheKind, limit int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.limits[kind] = limit
+	c.evictKind(kind)
+}
+
+// setBudget sets the maximum total size of the cached objects, evicting the
+// least recently used ones beyond it.
+func (c *odrCache) setBudget(budget uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.budget = budget
+	c.shrink(budget)
+}
+
+// get returns a cached object, marking it the most recently used.
+func (c *odrCache) get(kind odrCacheKind, key interface{}) (interface{}, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	elem, ok := c.items[odrCacheKey{kind, key}]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*odrCacheEntry).value, true
+}
+
+// peek returns a cached object without marking it used.
+func (c *odrCache) peek(kind odrCacheKind, key interface{}) (interface{}, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	elem, ok := c.items[odrCacheKey{kind, key}]
+	if !ok {
+		return nil, false
+	}
+	return elem.Value.(*odrCacheEntry).value, true
+}
+
+// add caches an object of the given estimated size as the most recently used,
+// evicting the least recently used ones beyond the budget and the limit of its
+// kind. Objects larger than the whole budget are not cached.
+func (c *odrCache) add(kind odrCacheKind, key interface{}, value interface{}, size uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	id := odrCacheKey{kind, key}
+	if elem, ok := c.items[id]; ok {
+		c.remove(elem)
+	}
+	if size > c.budget {
+		return
+	}
+	c.items[id] = c.order.PushFront(&odrCacheEntry{key: id, value: value, size: size})
+	c.size += size
+	c.counts[kind]++
+
+	c.evictKind(kind)
+	c.shrink(c.budget)
+}
+
+// purge drops all the cached objects of a kind.
+func (c *odrCache) purge(kind odrCacheKind) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for elem := c.order.Back(); elem != nil; {
+		prev := elem.Prev()
+		if elem.Value.(*odrCacheEntry).key.kind == kind {
+			c.remove(elem)
+		}
+		elem = prev
+	}
+}
+
+// trim evicts the least recently used objects until the cached ones take at
+// most target bytes, returning the number of bytes freed.
+func (c *odrCache) trim(target uint64) uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.shrink(target)
+}
+
+// shrink evicts the least recently used objects until the cached ones take at
+// most target bytes, returning the number of bytes freed. The lock must be held.
+func (c *odrCache) shrink(target uint64) uint64 {
+	var freed uint64
+	for c.size > target {
+		elem := c.order.Back()
+		freed += elem.Value.(*odrCacheEntry).size
+		c.remove(elem)
+	}
+	return freed
+}
+
+// evictKind evicts the least recently used objects of a kind beyond its limit.
+// The lock must be held.
+func (c *odrCache) evictKind(kind odrCacheKind) {
+	if c.limits[kind] == 0 {
+		return
+	}
+	for elem := c.order.Back(); elem != nil && c.counts[kind] > c.limits[kind]; {
+		prev := elem.Prev()
+		if elem.Value.(*odrCacheEntry).key.kind == kind {
+			c.remove(elem)
+		}
+		elem = prev
+	}
+}
+
+// remove drops a cached entry. The lock must be held.
+func (c *odrCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*odrCacheEntry)
+	delete(c.items, entry.key)
+	c.size -= entry.size
+	c.counts[entry.key.kind]--
+}
+
+// stats returns the number of cached objects of a kind and the estimated total
+// size of all the cached objects.
+func (c *odrCache) stats(kind odrCacheKind) (count int, size uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.counts[kind], c.size
+}
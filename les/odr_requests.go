@@ -33,16 +33,22 @@ import (
 )
 
 var (
-	errInvalidMessageType  = errors.New("invalid message type")
-	errInvalidEntryCount   = errors.New("invalid number of response entries")
-	errHeaderUnavailable   = errors.New("header unavailable")
-	errTxHashMismatch      = errors.New("transaction hash mismatch")
-	errUncleHashMismatch   = errors.New("uncle hash mismatch")
-	errReceiptHashMismatch = errors.New("receipt hash mismatch")
-	errDataHashMismatch    = errors.New("data hash mismatch")
-	errCHTHashMismatch     = errors.New("cht hash mismatch")
-	errCHTNumberMismatch   = errors.New("cht number mismatch")
-	errUselessNodes        = errors.New("useless nodes in merkle proof nodeset")
+	errInvalidMessageType    = errors.New("invalid message type")
+	errInvalidEntryCount     = errors.New("invalid number of response entries")
+	errHeaderUnavailable     = errors.New("header unavailable")
+	errTxHashMismatch        = errors.New("transaction hash mismatch")
+	errUncleHashMismatch     = errors.New("uncle hash mismatch")
+	errReceiptHashMismatch   = errors.New("receipt hash mismatch")
+	errDataHashMismatch      = errors.New("data hash mismatch")
+	errCHTHashMismatch       = errors.New("cht hash mismatch")
+	errCHTNumberMismatch     = errors.New("cht number mismatch")
+	errSnailNumberMismatch   = errors.New("snail header number mismatch")
+	errSnailDifficultyTooLow = errors.New("snail header difficulty too low")
+	errRewardNumberMismatch  = errors.New("reward snail number mismatch")
+	errSnailHashMismatch     = errors.New("snail block hash mismatch")
+	errFruitsHashMismatch    = errors.New("fruits hash mismatch")
+	errSignHashMismatch      = errors.New("fruit sign hash mismatch")
+	errUselessNodes          = errors.New("useless nodes in merkle proof nodeset")
 )
 
 type LesOdrRequest interface {
@@ -66,6 +72,16 @@ func LesRequest(req light.OdrRequest) LesOdrRequest {
 		return (*ChtRequest)(r)
 	case *light.BloomRequest:
 		return (*BloomRequest)(r)
+	case *light.SnailHeaderRequest:
+		return (*SnailHeaderRequest)(r)
+	case *light.BlockRewardRequest:
+		return (*BlockRewardRequest)(r)
+	case *light.TxStatusRequest:
+		return (*TxStatusRequest)(r)
+	case *light.SnailBlockRequest:
+		return (*SnailBlockRequest)(r)
+	case *light.BalanceChangeRequest:
+		return (*BalanceChangeRequest)(r)
 	default:
 		return nil
 	}
@@ -188,7 +204,7 @@ func (r *TrieRequest) GetCost(peer *peer) uint64 {
 	switch peer.version {
 	case lpv1:
 		return peer.GetRequestCost(GetProofsV1Msg, 1)
-	case lpv2:
+	case lpv2, lpv3:
 		return peer.GetRequestCost(GetProofsV2Msg, 1)
 	default:
 		panic(nil)
@@ -304,6 +320,257 @@ func (r *CodeRequest) Validate(db abeydb.Database, msg *Msg) error {
 	return nil
 }
 
+// SnailHeaderReq is the LES request for a single snail chain header
+type SnailHeaderReq struct {
+	Number uint64
+	Latest bool
+}
+
+// ODR request type for snail chain headers, see LesOdrRequest interface
+type SnailHeaderRequest light.SnailHeaderRequest
+
+// GetCost returns the cost of the given ODR request according to the serving
+// peer's cost table (implementation of LesOdrRequest)
+func (r *SnailHeaderRequest) GetCost(peer *peer) uint64 {
+	return peer.GetRequestCost(GetSnailHeadersMsg, 1)
+}
+
+// CanSend tells if a certain peer is suitable for serving the given request
+func (r *SnailHeaderRequest) CanSend(peer *peer) bool {
+	return peer.version >= lpv3 && peer.HasRequestCost(GetSnailHeadersMsg)
+}
+
+// Request sends an ODR request to the LES network (implementation of LesOdrRequest)
+func (r *SnailHeaderRequest) Request(reqID uint64, peer *peer) error {
+	peer.Log().Debug("Requesting snail header", "number", r.Number, "latest", r.Latest)
+	return peer.RequestSnailHeaders(reqID, r.GetCost(peer), []SnailHeaderReq{{Number: r.Number, Latest: r.Latest}})
+}
+
+// Valid processes an ODR request reply message from the LES network
+// returns true and stores results in memory if the message was a valid reply
+// to the request (implementation of LesOdrRequest)
+func (r *SnailHeaderRequest) Validate(db abeydb.Database, msg *Msg) error {
+	log.Debug("Validating snail header", "number", r.Number, "latest", r.Latest)
+
+	// Ensure we have a correct message with a single snail header
+	if msg.MsgType != MsgSnailHeaders {
+		return errInvalidMessageType
+	}
+	reply := msg.Obj.([]*types.SnailHeader)
+	if len(reply) != 1 {
+		return errInvalidEntryCount
+	}
+	header := reply[0]
+	if header == nil || header.Number == nil || header.Difficulty == nil || header.FruitDifficulty == nil {
+		return errHeaderUnavailable
+	}
+	if !r.Latest && header.Number.Uint64() != r.Number {
+		return errSnailNumberMismatch
+	}
+	// Ensure the header is sealed with at least the minimum work of a snail block
+	if header.Difficulty.Cmp(r.Config.Minerva.MinimumDifficulty) < 0 {
+		return errSnailDifficultyTooLow
+	}
+	if err := r.Engine.VerifySnailSeal(nil, header, false); err != nil {
+		return err
+	}
+	r.Header = header
+	return nil
+}
+
+// ODR request type for snail block rewards, see LesOdrRequest interface
+type BlockRewardRequest light.BlockRewardRequest
+
+// GetCost returns the cost of the given ODR request according to the serving
+// peer's cost table (implementation of LesOdrRequest)
+func (r *BlockRewardRequest) GetCost(peer *peer) uint64 {
+	return peer.GetRequestCost(GetBlockRewardsMsg, 1)
+}
+
+// CanSend tells if a certain peer is suitable for serving the given request
+func (r *BlockRewardRequest) CanSend(peer *peer) bool {
+	return peer.version >= lpv3 && peer.HasRequestCost(GetBlockRewardsMsg)
+}
+
+// Request sends an ODR request to the LES network (implementation of LesOdrRequest)
+func (r *BlockRewardRequest) Request(reqID uint64, peer *peer) error {
+	peer.Log().Debug("Requesting block reward", "snail", r.SnailNumber)
+	return peer.RequestBlockRewards(reqID, r.GetCost(peer), []uint64{r.SnailNumber})
+}
+
+// Valid processes an ODR request reply message from the LES network
+// returns true and stores results in memory if the message was a valid reply
+// to the request (implementation of LesOdrRequest)
+func (r *BlockRewardRequest) Validate(db abeydb.Database, msg *Msg) error {
+	log.Debug("Validating block reward", "snail", r.SnailNumber)
+
+	// Ensure we have a correct message with a single fast header
+	if msg.MsgType != MsgBlockRewards {
+		return errInvalidMessageType
+	}
+	reply := msg.Obj.([]*types.Header)
+	if len(reply) != 1 {
+		return errInvalidEntryCount
+	}
+	header := reply[0]
+	if header == nil || header.Number == nil || header.SnailNumber == nil {
+		return errHeaderUnavailable
+	}
+	if header.SnailNumber.Uint64() != r.SnailNumber {
+		return errRewardNumberMismatch
+	}
+	r.Header = header
+	return nil
+}
+
+// ODR request type for transaction status, see LesOdrRequest interface
+type TxStatusRequest light.TxStatusRequest
+
+// GetCost returns the cost of the given ODR request according to the serving
+// peer's cost table (implementation of LesOdrRequest)
+func (r *TxStatusRequest) GetCost(peer *peer) uint64 {
+	return peer.GetRequestCost(GetTxStatusMsg, len(r.Hashes))
+}
+
+// CanSend tells if a certain peer is suitable for serving the given request
+func (r *TxStatusRequest) CanSend(peer *peer) bool {
+	return peer.version >= lpv2 && peer.HasRequestCost(GetTxStatusMsg)
+}
+
+// Request sends an ODR request to the LES network (implementation of LesOdrRequest)
+func (r *TxStatusRequest) Request(reqID uint64, peer *peer) error {
+	peer.Log().Debug("Requesting transaction status", "count", len(r.Hashes))
+	return peer.RequestTxStatus(reqID, r.GetCost(peer), r.Hashes)
+}
+
+// Valid processes an ODR request reply message from the LES network
+// returns true and stores results in memory if the message was a valid reply
+// to the request (implementation of LesOdrRequest)
+func (r *TxStatusRequest) Validate(db abeydb.Database, msg *Msg) error {
+	log.Debug("Validating transaction status", "count", len(r.Hashes))
+
+	// Ensure we have a correct message with a status for every transaction
+	if msg.MsgType != MsgTxStatus {
+		return errInvalidMessageType
+	}
+	reply := msg.Obj.([]txStatus)
+	if len(reply) != len(r.Hashes) {
+		return errInvalidEntryCount
+	}
+	r.Status = make([]light.TxStatus, len(reply))
+	for i, status := range reply {
+		r.Status[i] = light.TxStatus{Status: status.Status, Lookup: status.Lookup, Error: status.Error}
+	}
+	return nil
+}
+
+// ODR request type for snail blocks with their fruits, see LesOdrRequest interface
+type SnailBlockRequest light.SnailBlockRequest
+
+// GetCost returns the cost of the given ODR request according to the serving
+// peer's cost table (implementation of LesOdrRequest)
+func (r *SnailBlockRequest) GetCost(peer *peer) uint64 {
+	return peer.GetRequestCost(GetSnailBlocksMsg, 1)
+}
+
+// CanSend tells if a certain peer is suitable for serving the given request
+func (r *SnailBlockRequest) CanSend(peer *peer) bool {
+	return peer.version >= lpv3 && peer.HasRequestCost(GetSnailBlocksMsg)
+}
+
+// Request sends an ODR request to the LES network (implementation of LesOdrRequest)
+func (r *SnailBlockRequest) Request(reqID uint64, peer *peer) error {
+	peer.Log().Debug("Requesting snail block", "number", r.Number, "hash", r.Hash)
+	return peer.RequestSnailBlocks(reqID, r.GetCost(peer), []common.Hash{r.Hash})
+}
+
+// Valid processes an ODR request reply message from the LES network
+// returns true and stores results in memory if the message was a valid reply
+// to the request (implementation of LesOdrRequest)
+func (r *SnailBlockRequest) Validate(db abeydb.Database, msg *Msg) error {
+	log.Debug("Validating snail block", "number", r.Number, "hash", r.Hash)
+
+	// Ensure we have a correct message with a single snail block
+	if msg.MsgType != MsgSnailBlocks {
+		return errInvalidMessageType
+	}
+	reply := msg.Obj.([]*types.SnailBlock)
+	if len(reply) != 1 {
+		return errInvalidEntryCount
+	}
+	block := reply[0]
+	if block == nil || block.Header() == nil {
+		return errHeaderUnavailable
+	}
+	if block.Hash() != r.Hash {
+		return errSnailHashMismatch
+	}
+	// Depending on the fork, the fruits hash covers either the full fruits or
+	// only their headers, which in turn cover the fruit signs
+	fruits := block.Fruits()
+	headers := make([]*types.SnailHeader, len(fruits))
+	for i, fruit := range fruits {
+		if types.CalcSignHash(fruit.Signs()) != fruit.Header().SignHash {
+			return errSignHashMismatch
+		}
+		headers[i] = fruit.Header()
+	}
+	root := block.FruitsHash()
+	if root != types.DeriveSha(types.Fruits(fruits)) && root != types.DeriveSha(types.FruitsHeaders(headers)) {
+		return errFruitsHashMismatch
+	}
+	r.Block = block
+	return nil
+}
+
+// ODR request type for the balance changes of a fast block, see LesOdrRequest interface
+type BalanceChangeRequest light.BalanceChangeRequest
+
+// GetCost returns the cost of the given ODR request according to the serving
+// peer's cost table (implementation of LesOdrRequest)
+func (r *BalanceChangeRequest) GetCost(peer *peer) uint64 {
+	return peer.GetRequestCost(GetBalanceChangesMsg, 1)
+}
+
+// CanSend tells if a certain peer is suitable for serving the given request
+func (r *BalanceChangeRequest) CanSend(peer *peer) bool {
+	return peer.version >= lpv3 && peer.HasRequestCost(GetBalanceChangesMsg)
+}
+
+// Request sends an ODR request to the LES network (implementation of LesOdrRequest)
+func (r *BalanceChangeRequest) Request(reqID uint64, peer *peer) error {
+	peer.Log().Debug("Requesting balance changes", "number", r.Number)
+	return peer.RequestBalanceChanges(reqID, r.GetCost(peer), []uint64{r.Number})
+}
+
+// Valid processes an ODR request reply message from the LES network
+// returns true and stores results in memory if the message was a valid reply
+// to the request (implementation of LesOdrRequest). The entries are proven
+// against the state of the block by light.GetBalanceChange, their completeness
+// can't be.
+func (r *BalanceChangeRequest) Validate(db abeydb.Database, msg *Msg) error {
+	log.Debug("Validating balance changes", "number", r.Number)
+
+	// Ensure we have a correct message with a single balance change set
+	if msg.MsgType != MsgBalanceChanges {
+		return errInvalidMessageType
+	}
+	reply := msg.Obj.([]*types.BlockBalance)
+	if len(reply) != 1 {
+		return errInvalidEntryCount
+	}
+	if reply[0] == nil {
+		return errInvalidEntryCount
+	}
+	for _, info := range reply[0].Balance {
+		if info == nil || info.Valid == nil || info.Lock == nil {
+			return errInvalidEntryCount
+		}
+	}
+	r.Balance = reply[0]
+	return nil
+}
+
 const (
 	// helper trie type constants
 	htCanonical = iota // Canonical hash trie
@@ -348,7 +615,7 @@ func (r *ChtRequest) GetCost(peer *peer) uint64 {
 	switch peer.version {
 	case lpv1:
 		return peer.GetRequestCost(GetHeaderProofsMsg, 1)
-	case lpv2:
+	case lpv2, lpv3:
 		return peer.GetRequestCost(GetHelperTrieProofsMsg, 1)
 	default:
 		panic(nil)
@@ -384,7 +651,7 @@ func (r *ChtRequest) Request(reqID uint64, peer *peer) error {
 		// convert HelperTrie request to old CHT request
 		reqsV1 = ChtReq{ChtNum: (req.TrieIdx + 1) * (r.Config.ChtSize / r.Config.PairChtSize), BlockNum: blockNum, FromLevel: req.FromLevel}
 		return peer.RequestHelperTrieProofs(reqID, r.GetCost(peer), []ChtReq{reqsV1})
-	case lpv2:
+	case lpv2, lpv3:
 		return peer.RequestHelperTrieProofs(reqID, r.GetCost(peer), []HelperTrieReq{req})
 	default:
 		panic(nil)
@@ -163,6 +163,15 @@ func sendResponse(w p2p.MsgWriter, msgcode, reqID, bv uint64, data interface{})
 	return p2p.Send(w, msgcode, resp{reqID, bv, data})
 }
 
+// HasRequestCost returns whether the server announced a cost for the given
+// message code, i.e. whether it is able to serve that type of request.
+func (p *peer) HasRequestCost(msgcode uint64) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.fcCosts[msgcode] != nil
+}
+
 func (p *peer) GetRequestCost(msgcode uint64, amount int) uint64 {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
@@ -186,7 +195,7 @@ func (p *peer) GetTxRelayCost(amount, size int) uint64 {
 	switch p.version {
 	case lpv1:
 		msgcode = SendTxMsg
-	case lpv2:
+	case lpv2, lpv3:
 		msgcode = SendTxV2Msg
 	default:
 		panic(nil)
@@ -268,6 +277,29 @@ func (p *peer) SendHelperTrieProofs(reqID, bv uint64, resp HelperTrieResps) erro
 	return sendResponse(p.rw, HelperTrieProofsMsg, reqID, bv, resp)
 }
 
+// SendBlockRewards sends a batch of fast headers proving the rewards of the
+// requested snail blocks.
+func (p *peer) SendBlockRewards(reqID, bv uint64, headers []*types.Header) error {
+	return sendResponse(p.rw, BlockRewardsMsg, reqID, bv, headers)
+}
+
+// SendSnailBlocks sends a batch of snail blocks with their fruits, corresponding
+// to the ones requested.
+func (p *peer) SendSnailBlocks(reqID, bv uint64, blocks []*types.SnailBlock) error {
+	return sendResponse(p.rw, SnailBlocksMsg, reqID, bv, blocks)
+}
+
+// SendBalanceChanges sends a batch of fast block balance changes, corresponding
+// to the ones requested.
+func (p *peer) SendBalanceChanges(reqID, bv uint64, balances []*types.BlockBalance) error {
+	return sendResponse(p.rw, BalanceChangesMsg, reqID, bv, balances)
+}
+
+// SendSnailHeaders sends a batch of snail chain headers, corresponding to the ones requested.
+func (p *peer) SendSnailHeaders(reqID, bv uint64, headers []*types.SnailHeader) error {
+	return sendResponse(p.rw, SnailHeadersMsg, reqID, bv, headers)
+}
+
 // SendTxStatus sends a batch of transaction status records, corresponding to the ones requested.
 func (p *peer) SendTxStatus(reqID, bv uint64, stats []txStatus) error {
 	return sendResponse(p.rw, TxStatusMsg, reqID, bv, stats)
@@ -313,7 +345,7 @@ func (p *peer) RequestProofs(reqID, cost uint64, reqs []ProofReq) error {
 	switch p.version {
 	case lpv1:
 		return sendRequest(p.rw, GetProofsV1Msg, reqID, cost, reqs)
-	case lpv2:
+	case lpv2, lpv3:
 		return sendRequest(p.rw, GetProofsV2Msg, reqID, cost, reqs)
 	default:
 		panic(nil)
@@ -330,7 +362,7 @@ func (p *peer) RequestHelperTrieProofs(reqID, cost uint64, data interface{}) err
 		}
 		p.Log().Debug("Fetching batch of header proofs", "count", len(reqs))
 		return sendRequest(p.rw, GetHeaderProofsMsg, reqID, cost, reqs)
-	case lpv2:
+	case lpv2, lpv3:
 		reqs, ok := data.([]HelperTrieReq)
 		if !ok {
 			return errInvalidHelpTrieReq
@@ -342,6 +374,33 @@ func (p *peer) RequestHelperTrieProofs(reqID, cost uint64, data interface{}) err
 	}
 }
 
+// RequestBlockRewards fetches the fast headers rewarding a batch of snail blocks
+// from a remote node.
+func (p *peer) RequestBlockRewards(reqID, cost uint64, snailNumbers []uint64) error {
+	p.Log().Debug("Fetching batch of block rewards", "count", len(snailNumbers))
+	return sendRequest(p.rw, GetBlockRewardsMsg, reqID, cost, snailNumbers)
+}
+
+// RequestSnailBlocks fetches a batch of snail blocks with their fruits from a
+// remote node.
+func (p *peer) RequestSnailBlocks(reqID, cost uint64, hashes []common.Hash) error {
+	p.Log().Debug("Fetching batch of snail blocks", "count", len(hashes))
+	return sendRequest(p.rw, GetSnailBlocksMsg, reqID, cost, hashes)
+}
+
+// RequestBalanceChanges fetches the balance changes of a batch of fast blocks
+// from a remote node.
+func (p *peer) RequestBalanceChanges(reqID, cost uint64, numbers []uint64) error {
+	p.Log().Debug("Fetching batch of balance changes", "count", len(numbers))
+	return sendRequest(p.rw, GetBalanceChangesMsg, reqID, cost, numbers)
+}
+
+// RequestSnailHeaders fetches a batch of snail chain headers from a remote node.
+func (p *peer) RequestSnailHeaders(reqID, cost uint64, reqs []SnailHeaderReq) error {
+	p.Log().Debug("Fetching batch of snail headers", "count", len(reqs))
+	return sendRequest(p.rw, GetSnailHeadersMsg, reqID, cost, reqs)
+}
+
 // RequestTxStatus fetches a batch of transaction status records from a remote node.
 func (p *peer) RequestTxStatus(reqID, cost uint64, txHashes []common.Hash) error {
 	p.Log().Debug("Requesting transaction status", "count", len(txHashes))
@@ -354,7 +413,7 @@ func (p *peer) SendTxs(reqID, cost uint64, txs rlp.RawValue) error {
 	switch p.version {
 	case lpv1:
 		return p2p.Send(p.rw, SendTxMsg, txs) // old message format does not include reqID
-	case lpv2:
+	case lpv2, lpv3:
 		return sendRequest(p.rw, SendTxV2Msg, reqID, cost, txs)
 	default:
 		panic(nil)
@@ -532,6 +591,8 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 			checkList = reqListV1
 		case lpv2:
 			checkList = reqListV2
+		case lpv3:
+			checkList = reqListV3
 		default:
 			panic(nil)
 		}
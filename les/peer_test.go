@@ -0,0 +1,105 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/AbeyFoundation/go-abey/common"
+	"github.com/AbeyFoundation/go-abey/p2p"
+	"github.com/AbeyFoundation/go-abey/p2p/enode"
+)
+
+// serverStatus returns the handshake of a server speaking the given version and
+// announcing the costs of the given requests only.
+func serverStatus(version uint64, genesis common.Hash, codes []uint64) keyValueList {
+	costs := make(RequestCostList, len(codes))
+	for i, code := range codes {
+		costs[i].MsgCode, costs[i].BaseCost, costs[i].ReqCost = code, 1, 1
+	}
+	var list keyValueList
+	list = list.add("protocolVersion", version)
+	list = list.add("networkId", uint64(NetworkId))
+	list = list.add("headTd", big.NewInt(1))
+	list = list.add("headHash", common.Hash{})
+	list = list.add("headNum", uint64(0))
+	list = list.add("genesisHash", genesis)
+	list = list.add("serveHeaders", nil)
+	list = list.add("serveChainSince", uint64(0))
+	list = list.add("serveStateSince", uint64(0))
+	list = list.add("txRelay", nil)
+	list = list.add("flowControl/BL", uint64(300000000))
+	list = list.add("flowControl/MRR", uint64(50000))
+	list = list.add("flowControl/MRC", costs)
+	return list
+}
+
+// clientHandshake runs the handshake of a client speaking the given version with
+// a server answering the given status.
+func clientHandshake(version int, genesis common.Hash, status keyValueList) (*peer, error) {
+	app, net := p2p.MsgPipe()
+	defer app.Close()
+
+	go func() {
+		if msg, err := net.ReadMsg(); err == nil {
+			msg.Discard()
+			p2p.Send(net, StatusMsg, status)
+		}
+	}()
+	p := newPeer(version, NetworkId, p2p.NewPeer(enode.ID{0x01}, "server", nil), app)
+	return p, p.Handshake(big.NewInt(1), common.Hash{}, 0, genesis, nil)
+}
+
+// Tests that clients negotiating les/2 keep working with servers predating les/3,
+// which advertise the costs of the les/2 requests only, while les/3 clients
+// require the costs of the les/3 requests.
+func TestHandshakeLes2Server(t *testing.T) {
+	genesis := common.Hash{0x01}
+	les2Codes := append(append([]uint64{}, reqListV1...), reqListV2...)
+
+	// Both versions are looked up on the les/2 topic of the old servers
+	if AdvertiseProtocolVersions[0] != lpv2 {
+		t.Fatalf("advertised version mismatch: have %d, want %d", AdvertiseProtocolVersions[0], lpv2)
+	}
+	p, err := clientHandshake(lpv2, genesis, serverStatus(lpv2, genesis, les2Codes))
+	if err != nil {
+		t.Fatalf("les/2 handshake failed: %v", err)
+	}
+	// No les/3 request must be sent to a les/2 server
+	if (&SnailHeaderRequest{}).CanSend(p) || (&BlockRewardRequest{}).CanSend(p) ||
+		(&SnailBlockRequest{}).CanSend(p) || (&BalanceChangeRequest{}).CanSend(p) {
+		t.Fatalf("les/3 request sendable to les/2 server")
+	}
+	if !(&TxStatusRequest{}).CanSend(p) {
+		t.Fatalf("les/2 request not sendable to les/2 server")
+	}
+	// Servers speaking les/3 announce the costs of all requests to les/2 clients
+	if _, err := clientHandshake(lpv2, genesis, serverStatus(lpv2, genesis, reqList)); err != nil {
+		t.Fatalf("les/2 handshake with les/3 server failed: %v", err)
+	}
+	if _, err := clientHandshake(lpv3, genesis, serverStatus(lpv3, genesis, les2Codes)); err == nil {
+		t.Fatalf("les/3 handshake accepted without les/3 request costs")
+	}
+	p, err = clientHandshake(lpv3, genesis, serverStatus(lpv3, genesis, reqList))
+	if err != nil {
+		t.Fatalf("les/3 handshake failed: %v", err)
+	}
+	if !(&SnailHeaderRequest{}).CanSend(p) {
+		t.Fatalf("les/3 request not sendable to les/3 server")
+	}
+}
@@ -36,17 +36,19 @@ import (
 const (
 	lpv1 = 1
 	lpv2 = 2
+	lpv3 = 3
 )
 
-// Supported versions of the les protocol (first is primary)
+// Supported versions of the les protocol (first is primary). Servers keep being
+// advertised under les/2, where clients of both versions look them up.
 var (
-	ClientProtocolVersions    = []uint{lpv2, lpv1}
-	ServerProtocolVersions    = []uint{lpv2, lpv1}
+	ClientProtocolVersions    = []uint{lpv3, lpv2, lpv1}
+	ServerProtocolVersions    = []uint{lpv3, lpv2, lpv1}
 	AdvertiseProtocolVersions = []uint{lpv2} // clients are searching for the first advertised protocol in the list
 )
 
 // Number of implemented message corresponding to different protocol versions.
-var ProtocolLengths = map[uint]uint64{lpv1: 15, lpv2: 22}
+var ProtocolLengths = map[uint]uint64{lpv1: 15, lpv2: 22, lpv3: 30}
 
 const (
 	NetworkId          = 1
@@ -79,6 +81,15 @@ const (
 	SendTxV2Msg            = 0x13
 	GetTxStatusMsg         = 0x14
 	TxStatusMsg            = 0x15
+	// Protocol messages belonging to LPV3
+	GetSnailHeadersMsg   = 0x16
+	SnailHeadersMsg      = 0x17
+	GetBlockRewardsMsg   = 0x18
+	BlockRewardsMsg      = 0x19
+	GetSnailBlocksMsg    = 0x1a
+	SnailBlocksMsg       = 0x1b
+	GetBalanceChangesMsg = 0x1c
+	BalanceChangesMsg    = 0x1d
 )
 
 type errCode int
@@ -26,8 +26,11 @@ import (
 
 	"github.com/AbeyFoundation/go-abey/abeydb"
 	"github.com/AbeyFoundation/go-abey/common"
+	"github.com/AbeyFoundation/go-abey/consensus"
 	"github.com/AbeyFoundation/go-abey/core/rawdb"
+	snaildb "github.com/AbeyFoundation/go-abey/core/snailchain/rawdb"
 	"github.com/AbeyFoundation/go-abey/core/types"
+	"github.com/AbeyFoundation/go-abey/params"
 )
 
 // NoOdr is the default context passed to an ODR capable function when the ODR
@@ -134,6 +137,81 @@ func (req *ReceiptsRequest) StoreResult(db abeydb.Database) {
 	rawdb.WriteReceipts(db, req.Hash, req.Number, req.Receipts)
 }
 
+// SnailHeaderRequest is the ODR request type for retrieving snail chain headers
+type SnailHeaderRequest struct {
+	OdrRequest
+	Config *params.ChainConfig // Chain configuration bounding the header difficulty
+	Engine consensus.Engine    // Consensus engine verifying the header seal
+	Number uint64              // Snail block number, ignored if Latest is set
+	Latest bool                // Retrieve the current snail head of the serving peer
+	Header *types.SnailHeader
+}
+
+// StoreResult stores the retrieved data in local database. A valid seal does not
+// prove the header canonical, so it is only stored by GetSnailHeaderByNumber once
+// the reward of its block links it to the canonical fast chain.
+func (req *SnailHeaderRequest) StoreResult(db abeydb.Database) {}
+
+// BlockRewardRequest is the ODR request type for retrieving the reward of a
+// snail block, proven by the fast header that rewarded it
+type BlockRewardRequest struct {
+	OdrRequest
+	SnailNumber uint64
+	Header      *types.Header
+}
+
+// StoreResult stores the retrieved data in local database. The reply alone does
+// not prove the header canonical, so the reward is only stored by GetBlockReward
+// once the header is checked against the canonical chain.
+func (req *BlockRewardRequest) StoreResult(db abeydb.Database) {}
+
+// TxStatus describes the status of a transaction as reported by a serving peer,
+// along with its position in the chain once included.
+type TxStatus struct {
+	Status core.TxStatus
+	Lookup *rawdb.TxLookupEntry `rlp:"nil"`
+	Error  string
+}
+
+// TxStatusRequest is the ODR request type for retrieving transaction status
+type TxStatusRequest struct {
+	OdrRequest
+	Hashes []common.Hash
+	Status []TxStatus
+}
+
+// StoreResult stores the retrieved data in local database. The status reported
+// by a peer is not proven, so nothing is stored until the transaction is found
+// in the verified body of the block it points to.
+func (req *TxStatusRequest) StoreResult(db abeydb.Database) {}
+
+// SnailBlockRequest is the ODR request type for retrieving a snail block of a
+// known hash together with its fruits
+type SnailBlockRequest struct {
+	OdrRequest
+	Hash   common.Hash
+	Number uint64
+	Block  *types.SnailBlock
+}
+
+// StoreResult stores the retrieved data in local database
+func (req *SnailBlockRequest) StoreResult(db abeydb.Database) {
+	snaildb.WriteBody(db, req.Hash, req.Number, req.Block.Body())
+	snaildb.WriteHeader(db, req.Block.Header())
+}
+
+// BalanceChangeRequest is the ODR request type for retrieving the balance
+// changes produced by a fast block
+type BalanceChangeRequest struct {
+	OdrRequest
+	Number  uint64
+	Balance *types.BlockBalance
+}
+
+// StoreResult stores the retrieved data in local database. The completeness of
+// the changes can't be proven, so they are never stored.
+func (req *BalanceChangeRequest) StoreResult(db abeydb.Database) {}
+
 // ChtRequest is the ODR request type for state/storage trie entries
 type ChtRequest struct {
 	OdrRequest
@@ -19,18 +19,48 @@ package light
 import (
 	"bytes"
 	"context"
+	"errors"
 	"github.com/AbeyFoundation/go-abey/core"
 	"github.com/AbeyFoundation/go-abey/log"
+	"math/big"
 
 	"github.com/AbeyFoundation/go-abey/common"
+	"github.com/AbeyFoundation/go-abey/consensus"
 	"github.com/AbeyFoundation/go-abey/core/rawdb"
+	snaildb "github.com/AbeyFoundation/go-abey/core/snailchain/rawdb"
 	"github.com/AbeyFoundation/go-abey/core/types"
 	"github.com/AbeyFoundation/go-abey/crypto"
+	"github.com/AbeyFoundation/go-abey/params"
 	"github.com/AbeyFoundation/go-abey/rlp"
+	"github.com/AbeyFoundation/go-abey/trie"
 )
 
 var sha3_nil = crypto.Keccak256Hash(nil)
 
+// ErrReceiptsMismatch is returned if the receipts of a block don't match the
+// receipt root of its header.
+var ErrReceiptsMismatch = errors.New("receipts don't match header receipt root")
+
+// ErrLogsBloomMismatch is returned if the logs of a block don't match the logs
+// bloom of its header, e.g. because some of them were withheld.
+var ErrLogsBloomMismatch = errors.New("logs don't match header logs bloom")
+
+// ErrTxLookupMismatch is returned if the block reported to include a
+// transaction does not contain it at the reported position.
+var ErrTxLookupMismatch = errors.New("transaction not found at reported position")
+
+// ErrBlockRewardMismatch is returned if the header proving a snail block reward
+// is not the canonical fast header at its number.
+var ErrBlockRewardMismatch = errors.New("block reward header not canonical")
+
+// ErrSnailHeaderMismatch is returned if a retrieved snail header is not the one
+// rewarded by the canonical fast chain at its number.
+var ErrSnailHeaderMismatch = errors.New("snail header not rewarded by canonical chain")
+
+// ErrBalanceChangeMismatch is returned if a retrieved balance change doesn't
+// match the balances of the account in the state of its block.
+var ErrBalanceChangeMismatch = errors.New("balance change doesn't match block state")
+
 func GetHeaderByNumber(ctx context.Context, odr OdrBackend, number uint64) (*types.Header, error) {
 	db := odr.Database()
 	hash := rawdb.ReadCanonicalHash(db, number)
@@ -42,7 +72,34 @@ func GetHeaderByNumber(ctx context.Context, odr OdrBackend, number uint64) (*typ
 		}
 		return header, nil
 	}
+	r, err := retrieveCht(ctx, odr, number)
+	if err != nil {
+		return nil, err
+	}
+	return r.Header, nil
+}
 
+// GetTd retrieves the total difficulty corresponding to the hash and number,
+// falling back to a CHT proof retrieval if it is not available locally. A nil
+// total difficulty is returned if the hash is not canonical at the given number.
+func GetTd(ctx context.Context, odr OdrBackend, hash common.Hash, number uint64) (*big.Int, error) {
+	if td := rawdb.ReadTd(odr.Database(), hash, number); td != nil {
+		return td, nil
+	}
+	r, err := retrieveCht(ctx, odr, number)
+	if err != nil {
+		return nil, err
+	}
+	if r.Header.Hash() != hash {
+		return nil, nil
+	}
+	return r.Td, nil
+}
+
+// retrieveCht fetches the header and total difficulty of the given canonical
+// block number from the network, proven against the latest trusted CHT.
+func retrieveCht(ctx context.Context, odr OdrBackend, number uint64) (*ChtRequest, error) {
+	db := odr.Database()
 	var (
 		chtCount, sectionHeadNum uint64
 		sectionHead              common.Hash
@@ -68,6 +125,125 @@ func GetHeaderByNumber(ctx context.Context, odr OdrBackend, number uint64) (*typ
 	if err := odr.Retrieve(ctx, r); err != nil {
 		return nil, err
 	}
+	return r, nil
+}
+
+// GetSnailHeaderByNumber retrieves the canonical snail header with the given
+// number, fetching it from the network if it is not available locally. Retrieved
+// headers are checked for their seal and difficulty by the serving peer's reply
+// validation. Headers of rewarded blocks are then linked to the canonical fast
+// chain, proven by the CHT for old blocks, through the hash recorded in their
+// reward, and only stored once linked. Blocks not rewarded yet by the light chain
+// can't be linked, so their headers are returned without being stored.
+func GetSnailHeaderByNumber(ctx context.Context, odr OdrBackend, config *params.ChainConfig, engine consensus.Engine, number uint64) (*types.SnailHeader, error) {
+	db := odr.Database()
+	if hash := snaildb.ReadCanonicalHash(db, number); hash != (common.Hash{}) {
+		if header := snaildb.ReadHeader(db, hash, number); header != nil {
+			return header, nil
+		}
+	}
+	r := &SnailHeaderRequest{Config: config, Engine: engine, Number: number}
+	if err := odr.Retrieve(ctx, r); err != nil {
+		return nil, err
+	}
+	if number > rawdb.ReadHeadRewardNumber(db) {
+		return r.Header, nil
+	}
+	reward, err := GetBlockReward(ctx, odr, number)
+	if err != nil {
+		return nil, err
+	}
+	if reward.SnailHash != r.Header.Hash() {
+		return nil, ErrSnailHeaderMismatch
+	}
+	snaildb.WriteHeader(db, r.Header)
+	snaildb.WriteCanonicalHash(db, r.Header.Hash(), number)
+	return r.Header, nil
+}
+
+// GetBlockReward retrieves the reward of the snail block with the given number,
+// fetching the fast header that rewarded it from the network if the reward is not
+// available locally. The header is checked against the canonical chain, proven
+// by the CHT for old blocks, before the reward is accepted and stored.
+func GetBlockReward(ctx context.Context, odr OdrBackend, snailNumber uint64) (*types.BlockReward, error) {
+	db := odr.Database()
+	if reward := rawdb.ReadBlockReward(db, snailNumber); reward != nil {
+		return reward, nil
+	}
+	r := &BlockRewardRequest{SnailNumber: snailNumber}
+	if err := odr.Retrieve(ctx, r); err != nil {
+		return nil, err
+	}
+	canonical, err := GetHeaderByNumber(ctx, odr, r.Header.Number.Uint64())
+	if err != nil {
+		return nil, err
+	}
+	if canonical.Hash() != r.Header.Hash() {
+		return nil, ErrBlockRewardMismatch
+	}
+	reward := &types.BlockReward{
+		FastHash:    canonical.Hash(),
+		FastNumber:  canonical.Number,
+		SnailHash:   canonical.SnailHash,
+		SnailNumber: canonical.SnailNumber,
+	}
+	rawdb.WriteBlockReward(db, reward)
+	return reward, nil
+}
+
+// GetSnailBlock retrieves the snail block with the given hash and number together
+// with its fruits, fetching it from the network if it is not available locally.
+// The retrieved block is validated against the requested hash.
+func GetSnailBlock(ctx context.Context, odr OdrBackend, hash common.Hash, number uint64) (*types.SnailBlock, error) {
+	if block := snaildb.ReadBlock(odr.Database(), hash, number); block != nil {
+		return block, nil
+	}
+	r := &SnailBlockRequest{Hash: hash, Number: number}
+	if err := odr.Retrieve(ctx, r); err != nil {
+		return nil, err
+	}
+	return r.Block, nil
+}
+
+// GetBalanceChange retrieves the balance changes produced by the canonical fast
+// block with the given number from the network. Every retrieved entry is checked
+// against the unlocked and locked balances proven by the post-block state before
+// the changes are accepted. Nothing commits to the set of accounts a block
+// changed though, so a peer leaving entries out goes unnoticed. The changes are
+// thus never stored, lest an incomplete set be served from then on.
+func GetBalanceChange(ctx context.Context, odr OdrBackend, number uint64) (*types.BlockBalance, error) {
+	r := &BalanceChangeRequest{Number: number}
+	if err := odr.Retrieve(ctx, r); err != nil {
+		return nil, err
+	}
+	header, err := GetHeaderByNumber(ctx, odr, number)
+	if err != nil {
+		return nil, err
+	}
+	statedb := NewState(ctx, header, odr)
+	for _, info := range r.Balance.Balance {
+		locked := statedb.GetPOSLocked(info.Address)
+		unlocked := statedb.GetUnlockedBalance(info.Address)
+		if err := statedb.Error(); err != nil {
+			return nil, err
+		}
+		if info.Lock.Cmp(locked) != 0 || info.Valid.Cmp(unlocked) != 0 {
+			return nil, ErrBalanceChangeMismatch
+		}
+	}
+	return r.Balance, nil
+}
+
+// GetCurrentSnailHeader retrieves the current snail chain head known by the
+// serving peer, checked for its seal and difficulty. The light client does not
+// follow the snail chain itself, so the head is always fetched from the network
+// and, as it can't be linked to the fast chain before it is rewarded, never
+// stored.
+func GetCurrentSnailHeader(ctx context.Context, odr OdrBackend, config *params.ChainConfig, engine consensus.Engine) (*types.SnailHeader, error) {
+	r := &SnailHeaderRequest{Config: config, Engine: engine, Latest: true}
+	if err := odr.Retrieve(ctx, r); err != nil {
+		return nil, err
+	}
 	return r.Header, nil
 }
 
@@ -155,9 +331,108 @@ func GetBlockReceipts(ctx context.Context, odr OdrBackend, hash common.Hash, num
 	return receipts, nil
 }
 
+// GetTxLookup returns the position of a mined transaction, reading it from the
+// local database or asking the network for it otherwise. A nil block hash with
+// no error is returned if the transaction is not known to be included.
+func GetTxLookup(ctx context.Context, odr OdrBackend, txHash common.Hash) (common.Hash, uint64, uint64, error) {
+	if blockHash, number, index := rawdb.ReadTxLookupEntry(odr.Database(), txHash); blockHash != (common.Hash{}) {
+		return blockHash, number, index, nil
+	}
+	r := &TxStatusRequest{Hashes: []common.Hash{txHash}}
+	if err := odr.Retrieve(ctx, r); err != nil {
+		return common.Hash{}, 0, 0, err
+	}
+	if status := r.Status[0]; status.Status == core.TxStatusIncluded && status.Lookup != nil {
+		return status.Lookup.BlockHash, status.Lookup.BlockIndex, status.Lookup.Index, nil
+	}
+	return common.Hash{}, 0, 0, nil
+}
+
+// GetTransaction retrieves a mined transaction along with the hash, number and
+// index of its block. The transaction is taken from the block body, verified
+// against its header, and is checked to match the requested hash. A nil
+// transaction with no error is returned if it is not known to be included.
+func GetTransaction(ctx context.Context, odr OdrBackend, txHash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
+	blockHash, number, index, err := GetTxLookup(ctx, odr, txHash)
+	if err != nil || blockHash == (common.Hash{}) {
+		return nil, common.Hash{}, 0, 0, err
+	}
+	body, err := GetBody(ctx, odr, blockHash, number)
+	if err != nil {
+		return nil, common.Hash{}, 0, 0, err
+	}
+	if index >= uint64(len(body.Transactions)) {
+		return nil, common.Hash{}, 0, 0, ErrTxLookupMismatch
+	}
+	tx := body.Transactions[index]
+	if tx.Hash() != txHash && tx.HashOld() != txHash {
+		return nil, common.Hash{}, 0, 0, ErrTxLookupMismatch
+	}
+	return tx, blockHash, number, index, nil
+}
+
+// GetTransactionReceipt retrieves the receipt of a mined transaction along with
+// the hash, number and index of its block, taken from the verified receipts of
+// the block. A nil receipt with no error is returned if the transaction is not
+// known to be included.
+func GetTransactionReceipt(ctx context.Context, odr OdrBackend, txHash common.Hash) (*types.Receipt, common.Hash, uint64, uint64, error) {
+	blockHash, number, index, err := GetTxLookup(ctx, odr, txHash)
+	if err != nil || blockHash == (common.Hash{}) {
+		return nil, common.Hash{}, 0, 0, err
+	}
+	receipts, err := GetBlockReceipts(ctx, odr, blockHash, number)
+	if err != nil {
+		return nil, common.Hash{}, 0, 0, err
+	}
+	if index >= uint64(len(receipts)) || receipts[index].TxHash != txHash {
+		return nil, common.Hash{}, 0, 0, ErrTxLookupMismatch
+	}
+	return receipts[index], blockHash, number, index, nil
+}
+
+// GetBlockReceiptsWithProof retrieves the receipts of a block like GetBlockReceipts
+// and additionally returns the trie nodes proving each of them against the receipt
+// root of the block header, keyed by the RLP encoded index of the receipt.
+func GetBlockReceiptsWithProof(ctx context.Context, odr OdrBackend, hash common.Hash, number uint64) (types.Receipts, NodeList, error) {
+	receipts, err := GetBlockReceipts(ctx, odr, hash, number)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := rawdb.ReadHeader(odr.Database(), hash, number)
+	if header == nil {
+		return nil, nil, ErrNoHeader
+	}
+	var (
+		keybuf = new(bytes.Buffer)
+		tr     = new(trie.Trie)
+	)
+	for i := 0; i < receipts.Len(); i++ {
+		keybuf.Reset()
+		rlp.Encode(keybuf, uint(i))
+		tr.Update(keybuf.Bytes(), receipts.GetRlp(i))
+	}
+	if tr.Hash() != header.ReceiptHash {
+		return nil, nil, ErrReceiptsMismatch
+	}
+	proofs := NewNodeSet()
+	for i := 0; i < receipts.Len(); i++ {
+		keybuf.Reset()
+		rlp.Encode(keybuf, uint(i))
+		if err := tr.Prove(keybuf.Bytes(), 0, proofs); err != nil {
+			return nil, nil, err
+		}
+	}
+	return receipts, proofs.NodeList(), nil
+}
+
 // GetBlockLogs retrieves the logs generated by the transactions included in a
-// block given by its hash.
+// block given by its hash. The logs are checked against the logs bloom of the
+// block header, so that no logs can be withheld.
 func GetBlockLogs(ctx context.Context, odr OdrBackend, hash common.Hash, number uint64) ([][]*types.Log, error) {
+	header := rawdb.ReadHeader(odr.Database(), hash, number)
+	if header == nil {
+		return nil, ErrNoHeader
+	}
 	// Retrieve the potentially incomplete receipts from disk or network
 	receipts := rawdb.ReadReceipts(odr.Database(), hash, number)
 	if receipts == nil {
@@ -167,6 +442,9 @@ func GetBlockLogs(ctx context.Context, odr OdrBackend, hash common.Hash, number
 		}
 		receipts = r.Receipts
 	}
+	if types.CreateBloom(receipts) != header.Bloom {
+		return nil, ErrLogsBloomMismatch
+	}
 	// Return the logs without deriving any computed fields on the receipts
 	logs := make([][]*types.Log, len(receipts))
 	for i, receipt := range receipts {
@@ -19,6 +19,8 @@ package light
 import (
 	"context"
 	"fmt"
+	"math/big"
+	"sort"
 	"sync"
 	"time"
 
@@ -134,6 +136,38 @@ func (pool *TxPool) GetNonce(ctx context.Context, addr common.Address) (uint64,
 	return nonce, nil
 }
 
+// NonceGap reports the nonces missing between the nonce of an account in the
+// latest header and its highest pending transaction, which keep the pending
+// transactions above them from executing. The missing nonces are [start, end),
+// starting at the first nonce without a pending transaction, and gap is false
+// if all pending transactions of the account are contiguous.
+func (pool *TxPool) NonceGap(ctx context.Context, addr common.Address) (start, end uint64, gap bool, err error) {
+	state := pool.currentState(ctx)
+	start = state.GetNonce(addr)
+	if state.Error() != nil {
+		return 0, 0, false, state.Error()
+	}
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	nonces := make(map[uint64]bool)
+	for _, tx := range pool.pending {
+		if sender, _ := types.Sender(pool.signer, tx); sender == addr {
+			nonces[tx.Nonce()] = true
+		}
+	}
+	for nonces[start] {
+		start++
+	}
+	end = start
+	for nonce := range nonces {
+		if nonce > start && (end == start || nonce < end) {
+			end = nonce
+		}
+	}
+	return start, end, end > start, nil
+}
+
 // txStateChanges stores the recent changes between pending/mined states of
 // transactions. True means mined, false means rolled back, no entry means no change
 type txStateChanges map[common.Hash]bool
@@ -378,7 +412,7 @@ func (pool *TxPool) validateTx(ctx context.Context, tx *types.Transaction) error
 	}
 
 	// Should supply enough intrinsic gas
-	gas, err := core.IntrinsicGas(tx.Data(), tx.To() == nil, true)
+	gas, err := core.IntrinsicGasAt(pool.config, tx.Data(), tx.To() == nil, true)
 	if err != nil {
 		return err
 	}
@@ -394,7 +428,7 @@ func (pool *TxPool) add(ctx context.Context, tx *types.Transaction) error {
 	hash := tx.Hash()
 
 	if pool.pending[hash] != nil {
-		return fmt.Errorf("Known transaction (%x)", hash[:4])
+		return fmt.Errorf("%w (%x)", core.ErrAlreadyKnown, hash[:4])
 	}
 	err := pool.validateTx(ctx, tx)
 	if err != nil {
@@ -422,6 +456,31 @@ func (pool *TxPool) add(ctx context.Context, tx *types.Transaction) error {
 	return nil
 }
 
+// ValidateTransaction checks whether a transaction would be accepted into the
+// pool, without adding or relaying it. On top of the pool rules, the sender is
+// checked against the forbidden addresses at the height of the next block, as
+// the state processor will when executing it.
+func (pool *TxPool) ValidateTransaction(ctx context.Context, tx *types.Transaction) error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	hash := tx.Hash()
+	if pool.pending[hash] != nil {
+		return fmt.Errorf("%w (%x)", core.ErrAlreadyKnown, hash[:4])
+	}
+	if err := pool.validateTx(ctx, tx); err != nil {
+		return err
+	}
+	next := new(big.Int).Add(pool.chain.GetHeaderByHash(pool.head).Number, common.Big1)
+	if pool.config.IsForbid(next) {
+		from, _ := types.Sender(pool.signer, tx) // already validated
+		if err := types.ForbidAddress(from); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Add adds a transaction to the pool if valid and passes it to the tx relay
 // backend
 func (pool *TxPool) Add(ctx context.Context, tx *types.Transaction) error {
@@ -485,6 +544,20 @@ func (pool *TxPool) GetTransactions() (txs types.Transactions, err error) {
 	return txs, nil
 }
 
+// PendingPriceHistogram counts the pending transactions by gas price, bucketed by
+// the given ascending bounds, like the PendingPriceHistogram of the full pool.
+func (pool *TxPool) PendingPriceHistogram(bounds []*big.Int) []int {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	counts := make([]int, len(bounds)+1)
+	for _, tx := range pool.pending {
+		price := tx.GasPrice()
+		counts[sort.Search(len(bounds), func(i int) bool { return bounds[i].Cmp(price) > 0 })]++
+	}
+	return counts
+}
+
 // Content retrieves the data content of the transaction pool, returning all the
 // pending as well as queued transactions, grouped by account and nonce.
 func (pool *TxPool) Content() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
@@ -502,6 +575,22 @@ func (pool *TxPool) Content() (map[common.Address]types.Transactions, map[common
 	return pending, queued
 }
 
+// ContentFrom retrieves the pending transactions of the given account, sorted by
+// nonce. There are no queued transactions in a light pool.
+func (pool *TxPool) ContentFrom(addr common.Address) types.Transactions {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	var txs types.Transactions
+	for _, tx := range pool.pending {
+		if account, _ := types.Sender(pool.signer, tx); account == addr {
+			txs = append(txs, tx)
+		}
+	}
+	sort.Sort(types.TxByNonce(txs))
+	return txs
+}
+
 // RemoveTransactions removes all given transactions from the pool.
 func (pool *TxPool) RemoveTransactions(txs types.Transactions) {
 	pool.mu.Lock()
@@ -528,3 +617,24 @@ func (pool *TxPool) RemoveTx(hash common.Hash) {
 	pool.chainDb.Delete(hash[:])
 	pool.relay.Discard([]common.Hash{hash})
 }
+
+// RemoveTxs removes the transactions with the given hashes from the pool while
+// holding the pool lock only once, returning the number of transactions that
+// were actually pending.
+func (pool *TxPool) RemoveTxs(hashes []common.Hash) int {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	var removed int
+	batch := pool.chainDb.NewBatch()
+	for _, hash := range hashes {
+		if _, ok := pool.pending[hash]; ok {
+			delete(pool.pending, hash)
+			removed++
+		}
+		batch.Delete(hash.Bytes())
+	}
+	batch.Write()
+	pool.relay.Discard(hashes)
+	return removed
+}
@@ -0,0 +1,104 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/AbeyFoundation/go-abey/abeydb"
+	"github.com/AbeyFoundation/go-abey/common"
+	"github.com/AbeyFoundation/go-abey/core/types"
+	"github.com/AbeyFoundation/go-abey/crypto"
+)
+
+type testTxRelay struct {
+	discard []common.Hash
+}
+
+func (self *testTxRelay) Send(txs types.Transactions) {}
+
+func (self *testTxRelay) NewHead(head common.Hash, mined []common.Hash, rollback []common.Hash) {}
+
+func (self *testTxRelay) Discard(hashes []common.Hash) {
+	self.discard = append(self.discard, hashes...)
+}
+
+func TestTxPoolContentFrom(t *testing.T) {
+	signer := types.NewTIP1Signer(big.NewInt(1))
+	pool := &TxPool{
+		signer:  signer,
+		pending: make(map[common.Hash]*types.Transaction),
+	}
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	for _, nonce := range []uint64{2, 0, 1} {
+		for _, k := range []*ecdsa.PrivateKey{key, other} {
+			tx, err := types.SignTx(types.NewTransaction(nonce, common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil), signer, k)
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			pool.pending[tx.Hash()] = tx
+		}
+	}
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	txs := pool.ContentFrom(addr)
+	if len(txs) != 3 {
+		t.Fatalf("transaction count mismatch: have %d, want 3", len(txs))
+	}
+	for i, tx := range txs {
+		if from, _ := types.Sender(signer, tx); from != addr {
+			t.Errorf("tx %d: sender mismatch: have %x, want %x", i, from, addr)
+		}
+		if tx.Nonce() != uint64(i) {
+			t.Errorf("tx %d: nonce mismatch: have %d, want %d", i, tx.Nonce(), i)
+		}
+	}
+}
+
+func TestTxPoolRemoveTxs(t *testing.T) {
+	relay := new(testTxRelay)
+	pool := &TxPool{
+		chainDb: abeydb.NewMemDatabase(),
+		relay:   relay,
+		pending: make(map[common.Hash]*types.Transaction),
+	}
+	var txs []*types.Transaction
+	for i := 0; i < 5; i++ {
+		tx := types.NewTransaction(uint64(i), common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil)
+		pool.pending[tx.Hash()] = tx
+		txs = append(txs, tx)
+	}
+	// Remove a subset together with a transaction unknown to the pool
+	unknown := common.Hash{0xff}
+	if removed := pool.RemoveTxs([]common.Hash{txs[1].Hash(), txs[3].Hash(), unknown}); removed != 2 {
+		t.Fatalf("removed count mismatch: have %d, want 2", removed)
+	}
+	for i, tx := range txs {
+		removed := i == 1 || i == 3
+		if have := pool.GetTransaction(tx.Hash()) == nil; have != removed {
+			t.Errorf("tx %d: removal mismatch: have %v, want %v", i, have, removed)
+		}
+	}
+	if len(relay.discard) != 3 {
+		t.Errorf("discarded count mismatch: have %d, want 3", len(relay.discard))
+	}
+	if txs, err := pool.GetTransactions(); err != nil || len(txs) != 3 {
+		t.Errorf("pending count mismatch: have %d (%v), want 3", len(txs), err)
+	}
+}
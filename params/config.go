@@ -50,6 +50,11 @@ var TrustedCheckpoints = map[common.Hash]*TrustedCheckpoint{
 }
 
 var (
+	// DefaultForbidBlock is the block after which forbidden addresses are rejected
+	// on chains whose stored or genesis config predates ForbidBlock, as they all
+	// were before the height became configurable.
+	DefaultForbidBlock = big.NewInt(6638000)
+
 	// MainnetChainConfig is the chain parameters to run a node on the main network.
 	MainnetChainConfig = &ChainConfig{
 		ChainID: big.NewInt(179),
@@ -58,12 +63,13 @@ var (
 			MinimumFruitDifficulty: big.NewInt(10000),
 			DurationLimit:          big.NewInt(600),
 		}),
-		TIP3:  &BlockConfig{FastNumber: big.NewInt(0)},
-		TIP5:  &BlockConfig{SnailNumber: big.NewInt(0)},
-		TIP7:  &BlockConfig{FastNumber: big.NewInt(0)},
-		TIP8:  &BlockConfig{FastNumber: big.NewInt(0), CID: big.NewInt(0)},
-		TIP9:  &BlockConfig{FastNumber: big.NewInt(8742700), SnailNumber: big.NewInt(73000)},
-		TIP10: &BlockConfig{FastNumber: big.NewInt(13303000)},
+		TIP3:        &BlockConfig{FastNumber: big.NewInt(0)},
+		TIP5:        &BlockConfig{SnailNumber: big.NewInt(0)},
+		TIP7:        &BlockConfig{FastNumber: big.NewInt(0)},
+		TIP8:        &BlockConfig{FastNumber: big.NewInt(0), CID: big.NewInt(0)},
+		TIP9:        &BlockConfig{FastNumber: big.NewInt(8742700), SnailNumber: big.NewInt(73000)},
+		TIP10:       &BlockConfig{FastNumber: big.NewInt(13303000)},
+		ForbidBlock: DefaultForbidBlock,
 	}
 
 	// MainnetTrustedCheckpoint contains the light client trusted checkpoint for the main network.
@@ -95,12 +101,13 @@ var (
 			MinimumFruitDifficulty: big.NewInt(200),
 			DurationLimit:          big.NewInt(600),
 		}),
-		TIP3:  &BlockConfig{FastNumber: big.NewInt(0)},
-		TIP5:  &BlockConfig{SnailNumber: big.NewInt(0)},
-		TIP7:  &BlockConfig{FastNumber: big.NewInt(0)},
-		TIP8:  &BlockConfig{FastNumber: big.NewInt(0), CID: big.NewInt(0)},
-		TIP9:  &BlockConfig{FastNumber: big.NewInt(2660000), SnailNumber: big.NewInt(21400)},
-		TIP10: &BlockConfig{FastNumber: big.NewInt(6000000)},
+		TIP3:        &BlockConfig{FastNumber: big.NewInt(0)},
+		TIP5:        &BlockConfig{SnailNumber: big.NewInt(0)},
+		TIP7:        &BlockConfig{FastNumber: big.NewInt(0)},
+		TIP8:        &BlockConfig{FastNumber: big.NewInt(0), CID: big.NewInt(0)},
+		TIP9:        &BlockConfig{FastNumber: big.NewInt(2660000), SnailNumber: big.NewInt(21400)},
+		TIP10:       &BlockConfig{FastNumber: big.NewInt(6000000)},
+		ForbidBlock: DefaultForbidBlock,
 	}
 
 	// TestnetTrustedCheckpoint contains the light client trusted checkpoint for the Ropsten test network.
@@ -132,12 +139,13 @@ var (
 			MinimumFruitDifficulty: big.NewInt(20),
 			DurationLimit:          big.NewInt(150),
 		}),
-		TIP3:  &BlockConfig{FastNumber: big.NewInt(0)},
-		TIP5:  &BlockConfig{SnailNumber: big.NewInt(0)},
-		TIP7:  &BlockConfig{FastNumber: big.NewInt(0)},
-		TIP8:  &BlockConfig{FastNumber: big.NewInt(0), CID: big.NewInt(0)},
-		TIP9:  &BlockConfig{FastNumber: big.NewInt(0), SnailNumber: big.NewInt(0)},
-		TIP10: &BlockConfig{FastNumber: big.NewInt(10000)},
+		TIP3:        &BlockConfig{FastNumber: big.NewInt(0)},
+		TIP5:        &BlockConfig{SnailNumber: big.NewInt(0)},
+		TIP7:        &BlockConfig{FastNumber: big.NewInt(0)},
+		TIP8:        &BlockConfig{FastNumber: big.NewInt(0), CID: big.NewInt(0)},
+		TIP9:        &BlockConfig{FastNumber: big.NewInt(0), SnailNumber: big.NewInt(0)},
+		TIP10:       &BlockConfig{FastNumber: big.NewInt(10000)},
+		ForbidBlock: DefaultForbidBlock,
 	}
 
 	SingleNodeChainConfig = &ChainConfig{
@@ -147,12 +155,13 @@ var (
 			MinimumFruitDifficulty: big.NewInt(2),
 			DurationLimit:          big.NewInt(120),
 		}),
-		TIP3:  &BlockConfig{FastNumber: big.NewInt(0)},
-		TIP5:  &BlockConfig{SnailNumber: big.NewInt(0)},
-		TIP7:  &BlockConfig{FastNumber: big.NewInt(0)},
-		TIP8:  &BlockConfig{FastNumber: big.NewInt(0), CID: big.NewInt(-1)},
-		TIP9:  &BlockConfig{FastNumber: big.NewInt(0), SnailNumber: big.NewInt(0)},
-		TIP10: &BlockConfig{FastNumber: big.NewInt(0)},
+		TIP3:        &BlockConfig{FastNumber: big.NewInt(0)},
+		TIP5:        &BlockConfig{SnailNumber: big.NewInt(0)},
+		TIP7:        &BlockConfig{FastNumber: big.NewInt(0)},
+		TIP8:        &BlockConfig{FastNumber: big.NewInt(0), CID: big.NewInt(-1)},
+		TIP9:        &BlockConfig{FastNumber: big.NewInt(0), SnailNumber: big.NewInt(0)},
+		TIP10:       &BlockConfig{FastNumber: big.NewInt(0)},
+		ForbidBlock: DefaultForbidBlock,
 	}
 
 	// TestnetTrustedCheckpoint contains the light client trusted checkpoint for the Ropsten test network.
@@ -170,14 +179,21 @@ var (
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
 	AllMinervaProtocolChanges = &ChainConfig{ChainID: chainId, Minerva: new(MinervaConfig), TIP3: &BlockConfig{FastNumber: big.NewInt(0)},
-		TIP5: nil, TIP7: nil, TIP8: nil, TIP9: nil, TIP10: nil,
+		TIP5: nil, TIP7: nil, TIP8: nil, TIP9: nil, TIP10: nil, ForbidBlock: DefaultForbidBlock,
 	}
 
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
 
+	//
+	// The staking state is initialised at genesis and transactions are looked up
+	// by their new hashes, while the DPoS and snail mining stop forks never come.
 	TestChainConfig = &ChainConfig{ChainID: chainId, Minerva: &MinervaConfig{MinimumDifficulty, MinimumFruitDifficulty, DurationLimit}, TIP3: &BlockConfig{FastNumber: big.NewInt(0)},
-		TIP5: nil, TIP7: nil, TIP8: nil, TIP9: nil, TIP10: nil,
+		TIP5:  nil,
+		TIP7:  &BlockConfig{FastNumber: big.NewInt(0)},
+		TIP8:  &BlockConfig{FastNumber: new(big.Int).Set(math.MaxBig63), CID: new(big.Int).Set(math.MaxBig63)},
+		TIP9:  &BlockConfig{FastNumber: big.NewInt(-1), SnailNumber: new(big.Int).Set(math.MaxBig63)},
+		TIP10: &BlockConfig{FastNumber: big.NewInt(0)},
 	}
 )
 
@@ -247,6 +263,66 @@ type ChainConfig struct {
 	TIP10 *BlockConfig `json:"tip10"`
 
 	TIPStake *BlockConfig `json:"tipstake"`
+
+	ForbidBlock *big.Int `json:"forbidBlock"` // Forbidden addresses are rejected after this block (nil = never)
+
+	IntrinsicGas *IntrinsicGasConfig `json:"intrinsicGas,omitempty"` // Intrinsic gas overrides of private networks (nil = mainnet schedule)
+
+	Precompiles []PrecompileConfig `json:"precompiles,omitempty"` // Custom precompiled contracts of private networks (nil = none)
+
+	AllowUnprotectedTxs bool `json:"allowUnprotectedTxs,omitempty"` // Whether transactions signed without a chain ID are executed
+}
+
+// PrecompileConfig schedules a custom precompiled contract, allowing private
+// networks to extend the EVM from a chosen block. The contract itself must be
+// registered with the EVM under the configured name by every node of the chain.
+type PrecompileConfig struct {
+	Name         string         `json:"name"`                   // Name the contract is registered under
+	Address      common.Address `json:"address"`                // Address the contract is called at
+	Block        *big.Int       `json:"block"`                  // Block the contract is enabled at
+	DisableBlock *big.Int       `json:"disableBlock,omitempty"` // Block the contract is disabled at (nil = never)
+}
+
+// IsActive returns whether the precompiled contract is callable at block num.
+func (p *PrecompileConfig) IsActive(num *big.Int) bool {
+	return isForked(p.Block, num) && !isForked(p.DisableBlock, num)
+}
+
+// IntrinsicGasConfig overrides the gas charged for a transaction ahead of its
+// execution, allowing private networks to experiment with other schedules. Zero
+// fields keep the mainnet constants.
+type IntrinsicGasConfig struct {
+	TxGas                 uint64 `json:"txGas,omitempty"`                 // Base gas of a message call
+	TxGasContractCreation uint64 `json:"txGasContractCreation,omitempty"` // Base gas of a contract creation
+	TxDataZeroGas         uint64 `json:"txDataZeroGas,omitempty"`         // Gas per zero byte of data
+	TxDataNonZeroGas      uint64 `json:"txDataNonZeroGas,omitempty"`      // Gas per non-zero byte of data
+}
+
+// IntrinsicGasSchedule returns the intrinsic gas schedule of the chain, with the
+// fields not overridden set to the mainnet constants.
+func (c *ChainConfig) IntrinsicGasSchedule() IntrinsicGasConfig {
+	schedule := IntrinsicGasConfig{
+		TxGas:                 TxGas,
+		TxGasContractCreation: TxGasContractCreation,
+		TxDataZeroGas:         TxDataZeroGas,
+		TxDataNonZeroGas:      TxDataNonZeroGas,
+	}
+	if c == nil || c.IntrinsicGas == nil {
+		return schedule
+	}
+	if c.IntrinsicGas.TxGas != 0 {
+		schedule.TxGas = c.IntrinsicGas.TxGas
+	}
+	if c.IntrinsicGas.TxGasContractCreation != 0 {
+		schedule.TxGasContractCreation = c.IntrinsicGas.TxGasContractCreation
+	}
+	if c.IntrinsicGas.TxDataZeroGas != 0 {
+		schedule.TxDataZeroGas = c.IntrinsicGas.TxDataZeroGas
+	}
+	if c.IntrinsicGas.TxDataNonZeroGas != 0 {
+		schedule.TxDataNonZeroGas = c.IntrinsicGas.TxDataNonZeroGas
+	}
+	return schedule
 }
 
 type BlockConfig struct {
@@ -260,6 +336,14 @@ func (c *ChainConfig) UnmarshalJSON(input []byte) error {
 		ChainID *big.Int `json:"chainId"` // chainId identifies the current chain and is used for replay protection
 
 		Minerva *MinervaConfig `json:"minerva"`
+
+		ForbidBlock json.RawMessage `json:"forbidBlock"`
+
+		IntrinsicGas *IntrinsicGasConfig `json:"intrinsicGas,omitempty"`
+
+		Precompiles []PrecompileConfig `json:"precompiles,omitempty"`
+
+		AllowUnprotectedTxs bool `json:"allowUnprotectedTxs,omitempty"`
 	}
 	var dec ChainConfig
 	if err := json.Unmarshal(input, &dec); err != nil {
@@ -275,6 +359,22 @@ func (c *ChainConfig) UnmarshalJSON(input []byte) error {
 	} else {
 		c.Minerva = dec.Minerva
 	}
+	// Configs predating the forbid block enforced it at the default height, an
+	// explicit null disables it
+	switch {
+	case dec.ForbidBlock == nil:
+		c.ForbidBlock = new(big.Int).Set(DefaultForbidBlock)
+	case string(dec.ForbidBlock) == "null":
+		c.ForbidBlock = nil
+	default:
+		c.ForbidBlock = new(big.Int)
+		if err := json.Unmarshal(dec.ForbidBlock, c.ForbidBlock); err != nil {
+			return err
+		}
+	}
+	c.IntrinsicGas = dec.IntrinsicGas
+	c.Precompiles = dec.Precompiles
+	c.AllowUnprotectedTxs = dec.AllowUnprotectedTxs
 
 	return nil
 }
@@ -375,6 +475,9 @@ func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *Confi
 }
 
 func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *ConfigCompatError {
+	if isForkIncompatible(c.ForbidBlock, newcfg.ForbidBlock, head) {
+		return newCompatError("forbid block", c.ForbidBlock, newcfg.ForbidBlock)
+	}
 	return nil
 }
 
@@ -503,3 +606,12 @@ func (c *ChainConfig) IsTIP10(num *big.Int) bool {
 	}
 	return isForked(c.TIP10.FastNumber, num)
 }
+
+// IsForbid returns whether transactions from forbidden addresses are rejected
+// at block num, which is the case for every block after ForbidBlock.
+func (c *ChainConfig) IsForbid(num *big.Int) bool {
+	if c.ForbidBlock == nil || num == nil {
+		return false
+	}
+	return num.Cmp(c.ForbidBlock) > 0
+}
@@ -17,6 +17,7 @@
 package params
 
 import (
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"reflect"
@@ -32,6 +33,24 @@ func TestCheckCompatible(t *testing.T) {
 	tests := []test{
 		{stored: AllMinervaProtocolChanges, new: AllMinervaProtocolChanges, head: 0, wantErr: nil},
 		{stored: AllMinervaProtocolChanges, new: AllMinervaProtocolChanges, head: 100, wantErr: nil},
+		{
+			stored:  &ChainConfig{ForbidBlock: big.NewInt(10)},
+			new:     &ChainConfig{ForbidBlock: big.NewInt(20)},
+			head:    9,
+			wantErr: nil,
+		},
+		{
+			stored:  &ChainConfig{ForbidBlock: big.NewInt(10)},
+			new:     &ChainConfig{ForbidBlock: big.NewInt(20)},
+			head:    25,
+			wantErr: &ConfigCompatError{What: "forbid block", StoredConfig: big.NewInt(10), NewConfig: big.NewInt(20), RewindTo: 9},
+		},
+		{
+			stored:  &ChainConfig{ForbidBlock: big.NewInt(10)},
+			new:     &ChainConfig{},
+			head:    25,
+			wantErr: &ConfigCompatError{What: "forbid block", StoredConfig: big.NewInt(10), NewConfig: nil, RewindTo: 9},
+		},
 	}
 
 	for _, test := range tests {
@@ -48,3 +67,54 @@ func TestFork(t *testing.T) {
 	forked := isForked(Tip, cur)
 	fmt.Println("fork:", forked)
 }
+
+func TestIsForbid(t *testing.T) {
+	tests := []struct {
+		forbid *big.Int
+		number int64
+		want   bool
+	}{
+		{nil, 0, false},
+		{nil, 1000000000, false},
+		{big.NewInt(100), 99, false},
+		{big.NewInt(100), 100, false},
+		{big.NewInt(100), 101, true},
+		{big.NewInt(0), 1, true},
+		{MainnetChainConfig.ForbidBlock, 6638000, false},
+		{MainnetChainConfig.ForbidBlock, 6638001, true},
+	}
+	for i, tt := range tests {
+		config := &ChainConfig{ChainID: big.NewInt(1), ForbidBlock: tt.forbid}
+		if have := config.IsForbid(big.NewInt(tt.number)); have != tt.want {
+			t.Errorf("test %d: forbid mismatch at block %d: have %v, want %v", i, tt.number, have, tt.want)
+		}
+	}
+}
+
+func TestForbidBlockJSON(t *testing.T) {
+	var config ChainConfig
+	if err := json.Unmarshal([]byte(`{"chainId": 1, "forbidBlock": 500}`), &config); err != nil {
+		t.Fatalf("failed to decode config: %v", err)
+	}
+	if config.ForbidBlock == nil || config.ForbidBlock.Int64() != 500 {
+		t.Fatalf("forbid block mismatch: have %v, want 500", config.ForbidBlock)
+	}
+	// Configs written before the forbid block was configurable enforce the default
+	if err := json.Unmarshal([]byte(`{"chainId": 1}`), &config); err != nil {
+		t.Fatalf("failed to decode config: %v", err)
+	}
+	if config.ForbidBlock == nil || config.ForbidBlock.Cmp(DefaultForbidBlock) != 0 {
+		t.Fatalf("legacy forbid block mismatch: have %v, want %v", config.ForbidBlock, DefaultForbidBlock)
+	}
+	// A disabled forbid block stays disabled across encoding
+	blob, err := json.Marshal(&ChainConfig{ChainID: big.NewInt(1)})
+	if err != nil {
+		t.Fatalf("failed to encode config: %v", err)
+	}
+	if err := json.Unmarshal(blob, &config); err != nil {
+		t.Fatalf("failed to decode config: %v", err)
+	}
+	if config.ForbidBlock != nil {
+		t.Fatalf("disabled forbid block mismatch: have %v, want nil", config.ForbidBlock)
+	}
+}